@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/weaveworks/common/logging"
+
+	"github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/cortexproject/cortex/tools/queryreplay"
+)
+
+func main() {
+	var (
+		cfg     queryreplay.Config
+		limit   int
+		queries []queryreplay.Query
+	)
+
+	logfmt, loglvl := logging.Format{}, logging.Level{}
+	logfmt.RegisterFlags(flag.CommandLine)
+	loglvl.RegisterFlags(flag.CommandLine)
+	cfg.RegisterFlags(flag.CommandLine)
+	flag.IntVar(&limit, "replay.limit", 0, "The maximum number of queries to replay. 0 to replay all queries.")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "%s replays the queries logged by the query-frontend slow queries log or query stats log against a target Cortex cluster.\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <log file>...\n\nThe logs are read from stdin if no file is given.\n\nFlags:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	logger, err := log.NewPrometheusLogger(loglvl, logfmt)
+	if err != nil {
+		fatal("failed to create logger: %v", err)
+	}
+
+	replayer, err := queryreplay.NewReplayer(cfg, logger)
+	if err != nil {
+		fatal("invalid config: %v", err)
+	}
+
+	if flag.NArg() == 0 {
+		if queries, err = queryreplay.ParseQueryLogs(os.Stdin); err != nil {
+			fatal("failed to parse logs from stdin: %v", err)
+		}
+	}
+	for _, path := range flag.Args() {
+		parsed, err := parseFile(path)
+		if err != nil {
+			fatal("failed to parse logs from %s: %v", path, err)
+		}
+		queries = append(queries, parsed...)
+	}
+
+	queries = queryreplay.SortQueries(queries)
+	if limit > 0 && len(queries) > limit {
+		queries = queries[:limit]
+	}
+
+	// Stop replaying on SIGINT or SIGTERM, still reporting the queries executed so far.
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	level.Info(logger).Log("msg", "replaying queries", "queries", len(queries), "target", cfg.TargetURL, "speed", cfg.Speed)
+	report := replayer.Run(ctx, queries)
+
+	if err := report.Print(os.Stdout); err != nil {
+		fatal("failed to print report: %v", err)
+	}
+}
+
+func parseFile(path string) ([]queryreplay.Query, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return queryreplay.ParseQueryLogs(f)
+}
+
+func fatal(msg string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, msg+"\n", args...)
+	os.Exit(1)
+}
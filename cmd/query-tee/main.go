@@ -66,14 +66,27 @@ func cortexReadRoutes(cfg Config) []querytee.Route {
 		prefix = prefix[:len(prefix)-1]
 	}
 
-	samplesComparator := querytee.NewSamplesComparator(cfg.ProxyConfig.ValueComparisonTolerance)
+	samplesComparator := querytee.NewSamplesComparator(querytee.SampleComparisonOptions{
+		Tolerance:         cfg.ProxyConfig.ValueComparisonTolerance,
+		IgnoredLabels:     cfg.ProxyConfig.CompareIgnoredLabels,
+		SkipRecentSamples: cfg.ProxyConfig.CompareSkipRecentSamples,
+	})
+
+	// The responses of the series, labels and metadata endpoints are compared only if explicitly enabled.
+	var seriesComparator, labelsComparator, metadataComparator querytee.ResponsesComparator
+	if cfg.ProxyConfig.CompareMetadataResponses {
+		seriesComparator = querytee.NewSeriesComparator(cfg.ProxyConfig.CompareIgnoredLabels)
+		labelsComparator = querytee.NewLabelsComparator()
+		metadataComparator = querytee.NewMetadataComparator()
+	}
+
 	return []querytee.Route{
 		{Path: prefix + "/api/v1/query", RouteName: "api_v1_query", Methods: []string{"GET"}, ResponseComparator: samplesComparator},
 		{Path: prefix + "/api/v1/query_range", RouteName: "api_v1_query_range", Methods: []string{"GET"}, ResponseComparator: samplesComparator},
-		{Path: prefix + "/api/v1/labels", RouteName: "api_v1_labels", Methods: []string{"GET"}, ResponseComparator: nil},
-		{Path: prefix + "/api/v1/label/{name}/values", RouteName: "api_v1_label_name_values", Methods: []string{"GET"}, ResponseComparator: nil},
-		{Path: prefix + "/api/v1/series", RouteName: "api_v1_series", Methods: []string{"GET"}, ResponseComparator: nil},
-		{Path: prefix + "/api/v1/metadata", RouteName: "api_v1_metadata", Methods: []string{"GET"}, ResponseComparator: nil},
+		{Path: prefix + "/api/v1/labels", RouteName: "api_v1_labels", Methods: []string{"GET"}, ResponseComparator: labelsComparator},
+		{Path: prefix + "/api/v1/label/{name}/values", RouteName: "api_v1_label_name_values", Methods: []string{"GET"}, ResponseComparator: labelsComparator},
+		{Path: prefix + "/api/v1/series", RouteName: "api_v1_series", Methods: []string{"GET"}, ResponseComparator: seriesComparator},
+		{Path: prefix + "/api/v1/metadata", RouteName: "api_v1_metadata", Methods: []string{"GET"}, ResponseComparator: metadataComparator},
 		{Path: prefix + "/api/v1/rules", RouteName: "api_v1_rules", Methods: []string{"GET"}, ResponseComparator: nil},
 		{Path: prefix + "/api/v1/alerts", RouteName: "api_v1_alerts", Methods: []string{"GET"}, ResponseComparator: nil},
 	}
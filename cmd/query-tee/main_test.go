@@ -18,3 +18,21 @@ func TestCortexReadRoutes(t *testing.T) {
 		assert.True(t, strings.HasPrefix(r.Path, "/some/random/prefix/api/v1/"))
 	}
 }
+
+func TestCortexReadRoutes_CompareMetadataResponses(t *testing.T) {
+	comparedRoutes := func(cfg Config) []string {
+		var names []string
+		for _, r := range cortexReadRoutes(cfg) {
+			if r.ResponseComparator != nil {
+				names = append(names, r.RouteName)
+			}
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"api_v1_query", "api_v1_query_range"}, comparedRoutes(Config{}))
+
+	cfg := Config{}
+	cfg.ProxyConfig.CompareMetadataResponses = true
+	assert.Equal(t, []string{"api_v1_query", "api_v1_query_range", "api_v1_labels", "api_v1_label_name_values", "api_v1_series", "api_v1_metadata"}, comparedRoutes(cfg))
+}
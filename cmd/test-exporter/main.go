@@ -23,8 +23,9 @@ func main() {
 	var (
 		serverConfig server.Config
 		runnerConfig correctness.RunnerConfig
+		proberConfig correctness.ProberConfig
 	)
-	flagext.RegisterFlags(&serverConfig, &runnerConfig)
+	flagext.RegisterFlags(&serverConfig, &runnerConfig, &proberConfig)
 	flag.Parse()
 
 	log.InitLogger(&serverConfig)
@@ -64,6 +65,14 @@ func main() {
 		}, runnerConfig.DeleteSeriesTestConfig, runnerConfig.CommonTestConfig))
 	}
 
+	if proberConfig.Enabled {
+		proberConfig.PrometheusAddr = runnerConfig.PrometheusAddr
+		proberConfig.UserID = runnerConfig.UserID
+		prober, err := correctness.NewProber(proberConfig, log.Logger, prometheus.DefaultRegisterer)
+		log.CheckFatal("initializing prober", err)
+		defer prober.Stop()
+	}
+
 	prometheus.MustRegister(runner)
 	err = server.Run()
 	log.CheckFatal("running server", err)
@@ -79,6 +79,7 @@ type Config struct {
 	Replicator        Replicator
 	Store             alertstore.AlertStore
 	PersisterConfig   PersisterConfig
+	HistoryConfig     HistoryConfig
 }
 
 // An Alertmanager manages the alerts for one user.
@@ -88,6 +89,7 @@ type Alertmanager struct {
 	logger          log.Logger
 	state           State
 	persister       *statePersister
+	history         *historyRecorder
 	nflog           *nflog.Log
 	silences        *silence.Silences
 	marker          types.Marker
@@ -103,6 +105,10 @@ type Alertmanager struct {
 	// Pipeline created during last ApplyConfig call. Used for testing only.
 	lastPipeline notify.Stage
 
+	// Integrations built during last ApplyConfig call, by receiver name. Used to send test notifications.
+	integrationsMtx sync.RWMutex
+	integrations    map[string][]notify.Integration
+
 	// The Dispatcher is the only component we need to recreate when we call ApplyConfig.
 	// Given its metrics don't have any variable labels we need to re-use the same metrics.
 	dispatcherMetrics *dispatch.DispatcherMetrics
@@ -202,7 +208,7 @@ func New(cfg *Config, reg *prometheus.Registry) (*Alertmanager, error) {
 		return nil, fmt.Errorf("failed to create notification log: %v", err)
 	}
 
-	c := am.state.AddState("nfl:"+cfg.UserID, am.nflog, am.registry)
+	c := am.state.AddState(nflogStateKeyPrefix+cfg.UserID, am.nflog, am.registry)
 	am.nflog.SetBroadcast(c.Broadcast)
 
 	am.marker = types.NewMarker(am.registry)
@@ -218,7 +224,7 @@ func New(cfg *Config, reg *prometheus.Registry) (*Alertmanager, error) {
 		return nil, fmt.Errorf("failed to create silences: %v", err)
 	}
 
-	c = am.state.AddState("sil:"+cfg.UserID, am.silences, am.registry)
+	c = am.state.AddState(silencesStateKeyPrefix+cfg.UserID, am.silences, am.registry)
 	am.silences.SetBroadcast(c.Broadcast)
 
 	// State replication needs to be started after the state keys are defined.
@@ -234,6 +240,10 @@ func New(cfg *Config, reg *prometheus.Registry) (*Alertmanager, error) {
 		}
 	}
 
+	if cfg.HistoryConfig.Enabled {
+		am.history = newHistoryRecorder(cfg.HistoryConfig, cfg.UserID, am.state, cfg.Store, am.logger, am.registry)
+	}
+
 	am.pipelineBuilder = notify.NewPipelineBuilder(am.registry)
 
 	am.wg.Add(1)
@@ -242,9 +252,19 @@ func New(cfg *Config, reg *prometheus.Registry) (*Alertmanager, error) {
 		am.wg.Done()
 	}()
 
-	var callback mem.AlertStoreCallback
+	var callbacks alertStoreCallbacks
 	if am.cfg.Limits != nil {
-		callback = newAlertsLimiter(am.cfg.UserID, am.cfg.Limits, reg)
+		callbacks = append(callbacks, newAlertsLimiter(am.cfg.UserID, am.cfg.Limits, reg))
+	}
+	if am.history != nil {
+		callbacks = append(callbacks, am.history)
+	}
+
+	var callback mem.AlertStoreCallback
+	if len(callbacks) == 1 {
+		callback = callbacks[0]
+	} else if len(callbacks) > 1 {
+		callback = callbacks
 	}
 
 	am.alerts, err = mem.NewAlerts(context.Background(), am.marker, 30*time.Minute, callback, am.logger)
@@ -284,8 +304,19 @@ func New(cfg *Config, reg *prometheus.Registry) (*Alertmanager, error) {
 		am.mux.Handle(a, http.NotFoundHandler())
 	}
 
+	// Register Cortex-specific endpoints which are not part of the upstream Alertmanager API.
+	am.mux.Handle(path.Join(am.cfg.ExternalURL.Path, testReceiverPath), http.HandlerFunc(am.TestReceiverHandler))
+	am.mux.Handle(path.Join(am.cfg.ExternalURL.Path, historyPath), http.HandlerFunc(am.AlertsHistoryHandler))
+
 	am.dispatcherMetrics = dispatch.NewDispatcherMetrics(true, am.registry)
 
+	// The alerts history service is started last, so that it's not leaked if any of the previous steps fails.
+	if am.history != nil {
+		if err := am.history.StartAsync(context.Background()); err != nil {
+			return nil, errors.Wrap(err, "failed to start alerts history service")
+		}
+	}
+
 	//TODO: From this point onward, the alertmanager _might_ receive requests - we need to make sure we've settled and are ready.
 	return am, nil
 }
@@ -309,14 +340,15 @@ func clusterWait(position func() int, timeout time.Duration) func() time.Duratio
 
 // ApplyConfig applies a new configuration to an Alertmanager.
 func (am *Alertmanager) ApplyConfig(userID string, conf *config.Config, rawCfg string) error {
-	templateFiles := make([]string, len(conf.Templates))
-	for i, t := range conf.Templates {
+	// The shared templates are loaded first, so that the tenant's templates can override them.
+	templateFiles := []string{filepath.Join(am.cfg.TenantDataDir, sharedTemplatesDir, "*")}
+	for _, t := range conf.Templates {
 		templateFilepath, err := safeTemplateFilepath(filepath.Join(am.cfg.TenantDataDir, templatesDir), t)
 		if err != nil {
 			return err
 		}
 
-		templateFiles[i] = templateFilepath
+		templateFiles = append(templateFiles, templateFilepath)
 	}
 
 	tmpl, err := template.FromGlobs(templateFiles...)
@@ -359,7 +391,13 @@ func (am *Alertmanager) ApplyConfig(userID string, conf *config.Config, rawCfg s
 				integration: integrationName,
 			}
 
-			return newRateLimitedNotifier(notifier, rl, 10*time.Second, am.rateLimitedNotifications.WithLabelValues(integrationName))
+			notifier = newRateLimitedNotifier(notifier, rl, 10*time.Second, am.rateLimitedNotifications.WithLabelValues(integrationName))
+		}
+
+		// Only successful notifications are recorded in the alerts history, so the
+		// history notifier wraps the rate limited one.
+		if am.history != nil {
+			notifier = am.history.wrapNotifier(integrationName, notifier)
 		}
 		return notifier
 	})
@@ -367,6 +405,10 @@ func (am *Alertmanager) ApplyConfig(userID string, conf *config.Config, rawCfg s
 		return nil
 	}
 
+	am.integrationsMtx.Lock()
+	am.integrations = integrationsMap
+	am.integrationsMtx.Unlock()
+
 	muteTimes := make(map[string][]timeinterval.TimeInterval, len(conf.MuteTimeIntervals))
 	for _, ti := range conf.MuteTimeIntervals {
 		muteTimes[ti.Name] = ti.TimeIntervals
@@ -414,6 +456,10 @@ func (am *Alertmanager) Stop() {
 		am.persister.StopAsync()
 	}
 
+	if am.history != nil {
+		am.history.StopAsync()
+	}
+
 	if service, ok := am.state.(services.Service); ok {
 		service.StopAsync()
 	}
@@ -431,6 +477,12 @@ func (am *Alertmanager) StopAndWait() {
 		}
 	}
 
+	if am.history != nil {
+		if err := am.history.AwaitTerminated(context.Background()); err != nil {
+			level.Warn(am.logger).Log("msg", "error while stopping alerts history service", "err", err)
+		}
+	}
+
 	if service, ok := am.state.(services.Service); ok {
 		if err := service.AwaitTerminated(context.Background()); err != nil {
 			level.Warn(am.logger).Log("msg", "error while stopping ring-based replication service", "err", err)
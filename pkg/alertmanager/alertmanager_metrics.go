@@ -64,6 +64,13 @@ type alertmanagerMetrics struct {
 	insertAlertFailures                     *prometheus.Desc
 	alertsLimiterAlertsCount                *prometheus.Desc
 	alertsLimiterAlertsSize                 *prometheus.Desc
+
+	// exported metrics, gathered from the alerts history recorder
+	historyEventsTotal        *prometheus.Desc
+	historyFlushTotal         *prometheus.Desc
+	historyFlushFailed        *prometheus.Desc
+	historyFlushedEventsTotal *prometheus.Desc
+	historyDroppedEventsTotal *prometheus.Desc
 }
 
 func newAlertmanagerMetrics() *alertmanagerMetrics {
@@ -229,6 +236,26 @@ func newAlertmanagerMetrics() *alertmanagerMetrics {
 			"cortex_alertmanager_alerts_limiter_current_alerts_size_bytes",
 			"Total size of alerts tracked by alerts limiter.",
 			[]string{"user"}, nil),
+		historyEventsTotal: prometheus.NewDesc(
+			"cortex_alertmanager_alerts_history_events_total",
+			"Number of alerts history events recorded.",
+			[]string{"user", "type"}, nil),
+		historyFlushTotal: prometheus.NewDesc(
+			"cortex_alertmanager_alerts_history_flush_total",
+			"Number of times we have tried to flush the alerts history to remote storage.",
+			[]string{"user"}, nil),
+		historyFlushFailed: prometheus.NewDesc(
+			"cortex_alertmanager_alerts_history_flush_failed_total",
+			"Number of times we have failed to flush the alerts history to remote storage.",
+			[]string{"user"}, nil),
+		historyFlushedEventsTotal: prometheus.NewDesc(
+			"cortex_alertmanager_alerts_history_flushed_events_total",
+			"Number of alerts history events flushed to remote storage.",
+			[]string{"user"}, nil),
+		historyDroppedEventsTotal: prometheus.NewDesc(
+			"cortex_alertmanager_alerts_history_dropped_events_total",
+			"Number of alerts history events dropped because they failed to be flushed to remote storage for too long.",
+			[]string{"user"}, nil),
 	}
 }
 
@@ -283,6 +310,11 @@ func (m *alertmanagerMetrics) Describe(out chan<- *prometheus.Desc) {
 	out <- m.insertAlertFailures
 	out <- m.alertsLimiterAlertsCount
 	out <- m.alertsLimiterAlertsSize
+	out <- m.historyEventsTotal
+	out <- m.historyFlushTotal
+	out <- m.historyFlushFailed
+	out <- m.historyFlushedEventsTotal
+	out <- m.historyDroppedEventsTotal
 }
 
 func (m *alertmanagerMetrics) Collect(out chan<- prometheus.Metric) {
@@ -334,4 +366,10 @@ func (m *alertmanagerMetrics) Collect(out chan<- prometheus.Metric) {
 	data.SendSumOfCountersPerUser(out, m.insertAlertFailures, "alertmanager_alerts_insert_limited_total")
 	data.SendSumOfGaugesPerUser(out, m.alertsLimiterAlertsCount, "alertmanager_alerts_limiter_current_alerts")
 	data.SendSumOfGaugesPerUser(out, m.alertsLimiterAlertsSize, "alertmanager_alerts_limiter_current_alerts_size_bytes")
+
+	data.SendSumOfCountersPerUserWithLabels(out, m.historyEventsTotal, "alertmanager_alerts_history_events_total", "type")
+	data.SendSumOfCountersPerUser(out, m.historyFlushTotal, "alertmanager_alerts_history_flush_total")
+	data.SendSumOfCountersPerUser(out, m.historyFlushFailed, "alertmanager_alerts_history_flush_failed_total")
+	data.SendSumOfCountersPerUser(out, m.historyFlushedEventsTotal, "alertmanager_alerts_history_flushed_events_total")
+	data.SendSumOfCountersPerUser(out, m.historyDroppedEventsTotal, "alertmanager_alerts_history_dropped_events_total")
 }
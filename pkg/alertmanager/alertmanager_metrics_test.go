@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"testing"
 
+	"github.com/go-kit/kit/log"
 	"github.com/prometheus/alertmanager/types"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
 )
 
 var integrations = []string{
@@ -1121,3 +1124,51 @@ func newLimiterMetrics(r prometheus.Registerer) *limiterMetrics {
 		insertFailures: insertAlertFailures,
 	}
 }
+
+func TestAlertmanagerMetricsStore_AlertsHistory(t *testing.T) {
+	mainReg := prometheus.NewPedanticRegistry()
+
+	alertmanagerMetrics := newAlertmanagerMetrics()
+	mainReg.MustRegister(alertmanagerMetrics)
+
+	for i, user := range []string{"user1", "user2"} {
+		reg := prometheus.NewRegistry()
+		h := newHistoryRecorder(HistoryConfig{}, user, &NilPeer{}, nil, log.NewNopLogger(), reg)
+		h.eventsTotal.WithLabelValues(alertspb.HistoryEventFiring).Add(float64(i + 1))
+		h.flushTotal.Add(float64(i + 2))
+		h.flushFailed.Add(float64(i + 3))
+		h.flushedTotal.Add(float64(i + 4))
+		h.droppedTotal.Add(float64(i + 5))
+		alertmanagerMetrics.addUserRegistry(user, reg)
+	}
+
+	err := testutil.GatherAndCompare(mainReg, bytes.NewBufferString(`
+		# HELP cortex_alertmanager_alerts_history_dropped_events_total Number of alerts history events dropped because they failed to be flushed to remote storage for too long.
+		# TYPE cortex_alertmanager_alerts_history_dropped_events_total counter
+		cortex_alertmanager_alerts_history_dropped_events_total{user="user1"} 5
+		cortex_alertmanager_alerts_history_dropped_events_total{user="user2"} 6
+		# HELP cortex_alertmanager_alerts_history_events_total Number of alerts history events recorded.
+		# TYPE cortex_alertmanager_alerts_history_events_total counter
+		cortex_alertmanager_alerts_history_events_total{type="firing",user="user1"} 1
+		cortex_alertmanager_alerts_history_events_total{type="firing",user="user2"} 2
+		# HELP cortex_alertmanager_alerts_history_flush_failed_total Number of times we have failed to flush the alerts history to remote storage.
+		# TYPE cortex_alertmanager_alerts_history_flush_failed_total counter
+		cortex_alertmanager_alerts_history_flush_failed_total{user="user1"} 3
+		cortex_alertmanager_alerts_history_flush_failed_total{user="user2"} 4
+		# HELP cortex_alertmanager_alerts_history_flush_total Number of times we have tried to flush the alerts history to remote storage.
+		# TYPE cortex_alertmanager_alerts_history_flush_total counter
+		cortex_alertmanager_alerts_history_flush_total{user="user1"} 2
+		cortex_alertmanager_alerts_history_flush_total{user="user2"} 3
+		# HELP cortex_alertmanager_alerts_history_flushed_events_total Number of alerts history events flushed to remote storage.
+		# TYPE cortex_alertmanager_alerts_history_flushed_events_total counter
+		cortex_alertmanager_alerts_history_flushed_events_total{user="user1"} 4
+		cortex_alertmanager_alerts_history_flushed_events_total{user="user2"} 5
+`),
+		"cortex_alertmanager_alerts_history_events_total",
+		"cortex_alertmanager_alerts_history_flush_total",
+		"cortex_alertmanager_alerts_history_flush_failed_total",
+		"cortex_alertmanager_alerts_history_flushed_events_total",
+		"cortex_alertmanager_alerts_history_dropped_events_total",
+	)
+	require.NoError(t, err)
+}
@@ -55,6 +55,8 @@ type RingConfig struct {
 	InstanceAddr           string   `yaml:"instance_addr" doc:"hidden"`
 	InstanceZone           string   `yaml:"instance_availability_zone"`
 
+	TokenGenerator ring.TokenGeneratorConfig `yaml:",inline"`
+
 	// Injected internally
 	ListenPort      int           `yaml:"-"`
 	RingCheckPeriod time.Duration `yaml:"-"`
@@ -88,10 +90,16 @@ func (cfg *RingConfig) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.InstancePort, rfprefix+"instance-port", 0, "Port to advertise in the ring (defaults to server.grpc-listen-port).")
 	f.StringVar(&cfg.InstanceID, rfprefix+"instance-id", hostname, "Instance ID to register in the ring.")
 	f.StringVar(&cfg.InstanceZone, rfprefix+"instance-availability-zone", "", "The availability zone where this instance is running. Required if zone-awareness is enabled.")
+	cfg.TokenGenerator.RegisterFlagsWithPrefix(rfprefix, f)
 
 	cfg.RingCheckPeriod = 5 * time.Second
 }
 
+// Validate the config.
+func (cfg *RingConfig) Validate() error {
+	return cfg.TokenGenerator.Validate(cfg.InstanceZone)
+}
+
 // ToLifecyclerConfig returns a LifecyclerConfig based on the alertmanager
 // ring config.
 func (cfg *RingConfig) ToLifecyclerConfig() (ring.BasicLifecyclerConfig, error) {
@@ -102,6 +110,11 @@ func (cfg *RingConfig) ToLifecyclerConfig() (ring.BasicLifecyclerConfig, error)
 
 	instancePort := ring.GetInstancePort(cfg.InstancePort, cfg.ListenPort)
 
+	tokenGenerator, err := cfg.TokenGenerator.NewTokenGenerator(cfg.InstanceID, cfg.InstanceZone)
+	if err != nil {
+		return ring.BasicLifecyclerConfig{}, err
+	}
+
 	return ring.BasicLifecyclerConfig{
 		ID:                  cfg.InstanceID,
 		Addr:                fmt.Sprintf("%s:%d", instanceAddr, instancePort),
@@ -109,6 +122,7 @@ func (cfg *RingConfig) ToLifecyclerConfig() (ring.BasicLifecyclerConfig, error)
 		TokensObservePeriod: 0,
 		Zone:                cfg.InstanceZone,
 		NumTokens:           RingNumTokens,
+		TokenGenerator:      tokenGenerator,
 	}, nil
 }
 
@@ -0,0 +1,165 @@
+package alertspb
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types of the events recorded in the alerts history.
+const (
+	HistoryEventFiring   = "firing"
+	HistoryEventResolved = "resolved"
+	HistoryEventNotified = "notified"
+)
+
+var (
+	errInvalidHistoryToken = errors.New("invalid alerts history pagination token")
+	errInvalidHistoryLimit = errors.New("invalid alerts history limit, must be greater than zero")
+)
+
+// HistoryEvent is an event recorded in the alerts history of a tenant.
+type HistoryEvent struct {
+	Type        string            `json:"type"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Fingerprint string            `json:"fingerprint"`
+	Labels      map[string]string `json:"labels"`
+
+	// Receiver and integration are only set for notified events.
+	Receiver    string `json:"receiver,omitempty"`
+	Integration string `json:"integration,omitempty"`
+}
+
+// key returns a string identifying the event, excluding its timestamp.
+func (e HistoryEvent) key() string {
+	return strings.Join([]string{e.Type, e.Fingerprint, e.Receiver, e.Integration}, "|")
+}
+
+// historyPosition is the position of an event in the alerts history order, which is newest first.
+type historyPosition struct {
+	ts  int64
+	key string
+}
+
+func (e HistoryEvent) position() historyPosition {
+	return historyPosition{ts: e.Timestamp.UnixNano(), key: e.key()}
+}
+
+// before returns true if p comes before o in the alerts history order.
+func (p historyPosition) before(o historyPosition) bool {
+	if p.ts != o.ts {
+		return p.ts > o.ts
+	}
+	return p.key < o.key
+}
+
+// HistoryResult is a page of the alerts history returned by a query.
+type HistoryResult struct {
+	Events []HistoryEvent `json:"events"`
+	Limit  int            `json:"limit"`
+
+	// NextToken is set when more events may be available, and should be passed
+	// to the next query to get the following page.
+	NextToken string `json:"next_token,omitempty"`
+}
+
+// MergeHistoryEvents sorts and deduplicates the input events, returning the first page of at most limit
+// events which come after the input token in the alerts history order. If token is empty, the page starts
+// from the newest event. If more events are available, the returned result has the NextToken set.
+func MergeHistoryEvents(events []HistoryEvent, limit int, token string) (HistoryResult, error) {
+	if limit <= 0 {
+		return HistoryResult{}, errInvalidHistoryLimit
+	}
+
+	var after *historyPosition
+	if token != "" {
+		cursor, err := decodeHistoryToken(token)
+		if err != nil {
+			return HistoryResult{}, err
+		}
+		after = &cursor
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].position().before(events[j].position())
+	})
+
+	res := HistoryResult{Events: make([]HistoryEvent, 0, limit), Limit: limit}
+	for i, e := range events {
+		// Skip duplicated events, which are adjacent once sorted.
+		if i > 0 && !events[i-1].position().before(e.position()) {
+			continue
+		}
+		if after != nil && !after.before(e.position()) {
+			continue
+		}
+		if len(res.Events) == limit {
+			res.NextToken = encodeHistoryToken(res.Events[limit-1])
+			break
+		}
+		res.Events = append(res.Events, e)
+	}
+
+	return res, nil
+}
+
+// MergeHistoryResults merges multiple pages of the alerts history, queried with the same token, into
+// a single page. The limit of the merged page is the lowest one across the input pages.
+func MergeHistoryResults(results []HistoryResult) (HistoryResult, error) {
+	var (
+		events    []HistoryEvent
+		limit     = 0
+		truncated = false
+	)
+
+	for _, res := range results {
+		events = append(events, res.Events...)
+		if limit == 0 || res.Limit < limit {
+			limit = res.Limit
+		}
+		if res.NextToken != "" {
+			truncated = true
+		}
+	}
+
+	// The input pages already start after the query token, so there's no need to pass it again.
+	merged, err := MergeHistoryEvents(events, limit, "")
+	if err != nil {
+		return HistoryResult{}, err
+	}
+
+	// If any input page was truncated there may be more events, even if the merged page is not full.
+	if truncated && merged.NextToken == "" && len(merged.Events) > 0 {
+		merged.NextToken = encodeHistoryToken(merged.Events[len(merged.Events)-1])
+	}
+
+	return merged, nil
+}
+
+func encodeHistoryToken(e HistoryEvent) string {
+	pos := e.position()
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d|%s", pos.ts, pos.key)))
+}
+
+func decodeHistoryToken(token string) (historyPosition, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return historyPosition{}, errInvalidHistoryToken
+	}
+
+	parts := strings.SplitN(string(data), "|", 2)
+	if len(parts) != 2 {
+		return historyPosition{}, errInvalidHistoryToken
+	}
+
+	ts, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return historyPosition{}, errInvalidHistoryToken
+	}
+
+	return historyPosition{ts: ts, key: parts[1]}, nil
+}
@@ -2,7 +2,9 @@ package bucketclient
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"io/ioutil"
 	"strings"
 	"sync"
@@ -32,6 +34,11 @@ const (
 	// The name of alertmanager full state objects (notification log + silences).
 	fullStateName = "fullstate"
 
+	// The prefix under which the alerts history segments of each tenant are stored.
+	// Note that objects stored under this prefix follow the pattern:
+	//     alertmanager/<user-id>/history/<segment>
+	historyPrefix = "history/"
+
 	// How many users to load concurrently.
 	fetchConcurrency = 16
 )
@@ -172,6 +179,78 @@ func (s *BucketAlertStore) DeleteFullState(ctx context.Context, userID string) e
 	return err
 }
 
+// ListHistorySegments implements alertstore.AlertStore.
+func (s *BucketAlertStore) ListHistorySegments(ctx context.Context, userID string) ([]string, error) {
+	var segments []string
+
+	err := s.getAlertmanagerUserBucket(userID).Iter(ctx, historyPrefix, func(key string) error {
+		segments = append(segments, strings.TrimPrefix(key, historyPrefix))
+		return nil
+	})
+
+	return segments, err
+}
+
+// GetHistorySegment implements alertstore.AlertStore.
+func (s *BucketAlertStore) GetHistorySegment(ctx context.Context, userID, segment string) ([]alertspb.HistoryEvent, error) {
+	bkt := s.getAlertmanagerUserBucket(userID)
+
+	readCloser, err := bkt.Get(ctx, historyPrefix+segment)
+	if bkt.IsObjNotFoundErr(err) {
+		return nil, alertspb.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	defer runutil.CloseWithLogOnErr(s.logger, readCloser, "close bucket reader")
+
+	gzipReader, err := gzip.NewReader(readCloser)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read alerts history segment %s for user %s", segment, userID)
+	}
+
+	var events []alertspb.HistoryEvent
+	decoder := json.NewDecoder(gzipReader)
+	for decoder.More() {
+		event := alertspb.HistoryEvent{}
+		if err := decoder.Decode(&event); err != nil {
+			return nil, errors.Wrapf(err, "failed to deserialize alerts history segment %s for user %s", segment, userID)
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// SetHistorySegment implements alertstore.AlertStore. Events are stored as gzipped JSON lines.
+func (s *BucketAlertStore) SetHistorySegment(ctx context.Context, userID, segment string, events []alertspb.HistoryEvent) error {
+	buf := bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(gzipWriter)
+
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return err
+	}
+
+	return s.getAlertmanagerUserBucket(userID).Upload(ctx, historyPrefix+segment, &buf)
+}
+
+// DeleteHistorySegment implements alertstore.AlertStore.
+func (s *BucketAlertStore) DeleteHistorySegment(ctx context.Context, userID, segment string) error {
+	userBkt := s.getAlertmanagerUserBucket(userID)
+
+	err := userBkt.Delete(ctx, historyPrefix+segment)
+	if userBkt.IsObjNotFoundErr(err) {
+		return nil
+	}
+	return err
+}
+
 func (s *BucketAlertStore) getAlertConfig(ctx context.Context, userID string) (alertspb.AlertConfigDesc, error) {
 	config := alertspb.AlertConfigDesc{}
 	err := s.get(ctx, s.getUserBucket(userID), userID, &config)
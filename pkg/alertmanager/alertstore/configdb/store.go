@@ -16,6 +16,7 @@ const (
 var (
 	errReadOnly = errors.New("configdb alertmanager config storage is read-only")
 	errState    = errors.New("configdb alertmanager storage does not support state persistency")
+	errHistory  = errors.New("configdb alertmanager storage does not support alerts history")
 )
 
 // Store is a concrete implementation of RuleStore that sources rules from the config service
@@ -113,6 +114,26 @@ func (c *Store) DeleteFullState(ctx context.Context, user string) error {
 	return errState
 }
 
+// ListHistorySegments implements alertstore.AlertStore.
+func (c *Store) ListHistorySegments(ctx context.Context, user string) ([]string, error) {
+	return nil, errHistory
+}
+
+// GetHistorySegment implements alertstore.AlertStore.
+func (c *Store) GetHistorySegment(ctx context.Context, user, segment string) ([]alertspb.HistoryEvent, error) {
+	return nil, errHistory
+}
+
+// SetHistorySegment implements alertstore.AlertStore.
+func (c *Store) SetHistorySegment(ctx context.Context, user, segment string, events []alertspb.HistoryEvent) error {
+	return errHistory
+}
+
+// DeleteHistorySegment implements alertstore.AlertStore.
+func (c *Store) DeleteHistorySegment(ctx context.Context, user, segment string) error {
+	return errHistory
+}
+
 func (c *Store) reloadConfigs(ctx context.Context) (map[string]alertspb.AlertConfigDesc, error) {
 	configs, err := c.configClient.GetAlerts(ctx, c.since)
 	if err != nil {
@@ -21,6 +21,7 @@ const (
 var (
 	errReadOnly = errors.New("local alertmanager config storage is read-only")
 	errState    = errors.New("local alertmanager storage does not support state persistency")
+	errHistory  = errors.New("local alertmanager storage does not support alerts history")
 )
 
 // StoreConfig configures a static file alertmanager store
@@ -121,6 +122,26 @@ func (f *Store) DeleteFullState(ctx context.Context, user string) error {
 	return errState
 }
 
+// ListHistorySegments implements alertstore.AlertStore.
+func (f *Store) ListHistorySegments(ctx context.Context, user string) ([]string, error) {
+	return nil, errHistory
+}
+
+// GetHistorySegment implements alertstore.AlertStore.
+func (f *Store) GetHistorySegment(ctx context.Context, user, segment string) ([]alertspb.HistoryEvent, error) {
+	return nil, errHistory
+}
+
+// SetHistorySegment implements alertstore.AlertStore.
+func (f *Store) SetHistorySegment(ctx context.Context, user, segment string, events []alertspb.HistoryEvent) error {
+	return errHistory
+}
+
+// DeleteHistorySegment implements alertstore.AlertStore.
+func (f *Store) DeleteHistorySegment(ctx context.Context, user, segment string) error {
+	return errHistory
+}
+
 func (f *Store) reloadConfigs() (map[string]alertspb.AlertConfigDesc, error) {
 	configs := map[string]alertspb.AlertConfigDesc{}
 	err := filepath.Walk(f.cfg.Path, func(path string, info os.FileInfo, err error) error {
@@ -31,7 +31,8 @@ const (
 )
 
 var (
-	errState = errors.New("legacy object alertmanager storage does not support state persistency")
+	errState   = errors.New("legacy object alertmanager storage does not support state persistency")
+	errHistory = errors.New("legacy object alertmanager storage does not support alerts history")
 )
 
 // AlertStore allows cortex alertmanager configs to be stored using an object store backend.
@@ -161,3 +162,23 @@ func (a *AlertStore) SetFullState(ctx context.Context, user string, cfg alertspb
 func (a *AlertStore) DeleteFullState(ctx context.Context, user string) error {
 	return errState
 }
+
+// ListHistorySegments implements alertstore.AlertStore.
+func (a *AlertStore) ListHistorySegments(ctx context.Context, user string) ([]string, error) {
+	return nil, errHistory
+}
+
+// GetHistorySegment implements alertstore.AlertStore.
+func (a *AlertStore) GetHistorySegment(ctx context.Context, user, segment string) ([]alertspb.HistoryEvent, error) {
+	return nil, errHistory
+}
+
+// SetHistorySegment implements alertstore.AlertStore.
+func (a *AlertStore) SetHistorySegment(ctx context.Context, user, segment string, events []alertspb.HistoryEvent) error {
+	return errHistory
+}
+
+// DeleteHistorySegment implements alertstore.AlertStore.
+func (a *AlertStore) DeleteHistorySegment(ctx context.Context, user, segment string) error {
+	return errHistory
+}
@@ -52,6 +52,19 @@ type AlertStore interface {
 	// DeleteFullState deletes the alertmanager state for an user.
 	// If state for the user doesn't exist, no error is reported.
 	DeleteFullState(ctx context.Context, user string) error
+
+	// ListHistorySegments returns the names of the alerts history segments stored for the given user.
+	ListHistorySegments(ctx context.Context, user string) ([]string, error)
+
+	// GetHistorySegment loads and returns the alerts history events stored in the given segment.
+	GetHistorySegment(ctx context.Context, user, segment string) ([]alertspb.HistoryEvent, error)
+
+	// SetHistorySegment stores the alerts history events in the given segment.
+	SetHistorySegment(ctx context.Context, user, segment string, events []alertspb.HistoryEvent) error
+
+	// DeleteHistorySegment deletes the given alerts history segment.
+	// If the segment doesn't exist, no error is reported.
+	DeleteHistorySegment(ctx context.Context, user, segment string) error
 }
 
 // NewLegacyAlertStore returns a new alertmanager storage backend poller and store
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/prometheus/alertmanager/cluster/clusterpb"
@@ -274,3 +275,61 @@ func TestBucketAlertStore_GetSetDeleteFullState(t *testing.T) {
 		require.NoError(t, store.DeleteFullState(ctx, "user-1"))
 	}
 }
+
+func TestBucketAlertStore_GetSetDeleteHistorySegment(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	store := bucketclient.NewBucketAlertStore(bucket, nil, log.NewNopLogger())
+	ctx := context.Background()
+
+	events := []alertspb.HistoryEvent{
+		{Type: alertspb.HistoryEventFiring, Timestamp: time.Unix(10, 0).UTC(), Fingerprint: "a", Labels: map[string]string{"alertname": "one"}},
+		{Type: alertspb.HistoryEventNotified, Timestamp: time.Unix(20, 0).UTC(), Fingerprint: "a", Labels: map[string]string{"alertname": "one"}, Receiver: "default", Integration: "webhook"},
+	}
+
+	// The storage is empty.
+	{
+		_, err := store.GetHistorySegment(ctx, "user-1", "segment-1")
+		assert.Equal(t, alertspb.ErrNotFound, err)
+
+		segments, err := store.ListHistorySegments(ctx, "user-1")
+		require.NoError(t, err)
+		assert.Empty(t, segments)
+	}
+
+	// The storage contains segments.
+	{
+		require.NoError(t, store.SetHistorySegment(ctx, "user-1", "segment-1", events))
+		require.NoError(t, store.SetHistorySegment(ctx, "user-1", "segment-2", events[:1]))
+
+		res, err := store.GetHistorySegment(ctx, "user-1", "segment-1")
+		require.NoError(t, err)
+		assert.Equal(t, events, res)
+
+		exists, err := bucket.Exists(ctx, "alertmanager/user-1/history/segment-1")
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		segments, err := store.ListHistorySegments(ctx, "user-1")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"segment-1", "segment-2"}, segments)
+
+		segments, err = store.ListHistorySegments(ctx, "user-2")
+		require.NoError(t, err)
+		assert.Empty(t, segments)
+	}
+
+	// The storage has had segment-1 deleted.
+	{
+		require.NoError(t, store.DeleteHistorySegment(ctx, "user-1", "segment-1"))
+
+		_, err := store.GetHistorySegment(ctx, "user-1", "segment-1")
+		assert.Equal(t, alertspb.ErrNotFound, err)
+
+		segments, err := store.ListHistorySegments(ctx, "user-1")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"segment-2"}, segments)
+
+		// Delete again (should be idempotent).
+		require.NoError(t, store.DeleteHistorySegment(ctx, "user-1", "segment-1"))
+	}
+}
@@ -26,16 +26,17 @@ import (
 )
 
 const (
-	errMarshallingYAML       = "error marshalling YAML Alertmanager config"
-	errValidatingConfig      = "error validating Alertmanager config"
-	errReadingConfiguration  = "unable to read the Alertmanager config"
-	errStoringConfiguration  = "unable to store the Alertmanager config"
-	errDeletingConfiguration = "unable to delete the Alertmanager config"
-	errNoOrgID               = "unable to determine the OrgID"
-	errListAllUser           = "unable to list the Alertmanager users"
-	errConfigurationTooBig   = "Alertmanager configuration is too big, limit: %d bytes"
-	errTooManyTemplates      = "too many templates in the configuration: %d (limit: %d)"
-	errTemplateTooBig        = "template %s is too big: %d bytes (limit: %d bytes)"
+	errMarshallingYAML        = "error marshalling YAML Alertmanager config"
+	errValidatingConfig       = "error validating Alertmanager config"
+	errReadingConfiguration   = "unable to read the Alertmanager config"
+	errStoringConfiguration   = "unable to store the Alertmanager config"
+	errDeletingConfiguration  = "unable to delete the Alertmanager config"
+	errNoOrgID                = "unable to determine the OrgID"
+	errListAllUser            = "unable to list the Alertmanager users"
+	errConfigurationTooBig    = "Alertmanager configuration is too big, limit: %d bytes"
+	errTooManyTemplates       = "too many templates in the configuration: %d (limit: %d)"
+	errTemplateTooBig         = "template %s is too big: %d bytes (limit: %d bytes)"
+	errSharedReceiverConflict = "the receiver %q conflicts with a shared receiver with the same name"
 
 	fetchConcurrency = 16
 )
@@ -102,27 +103,10 @@ func (am *MultitenantAlertmanager) SetUserConfig(w http.ResponseWriter, r *http.
 		return
 	}
 
-	var input io.Reader
-	maxConfigSize := am.limits.AlertmanagerMaxConfigSize(userID)
-	if maxConfigSize > 0 {
-		// LimitReader will return EOF after reading specified number of bytes. To check if
-		// we have read too many bytes, allow one extra byte.
-		input = io.LimitReader(r.Body, int64(maxConfigSize)+1)
-	} else {
-		input = r.Body
-	}
-
-	payload, err := ioutil.ReadAll(input)
+	payload, err := am.readUserConfigPayload(r, userID)
 	if err != nil {
-		level.Error(logger).Log("msg", errReadingConfiguration, "err", err.Error())
-		http.Error(w, fmt.Sprintf("%s: %s", errReadingConfiguration, err.Error()), http.StatusBadRequest)
-		return
-	}
-
-	if maxConfigSize > 0 && len(payload) > maxConfigSize {
-		msg := fmt.Sprintf(errConfigurationTooBig, maxConfigSize)
-		level.Warn(logger).Log("msg", msg)
-		http.Error(w, msg, http.StatusBadRequest)
+		level.Warn(logger).Log("msg", errReadingConfiguration, "err", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -135,7 +119,7 @@ func (am *MultitenantAlertmanager) SetUserConfig(w http.ResponseWriter, r *http.
 	}
 
 	cfgDesc := alertspb.ToProto(cfg.AlertmanagerConfig, cfg.TemplateFiles, userID)
-	if err := validateUserConfig(logger, cfgDesc, am.limits, userID); err != nil {
+	if err := validateUserConfig(logger, cfgDesc, am.limits, userID, am.sharedConfig()); err != nil {
 		level.Warn(logger).Log("msg", errValidatingConfig, "err", err.Error())
 		http.Error(w, fmt.Sprintf("%s: %s", errValidatingConfig, err.Error()), http.StatusBadRequest)
 		return
@@ -151,6 +135,30 @@ func (am *MultitenantAlertmanager) SetUserConfig(w http.ResponseWriter, r *http.
 	w.WriteHeader(http.StatusCreated)
 }
 
+// readUserConfigPayload reads the request body, enforcing the max configuration size configured for the tenant.
+func (am *MultitenantAlertmanager) readUserConfigPayload(r *http.Request, userID string) ([]byte, error) {
+	var input io.Reader
+	maxConfigSize := am.limits.AlertmanagerMaxConfigSize(userID)
+	if maxConfigSize > 0 {
+		// LimitReader will return EOF after reading specified number of bytes. To check if
+		// we have read too many bytes, allow one extra byte.
+		input = io.LimitReader(r.Body, int64(maxConfigSize)+1)
+	} else {
+		input = r.Body
+	}
+
+	payload, err := ioutil.ReadAll(input)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", errReadingConfiguration, err.Error())
+	}
+
+	if maxConfigSize > 0 && len(payload) > maxConfigSize {
+		return nil, fmt.Errorf(errConfigurationTooBig, maxConfigSize)
+	}
+
+	return payload, nil
+}
+
 // DeleteUserConfig is exposed via user-visible API (if enabled, uses DELETE method), but also as an internal endpoint using POST method.
 // Note that if no config exists for a user, StatusOK is returned.
 func (am *MultitenantAlertmanager) DeleteUserConfig(w http.ResponseWriter, r *http.Request) {
@@ -173,7 +181,7 @@ func (am *MultitenantAlertmanager) DeleteUserConfig(w http.ResponseWriter, r *ht
 }
 
 // Partially copied from: https://github.com/prometheus/alertmanager/blob/8e861c646bf67599a1704fc843c6a94d519ce312/cli/check_config.go#L65-L96
-func validateUserConfig(logger log.Logger, cfg alertspb.AlertConfigDesc, limits Limits, user string) error {
+func validateUserConfig(logger log.Logger, cfg alertspb.AlertConfigDesc, limits Limits, user string, shared *SharedConfig) error {
 	// We don't have a valid use case for empty configurations. If a tenant does not have a
 	// configuration set and issue a request to the Alertmanager, we'll a) upload an empty
 	// config and b) immediately start an Alertmanager instance for them if a fallback
@@ -182,13 +190,26 @@ func validateUserConfig(logger log.Logger, cfg alertspb.AlertConfigDesc, limits
 		return fmt.Errorf("configuration provided is empty, if you'd like to remove your configuration please use the delete configuration endpoint")
 	}
 
-	amCfg, err := config.Load(cfg.RawConfig)
+	amCfg, sharedNames, err := loadUserConfig(cfg.RawConfig, shared)
 	if err != nil {
 		return err
 	}
 
+	// The shared receivers are managed by the operators, so they're excluded from the validation.
+	userCfg := *amCfg
+	userCfg.Receivers = make([]*config.Receiver, 0, len(amCfg.Receivers))
+	for _, rcv := range amCfg.Receivers {
+		if sharedNames[rcv.Name] {
+			continue
+		}
+		if shared.receiver(rcv.Name) != nil {
+			return fmt.Errorf(errSharedReceiverConflict, rcv.Name)
+		}
+		userCfg.Receivers = append(userCfg.Receivers, rcv)
+	}
+
 	// Validate the config recursively scanning it.
-	if err := validateAlertmanagerConfig(amCfg); err != nil {
+	if err := validateAlertmanagerConfig(&userCfg); err != nil {
 		return err
 	}
 
@@ -219,6 +240,22 @@ func validateUserConfig(logger log.Logger, cfg alertspb.AlertConfigDesc, limits
 		}
 	}
 
+	if _, err := loadTemplatesFromTempDir(logger, cfg, amCfg, shared); err != nil {
+		return err
+	}
+
+	// Note: Not validating the MultitenantAlertmanager.transformConfig function as that
+	// that function shouldn't break configuration. Only way it can fail is if the base
+	// autoWebhookURL itself is broken. In that case, I would argue, we should accept the config
+	// not reject it.
+
+	return nil
+}
+
+// loadTemplatesFromTempDir stores the templates of the input config in a temporary directory
+// and loads the shared templates and the ones referenced by the Alertmanager config. The temporary
+// directory is removed once the templates have been parsed.
+func loadTemplatesFromTempDir(logger log.Logger, cfg alertspb.AlertConfigDesc, amCfg *config.Config, shared *SharedConfig) (*template.Template, error) {
 	// Create templates on disk in a temporary directory.
 	// Note: This means the validation will succeed if we can write to tmp but
 	// not to configured data dir, and on the flipside, it'll fail if we can't write
@@ -226,7 +263,7 @@ func validateUserConfig(logger log.Logger, cfg alertspb.AlertConfigDesc, limits
 	// we see this in the wild.
 	userTempDir, err := ioutil.TempDir("", "validate-config-"+cfg.User)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer os.RemoveAll(userTempDir)
 
@@ -234,31 +271,28 @@ func validateUserConfig(logger log.Logger, cfg alertspb.AlertConfigDesc, limits
 		templateFilepath, err := safeTemplateFilepath(userTempDir, tmpl.Filename)
 		if err != nil {
 			level.Error(logger).Log("msg", "unable to create template file path", "err", err, "user", cfg.User)
-			return err
+			return nil, err
 		}
 
 		if _, err = storeTemplateFile(templateFilepath, tmpl.Body); err != nil {
 			level.Error(logger).Log("msg", "unable to store template file", "err", err, "user", cfg.User)
-			return fmt.Errorf("unable to store template file '%s'", tmpl.Filename)
+			return nil, fmt.Errorf("unable to store template file '%s'", tmpl.Filename)
 		}
 	}
 
-	templateFiles := make([]string, len(amCfg.Templates))
-	for i, t := range amCfg.Templates {
-		templateFiles[i] = filepath.Join(userTempDir, t)
+	sharedDir := filepath.Join(userTempDir, sharedTemplatesDir)
+	if _, err := storeSharedTemplates(sharedDir, shared); err != nil {
+		level.Error(logger).Log("msg", "unable to store shared template files", "err", err, "user", cfg.User)
+		return nil, errors.Wrap(err, "unable to store shared template files")
 	}
 
-	_, err = template.FromGlobs(templateFiles...)
-	if err != nil {
-		return err
+	// The shared templates are loaded first, so that the tenant's templates can override them.
+	templateFiles := []string{filepath.Join(sharedDir, "*")}
+	for _, t := range amCfg.Templates {
+		templateFiles = append(templateFiles, filepath.Join(userTempDir, t))
 	}
 
-	// Note: Not validating the MultitenantAlertmanager.transformConfig function as that
-	// that function shouldn't break configuration. Only way it can fail is if the base
-	// autoWebhookURL itself is broken. In that case, I would argue, we should accept the config
-	// not reject it.
-
-	return nil
+	return template.FromGlobs(templateFiles...)
 }
 
 func (am *MultitenantAlertmanager) ListAllConfigs(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,330 @@
+package alertmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	tmpltext "text/template"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/dispatch"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	util_net "github.com/cortexproject/cortex/pkg/util/net"
+)
+
+const (
+	errPreviewingConfig = "error previewing Alertmanager config"
+
+	// pushoverAPIHost is the host used by the Pushover integration, which is not configurable.
+	pushoverAPIHost = "api.pushover.net"
+)
+
+// PreviewRequest is the payload accepted by the Alertmanager configuration preview endpoint.
+type PreviewRequest struct {
+	UserConfig `yaml:",inline"`
+
+	// Labels and annotations of the sample alert used to preview the configuration.
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// PreviewResponse is the result of previewing an Alertmanager configuration against a sample alert.
+type PreviewResponse struct {
+	Routes []PreviewRoute `json:"routes"`
+}
+
+// PreviewRoute describes a route of the routing tree matching the sample alert.
+type PreviewRoute struct {
+	Receiver          string            `json:"receiver"`
+	GroupBy           []string          `json:"group_by"`
+	GroupByAll        bool              `json:"group_by_all"`
+	GroupLabels       map[string]string `json:"group_labels"`
+	GroupWait         model.Duration    `json:"group_wait"`
+	GroupInterval     model.Duration    `json:"group_interval"`
+	RepeatInterval    model.Duration    `json:"repeat_interval"`
+	MuteTimeIntervals []string          `json:"mute_time_intervals,omitempty"`
+	Continue          bool              `json:"continue"`
+
+	Integrations []PreviewIntegration `json:"integrations"`
+	Templates    []PreviewTemplate    `json:"templates"`
+}
+
+// PreviewIntegration describes an integration of the receiver of a matching route. Only the host of
+// the integration endpoint is reported, because the full URL may contain secrets.
+type PreviewIntegration struct {
+	Name  string `json:"name"`
+	Index int    `json:"index"`
+	Host  string `json:"host,omitempty"`
+
+	// FirewallError is the reason why the receivers firewall would block the integration, if any.
+	// Email integrations are not subject to the receivers firewall.
+	FirewallError string `json:"firewall_error,omitempty"`
+}
+
+// PreviewTemplate is a template defined in the tenant's template files, rendered against the sample alert.
+type PreviewTemplate struct {
+	Name   string `json:"name"`
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// PreviewUserConfig validates the Alertmanager configuration in the request body and previews how a
+// sample alert, built from the input labels and annotations, would be handled by it. No notification is sent.
+func (am *MultitenantAlertmanager) PreviewUserConfig(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	payload, err := am.readUserConfigPayload(r, userID)
+	if err != nil {
+		level.Warn(logger).Log("msg", errReadingConfiguration, "err", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := &PreviewRequest{}
+	if err := yaml.Unmarshal(payload, req); err != nil {
+		level.Warn(logger).Log("msg", errMarshallingYAML, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errMarshallingYAML, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Labels) == 0 {
+		http.Error(w, fmt.Sprintf("%s: the sample alert has no labels", errPreviewingConfig), http.StatusBadRequest)
+		return
+	}
+
+	cfgDesc := alertspb.ToProto(req.AlertmanagerConfig, req.TemplateFiles, userID)
+	if err := validateUserConfig(logger, cfgDesc, am.limits, userID, am.sharedConfig()); err != nil {
+		level.Warn(logger).Log("msg", errValidatingConfig, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errValidatingConfig, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	res, err := am.previewUserConfig(r.Context(), logger, cfgDesc, req.Labels, req.Annotations)
+	if err != nil {
+		level.Warn(logger).Log("msg", errPreviewingConfig, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errPreviewingConfig, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	util.WriteJSONResponse(w, res)
+}
+
+// previewUserConfig matches a sample alert against the routing tree of a config which has already been
+// validated, rendering the tenant's templates and running the receivers firewall checks for each matching route.
+func (am *MultitenantAlertmanager) previewUserConfig(ctx context.Context, logger log.Logger, cfg alertspb.AlertConfigDesc, labels, annotations map[string]string) (*PreviewResponse, error) {
+	shared := am.sharedConfig()
+	amCfg, _, err := loadUserConfig(cfg.RawConfig, shared)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := am.transformConfig(cfg.User, amCfg); err != nil {
+		return nil, err
+	}
+
+	tmpl, err := loadTemplatesFromTempDir(logger, cfg, amCfg, shared)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.ExternalURL = am.cfg.ExternalURL.URL
+
+	templateNames, err := userTemplateNames(cfg, amCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	receivers := make(map[string]*config.Receiver, len(amCfg.Receivers))
+	for _, rcv := range amCfg.Receivers {
+		receivers[rcv.Name] = rcv
+	}
+
+	now := time.Now()
+	alert := &types.Alert{
+		Alert: model.Alert{
+			Labels:      model.LabelSet{},
+			Annotations: model.LabelSet{},
+			StartsAt:    now,
+		},
+		UpdatedAt: now,
+	}
+	for name, value := range labels {
+		alert.Labels[model.LabelName(name)] = model.LabelValue(value)
+	}
+	for name, value := range annotations {
+		alert.Annotations[model.LabelName(name)] = model.LabelValue(value)
+	}
+	if err := alert.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid sample alert: %v", err)
+	}
+
+	firewallDialer := util_net.NewFirewallDialer(newFirewallDialerConfigProvider(cfg.User, am.limits))
+	res := &PreviewResponse{Routes: []PreviewRoute{}}
+
+	for _, route := range dispatch.NewRoute(amCfg.Route, nil).Match(alert.Labels) {
+		groupLabels := model.LabelSet{}
+		for name, value := range alert.Labels {
+			if _, ok := route.RouteOpts.GroupBy[name]; ok || route.RouteOpts.GroupByAll {
+				groupLabels[name] = value
+			}
+		}
+
+		groupBy := make([]string, 0, len(route.RouteOpts.GroupBy))
+		for name := range route.RouteOpts.GroupBy {
+			groupBy = append(groupBy, string(name))
+		}
+		sort.Strings(groupBy)
+
+		previewRoute := PreviewRoute{
+			Receiver:          route.RouteOpts.Receiver,
+			GroupBy:           groupBy,
+			GroupByAll:        route.RouteOpts.GroupByAll,
+			GroupLabels:       labelSetToMap(groupLabels),
+			GroupWait:         model.Duration(route.RouteOpts.GroupWait),
+			GroupInterval:     model.Duration(route.RouteOpts.GroupInterval),
+			RepeatInterval:    model.Duration(route.RouteOpts.RepeatInterval),
+			MuteTimeIntervals: route.RouteOpts.MuteTimeIntervals,
+			Continue:          route.Continue,
+			Integrations:      []PreviewIntegration{},
+			Templates:         []PreviewTemplate{},
+		}
+
+		if rcv, ok := receivers[route.RouteOpts.Receiver]; ok {
+			previewRoute.Integrations = previewReceiverIntegrations(ctx, rcv, firewallDialer)
+		}
+
+		data := tmpl.Data(route.RouteOpts.Receiver, groupLabels, alert)
+		for _, name := range templateNames {
+			output, err := tmpl.ExecuteTextString(fmt.Sprintf("{{ template %q . }}", name), data)
+			previewTemplate := PreviewTemplate{Name: name, Output: output}
+			if err != nil {
+				previewTemplate.Error = err.Error()
+			}
+			previewRoute.Templates = append(previewRoute.Templates, previewTemplate)
+		}
+
+		res.Routes = append(res.Routes, previewRoute)
+	}
+
+	return res, nil
+}
+
+// userTemplateNames returns the sorted names of the templates defined in the tenant's template
+// files referenced by the Alertmanager config.
+func userTemplateNames(cfg alertspb.AlertConfigDesc, amCfg *config.Config) ([]string, error) {
+	names := map[string]struct{}{}
+
+	for _, file := range cfg.Templates {
+		referenced := false
+		for _, glob := range amCfg.Templates {
+			if ok, _ := filepath.Match(glob, file.Filename); ok {
+				referenced = true
+				break
+			}
+		}
+		if !referenced {
+			continue
+		}
+
+		t, err := tmpltext.New(file.Filename).Funcs(tmpltext.FuncMap(template.DefaultFuncs)).Parse(file.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, defined := range t.Templates() {
+			// Skip the root template, which holds the content outside of any "define" action.
+			if defined.Name() != file.Filename {
+				names[defined.Name()] = struct{}{}
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted, nil
+}
+
+// previewReceiverIntegrations lists the integrations of the input receiver, in the same order
+// they're built by buildReceiverIntegrations(), checking their endpoint against the receivers firewall.
+func previewReceiverIntegrations(ctx context.Context, rcv *config.Receiver, firewallDialer *util_net.FirewallDialer) []PreviewIntegration {
+	var integrations []PreviewIntegration
+
+	add := func(name string, i int, u *url.URL) {
+		integration := PreviewIntegration{Name: name, Index: i}
+		if u != nil {
+			integration.Host = u.Hostname()
+			if err := firewallDialer.CheckHost(ctx, integration.Host); err != nil {
+				integration.FirewallError = err.Error()
+			}
+		}
+		integrations = append(integrations, integration)
+	}
+
+	for i, c := range rcv.WebhookConfigs {
+		add("webhook", i, configURL(c.URL))
+	}
+	for i, c := range rcv.EmailConfigs {
+		// Email integrations don't dial through the receivers firewall.
+		integrations = append(integrations, PreviewIntegration{Name: "email", Index: i, Host: c.Smarthost.Host})
+	}
+	for i, c := range rcv.PagerdutyConfigs {
+		add("pagerduty", i, configURL(c.URL))
+	}
+	for i, c := range rcv.OpsGenieConfigs {
+		add("opsgenie", i, configURL(c.APIURL))
+	}
+	for i, c := range rcv.WechatConfigs {
+		add("wechat", i, configURL(c.APIURL))
+	}
+	for i, c := range rcv.SlackConfigs {
+		add("slack", i, configURL((*config.URL)(c.APIURL)))
+	}
+	for i, c := range rcv.VictorOpsConfigs {
+		add("victorops", i, configURL(c.APIURL))
+	}
+	for i := range rcv.PushoverConfigs {
+		add("pushover", i, &url.URL{Host: pushoverAPIHost})
+	}
+
+	if integrations == nil {
+		return []PreviewIntegration{}
+	}
+	return integrations
+}
+
+func configURL(u *config.URL) *url.URL {
+	if u == nil {
+		return nil
+	}
+	return u.URL
+}
+
+func labelSetToMap(ls model.LabelSet) map[string]string {
+	m := make(map[string]string, len(ls))
+	for name, value := range ls {
+		m[string(name)] = string(value)
+	}
+	return m
+}
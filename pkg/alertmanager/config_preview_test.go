@@ -0,0 +1,166 @@
+package alertmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+func TestMultitenantAlertmanager_PreviewUserConfig(t *testing.T) {
+	const cfg = `
+template_files:
+  "custom.tmpl": |
+    {{ define "custom.title" }}[{{ .Status }}] {{ .CommonLabels.alertname }}{{ end }}
+    {{ define "custom.broken" }}{{ template "not.existing" . }}{{ end }}
+alertmanager_config: |
+  templates:
+    - 'custom.tmpl'
+  route:
+    receiver: 'default'
+    group_by: [alertname]
+    routes:
+      - receiver: 'team-a'
+        group_by: [alertname, cluster]
+        group_wait: 10s
+        matchers:
+          - team="a"
+        continue: true
+      - receiver: 'private'
+        matchers:
+          - team="a"
+  receivers:
+    - name: 'default'
+    - name: 'team-a'
+      webhook_configs:
+        - url: 'http://1.1.1.1/hook'
+      email_configs:
+        - to: 'team-a@example.org'
+          from: 'cortex@example.org'
+          smarthost: 'smtp.example.org:25'
+    - name: 'private'
+      webhook_configs:
+        - url: 'http://127.0.0.1/hook'
+`
+
+	tests := map[string]struct {
+		body           string
+		expectedStatus int
+		expectedRoutes []PreviewRoute
+	}{
+		"should return the default route if no child route matches": {
+			body: cfg + `
+labels:
+  alertname: HighLatency
+  team: b
+`,
+			expectedStatus: http.StatusOK,
+			expectedRoutes: []PreviewRoute{{
+				Receiver:       "default",
+				GroupBy:        []string{"alertname"},
+				GroupLabels:    map[string]string{"alertname": "HighLatency"},
+				GroupWait:      model.Duration(30 * time.Second),
+				GroupInterval:  model.Duration(5 * time.Minute),
+				RepeatInterval: model.Duration(4 * time.Hour),
+				Integrations:   []PreviewIntegration{},
+				Templates: []PreviewTemplate{
+					{Name: "custom.broken", Error: `template: custom.tmpl:2:40: executing "custom.broken" at <{{template "not.existing" .}}>: template "not.existing" not defined`},
+					{Name: "custom.title", Output: "[firing] HighLatency"},
+				},
+			}},
+		},
+		"should return all the matching routes and run the firewall checks": {
+			body: cfg + `
+labels:
+  alertname: HighLatency
+  cluster: eu
+  team: a
+`,
+			expectedStatus: http.StatusOK,
+			expectedRoutes: []PreviewRoute{{
+				Receiver:       "team-a",
+				GroupBy:        []string{"alertname", "cluster"},
+				GroupLabels:    map[string]string{"alertname": "HighLatency", "cluster": "eu"},
+				GroupWait:      model.Duration(10 * time.Second),
+				GroupInterval:  model.Duration(5 * time.Minute),
+				RepeatInterval: model.Duration(4 * time.Hour),
+				Continue:       true,
+				Integrations: []PreviewIntegration{
+					{Name: "webhook", Index: 0, Host: "1.1.1.1"},
+					{Name: "email", Index: 0, Host: "smtp.example.org"},
+				},
+				Templates: []PreviewTemplate{
+					{Name: "custom.broken", Error: `template: custom.tmpl:2:40: executing "custom.broken" at <{{template "not.existing" .}}>: template "not.existing" not defined`},
+					{Name: "custom.title", Output: "[firing] HighLatency"},
+				},
+			}, {
+				Receiver:       "private",
+				GroupBy:        []string{"alertname"},
+				GroupLabels:    map[string]string{"alertname": "HighLatency"},
+				GroupWait:      model.Duration(30 * time.Second),
+				GroupInterval:  model.Duration(5 * time.Minute),
+				RepeatInterval: model.Duration(4 * time.Hour),
+				Integrations: []PreviewIntegration{
+					{Name: "webhook", Index: 0, Host: "127.0.0.1", FirewallError: "blocked address"},
+				},
+				Templates: []PreviewTemplate{
+					{Name: "custom.broken", Error: `template: custom.tmpl:2:40: executing "custom.broken" at <{{template "not.existing" .}}>: template "not.existing" not defined`},
+					{Name: "custom.title", Output: "[firing] HighLatency"},
+				},
+			}},
+		},
+		"should fail if the sample alert has no labels": {
+			body:           cfg,
+			expectedStatus: http.StatusBadRequest,
+		},
+		"should fail if the config is invalid": {
+			body: `
+alertmanager_config: |
+  route:
+    receiver: 'not-existing'
+labels:
+  alertname: HighLatency
+`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	am := &MultitenantAlertmanager{
+		cfg:    mockAlertmanagerConfig(t),
+		store:  prepareInMemoryAlertStore(),
+		logger: util_log.Logger,
+		limits: &mockAlertManagerLimits{blockPrivateAddresses: true},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "http://alertmanager/api/v1/alerts/preview", bytes.NewReader([]byte(testData.body)))
+			req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+			w := httptest.NewRecorder()
+			am.PreviewUserConfig(w, req)
+
+			resp := w.Result()
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, testData.expectedStatus, resp.StatusCode, string(body))
+
+			if testData.expectedStatus != http.StatusOK {
+				return
+			}
+
+			res := PreviewResponse{}
+			require.NoError(t, json.Unmarshal(body, &res))
+			assert.Equal(t, testData.expectedRoutes, res.Routes)
+		})
+	}
+}
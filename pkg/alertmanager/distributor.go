@@ -77,7 +77,8 @@ func (d *Distributor) isQuorumWritePath(p string) bool {
 }
 
 func (d *Distributor) isUnaryWritePath(p string) bool {
-	return strings.HasSuffix(p, "/silences")
+	return strings.HasSuffix(p, "/silences") ||
+		strings.HasSuffix(p, testReceiverPath)
 }
 
 func (d *Distributor) isUnaryDeletePath(p string) bool {
@@ -85,6 +86,9 @@ func (d *Distributor) isUnaryDeletePath(p string) bool {
 }
 
 func (d *Distributor) isQuorumReadPath(p string) (bool, merger.Merger) {
+	if strings.HasSuffix(p, historyPath) {
+		return true, merger.V1AlertsHistory{}
+	}
 	if strings.HasSuffix(p, "/v1/alerts") {
 		return true, merger.V1Alerts{}
 	}
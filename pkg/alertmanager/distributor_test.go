@@ -80,6 +80,16 @@ func TestDistributor_DistributeRequest(t *testing.T) {
 			expectedTotalCalls: 3,
 			route:              "/v1/alerts",
 			responseBody:       []byte(`{"status":"success","data":[]}`),
+		}, {
+			name:               "Read /api/v1/alerts/history is sent to 3 AMs",
+			numAM:              5,
+			numHappyAM:         5,
+			replicationFactor:  3,
+			isRead:             true,
+			expStatusCode:      http.StatusOK,
+			expectedTotalCalls: 3,
+			route:              "/api/v1/alerts/history",
+			responseBody:       []byte(`{"status":"success","data":{"events":[],"limit":100}}`),
 		}, {
 			name:               "Read /v2/alerts is sent to 3 AMs",
 			numAM:              5,
@@ -146,6 +156,14 @@ func TestDistributor_DistributeRequest(t *testing.T) {
 			expStatusCode:      http.StatusOK,
 			expectedTotalCalls: 1,
 			route:              "/silences",
+		}, {
+			name:               "Write /api/v1/receivers/test is sent to only 1 AM",
+			numAM:              5,
+			numHappyAM:         5,
+			replicationFactor:  3,
+			expStatusCode:      http.StatusOK,
+			expectedTotalCalls: 1,
+			route:              "/api/v1/receivers/test",
 		}, {
 			name:               "Read /v1/silence/id is sent to 3 AMs",
 			numAM:              5,
@@ -282,12 +300,14 @@ func TestDistributor_IsPathSupported(t *testing.T) {
 	supported := map[string]bool{
 		"/alertmanager/api/v1/alerts":           true,
 		"/alertmanager/api/v1/alerts/groups":    false,
+		"/alertmanager/api/v1/alerts/history":   true,
 		"/alertmanager/api/v1/silences":         true,
 		"/alertmanager/api/v1/silence/id":       true,
 		"/alertmanager/api/v1/silence/anything": true,
 		"/alertmanager/api/v1/silence/really":   true,
 		"/alertmanager/api/v1/status":           true,
 		"/alertmanager/api/v1/receivers":        true,
+		"/alertmanager/api/v1/receivers/test":   true,
 		"/alertmanager/api/v1/other":            false,
 		"/alertmanager/api/v2/alerts":           true,
 		"/alertmanager/api/v2/alerts/groups":    true,
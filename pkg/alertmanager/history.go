@@ -0,0 +1,570 @@
+package alertmanager
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/alertmanager/provider/mem"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertstore"
+	"github.com/cortexproject/cortex/pkg/util"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+const (
+	// historyPath is the path, relative to the Alertmanager external URL, of the endpoint
+	// used to query the alerts history.
+	historyPath = "/api/v1/alerts/history"
+
+	defaultHistoryQueryRange = 24 * time.Hour
+	defaultHistoryQueryLimit = 100
+	maxHistoryQueryLimit     = 1000
+
+	historyStoreTimeout    = 30 * time.Second
+	historyCleanupInterval = time.Hour
+
+	// maxHistoryPendingEvents is the max number of alert events, and of notified events, kept
+	// in memory when flushing them fails. The oldest events are dropped once exceeded.
+	maxHistoryPendingEvents = 10000
+)
+
+var (
+	errInvalidHistoryFlushInterval = errors.New("invalid alerts history flush interval, must be greater than zero")
+	errInvalidHistoryRetention     = errors.New("invalid alerts history retention, must be greater than zero")
+	errHistoryDisabled             = errors.New("the alerts history is disabled")
+)
+
+// HistoryConfig configures the alerts history.
+type HistoryConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	Retention     time.Duration `yaml:"retention"`
+}
+
+func (cfg *HistoryConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+".alerts-history.enabled", false, "Enable recording the firing, resolved and notified events of alerts to the object storage, and querying them through the alerts history API. Requires the Alertmanager storage to be backed by an object storage.")
+	f.DurationVar(&cfg.FlushInterval, prefix+".alerts-history.flush-interval", 5*time.Minute, "The interval between flushing the recorded alerts history events to the object storage. Events not flushed yet are queryable from the replica which recorded them.")
+	f.DurationVar(&cfg.Retention, prefix+".alerts-history.retention", 7*24*time.Hour, "How long to keep the alerts history events in the object storage.")
+}
+
+func (cfg *HistoryConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.FlushInterval <= 0 {
+		return errInvalidHistoryFlushInterval
+	}
+	if cfg.Retention <= 0 {
+		return errInvalidHistoryRetention
+	}
+	return nil
+}
+
+// historyRecorder records the alerts history events of a tenant, and periodically flushes them
+// to the object storage as segments. Firing and resolved events are received by all replicas,
+// so they're only flushed by the replica at position zero, while notified events are flushed by
+// the replica which sent the notification.
+type historyRecorder struct {
+	services.Service
+
+	cfg    HistoryConfig
+	userID string
+	state  State
+	store  alertstore.AlertStore
+	logger log.Logger
+
+	mtx sync.Mutex
+	// Whether the last event recorded for each alert was a resolved one.
+	resolved map[model.Fingerprint]bool
+	// Events not flushed yet to the object storage.
+	alertEvents  []alertspb.HistoryEvent
+	notifyEvents []alertspb.HistoryEvent
+
+	lastCleanup time.Time
+
+	// The max number of events of each kind kept in memory when flushing them fails.
+	maxPendingEvents int
+
+	eventsTotal  *prometheus.CounterVec
+	flushTotal   prometheus.Counter
+	flushFailed  prometheus.Counter
+	flushedTotal prometheus.Counter
+	droppedTotal prometheus.Counter
+}
+
+func newHistoryRecorder(cfg HistoryConfig, userID string, state State, store alertstore.AlertStore, l log.Logger, r prometheus.Registerer) *historyRecorder {
+	h := &historyRecorder{
+		cfg:              cfg,
+		userID:           userID,
+		state:            state,
+		store:            store,
+		logger:           l,
+		resolved:         map[model.Fingerprint]bool{},
+		maxPendingEvents: maxHistoryPendingEvents,
+		eventsTotal: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Name: "alertmanager_alerts_history_events_total",
+			Help: "Number of alerts history events recorded.",
+		}, []string{"type"}),
+		flushTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_alerts_history_flush_total",
+			Help: "Number of times we have tried to flush the alerts history to remote storage.",
+		}),
+		flushFailed: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_alerts_history_flush_failed_total",
+			Help: "Number of times we have failed to flush the alerts history to remote storage.",
+		}),
+		flushedTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_alerts_history_flushed_events_total",
+			Help: "Number of alerts history events flushed to remote storage.",
+		}),
+		droppedTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_alerts_history_dropped_events_total",
+			Help: "Number of alerts history events dropped because they failed to be flushed to remote storage for too long.",
+		}),
+	}
+
+	h.Service = services.NewTimerService(cfg.FlushInterval, nil, h.iteration, h.stopping)
+
+	return h
+}
+
+func (h *historyRecorder) iteration(ctx context.Context) error {
+	if err := h.flush(ctx); err != nil {
+		level.Error(h.logger).Log("msg", "failed to flush alerts history", "user", h.userID, "err", err)
+	}
+
+	if time.Since(h.lastCleanup) >= historyCleanupInterval {
+		if err := h.cleanup(ctx); err != nil {
+			level.Warn(h.logger).Log("msg", "failed to clean up alerts history", "user", h.userID, "err", err)
+		}
+		h.lastCleanup = time.Now()
+	}
+	return nil
+}
+
+func (h *historyRecorder) stopping(_ error) error {
+	// Flush the events recorded since the last iteration, so that they're not lost.
+	if err := h.flush(context.Background()); err != nil {
+		level.Warn(h.logger).Log("msg", "failed to flush alerts history while stopping", "user", h.userID, "err", err)
+	}
+	return nil
+}
+
+// PreStore implements mem.AlertStoreCallback.
+func (h *historyRecorder) PreStore(_ *types.Alert, _ bool) error {
+	return nil
+}
+
+// PostStore implements mem.AlertStoreCallback.
+func (h *historyRecorder) PostStore(alert *types.Alert, _ bool) {
+	if alert == nil {
+		return
+	}
+
+	fp := alert.Fingerprint()
+	resolved := alert.Resolved()
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	wasResolved, known := h.resolved[fp]
+	if known && wasResolved == resolved {
+		return
+	}
+	h.resolved[fp] = resolved
+
+	if resolved {
+		h.addAlertEvent(alertspb.HistoryEventResolved, alert.EndsAt, alert)
+	} else {
+		h.addAlertEvent(alertspb.HistoryEventFiring, alert.StartsAt, alert)
+	}
+}
+
+// PostDelete implements mem.AlertStoreCallback. Alerts are deleted once resolved, so a resolved
+// event is recorded for alerts which expired without being explicitly resolved.
+func (h *historyRecorder) PostDelete(alert *types.Alert) {
+	if alert == nil {
+		return
+	}
+
+	fp := alert.Fingerprint()
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if wasResolved, known := h.resolved[fp]; known && !wasResolved {
+		h.addAlertEvent(alertspb.HistoryEventResolved, alert.EndsAt, alert)
+	}
+	delete(h.resolved, fp)
+}
+
+// addAlertEvent must be called with the lock held.
+func (h *historyRecorder) addAlertEvent(typ string, ts time.Time, alert *types.Alert) {
+	h.alertEvents = append(h.alertEvents, alertspb.HistoryEvent{
+		Type:        typ,
+		Timestamp:   ts,
+		Fingerprint: alert.Fingerprint().String(),
+		Labels:      labelSetToMap(alert.Labels),
+	})
+	h.eventsTotal.WithLabelValues(typ).Inc()
+}
+
+// recordNotified records a notified event for each of the input alerts, which have been
+// successfully sent through the given integration.
+func (h *historyRecorder) recordNotified(ctx context.Context, integration string, alerts []*types.Alert) {
+	receiver, _ := notify.ReceiverName(ctx)
+	now := time.Now()
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	for _, alert := range alerts {
+		h.notifyEvents = append(h.notifyEvents, alertspb.HistoryEvent{
+			Type:        alertspb.HistoryEventNotified,
+			Timestamp:   now,
+			Fingerprint: alert.Fingerprint().String(),
+			Labels:      labelSetToMap(alert.Labels),
+			Receiver:    receiver,
+			Integration: integration,
+		})
+	}
+	h.eventsTotal.WithLabelValues(alertspb.HistoryEventNotified).Add(float64(len(alerts)))
+}
+
+// wrapNotifier returns a notifier recording a notified event for each alert successfully sent through it.
+func (h *historyRecorder) wrapNotifier(integration string, notifier notify.Notifier) notify.Notifier {
+	return &historyNotifier{Notifier: notifier, recorder: h, integration: integration}
+}
+
+// pendingEvents returns a copy of the events not flushed yet.
+func (h *historyRecorder) pendingEvents() []alertspb.HistoryEvent {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	events := make([]alertspb.HistoryEvent, 0, len(h.alertEvents)+len(h.notifyEvents))
+	events = append(events, h.alertEvents...)
+	return append(events, h.notifyEvents...)
+}
+
+func (h *historyRecorder) flush(ctx context.Context) (err error) {
+	h.mtx.Lock()
+	alertEvents, notifyEvents := h.alertEvents, h.notifyEvents
+	h.alertEvents, h.notifyEvents = nil, nil
+	h.mtx.Unlock()
+
+	// Only the replica at position zero should write the firing and resolved events.
+	events := notifyEvents
+	if h.state.Position() == 0 {
+		events = append(alertEvents, notifyEvents...)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	h.flushTotal.Inc()
+	defer func() {
+		if err != nil {
+			h.flushFailed.Inc()
+
+			// Keep the events for the next flush, up to the max number of pending events.
+			h.mtx.Lock()
+			h.alertEvents = h.truncatePendingEvents(append(alertEvents, h.alertEvents...))
+			h.notifyEvents = h.truncatePendingEvents(append(notifyEvents, h.notifyEvents...))
+			h.mtx.Unlock()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, historyStoreTimeout)
+	defer cancel()
+
+	segment := newHistorySegmentName(events)
+	if err = h.store.SetHistorySegment(ctx, h.userID, segment, events); err != nil {
+		return err
+	}
+
+	h.flushedTotal.Add(float64(len(events)))
+	level.Debug(h.logger).Log("msg", "flushed alerts history", "user", h.userID, "segment", segment, "events", len(events))
+	return nil
+}
+
+// truncatePendingEvents drops the oldest of the input events exceeding the max number of
+// pending events. It must be called with the lock held.
+func (h *historyRecorder) truncatePendingEvents(events []alertspb.HistoryEvent) []alertspb.HistoryEvent {
+	dropped := len(events) - h.maxPendingEvents
+	if dropped <= 0 {
+		return events
+	}
+
+	h.droppedTotal.Add(float64(dropped))
+	level.Warn(h.logger).Log("msg", "dropped alerts history events not flushed", "user", h.userID, "events", dropped)
+	return events[dropped:]
+}
+
+// cleanup deletes the segments whose events are all older than the retention period.
+func (h *historyRecorder) cleanup(ctx context.Context) error {
+	// Only the replica at position zero should clean up the storage.
+	if h.state.Position() != 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, historyStoreTimeout)
+	defer cancel()
+
+	segments, err := h.store.ListHistorySegments(ctx, h.userID)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(-h.cfg.Retention)
+	for _, segment := range segments {
+		_, maxTime, ok := parseHistorySegmentName(segment)
+		if !ok || !maxTime.Before(deadline) {
+			continue
+		}
+
+		if err := h.store.DeleteHistorySegment(ctx, h.userID, segment); err != nil {
+			return err
+		}
+		level.Debug(h.logger).Log("msg", "deleted alerts history segment", "user", h.userID, "segment", segment)
+	}
+
+	return nil
+}
+
+// deleteHistory deletes all the alerts history segments of the given user.
+func deleteHistory(ctx context.Context, store alertstore.AlertStore, userID string) error {
+	segments, err := store.ListHistorySegments(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, segment := range segments {
+		if err := store.DeleteHistorySegment(ctx, userID, segment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// query returns the events matching the input matchers and time range, both from the
+// object storage and from the events not flushed yet.
+func (h *historyRecorder) query(ctx context.Context, matchers []*labels.Matcher, start, end time.Time) ([]alertspb.HistoryEvent, error) {
+	segments, err := h.store.ListHistorySegments(ctx, h.userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []alertspb.HistoryEvent
+	for _, segment := range segments {
+		minTime, maxTime, ok := parseHistorySegmentName(segment)
+		if !ok || maxTime.Before(start) || minTime.After(end) {
+			continue
+		}
+
+		events, err := h.store.GetHistorySegment(ctx, h.userID, segment)
+		if errors.Is(err, alertspb.ErrNotFound) {
+			// The segment has been deleted in the meanwhile.
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, events...)
+	}
+	candidates = append(candidates, h.pendingEvents()...)
+
+	events := make([]alertspb.HistoryEvent, 0, len(candidates))
+	for _, e := range candidates {
+		if e.Timestamp.Before(start) || e.Timestamp.After(end) || !matchesHistoryEvent(matchers, e) {
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+func matchesHistoryEvent(matchers []*labels.Matcher, e alertspb.HistoryEvent) bool {
+	for _, m := range matchers {
+		if !m.Matches(e.Labels[m.Name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// newHistorySegmentName returns a unique segment name, containing the min and max timestamp
+// (in milliseconds) of the input events so that segments can be filtered without reading them.
+func newHistorySegmentName(events []alertspb.HistoryEvent) string {
+	minTime, maxTime := events[0].Timestamp, events[0].Timestamp
+	for _, e := range events[1:] {
+		if e.Timestamp.Before(minTime) {
+			minTime = e.Timestamp
+		}
+		if e.Timestamp.After(maxTime) {
+			maxTime = e.Timestamp
+		}
+	}
+
+	id := ulid.MustNew(ulid.Now(), rand.New(rand.NewSource(time.Now().UnixNano())))
+	return fmt.Sprintf("%020d-%020d-%s", util.TimeToMillis(minTime), util.TimeToMillis(maxTime), id.String())
+}
+
+func parseHistorySegmentName(segment string) (minTime, maxTime time.Time, ok bool) {
+	parts := strings.SplitN(segment, "-", 3)
+	if len(parts) != 3 {
+		return time.Time{}, time.Time{}, false
+	}
+
+	minMs, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	maxMs, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	return util.TimeFromMillis(minMs), util.TimeFromMillis(maxMs), true
+}
+
+// historyNotifier wraps a notifier to record the alerts history notified events.
+type historyNotifier struct {
+	notify.Notifier
+
+	recorder    *historyRecorder
+	integration string
+}
+
+func (n *historyNotifier) Notify(ctx context.Context, alerts ...*types.Alert) (bool, error) {
+	retry, err := n.Notifier.Notify(ctx, alerts...)
+
+	// The test notifications aren't part of the tenant's alerts history.
+	if err == nil && !isTestNotification(ctx) {
+		n.recorder.recordNotified(ctx, n.integration, alerts)
+	}
+	return retry, err
+}
+
+// alertStoreCallbacks chains multiple mem.AlertStoreCallback. PreStore fails on the first error.
+type alertStoreCallbacks []mem.AlertStoreCallback
+
+func (c alertStoreCallbacks) PreStore(alert *types.Alert, existing bool) error {
+	for _, callback := range c {
+		if err := callback.PreStore(alert, existing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c alertStoreCallbacks) PostStore(alert *types.Alert, existing bool) {
+	for _, callback := range c {
+		callback.PostStore(alert, existing)
+	}
+}
+
+func (c alertStoreCallbacks) PostDelete(alert *types.Alert) {
+	for _, callback := range c {
+		callback.PostDelete(alert)
+	}
+}
+
+// AlertsHistoryHandler returns a page of the alerts history events matching the input
+// label matchers and time range, newest first.
+func (am *Alertmanager) AlertsHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if am.history == nil {
+		http.Error(w, errHistoryDisabled.Error(), http.StatusNotFound)
+		return
+	}
+
+	params := r.URL.Query()
+
+	var matchers []*labels.Matcher
+	for _, filter := range params["filter"] {
+		parsed, err := labels.ParseMatchers(filter)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid filter: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		matchers = append(matchers, parsed...)
+	}
+
+	end := time.Now()
+	if v := params.Get("end"); v != "" {
+		t, err := util.ParseTime(v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid end: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		end = util.TimeFromMillis(t)
+	}
+
+	start := end.Add(-defaultHistoryQueryRange)
+	if v := params.Get("start"); v != "" {
+		t, err := util.ParseTime(v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid start: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		start = util.TimeFromMillis(t)
+	}
+
+	if end.Before(start) {
+		http.Error(w, "end timestamp must not be before start time", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultHistoryQueryLimit
+	if v := params.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 || l > maxHistoryQueryLimit {
+			http.Error(w, fmt.Sprintf("invalid limit, must be between 1 and %d", maxHistoryQueryLimit), http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+
+	events, err := am.history.query(r.Context(), matchers, start, end)
+	if err != nil {
+		level.Error(util_log.WithContext(r.Context(), am.logger)).Log("msg", "failed to query alerts history", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res, err := alertspb.MergeHistoryEvents(events, limit, params.Get("next_token"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	util.WriteJSONResponse(w, struct {
+		Status string                 `json:"status"`
+		Data   alertspb.HistoryResult `json:"data"`
+	}{
+		Status: "success",
+		Data:   res,
+	})
+}
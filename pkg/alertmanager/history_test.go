@@ -0,0 +1,272 @@
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertstore"
+)
+
+type failingNotifier struct{}
+
+func (n *failingNotifier) Notify(_ context.Context, _ ...*types.Alert) (bool, error) {
+	return false, errors.New("failed")
+}
+
+// failingHistoryStore is an alert store failing to store the alerts history segments.
+type failingHistoryStore struct {
+	alertstore.AlertStore
+}
+
+func (s *failingHistoryStore) SetHistorySegment(_ context.Context, _, _ string, _ []alertspb.HistoryEvent) error {
+	return errors.New("failed")
+}
+
+func TestHistoryRecorder(t *testing.T) {
+	ctx := context.Background()
+	store := prepareInMemoryAlertStore()
+	cfg := HistoryConfig{Enabled: true, FlushInterval: time.Minute, Retention: time.Hour}
+	h := newHistoryRecorder(cfg, "user-1", &NilPeer{}, store, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+	now := time.Now()
+	newAlert := func(name string, startsAt, endsAt time.Time) *types.Alert {
+		return &types.Alert{Alert: model.Alert{
+			Labels:   model.LabelSet{model.AlertNameLabel: model.LabelValue(name)},
+			StartsAt: startsAt,
+			EndsAt:   endsAt,
+		}}
+	}
+
+	// Alert "one" fires, gets updated while firing, and then is resolved.
+	one := newAlert("one", now.Add(-10*time.Minute), now.Add(time.Hour))
+	h.PostStore(one, false)
+	h.PostStore(one, true)
+	h.PostStore(newAlert("one", now.Add(-10*time.Minute), now.Add(-time.Minute)), true)
+
+	// Alert "two" fires and expires without being explicitly resolved.
+	two := newAlert("two", now.Add(-5*time.Minute), now.Add(-2*time.Minute))
+	h.PostStore(newAlert("two", now.Add(-5*time.Minute), now.Add(time.Hour)), false)
+	h.PostDelete(two)
+
+	// Alert "one" is notified successfully, while the notification of "two" fails.
+	notifyCtx := notify.WithReceiverName(ctx, "default")
+	_, err := h.wrapNotifier("webhook", &mockNotifier{}).Notify(notifyCtx, one)
+	require.NoError(t, err)
+	_, err = h.wrapNotifier("webhook", &failingNotifier{}).Notify(notifyCtx, two)
+	require.Error(t, err)
+
+	// Test notifications aren't recorded.
+	_, err = h.wrapNotifier("webhook", &mockNotifier{}).Notify(withTestNotification(notifyCtx), two)
+	require.NoError(t, err)
+
+	queryAll := func() []alertspb.HistoryEvent {
+		events, err := h.query(ctx, nil, now.Add(-time.Hour), now.Add(time.Hour))
+		require.NoError(t, err)
+
+		res, err := alertspb.MergeHistoryEvents(events, 100, "")
+		require.NoError(t, err)
+		return res.Events
+	}
+
+	assertEvents := func(events []alertspb.HistoryEvent) {
+		require.Len(t, events, 5)
+		assert.Equal(t, alertspb.HistoryEventNotified, events[0].Type)
+		assert.Equal(t, "one", events[0].Labels["alertname"])
+		assert.Equal(t, "default", events[0].Receiver)
+		assert.Equal(t, "webhook", events[0].Integration)
+		assert.Equal(t, alertspb.HistoryEventResolved, events[1].Type)
+		assert.Equal(t, "one", events[1].Labels["alertname"])
+		assert.Equal(t, alertspb.HistoryEventResolved, events[2].Type)
+		assert.Equal(t, "two", events[2].Labels["alertname"])
+		assert.Equal(t, alertspb.HistoryEventFiring, events[3].Type)
+		assert.Equal(t, "two", events[3].Labels["alertname"])
+		assert.Equal(t, alertspb.HistoryEventFiring, events[4].Type)
+		assert.Equal(t, "one", events[4].Labels["alertname"])
+	}
+
+	// Events are queryable before being flushed.
+	assertEvents(queryAll())
+
+	// Events are queryable from the storage once flushed.
+	require.NoError(t, h.flush(ctx))
+	assert.Empty(t, h.pendingEvents())
+
+	segments, err := store.ListHistorySegments(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	assertEvents(queryAll())
+
+	// Segments older than the retention are deleted.
+	h.cfg.Retention = time.Nanosecond
+	require.NoError(t, h.cleanup(ctx))
+
+	segments, err = store.ListHistorySegments(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Empty(t, segments)
+}
+
+func TestHistoryRecorder_FailedFlushKeepsMaxPendingEvents(t *testing.T) {
+	ctx := context.Background()
+	reg := prometheus.NewPedanticRegistry()
+	cfg := HistoryConfig{Enabled: true, FlushInterval: time.Minute, Retention: time.Hour}
+	h := newHistoryRecorder(cfg, "user-1", &NilPeer{}, &failingHistoryStore{prepareInMemoryAlertStore()}, log.NewNopLogger(), reg)
+	h.maxPendingEvents = 3
+
+	now := time.Now()
+	addAlerts := func(names ...string) {
+		for _, name := range names {
+			h.PostStore(&types.Alert{Alert: model.Alert{
+				Labels:   model.LabelSet{model.AlertNameLabel: model.LabelValue(name)},
+				StartsAt: now,
+				EndsAt:   now.Add(time.Hour),
+			}}, false)
+		}
+	}
+
+	// The events are kept when the flush fails.
+	addAlerts("one", "two")
+	require.Error(t, h.flush(ctx))
+	require.Len(t, h.pendingEvents(), 2)
+
+	// The oldest events are dropped once the max number of pending events is exceeded.
+	addAlerts("three", "four")
+	require.Error(t, h.flush(ctx))
+
+	events := h.pendingEvents()
+	require.Len(t, events, 3)
+	assert.Equal(t, "two", events[0].Labels["alertname"])
+	assert.Equal(t, "three", events[1].Labels["alertname"])
+	assert.Equal(t, "four", events[2].Labels["alertname"])
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP alertmanager_alerts_history_dropped_events_total Number of alerts history events dropped because they failed to be flushed to remote storage for too long.
+		# TYPE alertmanager_alerts_history_dropped_events_total counter
+		alertmanager_alerts_history_dropped_events_total 1
+		# HELP alertmanager_alerts_history_flush_failed_total Number of times we have failed to flush the alerts history to remote storage.
+		# TYPE alertmanager_alerts_history_flush_failed_total counter
+		alertmanager_alerts_history_flush_failed_total 2
+	`), "alertmanager_alerts_history_dropped_events_total", "alertmanager_alerts_history_flush_failed_total"))
+}
+
+func TestDeleteHistory(t *testing.T) {
+	ctx := context.Background()
+	store := prepareInMemoryAlertStore()
+	events := []alertspb.HistoryEvent{{Type: alertspb.HistoryEventFiring, Timestamp: time.Now(), Fingerprint: "a"}}
+
+	require.NoError(t, store.SetHistorySegment(ctx, "user-1", "segment-1", events))
+	require.NoError(t, store.SetHistorySegment(ctx, "user-1", "segment-2", events))
+	require.NoError(t, store.SetHistorySegment(ctx, "user-2", "segment-1", events))
+
+	require.NoError(t, deleteHistory(ctx, store, "user-1"))
+
+	segments, err := store.ListHistorySegments(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Empty(t, segments)
+
+	segments, err = store.ListHistorySegments(ctx, "user-2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"segment-1"}, segments)
+}
+
+func TestAlertmanager_AlertsHistoryHandler(t *testing.T) {
+	ctx := context.Background()
+	store := prepareInMemoryAlertStore()
+	cfg := HistoryConfig{Enabled: true, FlushInterval: time.Minute, Retention: time.Hour}
+	h := newHistoryRecorder(cfg, "user-1", &NilPeer{}, store, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+	base := time.Unix(1000, 0)
+	for i, name := range []string{"one", "two", "three"} {
+		h.PostStore(&types.Alert{Alert: model.Alert{
+			Labels:   model.LabelSet{model.AlertNameLabel: model.LabelValue(name), "team": "a"},
+			StartsAt: base.Add(time.Duration(i) * time.Minute),
+			EndsAt:   time.Now().Add(time.Hour),
+		}}, false)
+	}
+	require.NoError(t, h.flush(ctx))
+
+	am := &Alertmanager{history: h, logger: log.NewNopLogger()}
+
+	query := func(params url.Values) (int, alertspb.HistoryResult) {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost/api/prom"+historyPath+"?"+params.Encode(), nil)
+		w := httptest.NewRecorder()
+		am.AlertsHistoryHandler(w, req)
+
+		res := struct {
+			Status string                 `json:"status"`
+			Data   alertspb.HistoryResult `json:"data"`
+		}{}
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			assert.Equal(t, "success", res.Status)
+		}
+		return w.Code, res.Data
+	}
+
+	names := func(res alertspb.HistoryResult) []string {
+		var out []string
+		for _, e := range res.Events {
+			out = append(out, e.Labels["alertname"])
+		}
+		return out
+	}
+
+	t.Run("should paginate the events newest first", func(t *testing.T) {
+		params := url.Values{"start": {"0"}, "end": {"2000"}, "limit": {"2"}}
+		code, res := query(params)
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{"three", "two"}, names(res))
+		require.NotEmpty(t, res.NextToken)
+
+		params.Set("next_token", res.NextToken)
+		code, res = query(params)
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{"one"}, names(res))
+		assert.Empty(t, res.NextToken)
+	})
+
+	t.Run("should filter the events by label matchers and time range", func(t *testing.T) {
+		code, res := query(url.Values{"start": {"0"}, "end": {"2000"}, "filter": {`{alertname=~"one|two"}`}})
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{"two", "one"}, names(res))
+
+		code, res = query(url.Values{"start": {"1030"}, "end": {"1090"}})
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{"two"}, names(res))
+	})
+
+	t.Run("should fail on invalid parameters", func(t *testing.T) {
+		for _, params := range []url.Values{
+			{"limit": {"0"}},
+			{"start": {"2000"}, "end": {"1000"}},
+			{"filter": {"{invalid"}},
+			{"next_token": {"invalid!"}},
+		} {
+			code, _ := query(params)
+			assert.Equal(t, http.StatusBadRequest, code, params.Encode())
+		}
+	})
+
+	t.Run("should return 404 if the alerts history is disabled", func(t *testing.T) {
+		am := &Alertmanager{logger: log.NewNopLogger()}
+		w := httptest.NewRecorder()
+		am.AlertsHistoryHandler(w, httptest.NewRequest(http.MethodGet, "http://localhost"+historyPath, nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
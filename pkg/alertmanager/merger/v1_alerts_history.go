@@ -0,0 +1,44 @@
+package merger
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+)
+
+// V1AlertsHistory implements the Merger interface for GET /v1/alerts/history. It returns the
+// sorted and deduplicated union of the events over all the responses, truncated to the lowest
+// page limit across the responses.
+type V1AlertsHistory struct{}
+
+func (V1AlertsHistory) MergeResponses(in [][]byte) ([]byte, error) {
+	type bodyType struct {
+		Status string                 `json:"status"`
+		Data   alertspb.HistoryResult `json:"data"`
+	}
+
+	results := make([]alertspb.HistoryResult, 0, len(in))
+	for _, body := range in {
+		parsed := bodyType{}
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			return nil, err
+		}
+		if parsed.Status != statusSuccess {
+			return nil, fmt.Errorf("unable to merge response of status: %s", parsed.Status)
+		}
+		results = append(results, parsed.Data)
+	}
+
+	merged, err := alertspb.MergeHistoryResults(results)
+	if err != nil {
+		return nil, err
+	}
+
+	body := bodyType{
+		Status: statusSuccess,
+		Data:   merged,
+	}
+
+	return json.Marshal(body)
+}
@@ -0,0 +1,50 @@
+package merger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestV1AlertsHistory(t *testing.T) {
+	in := [][]byte{
+		[]byte(`{"status":"success","data":{"events":[` +
+			`{"type":"notified","timestamp":"2021-04-28T17:35:00Z","fingerprint":"a","labels":{"alertname":"one"},"receiver":"default","integration":"webhook"},` +
+			`{"type":"firing","timestamp":"2021-04-28T17:30:00Z","fingerprint":"a","labels":{"alertname":"one"}}` +
+			`],"limit":2}}`),
+		[]byte(`{"status":"success","data":{"events":[` +
+			`{"type":"firing","timestamp":"2021-04-28T17:32:00Z","fingerprint":"b","labels":{"alertname":"two"}},` +
+			`{"type":"firing","timestamp":"2021-04-28T17:30:00Z","fingerprint":"a","labels":{"alertname":"one"}}` +
+			`],"limit":2}}`),
+		[]byte(`{"status":"success","data":{"events":[],"limit":2}}`),
+	}
+
+	// The merged page is truncated to the limit, so the next token is set.
+	expected := []byte(`{"status":"success","data":{"events":[` +
+		`{"type":"notified","timestamp":"2021-04-28T17:35:00Z","fingerprint":"a","labels":{"alertname":"one"},"receiver":"default","integration":"webhook"},` +
+		`{"type":"firing","timestamp":"2021-04-28T17:32:00Z","fingerprint":"b","labels":{"alertname":"two"}}` +
+		`],"limit":2,"next_token":"MTYxOTYzMTEyMDAwMDAwMDAwMHxmaXJpbmd8Ynx8"}}`)
+
+	out, err := V1AlertsHistory{}.MergeResponses(in)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(out))
+}
+
+func TestV1AlertsHistory_Deduplicates(t *testing.T) {
+	in := [][]byte{
+		[]byte(`{"status":"success","data":{"events":[` +
+			`{"type":"firing","timestamp":"2021-04-28T17:30:00Z","fingerprint":"a","labels":{"alertname":"one"}}` +
+			`],"limit":100}}`),
+		[]byte(`{"status":"success","data":{"events":[` +
+			`{"type":"firing","timestamp":"2021-04-28T17:30:00Z","fingerprint":"a","labels":{"alertname":"one"}}` +
+			`],"limit":100}}`),
+	}
+
+	expected := []byte(`{"status":"success","data":{"events":[` +
+		`{"type":"firing","timestamp":"2021-04-28T17:30:00Z","fingerprint":"a","labels":{"alertname":"one"}}` +
+		`],"limit":100}}`)
+
+	out, err := V1AlertsHistory{}.MergeResponses(in)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(out))
+}
@@ -60,6 +60,7 @@ var (
 	errInvalidExternalURL                  = errors.New("the configured external URL is invalid: should not end with /")
 	errShardingLegacyStorage               = errors.New("deprecated -alertmanager.storage.* not supported with -alertmanager.sharding-enabled, use -alertmanager-storage.*")
 	errShardingUnsupportedStorage          = errors.New("the configured alertmanager storage backend is not supported when sharding is enabled")
+	errHistoryUnsupportedStorage           = errors.New("the configured alertmanager storage backend is not supported when the alerts history is enabled")
 	errZoneAwarenessEnabledWithoutZoneInfo = errors.New("the configured alertmanager has zone awareness enabled but zone is not set")
 )
 
@@ -88,6 +89,11 @@ type MultitenantAlertmanagerConfig struct {
 
 	// For the state persister.
 	Persister PersisterConfig `yaml:",inline"`
+
+	AlertsHistory HistoryConfig `yaml:"alerts_history"`
+
+	// SharedConfigFn returns the receivers and templates shared by all tenants, if any.
+	SharedConfigFn func() *SharedConfig `yaml:"-"`
 }
 
 type ClusterConfig struct {
@@ -122,6 +128,7 @@ func (cfg *MultitenantAlertmanagerConfig) RegisterFlags(f *flag.FlagSet) {
 
 	cfg.AlertmanagerClient.RegisterFlagsWithPrefix("alertmanager.alertmanager-client", f)
 	cfg.Persister.RegisterFlagsWithPrefix("alertmanager", f)
+	cfg.AlertsHistory.RegisterFlagsWithPrefix("alertmanager", f)
 	cfg.ShardingRing.RegisterFlags(f)
 	cfg.Store.RegisterFlags(f)
 	cfg.Cluster.RegisterFlags(f)
@@ -151,6 +158,14 @@ func (cfg *MultitenantAlertmanagerConfig) Validate(storageCfg alertstore.Config)
 		return err
 	}
 
+	if err := cfg.AlertsHistory.Validate(); err != nil {
+		return err
+	}
+
+	if cfg.AlertsHistory.Enabled && (!cfg.Store.IsDefaults() || !storageCfg.IsFullStateSupported()) {
+		return errHistoryUnsupportedStorage
+	}
+
 	if cfg.ShardingEnabled {
 		if !cfg.Store.IsDefaults() {
 			return errShardingLegacyStorage
@@ -161,6 +176,9 @@ func (cfg *MultitenantAlertmanagerConfig) Validate(storageCfg alertstore.Config)
 		if cfg.ShardingRing.ZoneAwarenessEnabled && cfg.ShardingRing.InstanceZone == "" {
 			return errZoneAwarenessEnabledWithoutZoneInfo
 		}
+		if err := cfg.ShardingRing.Validate(); err != nil {
+			return errors.Wrap(err, "invalid sharding ring config")
+		}
 	}
 
 	return nil
@@ -247,6 +265,7 @@ type MultitenantAlertmanager struct {
 	//     -> HandleRequest() (gRPC call) -> grpcServer() -> handlerForGRPCServer.ServeHTTP() -> serveRequest().
 	ringLifecycler *ring.BasicLifecycler
 	ring           *ring.Ring
+	ringStore      kv.Client
 	distributor    *Distributor
 	grpcServer     *server.Server
 
@@ -266,6 +285,8 @@ type MultitenantAlertmanager struct {
 	// Stores the current set of configurations we're running in each tenant's Alertmanager.
 	// Used for comparing configurations as we synchronize them.
 	cfgs map[string]alertspb.AlertConfigDesc
+	// Stores the hash of the shared config applied to each tenant's Alertmanager.
+	sharedCfgHashes map[string]string
 
 	logger              log.Logger
 	alertmanagerMetrics *alertmanagerMetrics
@@ -355,11 +376,13 @@ func createMultitenantAlertmanager(cfg *MultitenantAlertmanagerConfig, fallbackC
 		cfg:                 cfg,
 		fallbackConfig:      string(fallbackConfig),
 		cfgs:                map[string]alertspb.AlertConfigDesc{},
+		sharedCfgHashes:     map[string]string{},
 		alertmanagers:       map[string]*Alertmanager{},
 		alertmanagerMetrics: newAlertmanagerMetrics(),
 		multitenantMetrics:  newMultitenantAlertmanagerMetrics(registerer),
 		peer:                peer,
 		store:               store,
+		ringStore:           ringStore,
 		logger:              log.With(logger, "component", "MultiTenantAlertmanager"),
 		registry:            registerer,
 		limits:              limits,
@@ -724,6 +747,11 @@ func (am *MultitenantAlertmanager) stopping(_ error) error {
 		// subservices manages ring and lifecycler, if sharding was enabled.
 		_ = services.StopManagerAndAwaitStopped(context.Background(), am.subservices)
 	}
+
+	// The KV client is shared by the ring and the lifecycler, so we stop it once both are stopped.
+	if am.ringStore != nil {
+		kv.StopClient(am.ringStore)
+	}
 	return nil
 }
 
@@ -796,6 +824,7 @@ func (am *MultitenantAlertmanager) syncConfigs(cfgs map[string]alertspb.AlertCon
 			userAlertmanagersToStop[userID] = userAM
 			delete(am.alertmanagers, userID)
 			delete(am.cfgs, userID)
+			delete(am.sharedCfgHashes, userID)
 			am.multitenantMetrics.lastReloadSuccessful.DeleteLabelValues(userID)
 			am.multitenantMetrics.lastReloadSuccessfulTimestamp.DeleteLabelValues(userID)
 			am.alertmanagerMetrics.removeUserRegistry(userID)
@@ -818,6 +847,12 @@ func (am *MultitenantAlertmanager) setConfig(cfg alertspb.AlertConfigDesc) error
 	var err error
 	var hasTemplateChanges bool
 
+	shared := am.sharedConfig()
+	hasTemplateChanges, err = storeSharedTemplates(filepath.Join(am.getTenantDirectory(cfg.User), sharedTemplatesDir), shared)
+	if err != nil {
+		return err
+	}
+
 	for _, tmpl := range cfg.Templates {
 		templateFilepath, err := safeTemplateFilepath(filepath.Join(am.getTenantDirectory(cfg.User), templatesDir), tmpl.Filename)
 		if err != nil {
@@ -846,13 +881,13 @@ func (am *MultitenantAlertmanager) setConfig(cfg alertspb.AlertConfigDesc) error
 			return fmt.Errorf("blank Alertmanager configuration for %v", cfg.User)
 		}
 		level.Debug(am.logger).Log("msg", "blank Alertmanager configuration; using fallback", "user", cfg.User)
-		userAmConfig, err = amconfig.Load(am.fallbackConfig)
+		userAmConfig, _, err = loadUserConfig(am.fallbackConfig, shared)
 		if err != nil {
 			return fmt.Errorf("unable to load fallback configuration for %v: %v", cfg.User, err)
 		}
 		rawCfg = am.fallbackConfig
 	} else {
-		userAmConfig, err = amconfig.Load(cfg.RawConfig)
+		userAmConfig, _, err = loadUserConfig(cfg.RawConfig, shared)
 		if err != nil && hasExisting {
 			// This means that if a user has a working config and
 			// they submit a broken one, the Manager will keep running the last known
@@ -869,20 +904,8 @@ func (am *MultitenantAlertmanager) setConfig(cfg alertspb.AlertConfigDesc) error
 		return fmt.Errorf("no usable Alertmanager configuration for %v", cfg.User)
 	}
 
-	// Transform webhook configs URLs to the per tenant monitor
-	if am.cfg.AutoWebhookRoot != "" {
-		for i, r := range userAmConfig.Receivers {
-			for j, w := range r.WebhookConfigs {
-				if w.URL.String() == autoWebhookURL {
-					u, err := url.Parse(am.cfg.AutoWebhookRoot + "/" + cfg.User + "/monitor")
-					if err != nil {
-						return err
-					}
-
-					userAmConfig.Receivers[i].WebhookConfigs[j].URL = &amconfig.URL{URL: u}
-				}
-			}
-		}
+	if err := am.transformConfig(cfg.User, userAmConfig); err != nil {
+		return err
 	}
 
 	// If no Alertmanager instance exists for this user yet, start one.
@@ -893,7 +916,7 @@ func (am *MultitenantAlertmanager) setConfig(cfg alertspb.AlertConfigDesc) error
 			return err
 		}
 		am.alertmanagers[cfg.User] = newAM
-	} else if am.cfgs[cfg.User].RawConfig != cfg.RawConfig || hasTemplateChanges {
+	} else if am.cfgs[cfg.User].RawConfig != cfg.RawConfig || hasTemplateChanges || am.sharedCfgHashes[cfg.User] != shared.Hash() {
 		level.Info(am.logger).Log("msg", "updating new per-tenant alertmanager", "user", cfg.User)
 		// If the config changed, apply the new one.
 		err := existing.ApplyConfig(cfg.User, userAmConfig, rawCfg)
@@ -903,6 +926,37 @@ func (am *MultitenantAlertmanager) setConfig(cfg alertspb.AlertConfigDesc) error
 	}
 
 	am.cfgs[cfg.User] = cfg
+	am.sharedCfgHashes[cfg.User] = shared.Hash()
+	return nil
+}
+
+// sharedConfig returns the receivers and templates shared by all tenants, or nil if there are none.
+func (am *MultitenantAlertmanager) sharedConfig() *SharedConfig {
+	if am.cfg == nil || am.cfg.SharedConfigFn == nil {
+		return nil
+	}
+	return am.cfg.SharedConfigFn()
+}
+
+// transformConfig rewrites the webhook configs URLs matching the autoWebhookURL to the per tenant monitor.
+func (am *MultitenantAlertmanager) transformConfig(userID string, amConfig *amconfig.Config) error {
+	if am.cfg.AutoWebhookRoot == "" {
+		return nil
+	}
+
+	for i, r := range amConfig.Receivers {
+		for j, w := range r.WebhookConfigs {
+			if w.URL.String() == autoWebhookURL {
+				u, err := url.Parse(am.cfg.AutoWebhookRoot + "/" + userID + "/monitor")
+				if err != nil {
+					return err
+				}
+
+				amConfig.Receivers[i].WebhookConfigs[j].URL = &amconfig.URL{URL: u}
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -932,6 +986,7 @@ func (am *MultitenantAlertmanager) newAlertmanager(userID string, amConfig *amco
 		ReplicationFactor: am.cfg.ShardingRing.ReplicationFactor,
 		Store:             am.store,
 		PersisterConfig:   am.cfg.Persister,
+		HistoryConfig:     am.cfg.AlertsHistory,
 		Limits:            am.limits,
 	}, reg)
 	if err != nil {
@@ -1194,6 +1249,14 @@ func (am *MultitenantAlertmanager) deleteUnusedRemoteUserState(ctx context.Conte
 		} else {
 			level.Info(am.logger).Log("msg", "deleted remote state for user", "user", userID)
 		}
+
+		if am.cfg.AlertsHistory.Enabled {
+			if err := deleteHistory(ctx, am.store, userID); err != nil {
+				level.Warn(am.logger).Log("msg", "failed to delete alerts history for user", "user", userID, "err", err)
+			} else {
+				level.Info(am.logger).Log("msg", "deleted alerts history for user", "user", userID)
+			}
+		}
 	}
 }
 
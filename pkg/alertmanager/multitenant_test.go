@@ -153,6 +153,28 @@ func TestMultitenantAlertmanagerConfig_Validate(t *testing.T) {
 			},
 			expected: errShardingLegacyStorage,
 		},
+		"should succeed if alerts history enabled and new storage configuration given with bucket client": {
+			setup: func(t *testing.T, cfg *MultitenantAlertmanagerConfig, storageCfg *alertstore.Config) {
+				cfg.AlertsHistory.Enabled = true
+				storageCfg.Backend = "s3"
+			},
+			expected: nil,
+		},
+		"should fail if alerts history enabled and new storage store configuration given with local type": {
+			setup: func(t *testing.T, cfg *MultitenantAlertmanagerConfig, storageCfg *alertstore.Config) {
+				cfg.AlertsHistory.Enabled = true
+				storageCfg.Backend = "local"
+			},
+			expected: errHistoryUnsupportedStorage,
+		},
+		"should fail if alerts history enabled and flush interval is 0": {
+			setup: func(t *testing.T, cfg *MultitenantAlertmanagerConfig, storageCfg *alertstore.Config) {
+				cfg.AlertsHistory.Enabled = true
+				cfg.AlertsHistory.FlushInterval = 0
+				storageCfg.Backend = "s3"
+			},
+			expected: errInvalidHistoryFlushInterval,
+		},
 		"should fail if zone aware is enabled but zone is not set": {
 			setup: func(t *testing.T, cfg *MultitenantAlertmanagerConfig, storageCfg *alertstore.Config) {
 				cfg.ShardingEnabled = true
@@ -703,6 +725,8 @@ func TestMultitenantAlertmanager_deleteUnusedRemoteUserState(t *testing.T) {
 		// Increase state write interval so that state gets written sooner, making test faster.
 		cfg.Persister.Interval = 500 * time.Millisecond
 
+		cfg.AlertsHistory = HistoryConfig{Enabled: true, FlushInterval: time.Hour, Retention: time.Hour}
+
 		am, err := createMultitenantAlertmanager(cfg, nil, nil, alertStore, ringStore, nil, log.NewLogfmtLogger(os.Stdout), reg)
 		require.NoError(t, err)
 		t.Cleanup(func() {
@@ -757,8 +781,12 @@ func TestMultitenantAlertmanager_deleteUnusedRemoteUserState(t *testing.T) {
 		require.NoError(t, err)
 	}
 
-	// Delete one configuration and trigger cleanup; state for only that user should be deleted.
+	// Delete one configuration and trigger cleanup; state and history for only that user should be deleted.
 	{
+		events := []alertspb.HistoryEvent{{Type: alertspb.HistoryEventFiring, Timestamp: time.Now(), Fingerprint: "a"}}
+		require.NoError(t, alertStore.SetHistorySegment(ctx, user1, "segment-1", events))
+		require.NoError(t, alertStore.SetHistorySegment(ctx, user2, "segment-1", events))
+
 		require.NoError(t, alertStore.DeleteAlertConfig(ctx, user1))
 
 		err := am1.loadAndSyncConfigs(context.Background(), reasonPeriodic)
@@ -770,6 +798,13 @@ func TestMultitenantAlertmanager_deleteUnusedRemoteUserState(t *testing.T) {
 		require.Equal(t, alertspb.ErrNotFound, err)
 		_, err = alertStore.GetFullState(context.Background(), user2)
 		require.NoError(t, err)
+
+		segments, err := alertStore.ListHistorySegments(ctx, user1)
+		require.NoError(t, err)
+		require.Empty(t, segments)
+		segments, err = alertStore.ListHistorySegments(ctx, user2)
+		require.NoError(t, err)
+		require.Equal(t, []string{"segment-1"}, segments)
 	}
 }
 
@@ -2031,6 +2066,8 @@ type mockAlertManagerLimits struct {
 	maxDispatcherAggregationGroups int
 	maxAlertsCount                 int
 	maxAlertsSizeBytes             int
+	blockCIDRNetworks              []flagext.CIDR
+	blockPrivateAddresses          bool
 }
 
 func (m *mockAlertManagerLimits) AlertmanagerMaxConfigSize(tenant string) int {
@@ -2046,11 +2083,11 @@ func (m *mockAlertManagerLimits) AlertmanagerMaxTemplateSize(tenant string) int
 }
 
 func (m *mockAlertManagerLimits) AlertmanagerReceiversBlockCIDRNetworks(user string) []flagext.CIDR {
-	panic("implement me")
+	return m.blockCIDRNetworks
 }
 
 func (m *mockAlertManagerLimits) AlertmanagerReceiversBlockPrivateAddresses(user string) bool {
-	panic("implement me")
+	return m.blockPrivateAddresses
 }
 
 func (m *mockAlertManagerLimits) NotificationRateLimit(_ string, integration string) rate.Limit {
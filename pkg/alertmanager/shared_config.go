@@ -0,0 +1,274 @@
+package alertmanager
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+	amconfig "github.com/prometheus/alertmanager/config"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// sharedTemplatesDir is the directory, within the tenant-specific directory, where the shared templates are stored.
+	sharedTemplatesDir = "shared_templates"
+)
+
+// SharedConfig holds the receivers and templates managed by the operators and shared by all tenants,
+// typically set in the runtime config. Tenants can refer to shared receivers by name in their routes,
+// and to the templates defined in the shared template files, without having access to their content.
+type SharedConfig struct {
+	// Receivers are loaded as if they were defined in an Alertmanager config with the given global
+	// config, so that the tenant's global config never applies to them.
+	Global    *amconfig.GlobalConfig
+	Receivers []*amconfig.Receiver
+
+	// Templates maps the template filename to its content.
+	Templates map[string]string
+
+	// hash of the raw shared config, used to detect changes.
+	hash string
+}
+
+type rawSharedConfig struct {
+	Global    interface{}       `yaml:"global,omitempty"`
+	Receivers []yaml.MapSlice   `yaml:"receivers"`
+	Templates map[string]string `yaml:"templates"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *SharedConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	raw := rawSharedConfig{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	for name := range raw.Templates {
+		if err := validateTemplateFilename(name); err != nil {
+			return errors.Wrap(err, "invalid shared template")
+		}
+	}
+
+	// Load the receivers through an Alertmanager config, in order to apply the defaults and validate them.
+	doc := yaml.MapSlice{}
+	if raw.Global != nil {
+		doc = append(doc, yaml.MapItem{Key: "global", Value: raw.Global})
+	}
+	if len(raw.Receivers) > 0 {
+		name, _ := mapSliceValue(raw.Receivers[0], "name").(string)
+		doc = append(doc,
+			yaml.MapItem{Key: "route", Value: yaml.MapSlice{{Key: "receiver", Value: name}}},
+			yaml.MapItem{Key: "receivers", Value: raw.Receivers},
+		)
+	} else {
+		// A route is always required, so we use a placeholder one.
+		doc = append(doc,
+			yaml.MapItem{Key: "route", Value: yaml.MapSlice{{Key: "receiver", Value: "placeholder"}}},
+			yaml.MapItem{Key: "receivers", Value: []yaml.MapSlice{{{Key: "name", Value: "placeholder"}}}},
+		)
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := amconfig.Load(string(out))
+	if err != nil {
+		return errors.Wrap(err, "invalid shared receivers")
+	}
+
+	c.Global = cfg.Global
+	c.Receivers = nil
+	if len(raw.Receivers) > 0 {
+		c.Receivers = cfg.Receivers
+	}
+	c.Templates = raw.Templates
+
+	hash := md5.New()
+	_, _ = hash.Write(out)
+	for _, name := range sortedKeys(raw.Templates) {
+		_, _ = fmt.Fprintf(hash, "%s\x00%s\x00", name, raw.Templates[name])
+	}
+	c.hash = hex.EncodeToString(hash.Sum(nil))
+
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler. Secrets are masked by the Alertmanager config types.
+func (c SharedConfig) MarshalYAML() (interface{}, error) {
+	return struct {
+		Global    *amconfig.GlobalConfig `yaml:"global,omitempty"`
+		Receivers []*amconfig.Receiver   `yaml:"receivers,omitempty"`
+		Templates map[string]string      `yaml:"templates,omitempty"`
+	}{
+		Global:    c.Global,
+		Receivers: c.Receivers,
+		Templates: c.Templates,
+	}, nil
+}
+
+// Hash returns a hash of the shared config, which changes whenever its content changes.
+func (c *SharedConfig) Hash() string {
+	if c == nil {
+		return ""
+	}
+	return c.hash
+}
+
+func (c *SharedConfig) receiver(name string) *amconfig.Receiver {
+	if c == nil {
+		return nil
+	}
+	for _, rcv := range c.Receivers {
+		if rcv.Name == name {
+			return rcv
+		}
+	}
+	return nil
+}
+
+// loadUserConfig loads the tenant's Alertmanager config, which can refer to the shared receivers.
+// Receivers defined by the tenant take precedence over the shared ones with the same name. Returns
+// the config and the names of the shared receivers added to it.
+func loadUserConfig(rawCfg string, shared *SharedConfig) (*amconfig.Config, map[string]bool, error) {
+	if shared == nil || len(shared.Receivers) == 0 {
+		cfg, err := amconfig.Load(rawCfg)
+		return cfg, nil, err
+	}
+
+	doc := yaml.MapSlice{}
+	if err := yaml.Unmarshal([]byte(rawCfg), &doc); err != nil {
+		return nil, nil, err
+	}
+
+	// Add a placeholder receiver for each shared receiver not defined by the tenant,
+	// so that the tenant's routes referring to it pass the validation.
+	var receivers []interface{}
+	receiversIdx := -1
+	for i, item := range doc {
+		if item.Key == "receivers" {
+			receiversIdx = i
+			receivers, _ = item.Value.([]interface{})
+		}
+	}
+
+	defined := map[string]bool{}
+	for _, rcv := range receivers {
+		if m, ok := rcv.(yaml.MapSlice); ok {
+			if name, ok := mapSliceValue(m, "name").(string); ok {
+				defined[name] = true
+			}
+		}
+	}
+
+	sharedNames := map[string]bool{}
+	for _, rcv := range shared.Receivers {
+		if !defined[rcv.Name] {
+			receivers = append(receivers, yaml.MapSlice{{Key: "name", Value: rcv.Name}})
+			sharedNames[rcv.Name] = true
+		}
+	}
+
+	if receiversIdx >= 0 {
+		doc[receiversIdx].Value = receivers
+	} else {
+		doc = append(doc, yaml.MapItem{Key: "receivers", Value: receivers})
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg, err := amconfig.Load(string(out))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Replace the placeholders with a copy of the shared receivers, because the
+	// tenant's config may be mutated afterwards (eg. by transformConfig()).
+	for i, rcv := range cfg.Receivers {
+		if sharedNames[rcv.Name] {
+			cfg.Receivers[i] = copyReceiver(shared.receiver(rcv.Name))
+		}
+	}
+
+	return cfg, sharedNames, nil
+}
+
+// copyReceiver returns a copy of the receiver whose webhook configs can be safely mutated.
+func copyReceiver(rcv *amconfig.Receiver) *amconfig.Receiver {
+	out := *rcv
+	out.WebhookConfigs = make([]*amconfig.WebhookConfig, 0, len(rcv.WebhookConfigs))
+	for _, c := range rcv.WebhookConfigs {
+		copied := *c
+		out.WebhookConfigs = append(out.WebhookConfigs, &copied)
+	}
+	return &out
+}
+
+// storeSharedTemplates stores the shared templates in the given directory, removing any file
+// which is not a shared template anymore. Returns true if any file has changed.
+func storeSharedTemplates(dir string, shared *SharedConfig) (bool, error) {
+	var templates map[string]string
+	if shared != nil {
+		templates = shared.Templates
+	}
+
+	hasChanged := false
+	for name, body := range templates {
+		templateFilepath, err := safeTemplateFilepath(dir, name)
+		if err != nil {
+			return false, err
+		}
+
+		changed, err := storeTemplateFile(templateFilepath, body)
+		if err != nil {
+			return false, err
+		}
+		hasChanged = hasChanged || changed
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return hasChanged, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	for _, file := range files {
+		if _, ok := templates[file.Name()]; ok {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, file.Name())); err != nil {
+			return false, err
+		}
+		hasChanged = true
+	}
+
+	return hasChanged, nil
+}
+
+func mapSliceValue(m yaml.MapSlice, key string) interface{} {
+	for _, item := range m {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
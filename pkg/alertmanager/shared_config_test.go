@@ -0,0 +1,234 @@
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+)
+
+func loadSharedConfig(t *testing.T, cfg string) *SharedConfig {
+	t.Helper()
+
+	shared := &SharedConfig{}
+	require.NoError(t, yaml.UnmarshalStrict([]byte(cfg), shared))
+	return shared
+}
+
+func TestSharedConfig_UnmarshalYAML(t *testing.T) {
+	t.Run("should apply the shared global config to the receivers", func(t *testing.T) {
+		shared := loadSharedConfig(t, `
+global:
+  slack_api_url: 'http://slack.example.org/secret'
+receivers:
+  - name: corp-slack
+    slack_configs:
+      - channel: '#alerts'
+`)
+		require.Len(t, shared.Receivers, 1)
+		assert.Equal(t, "http://slack.example.org/secret", shared.Receivers[0].SlackConfigs[0].APIURL.String())
+		assert.NotEmpty(t, shared.Hash())
+	})
+
+	t.Run("should fail on invalid receivers", func(t *testing.T) {
+		err := yaml.Unmarshal([]byte(`
+receivers:
+  - name: corp-slack
+    slack_configs:
+      - channel: '#alerts'
+`), &SharedConfig{})
+		require.Error(t, err)
+	})
+
+	t.Run("should fail on invalid template filenames", func(t *testing.T) {
+		err := yaml.Unmarshal([]byte(`
+templates:
+  ../corp.tmpl: '{{ define "corp.title" }}title{{ end }}'
+`), &SharedConfig{})
+		require.Error(t, err)
+	})
+
+	t.Run("should not expose secrets when marshalled", func(t *testing.T) {
+		shared := loadSharedConfig(t, `
+receivers:
+  - name: corp-slack
+    slack_configs:
+      - api_url: 'http://slack.example.org/secret'
+        channel: '#alerts'
+`)
+		out, err := yaml.Marshal(shared)
+		require.NoError(t, err)
+		assert.NotContains(t, string(out), "http://slack.example.org/secret")
+	})
+}
+
+func TestLoadUserConfig(t *testing.T) {
+	shared := loadSharedConfig(t, `
+receivers:
+  - name: corp-webhook
+    webhook_configs:
+      - url: 'http://corp.example.org/secret'
+`)
+
+	t.Run("should resolve the shared receivers referred by the tenant", func(t *testing.T) {
+		cfg, sharedNames, err := loadUserConfig(`
+route:
+  receiver: default
+  routes:
+    - receiver: corp-webhook
+      matchers:
+        - team="a"
+receivers:
+  - name: default
+`, shared)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]bool{"corp-webhook": true}, sharedNames)
+		require.Len(t, cfg.Receivers, 2)
+		assert.Equal(t, "corp-webhook", cfg.Receivers[1].Name)
+		assert.Equal(t, "http://corp.example.org/secret", cfg.Receivers[1].WebhookConfigs[0].URL.String())
+
+		// The shared receiver must be copied, so that it can be safely mutated.
+		assert.NotSame(t, shared.Receivers[0], cfg.Receivers[1])
+		assert.NotSame(t, shared.Receivers[0].WebhookConfigs[0], cfg.Receivers[1].WebhookConfigs[0])
+	})
+
+	t.Run("should give precedence to the receivers defined by the tenant", func(t *testing.T) {
+		cfg, sharedNames, err := loadUserConfig(`
+route:
+  receiver: corp-webhook
+receivers:
+  - name: corp-webhook
+    webhook_configs:
+      - url: 'http://tenant.example.org/'
+`, shared)
+		require.NoError(t, err)
+		assert.Empty(t, sharedNames)
+		require.Len(t, cfg.Receivers, 1)
+		assert.Equal(t, "http://tenant.example.org/", cfg.Receivers[0].WebhookConfigs[0].URL.String())
+	})
+
+	t.Run("should fail if a route refers to a not existing receiver", func(t *testing.T) {
+		_, _, err := loadUserConfig(`
+route:
+  receiver: not-existing
+`, shared)
+		require.Error(t, err)
+	})
+}
+
+func TestMultitenantAlertmanager_SharedConfig(t *testing.T) {
+	ctx := context.Background()
+
+	shared := loadSharedConfig(t, `
+receivers:
+  - name: corp-webhook
+    webhook_configs:
+      - url: 'http://corp.example.org/secret'
+templates:
+  corp.tmpl: '{{ define "corp.title" }}title{{ end }}'
+`)
+
+	const userCfg = `
+route:
+  receiver: corp-webhook
+receivers:
+  - name: default
+`
+
+	store := prepareInMemoryAlertStore()
+	require.NoError(t, store.SetAlertConfig(ctx, alertspb.AlertConfigDesc{
+		User:      "user-1",
+		RawConfig: userCfg,
+	}))
+
+	cfg := mockAlertmanagerConfig(t)
+	cfg.SharedConfigFn = func() *SharedConfig { return shared }
+
+	am, err := createMultitenantAlertmanager(cfg, nil, nil, store, nil, &mockAlertManagerLimits{}, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		for _, userAM := range am.alertmanagers {
+			userAM.StopAndWait()
+		}
+	})
+
+	integrationsCount := func(receiver string) int {
+		userAM := am.alertmanagers["user-1"]
+		userAM.integrationsMtx.RLock()
+		defer userAM.integrationsMtx.RUnlock()
+		return len(userAM.integrations[receiver])
+	}
+
+	require.NoError(t, am.loadAndSyncConfigs(ctx, reasonPeriodic))
+	assert.Equal(t, 1, integrationsCount("corp-webhook"))
+
+	body, err := ioutil.ReadFile(filepath.Join(cfg.DataDir, "user-1", sharedTemplatesDir, "corp.tmpl"))
+	require.NoError(t, err)
+	assert.Equal(t, shared.Templates["corp.tmpl"], string(body))
+
+	// Changes to the shared config are applied to the tenant's Alertmanager.
+	shared = loadSharedConfig(t, `
+receivers:
+  - name: corp-webhook
+    webhook_configs:
+      - url: 'http://corp.example.org/secret'
+      - url: 'http://backup.example.org/secret'
+`)
+	require.NoError(t, am.loadAndSyncConfigs(ctx, reasonPeriodic))
+	assert.Equal(t, 2, integrationsCount("corp-webhook"))
+
+	_, err = os.Stat(filepath.Join(cfg.DataDir, "user-1", sharedTemplatesDir, "corp.tmpl"))
+	assert.True(t, os.IsNotExist(err))
+
+	t.Run("should not return the shared receivers in the tenant's config", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://alertmanager/api/v1/alerts", nil)
+		req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+		w := httptest.NewRecorder()
+		am.GetUserConfig(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "secret")
+	})
+
+	t.Run("should accept a tenant's config referring to the shared receivers", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "http://alertmanager/api/v1/alerts", bytes.NewReader([]byte(`
+alertmanager_config: |
+  route:
+    receiver: corp-webhook
+`)))
+		req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+		w := httptest.NewRecorder()
+		am.SetUserConfig(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	})
+
+	t.Run("should reject a tenant's config defining a receiver with the name of a shared one", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "http://alertmanager/api/v1/alerts", bytes.NewReader([]byte(`
+alertmanager_config: |
+  route:
+    receiver: corp-webhook
+  receivers:
+    - name: corp-webhook
+`)))
+		req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+		w := httptest.NewRecorder()
+		am.SetUserConfig(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "conflicts with a shared receiver")
+	})
+}
@@ -0,0 +1,264 @@
+package alertmanager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/alertmanager/cluster"
+	"github.com/prometheus/alertmanager/cluster/clusterpb"
+	"github.com/prometheus/alertmanager/nflog"
+	"github.com/prometheus/alertmanager/silence"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+const (
+	nflogStateKeyPrefix    = "nfl:"
+	silencesStateKeyPrefix = "sil:"
+
+	errShardingRequired    = "the Alertmanager state export and import require sharding to be enabled"
+	errExportingState      = "unable to export the Alertmanager state"
+	errImportingState      = "unable to import the Alertmanager state"
+	errReadingStateFile    = "unable to read the Alertmanager state file"
+	errStateFileTooBig     = "Alertmanager state file is too big, limit: %d bytes"
+	errNoStateToExport     = "no Alertmanager state found for the tenant"
+	stateSnapshotMediaType = "application/octet-stream"
+)
+
+// ExportUserState exports the full state (silences and notification log) of the tenant's Alertmanager
+// as a portable file, which can be imported in another cluster with ImportUserState. The state is
+// read from the running Alertmanager, the other replicas and the storage, and merged together.
+func (am *MultitenantAlertmanager) ExportUserState(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	if !am.cfg.ShardingEnabled {
+		http.Error(w, errShardingRequired, http.StatusNotImplemented)
+		return
+	}
+
+	states, err := am.collectUserState(r.Context(), logger, userID)
+	if err != nil {
+		level.Error(logger).Log("msg", errExportingState, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errExportingState, err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if len(states) == 0 {
+		http.Error(w, errNoStateToExport, http.StatusNotFound)
+		return
+	}
+
+	merged, err := mergeFullStates(userID, states)
+	if err != nil {
+		level.Error(logger).Log("msg", errExportingState, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errExportingState, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	desc := alertspb.FullStateDesc{State: merged}
+	data, err := desc.Marshal()
+	if err != nil {
+		level.Error(logger).Log("msg", errExportingState, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errExportingState, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", stateSnapshotMediaType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "alertmanager-state-"+userID+".bin"))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		level.Error(logger).Log("msg", "error writing the Alertmanager state", "err", err.Error())
+	}
+}
+
+// collectUserState returns all the known copies of the tenant's full state.
+func (am *MultitenantAlertmanager) collectUserState(ctx context.Context, logger log.Logger, userID string) ([]*clusterpb.FullState, error) {
+	var states []*clusterpb.FullState
+
+	am.alertmanagersMtx.Lock()
+	userAM, ok := am.alertmanagers[userID]
+	am.alertmanagersMtx.Unlock()
+
+	if ok {
+		s, err := userAM.getFullState()
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, s)
+	}
+
+	// The other replicas may not own the tenant, so a failure is not fatal.
+	if replicas, err := am.ReadFullStateForUser(ctx, userID); err != nil {
+		level.Debug(logger).Log("msg", "unable to read the state from the other replicas", "err", err)
+	} else {
+		states = append(states, replicas...)
+	}
+
+	stored, err := am.store.GetFullState(ctx, userID)
+	if err != nil && !errors.Is(err, alertspb.ErrNotFound) {
+		return nil, err
+	}
+	if err == nil && stored.State != nil {
+		states = append(states, stored.State)
+	}
+
+	return states, nil
+}
+
+// ImportUserState imports a state file, previously exported with ExportUserState, into the
+// tenant's Alertmanager. The tenant in the file doesn't need to match the target tenant. The
+// imported state is merged with the existing one, both in the storage and in the running replicas.
+func (am *MultitenantAlertmanager) ImportUserState(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	if !am.cfg.ShardingEnabled {
+		http.Error(w, errShardingRequired, http.StatusNotImplemented)
+		return
+	}
+
+	// LimitReader will return EOF after reading specified number of bytes. To check if
+	// we have read too many bytes, allow one extra byte.
+	payload, err := ioutil.ReadAll(io.LimitReader(r.Body, am.cfg.MaxRecvMsgSize+1))
+	if err != nil {
+		level.Error(logger).Log("msg", errReadingStateFile, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errReadingStateFile, err.Error()), http.StatusBadRequest)
+		return
+	}
+	if int64(len(payload)) > am.cfg.MaxRecvMsgSize {
+		http.Error(w, fmt.Sprintf(errStateFileTooBig, am.cfg.MaxRecvMsgSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	imported := alertspb.FullStateDesc{}
+	if err := imported.Unmarshal(payload); err != nil || imported.State == nil {
+		if err == nil {
+			err = errors.New("missing state")
+		}
+		http.Error(w, fmt.Sprintf("%s: %s", errReadingStateFile, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	// Validates the file and rekeys its parts to the target tenant.
+	state, err := mergeFullStates(userID, []*clusterpb.FullState{imported.State})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("%s: %s", errReadingStateFile, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	if err := am.importUserState(r.Context(), logger, userID, state); err != nil {
+		level.Error(logger).Log("msg", errImportingState, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errImportingState, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (am *MultitenantAlertmanager) importUserState(ctx context.Context, logger log.Logger, userID string, state *clusterpb.FullState) error {
+	// Merge with the stored state first, so that the imported state is not lost if the
+	// tenant's Alertmanager is not running yet.
+	stored, err := am.store.GetFullState(ctx, userID)
+	if err != nil && !errors.Is(err, alertspb.ErrNotFound) {
+		return err
+	}
+
+	toStore := state
+	if err == nil && stored.State != nil {
+		if toStore, err = mergeFullStates(userID, []*clusterpb.FullState{stored.State, state}); err != nil {
+			return err
+		}
+	}
+
+	if err := am.store.SetFullState(ctx, userID, alertspb.FullStateDesc{State: toStore}); err != nil {
+		return err
+	}
+
+	am.alertmanagersMtx.Lock()
+	userAM, ok := am.alertmanagers[userID]
+	am.alertmanagersMtx.Unlock()
+
+	for i := range state.Parts {
+		part := &state.Parts[i]
+		if ok {
+			if err := userAM.mergePartialExternalState(part); err != nil {
+				return err
+			}
+		}
+
+		// Propagate the imported state to the other replicas. They will pick it up from
+		// the storage anyway once they start the tenant's Alertmanager.
+		if err := am.ReplicateStateForUser(ctx, userID, part); err != nil {
+			level.Warn(logger).Log("msg", "failed to replicate the imported state", "key", part.Key, "err", err)
+		}
+	}
+
+	return nil
+}
+
+// mergeFullStates merges the given full states using the Alertmanager merge semantics, and
+// returns a full state whose parts are keyed for the given tenant. Expired entries are dropped.
+func mergeFullStates(userID string, states []*clusterpb.FullState) (*clusterpb.FullState, error) {
+	nfl, err := nflog.New()
+	if err != nil {
+		return nil, err
+	}
+	sil, err := silence.New(silence.Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range states {
+		for _, p := range s.Parts {
+			var st cluster.State
+			switch {
+			case strings.HasPrefix(p.Key, nflogStateKeyPrefix):
+				st = nfl
+			case strings.HasPrefix(p.Key, silencesStateKeyPrefix):
+				st = sil
+			default:
+				return nil, fmt.Errorf("unknown state key %q", p.Key)
+			}
+
+			if err := st.Merge(p.Data); err != nil {
+				return nil, errors.Wrapf(err, "failed to merge the state with key %q", p.Key)
+			}
+		}
+	}
+
+	merged := &clusterpb.FullState{}
+	for _, item := range []struct {
+		prefix string
+		state  cluster.State
+	}{
+		{prefix: nflogStateKeyPrefix, state: nfl},
+		{prefix: silencesStateKeyPrefix, state: sil},
+	} {
+		data, err := item.state.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		merged.Parts = append(merged.Parts, clusterpb.Part{Key: item.prefix + userID, Data: data})
+	}
+
+	return merged, nil
+}
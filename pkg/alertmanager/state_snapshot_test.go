@@ -0,0 +1,182 @@
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/alertmanager/cluster/clusterpb"
+	"github.com/prometheus/alertmanager/silence"
+	"github.com/prometheus/alertmanager/silence/silencepb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/ring/kv/consul"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+func newSilencesState(t *testing.T, key string, comments ...string) clusterpb.Part {
+	t.Helper()
+
+	sil, err := silence.New(silence.Options{})
+	require.NoError(t, err)
+
+	for _, comment := range comments {
+		_, err := sil.Set(&silencepb.Silence{
+			Matchers: []*silencepb.Matcher{{Name: "instance", Pattern: "prometheus-one"}},
+			Comment:  comment,
+			StartsAt: time.Now(),
+			EndsAt:   time.Now().Add(time.Hour),
+		})
+		require.NoError(t, err)
+	}
+
+	data, err := sil.MarshalBinary()
+	require.NoError(t, err)
+	return clusterpb.Part{Key: key, Data: data}
+}
+
+func silenceComments(t *testing.T, sil *silence.Silences) []string {
+	t.Helper()
+
+	silences, _, err := sil.Query()
+	require.NoError(t, err)
+
+	var comments []string
+	for _, s := range silences {
+		comments = append(comments, s.Comment)
+	}
+	return comments
+}
+
+func TestMergeFullStates(t *testing.T) {
+	t.Run("should merge the states and rekey them for the tenant", func(t *testing.T) {
+		merged, err := mergeFullStates("user-2", []*clusterpb.FullState{
+			{Parts: []clusterpb.Part{newSilencesState(t, "sil:user-1", "one")}},
+			{Parts: []clusterpb.Part{newSilencesState(t, "sil:user-1", "two", "three")}},
+		})
+		require.NoError(t, err)
+		require.Len(t, merged.Parts, 2)
+		assert.Equal(t, "nfl:user-2", merged.Parts[0].Key)
+		assert.Equal(t, "sil:user-2", merged.Parts[1].Key)
+
+		sil, err := silence.New(silence.Options{})
+		require.NoError(t, err)
+		require.NoError(t, sil.Merge(merged.Parts[1].Data))
+		assert.ElementsMatch(t, []string{"one", "two", "three"}, silenceComments(t, sil))
+	})
+
+	t.Run("should fail on unknown state keys", func(t *testing.T) {
+		_, err := mergeFullStates("user-1", []*clusterpb.FullState{
+			{Parts: []clusterpb.Part{{Key: "unknown:user-1"}}},
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("should fail on invalid state data", func(t *testing.T) {
+		_, err := mergeFullStates("user-1", []*clusterpb.FullState{
+			{Parts: []clusterpb.Part{{Key: "sil:user-1", Data: []byte("invalid")}}},
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestMultitenantAlertmanager_ExportImportUserState(t *testing.T) {
+	ctx := context.Background()
+	ringStore := consul.NewInMemoryClient(ring.GetCodec())
+	store := prepareInMemoryAlertStore()
+
+	for _, userID := range []string{"user-1", "user-2"} {
+		require.NoError(t, store.SetAlertConfig(ctx, alertspb.AlertConfigDesc{
+			User:      userID,
+			RawConfig: simpleConfigOne,
+		}))
+	}
+
+	cfg := mockAlertmanagerConfig(t)
+	cfg.ShardingEnabled = true
+	cfg.ShardingRing.ReplicationFactor = 1
+	cfg.ShardingRing.InstanceID = "alertmanager-1"
+	cfg.ShardingRing.InstanceAddr = "127.0.0.1"
+	cfg.PollInterval = time.Hour
+	cfg.ShardingRing.RingCheckPeriod = time.Hour
+
+	am, err := createMultitenantAlertmanager(cfg, nil, nil, store, ringStore, nil, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+	clientPool := newPassthroughAlertmanagerClientPool()
+	clientPool.setServer(cfg.ShardingRing.InstanceAddr+":0", am)
+	am.alertmanagerClientsPool = clientPool
+
+	require.NoError(t, services.StartAndAwaitRunning(ctx, am))
+	defer services.StopAndAwaitTerminated(ctx, am) //nolint:errcheck
+	require.NoError(t, ring.WaitInstanceState(ctx, am.ring, cfg.ShardingRing.InstanceID, ring.ACTIVE))
+	require.NoError(t, am.loadAndSyncConfigs(ctx, reasonRingChange))
+
+	// Create a silence for user-1, and a different one persisted in the storage for user-2.
+	_, err = am.alertmanagers["user-1"].silences.Set(&silencepb.Silence{
+		Matchers: []*silencepb.Matcher{{Name: "instance", Pattern: "prometheus-one"}},
+		Comment:  "exported",
+		StartsAt: time.Now(),
+		EndsAt:   time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.SetFullState(ctx, "user-2", alertspb.FullStateDesc{
+		State: &clusterpb.FullState{Parts: []clusterpb.Part{newSilencesState(t, "sil:user-2", "existing")}},
+	}))
+
+	doRequest := func(handler http.HandlerFunc, method, userID string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "http://alertmanager/multitenant_alertmanager/state", bytes.NewReader(body))
+		req = req.WithContext(user.InjectOrgID(req.Context(), userID))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	// Export the state of user-1.
+	w := doRequest(am.ExportUserState, http.MethodGet, "user-1", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, stateSnapshotMediaType, w.Header().Get("Content-Type"))
+	exported := w.Body.Bytes()
+
+	// Import it into user-2.
+	w = doRequest(am.ImportUserState, http.MethodPost, "user-2", exported)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	assert.ElementsMatch(t, []string{"exported"}, silenceComments(t, am.alertmanagers["user-2"].silences))
+
+	// The imported state has been merged with the stored one.
+	stored, err := store.GetFullState(ctx, "user-2")
+	require.NoError(t, err)
+	sil, err := silence.New(silence.Options{})
+	require.NoError(t, err)
+	for _, p := range stored.State.Parts {
+		if p.Key == "sil:user-2" {
+			require.NoError(t, sil.Merge(p.Data))
+		}
+	}
+	assert.ElementsMatch(t, []string{"exported", "existing"}, silenceComments(t, sil))
+
+	t.Run("should reject an invalid state file", func(t *testing.T) {
+		w := doRequest(am.ImportUserState, http.MethodPost, "user-2", []byte("invalid"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should return 404 if the tenant has no state", func(t *testing.T) {
+		w := doRequest(am.ExportUserState, http.MethodGet, "user-3", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("should return 501 if sharding is disabled", func(t *testing.T) {
+		am := &MultitenantAlertmanager{cfg: mockAlertmanagerConfig(t), logger: log.NewNopLogger()}
+		assert.Equal(t, http.StatusNotImplemented, doRequest(am.ExportUserState, http.MethodGet, "user-1", nil).Code)
+		assert.Equal(t, http.StatusNotImplemented, doRequest(am.ImportUserState, http.MethodPost, "user-1", nil).Code)
+	})
+}
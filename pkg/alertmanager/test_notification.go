@@ -0,0 +1,145 @@
+package alertmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+const (
+	// testReceiverPath is the path, relative to the Alertmanager external URL, of the endpoint
+	// used to send a test notification to a receiver.
+	testReceiverPath = "/api/v1/receivers/test"
+
+	// testNotificationTimeout is the max time a test notification can take across all the receiver integrations.
+	testNotificationTimeout = 30 * time.Second
+
+	testNotificationAlertName = "CortexTestNotification"
+
+	testNotificationStatusSuccess = "success"
+	testNotificationStatusFailed  = "failed"
+)
+
+var errReceiverNotFound = errors.New("receiver not found")
+
+// TestReceiverResponse is the result of sending a test notification to a receiver.
+type TestReceiverResponse struct {
+	Receiver     string                  `json:"receiver"`
+	Integrations []TestIntegrationResult `json:"integrations"`
+}
+
+// TestIntegrationResult is the result of sending a test notification through a single receiver integration.
+type TestIntegrationResult struct {
+	Name   string `json:"name"`
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	// Retry is true if the integration reported the failure as recoverable.
+	Retry bool `json:"retry,omitempty"`
+}
+
+// TestReceiverHandler sends a synthetic test notification to the receiver whose name is
+// specified in the "receiver" URL query parameter, through each of its integrations.
+func (am *Alertmanager) TestReceiverHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	receiver := r.URL.Query().Get("receiver")
+	if receiver == "" {
+		http.Error(w, "the receiver name is required", http.StatusBadRequest)
+		return
+	}
+
+	results, err := am.testReceiver(r.Context(), receiver)
+	if errors.Is(err, errReceiverNotFound) {
+		http.Error(w, fmt.Sprintf("%s: %s", err.Error(), receiver), http.StatusNotFound)
+		return
+	}
+
+	level.Info(util_log.WithContext(r.Context(), am.logger)).Log("msg", "sent test notification", "receiver", receiver)
+	util.WriteJSONResponse(w, TestReceiverResponse{
+		Receiver:     receiver,
+		Integrations: results,
+	})
+}
+
+// testReceiver sends a synthetic alert to all integrations of the given receiver, in the currently applied
+// configuration. The notification goes through the same rate limits and receivers firewall of real notifications.
+func (am *Alertmanager) testReceiver(ctx context.Context, receiver string) ([]TestIntegrationResult, error) {
+	am.integrationsMtx.RLock()
+	integrations, ok := am.integrations[receiver]
+	am.integrationsMtx.RUnlock()
+
+	if !ok {
+		return nil, errReceiverNotFound
+	}
+
+	now := time.Now()
+	alert := &types.Alert{
+		Alert: model.Alert{
+			Labels: model.LabelSet{
+				model.AlertNameLabel: testNotificationAlertName,
+			},
+			Annotations: model.LabelSet{
+				"summary":     "Test notification",
+				"description": "This is a test notification sent by Cortex to verify the receiver configuration.",
+			},
+			StartsAt: now,
+		},
+		UpdatedAt: now,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, testNotificationTimeout)
+	defer cancel()
+
+	ctx = notify.WithGroupKey(ctx, fmt.Sprintf("{}:{%s=%q}", model.AlertNameLabel, testNotificationAlertName))
+	ctx = notify.WithGroupLabels(ctx, alert.Labels)
+	ctx = notify.WithReceiverName(ctx, receiver)
+	ctx = notify.WithNow(ctx, now)
+	ctx = withTestNotification(ctx)
+
+	results := make([]TestIntegrationResult, 0, len(integrations))
+	for _, integration := range integrations {
+		result := TestIntegrationResult{
+			Name:   integration.Name(),
+			Index:  integration.Index(),
+			Status: testNotificationStatusSuccess,
+		}
+
+		if retry, err := integration.Notify(ctx, alert); err != nil {
+			result.Status = testNotificationStatusFailed
+			result.Error = err.Error()
+			result.Retry = retry
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+type testNotificationContextKey struct{}
+
+// withTestNotification marks the context as used to send a test notification.
+func withTestNotification(ctx context.Context) context.Context {
+	return context.WithValue(ctx, testNotificationContextKey{}, true)
+}
+
+// isTestNotification returns whether the context is used to send a test notification.
+func isTestNotification(ctx context.Context) bool {
+	v, ok := ctx.Value(testNotificationContextKey{}).(bool)
+	return ok && v
+}
@@ -0,0 +1,124 @@
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
+	"golang.org/x/time/rate"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+func TestMultitenantAlertmanager_TestReceiver(t *testing.T) {
+	t.Run("should send the test notification through the receiver integrations", func(t *testing.T) {
+		testReceiver, received := prepareTestReceiver(t, false)
+
+		code, res := testReceiver("webhook")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, TestReceiverResponse{
+			Receiver: "webhook",
+			Integrations: []TestIntegrationResult{
+				{Name: "webhook", Index: 0, Status: testNotificationStatusSuccess},
+			},
+		}, res)
+		assert.Equal(t, int32(1), received.Load())
+	})
+
+	t.Run("should apply the receivers firewall", func(t *testing.T) {
+		testReceiver, received := prepareTestReceiver(t, true)
+
+		code, res := testReceiver("webhook")
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, res.Integrations, 1)
+		assert.Equal(t, testNotificationStatusFailed, res.Integrations[0].Status)
+		assert.Contains(t, res.Integrations[0].Error, "blocked address")
+		assert.Equal(t, int32(0), received.Load())
+	})
+
+	t.Run("should return 404 if the receiver does not exist", func(t *testing.T) {
+		testReceiver, _ := prepareTestReceiver(t, false)
+
+		code, _ := testReceiver("not-existing")
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+
+	t.Run("should return 400 if the receiver is not specified", func(t *testing.T) {
+		testReceiver, _ := prepareTestReceiver(t, false)
+
+		code, _ := testReceiver("")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
+
+// prepareTestReceiver starts a webhook server and a multitenant Alertmanager whose "webhook"
+// receiver sends to it. Returns a function calling the test receiver endpoint, and the number
+// of notifications received by the webhook server.
+func prepareTestReceiver(t *testing.T, blockPrivateAddresses bool) (func(receiver string) (int, TestReceiverResponse), *atomic.Int32) {
+	ctx := context.Background()
+
+	received := atomic.NewInt32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Inc()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := fmt.Sprintf(`
+route:
+  receiver: 'webhook'
+receivers:
+- name: 'webhook'
+  webhook_configs:
+  - url: '%s'
+`, server.URL)
+
+	store := prepareInMemoryAlertStore()
+	require.NoError(t, store.SetAlertConfig(ctx, alertspb.AlertConfigDesc{
+		User:      "user-1",
+		RawConfig: cfg,
+	}))
+
+	limits := &mockAlertManagerLimits{
+		emailNotificationRateLimit: rate.Inf,
+		emailNotificationBurst:     1,
+		blockPrivateAddresses:      blockPrivateAddresses,
+	}
+
+	amCfg := mockAlertmanagerConfig(t)
+	am, err := createMultitenantAlertmanager(amCfg, nil, nil, store, nil, limits, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, am))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(ctx, am))
+	})
+
+	testReceiver := func(receiver string) (int, TestReceiverResponse) {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost/api/prom/api/v1/receivers/test?receiver=%s", receiver), nil)
+		req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+		w := httptest.NewRecorder()
+		am.ServeHTTP(w, req)
+
+		body, err := ioutil.ReadAll(w.Result().Body)
+		require.NoError(t, err)
+
+		res := TestReceiverResponse{}
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(body, &res))
+		}
+		return w.Code, res
+	}
+
+	return testReceiver, received
+}
@@ -0,0 +1,424 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+const (
+	tenantNamespaceKeyPrefix = "tenant-namespace:"
+
+	// The keys prefix of the entries stored on behalf of many tenants is hashed when longer than
+	// this, so that the keys don't exceed the max key length of the downstream cache (eg. memcached).
+	maxTenantKeysPrefixLength = 64
+)
+
+// TenantLimits are the per-tenant limits enforced by TenantCache.
+type TenantLimits interface {
+	// MaxCacheBytes returns the maximum number of bytes the tenant can store in the cache.
+	// 0 to disable the limit.
+	MaxCacheBytes(userID string) int
+}
+
+// TenantCache is a Cache wrapper isolating the entries of each tenant. Keys are prefixed with
+// a per-tenant namespace, stored in the downstream cache itself, so that all the entries of a
+// tenant can be invalidated at once by switching the tenant to a new namespace. The bytes
+// stored by each tenant are accounted locally, until the entries expire, and stores exceeding
+// the tenant quota are skipped so that a single tenant can't evict the entries of everyone else.
+//
+// The tenant is read from the context: Store and Fetch calls without a tenant are passed
+// through to the downstream cache. Entries stored on behalf of multiple tenants (eg. by
+// a federated query) are invalidated when any of the tenants is invalidated.
+type TenantCache struct {
+	next   Cache
+	limits TenantLimits
+	logger log.Logger
+
+	// How long stored entries are accounted, and how often the namespaces are re-read
+	// from the downstream cache to pick up invalidations done by other processes.
+	validity               time.Duration
+	namespaceRefreshPeriod time.Duration
+
+	mtx     sync.Mutex
+	tenants map[string]*tenantCacheState
+
+	// Used to remove the metrics and state of the inactive tenants.
+	activeUsers *util.ActiveUsersCleanupService
+
+	hits           *prometheus.CounterVec
+	misses         *prometheus.CounterVec
+	storedBytes    *prometheus.GaugeVec
+	rejectedStores *prometheus.CounterVec
+	invalidations  *prometheus.CounterVec
+
+	// Allow to mock time in tests.
+	now func() time.Time
+}
+
+type tenantCacheState struct {
+	namespace          string
+	namespaceFetchedAt time.Time
+
+	// The entries stored with the given keys prefix. They're not reachable anymore once
+	// the prefix changes, so they're not accounted anymore.
+	keysPrefix string
+	bytes      int
+	entries    map[string]tenantCacheEntry
+	nextPurge  time.Time
+}
+
+type tenantCacheEntry struct {
+	size      int
+	expiresAt time.Time
+}
+
+// NewTenantCache makes a new TenantCache. The validity is the time entries are expected to stay in
+// the downstream cache, and must be greater than 0 since the bytes stored by each tenant are
+// accounted until then. The namespaceRefreshPeriod is the maximum time it takes to pick up the
+// invalidation of a tenant done by another process sharing the same cache.
+func NewTenantCache(name string, next Cache, limits TenantLimits, validity, namespaceRefreshPeriod time.Duration, reg prometheus.Registerer, logger log.Logger) *TenantCache {
+	c := &TenantCache{
+		next:                   next,
+		limits:                 limits,
+		logger:                 logger,
+		validity:               validity,
+		namespaceRefreshPeriod: namespaceRefreshPeriod,
+		tenants:                map[string]*tenantCacheState{},
+		now:                    time.Now,
+
+		hits: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "cortex",
+			Name:        "cache_tenant_hits_total",
+			Help:        "Total count of keys found in cache, per tenant.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}, []string{"user"}),
+		misses: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "cortex",
+			Name:        "cache_tenant_misses_total",
+			Help:        "Total count of keys not found in cache, per tenant.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}, []string{"user"}),
+		storedBytes: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   "cortex",
+			Name:        "cache_tenant_stored_bytes",
+			Help:        "Number of bytes stored in cache by this process and not expired yet, per tenant.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}, []string{"user"}),
+		rejectedStores: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "cortex",
+			Name:        "cache_tenant_rejected_stores_total",
+			Help:        "Total count of keys not stored in cache because the tenant reached its quota.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}, []string{"user"}),
+		invalidations: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "cortex",
+			Name:        "cache_tenant_invalidations_total",
+			Help:        "Total count of invalidations of all the entries of a tenant.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}, []string{"user"}),
+	}
+
+	c.activeUsers = util.NewActiveUsersCleanupWithDefaultValues(c.cleanupInactiveUser)
+	// If cleaner stops or fail, we will simply not clean the metrics for inactive users.
+	_ = c.activeUsers.StartAsync(context.Background())
+
+	return c
+}
+
+// Store implements Cache.
+func (c *TenantCache) Store(ctx context.Context, keys []string, bufs [][]byte) {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		c.next.Store(ctx, keys, bufs)
+		return
+	}
+
+	userID := tenant.JoinTenantIDs(tenantIDs)
+	prefix := c.keysPrefix(ctx, tenantIDs)
+	maxBytes := c.maxBytes(tenantIDs)
+	c.updateActiveUsers(userID, tenantIDs)
+
+	c.mtx.Lock()
+	state := c.getState(userID)
+	if state.keysPrefix != prefix {
+		state.keysPrefix = prefix
+		state.entries = map[string]tenantCacheEntry{}
+		state.bytes = 0
+	}
+	now := c.now()
+	c.purgeExpired(state, now)
+
+	storeKeys := make([]string, 0, len(keys))
+	storeBufs := make([][]byte, 0, len(bufs))
+	rejected := 0
+
+	for i, key := range keys {
+		// The previous value of the key, if any, is replaced.
+		bytes := state.bytes - state.entries[key].size + len(bufs[i])
+		if maxBytes > 0 && bytes > maxBytes {
+			rejected++
+			continue
+		}
+
+		entry := tenantCacheEntry{size: len(bufs[i])}
+		if c.validity > 0 {
+			entry.expiresAt = now.Add(c.validity)
+		}
+		state.entries[key] = entry
+		state.bytes = bytes
+
+		storeKeys = append(storeKeys, prefix+key)
+		storeBufs = append(storeBufs, bufs[i])
+	}
+	c.storedBytes.WithLabelValues(userID).Set(float64(state.bytes))
+	c.mtx.Unlock()
+
+	if rejected > 0 {
+		c.rejectedStores.WithLabelValues(userID).Add(float64(rejected))
+	}
+	if len(storeKeys) > 0 {
+		c.next.Store(ctx, storeKeys, storeBufs)
+	}
+}
+
+// Fetch implements Cache.
+func (c *TenantCache) Fetch(ctx context.Context, keys []string) (found []string, bufs [][]byte, missing []string) {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return c.next.Fetch(ctx, keys)
+	}
+
+	userID := tenant.JoinTenantIDs(tenantIDs)
+	prefix := c.keysPrefix(ctx, tenantIDs)
+	c.updateActiveUsers(userID, tenantIDs)
+
+	prefixedKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		prefixedKeys = append(prefixedKeys, prefix+key)
+	}
+
+	found, bufs, missing = c.next.Fetch(ctx, prefixedKeys)
+	for i := range found {
+		found[i] = strings.TrimPrefix(found[i], prefix)
+	}
+	for i := range missing {
+		missing[i] = strings.TrimPrefix(missing[i], prefix)
+	}
+
+	c.hits.WithLabelValues(userID).Add(float64(len(found)))
+	c.misses.WithLabelValues(userID).Add(float64(len(missing)))
+	return found, bufs, missing
+}
+
+// Stop implements Cache.
+func (c *TenantCache) Stop() {
+	_ = services.StopAndAwaitTerminated(context.Background(), c.activeUsers)
+	c.next.Stop()
+}
+
+// Invalidate switches the tenant to a new namespace, making all the entries previously stored by
+// the tenant unreachable. The old entries are left to expire in the downstream cache.
+func (c *TenantCache) Invalidate(ctx context.Context, userID string) {
+	namespace := newTenantNamespace()
+	c.next.Store(ctx, []string{tenantNamespaceKeyPrefix + userID}, [][]byte{[]byte(namespace)})
+
+	c.activeUsers.UpdateUserTimestamp(userID, c.now())
+
+	c.mtx.Lock()
+	state := c.getState(userID)
+	c.setNamespace(state, namespace, c.now())
+	state.entries = map[string]tenantCacheEntry{}
+	state.bytes = 0
+	c.storedBytes.WithLabelValues(userID).Set(0)
+	c.mtx.Unlock()
+
+	c.invalidations.WithLabelValues(userID).Inc()
+	level.Info(c.logger).Log("msg", "invalidated cache entries of tenant", "user", userID)
+}
+
+// InvalidateHandler is the HTTP handler invalidating all the entries of the tenant of the request.
+func (c *TenantCache) InvalidateHandler(w http.ResponseWriter, r *http.Request) {
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	for _, userID := range tenantIDs {
+		c.Invalidate(r.Context(), userID)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// keysPrefix returns the prefix of the keys stored on behalf of the given tenants, based on
+// their current namespaces.
+func (c *TenantCache) keysPrefix(ctx context.Context, tenantIDs []string) string {
+	prefix := strings.Join(c.namespaces(ctx, tenantIDs), "-")
+	if len(prefix) > maxTenantKeysPrefixLength {
+		prefix = fmt.Sprintf("%x", sha256.Sum256([]byte(prefix)))
+	}
+	return prefix + ":"
+}
+
+// updateActiveUsers tracks the activity of the tenants of a request, including each tenant of a
+// federated request, since their namespaces are used to build the keys prefix.
+func (c *TenantCache) updateActiveUsers(userID string, tenantIDs []string) {
+	now := c.now()
+	c.activeUsers.UpdateUserTimestamp(userID, now)
+	if len(tenantIDs) > 1 {
+		for _, tenantID := range tenantIDs {
+			c.activeUsers.UpdateUserTimestamp(tenantID, now)
+		}
+	}
+}
+
+// cleanupInactiveUser removes the metrics of the inactive tenant, and its state once all the
+// entries it stored have expired.
+func (c *TenantCache) cleanupInactiveUser(userID string) {
+	c.hits.DeleteLabelValues(userID)
+	c.misses.DeleteLabelValues(userID)
+	c.storedBytes.DeleteLabelValues(userID)
+	c.rejectedStores.DeleteLabelValues(userID)
+	c.invalidations.DeleteLabelValues(userID)
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if state, ok := c.tenants[userID]; ok {
+		c.purgeExpired(state, c.now())
+		if len(state.entries) == 0 {
+			delete(c.tenants, userID)
+		}
+	}
+}
+
+// namespaces returns the current namespace of each tenant, re-reading it from the downstream cache
+// if it hasn't been read recently. Tenants without a namespace are assigned a new one.
+func (c *TenantCache) namespaces(ctx context.Context, tenantIDs []string) []string {
+	now := c.now()
+	namespaces := make([]string, len(tenantIDs))
+
+	var refresh []string
+	c.mtx.Lock()
+	for i, userID := range tenantIDs {
+		state := c.getState(userID)
+		if state.namespace == "" || now.Sub(state.namespaceFetchedAt) >= c.namespaceRefreshPeriod {
+			refresh = append(refresh, tenantNamespaceKeyPrefix+userID)
+		}
+		namespaces[i] = state.namespace
+	}
+	c.mtx.Unlock()
+
+	if len(refresh) == 0 {
+		return namespaces
+	}
+
+	fetched := map[string]string{}
+	found, bufs, _ := c.next.Fetch(ctx, refresh)
+	for i, key := range found {
+		fetched[strings.TrimPrefix(key, tenantNamespaceKeyPrefix)] = string(bufs[i])
+	}
+
+	var newKeys []string
+	var newBufs [][]byte
+
+	c.mtx.Lock()
+	for i, userID := range tenantIDs {
+		state := c.getState(userID)
+		if state.namespace != "" && now.Sub(state.namespaceFetchedAt) < c.namespaceRefreshPeriod {
+			// Refreshed concurrently.
+			namespaces[i] = state.namespace
+			continue
+		}
+
+		// The namespace of the tenant may have been evicted from the downstream cache, in which
+		// case the tenant gets a new one: this is safe because the old entries are unreachable.
+		namespace, ok := fetched[userID]
+		if !ok {
+			namespace = newTenantNamespace()
+			newKeys = append(newKeys, tenantNamespaceKeyPrefix+userID)
+			newBufs = append(newBufs, []byte(namespace))
+		}
+
+		c.setNamespace(state, namespace, now)
+		namespaces[i] = namespace
+	}
+	c.mtx.Unlock()
+
+	if len(newKeys) > 0 {
+		c.next.Store(ctx, newKeys, newBufs)
+	}
+
+	return namespaces
+}
+
+// setNamespace sets the namespace of the tenant. Must be called with the lock held.
+func (c *TenantCache) setNamespace(state *tenantCacheState, namespace string, now time.Time) {
+	state.namespace = namespace
+	state.namespaceFetchedAt = now
+}
+
+// getState returns the state of the tenant, creating it if it doesn't exist. Must be called with
+// the lock held.
+func (c *TenantCache) getState(userID string) *tenantCacheState {
+	state, ok := c.tenants[userID]
+	if !ok {
+		state = &tenantCacheState{entries: map[string]tenantCacheEntry{}}
+		c.tenants[userID] = state
+	}
+	return state
+}
+
+// purgeExpired releases the bytes of the expired entries of the tenant. Entries are scanned at most
+// a few times per validity period. Must be called with the lock held.
+func (c *TenantCache) purgeExpired(state *tenantCacheState, now time.Time) {
+	if c.validity <= 0 || now.Before(state.nextPurge) {
+		return
+	}
+
+	for key, entry := range state.entries {
+		if !now.Before(entry.expiresAt) {
+			state.bytes -= entry.size
+			delete(state.entries, key)
+		}
+	}
+	state.nextPurge = now.Add(c.validity / 10)
+}
+
+// maxBytes returns the quota of the given tenants: the smallest quota applies to the entries
+// stored on behalf of multiple tenants.
+func (c *TenantCache) maxBytes(tenantIDs []string) int {
+	result := 0
+	for _, userID := range tenantIDs {
+		if limit := c.limits.MaxCacheBytes(userID); limit > 0 && (result == 0 || limit < result) {
+			result = limit
+		}
+	}
+	return result
+}
+
+func newTenantNamespace() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		// The current time is unique enough for a namespace.
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
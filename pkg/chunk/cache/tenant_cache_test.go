@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+)
+
+type mockTenantLimits map[string]int
+
+func (m mockTenantLimits) MaxCacheBytes(userID string) int {
+	return m[userID]
+}
+
+func newTestTenantCache(next Cache, limits TenantLimits, now *time.Time) *TenantCache {
+	c := NewTenantCache("test", next, limits, time.Hour, time.Minute, prometheus.NewPedanticRegistry(), log.NewNopLogger())
+	c.now = func() time.Time { return *now }
+	return c
+}
+
+func TestTenantCache_ShouldIsolateTenants(t *testing.T) {
+	now := time.Now()
+	c := newTestTenantCache(NewMockCache(), mockTenantLimits{}, &now)
+
+	ctxA := user.InjectOrgID(context.Background(), "user-a")
+	ctxB := user.InjectOrgID(context.Background(), "user-b")
+
+	c.Store(ctxA, []string{"key"}, [][]byte{[]byte("value-a")})
+
+	found, bufs, missing := c.Fetch(ctxA, []string{"key", "other"})
+	assert.Equal(t, []string{"key"}, found)
+	assert.Equal(t, [][]byte{[]byte("value-a")}, bufs)
+	assert.Equal(t, []string{"other"}, missing)
+
+	found, _, missing = c.Fetch(ctxB, []string{"key"})
+	assert.Empty(t, found)
+	assert.Equal(t, []string{"key"}, missing)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.hits.WithLabelValues("user-a")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.misses.WithLabelValues("user-a")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(c.hits.WithLabelValues("user-b")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.misses.WithLabelValues("user-b")))
+
+	// Requests without a tenant are passed through.
+	c.Store(context.Background(), []string{"key"}, [][]byte{[]byte("value")})
+	found, bufs, _ = c.Fetch(context.Background(), []string{"key"})
+	assert.Equal(t, []string{"key"}, found)
+	assert.Equal(t, [][]byte{[]byte("value")}, bufs)
+}
+
+func TestTenantCache_ShouldEnforceTenantQuota(t *testing.T) {
+	now := time.Now()
+	c := newTestTenantCache(NewMockCache(), mockTenantLimits{"user-a": 10}, &now)
+	ctx := user.InjectOrgID(context.Background(), "user-a")
+
+	c.Store(ctx, []string{"key-1", "key-2"}, [][]byte{[]byte("123456"), []byte("123456")})
+	found, _, _ := c.Fetch(ctx, []string{"key-1", "key-2"})
+	assert.Equal(t, []string{"key-1"}, found)
+	assert.Equal(t, float64(6), testutil.ToFloat64(c.storedBytes.WithLabelValues("user-a")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.rejectedStores.WithLabelValues("user-a")))
+
+	// Replacing a value only accounts the difference.
+	c.Store(ctx, []string{"key-1", "key-2"}, [][]byte{[]byte("1234"), []byte("123456")})
+	found, _, _ = c.Fetch(ctx, []string{"key-1", "key-2"})
+	assert.Equal(t, []string{"key-1", "key-2"}, found)
+	assert.Equal(t, float64(10), testutil.ToFloat64(c.storedBytes.WithLabelValues("user-a")))
+
+	// Expired entries are not accounted anymore.
+	now = now.Add(2 * time.Hour)
+	c.Store(ctx, []string{"key-3"}, [][]byte{[]byte("12345678")})
+	found, _, _ = c.Fetch(ctx, []string{"key-3"})
+	assert.Equal(t, []string{"key-3"}, found)
+	assert.Equal(t, float64(8), testutil.ToFloat64(c.storedBytes.WithLabelValues("user-a")))
+}
+
+func TestTenantCache_Invalidate(t *testing.T) {
+	// Enable the support for queries spanning multiple tenants.
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	t.Cleanup(func() { tenant.WithDefaultResolver(tenant.NewSingleResolver()) })
+
+	now := time.Now()
+	backend := NewMockCache()
+
+	// Two processes sharing the same downstream cache.
+	c1 := newTestTenantCache(backend, mockTenantLimits{}, &now)
+	c2 := newTestTenantCache(backend, mockTenantLimits{}, &now)
+
+	ctxA := user.InjectOrgID(context.Background(), "user-a")
+	ctxB := user.InjectOrgID(context.Background(), "user-b")
+	ctxAB := user.InjectOrgID(context.Background(), "user-a|user-b")
+
+	c1.Store(ctxA, []string{"key"}, [][]byte{[]byte("value-a")})
+	c1.Store(ctxB, []string{"key"}, [][]byte{[]byte("value-b")})
+	c1.Store(ctxAB, []string{"key"}, [][]byte{[]byte("value-ab")})
+
+	for _, ctx := range []context.Context{ctxA, ctxB, ctxAB} {
+		found, _, _ := c2.Fetch(ctx, []string{"key"})
+		require.Equal(t, []string{"key"}, found)
+	}
+
+	// Invalidate through the HTTP API.
+	rec := httptest.NewRecorder()
+	c1.InvalidateHandler(rec, httptest.NewRequest(http.MethodPost, "/", nil).WithContext(ctxA))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, float64(1), testutil.ToFloat64(c1.invalidations.WithLabelValues("user-a")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(c1.storedBytes.WithLabelValues("user-a")))
+
+	// The process which invalidated the tenant doesn't find its entries anymore, including the
+	// ones stored on behalf of multiple tenants, while the other tenants are not affected.
+	found, _, _ := c1.Fetch(ctxA, []string{"key"})
+	assert.Empty(t, found)
+	found, _, _ = c1.Fetch(ctxAB, []string{"key"})
+	assert.Empty(t, found)
+	found, _, _ = c1.Fetch(ctxB, []string{"key"})
+	assert.Equal(t, []string{"key"}, found)
+
+	// The other process picks up the invalidation once it refreshes the namespace.
+	found, _, _ = c2.Fetch(ctxA, []string{"key"})
+	assert.Equal(t, []string{"key"}, found)
+
+	now = now.Add(time.Minute)
+	found, _, _ = c2.Fetch(ctxA, []string{"key"})
+	assert.Empty(t, found)
+	found, _, _ = c2.Fetch(ctxB, []string{"key"})
+	assert.Equal(t, []string{"key"}, found)
+}
+
+func TestTenantCache_ShouldHashLongKeysPrefix(t *testing.T) {
+	// Enable the support for queries spanning multiple tenants.
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	t.Cleanup(func() { tenant.WithDefaultResolver(tenant.NewSingleResolver()) })
+
+	now := time.Now()
+	backend := NewMockCache()
+	c := newTestTenantCache(backend, mockTenantLimits{}, &now)
+
+	tenantIDs := make([]string, 0, 50)
+	for i := 0; i < 50; i++ {
+		tenantIDs = append(tenantIDs, fmt.Sprintf("user-%d", i))
+	}
+	ctx := user.InjectOrgID(context.Background(), strings.Join(tenantIDs, "|"))
+
+	c.Store(ctx, []string{"key"}, [][]byte{[]byte("value")})
+	found, _, _ := c.Fetch(ctx, []string{"key"})
+	assert.Equal(t, []string{"key"}, found)
+
+	for key := range backend.(*mockCache).cache {
+		assert.LessOrEqual(t, len(key), maxTenantKeysPrefixLength+len(":key"))
+	}
+}
+
+func TestTenantCache_ShouldCleanupInactiveUsers(t *testing.T) {
+	now := time.Now()
+	c := newTestTenantCache(NewMockCache(), mockTenantLimits{}, &now)
+	defer c.Stop()
+
+	ctx := user.InjectOrgID(context.Background(), "user-a")
+	c.Store(ctx, []string{"key"}, [][]byte{[]byte("value")})
+	c.Fetch(ctx, []string{"key"})
+
+	// The state is kept until the stored entries expire.
+	c.cleanupInactiveUser("user-a")
+	assert.Contains(t, c.tenants, "user-a")
+	assert.Equal(t, 0, testutil.CollectAndCount(c.hits))
+
+	now = now.Add(2 * time.Hour)
+	c.cleanupInactiveUser("user-a")
+	assert.NotContains(t, c.tenants, "user-a")
+}
+
+func TestTenantCache_InvalidateHandler_ShouldRequireTenant(t *testing.T) {
+	now := time.Now()
+	c := newTestTenantCache(NewMockCache(), mockTenantLimits{}, &now)
+
+	rec := httptest.NewRecorder()
+	c.InvalidateHandler(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -159,6 +159,12 @@ func (cfg *Config) Validate() error {
 		}
 	}
 
+	if cfg.ShardingEnabled {
+		if err := cfg.ShardingRing.Validate(); err != nil {
+			return errors.Wrap(err, "invalid sharding ring config")
+		}
+	}
+
 	return nil
 }
 
@@ -32,6 +32,8 @@ type RingConfig struct {
 	InstancePort           int      `yaml:"instance_port" doc:"hidden"`
 	InstanceAddr           string   `yaml:"instance_addr" doc:"hidden"`
 
+	TokenGenerator ring.TokenGeneratorConfig `yaml:",inline"`
+
 	// Injected internally
 	ListenPort int `yaml:"-"`
 
@@ -63,11 +65,17 @@ func (cfg *RingConfig) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.InstanceAddr, "compactor.ring.instance-addr", "", "IP address to advertise in the ring.")
 	f.IntVar(&cfg.InstancePort, "compactor.ring.instance-port", 0, "Port to advertise in the ring (defaults to server.grpc-listen-port).")
 	f.StringVar(&cfg.InstanceID, "compactor.ring.instance-id", hostname, "Instance ID to register in the ring.")
+	cfg.TokenGenerator.RegisterFlagsWithPrefix("compactor.ring.", f)
 
 	// Timeout durations
 	f.DurationVar(&cfg.WaitActiveInstanceTimeout, "compactor.ring.wait-active-instance-timeout", 10*time.Minute, "Timeout for waiting on compactor to become ACTIVE in the ring.")
 }
 
+// Validate the config.
+func (cfg *RingConfig) Validate() error {
+	return cfg.TokenGenerator.Validate("")
+}
+
 // ToLifecyclerConfig returns a LifecyclerConfig based on the compactor
 // ring config.
 func (cfg *RingConfig) ToLifecyclerConfig() ring.LifecyclerConfig {
@@ -98,6 +106,7 @@ func (cfg *RingConfig) ToLifecyclerConfig() ring.LifecyclerConfig {
 	lc.JoinAfter = 0
 	lc.MinReadyDuration = 0
 	lc.FinalSleep = 0
+	lc.TokenGenerator = cfg.TokenGenerator
 
 	// We use a safe default instead of exposing to config option to the user
 	// in order to simplify the config.
@@ -106,6 +106,13 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			expected: errors.Errorf(errInvalidBlockRanges, 30*time.Hour, 24*time.Hour).Error(),
 		},
+		"should fail with an unsupported tokens generation strategy when sharding is enabled": {
+			setup: func(cfg *Config) {
+				cfg.ShardingEnabled = true
+				cfg.ShardingRing.TokenGenerator.Strategy = "xxx"
+			},
+			expected: `invalid sharding ring config: unsupported tokens generation strategy "xxx", supported values are: random, spread-minimizing`,
+		},
 	}
 
 	for testName, testData := range tests {
@@ -53,6 +53,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/storegateway"
 	"github.com/cortexproject/cortex/pkg/tenant"
 	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/auth"
 	"github.com/cortexproject/cortex/pkg/util/fakeauth"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 	"github.com/cortexproject/cortex/pkg/util/grpc/healthcheck"
@@ -66,6 +67,13 @@ import (
 
 var (
 	errInvalidHTTPPrefix = errors.New("HTTP prefix should be empty or start with /")
+
+	// gRPC methods called by clients from outside of the Cortex cluster, which are authenticated
+	// by the built-in authentication, along with the scope they require. The other methods are
+	// only called by Cortex components, which propagate the tenant of the original request.
+	externalGRPCMethods = map[string]auth.Scope{
+		"/distributor.Distributor/Push": auth.ScopeWrite,
+	}
 )
 
 // The design pattern for Cortex is a series of config objects, which are
@@ -92,6 +100,7 @@ type Config struct {
 	PrintConfig bool                   `yaml:"-"`
 	HTTPPrefix  string                 `yaml:"http_prefix"`
 
+	Auth             auth.Config                     `yaml:"auth"`
 	API              api.Config                      `yaml:"api"`
 	Server           server.Config                   `yaml:"server"`
 	Distributor      distributor.Config              `yaml:"distributor"`
@@ -141,6 +150,7 @@ func (c *Config) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&c.PrintConfig, "print.config", false, "Print the config and exit.")
 	f.StringVar(&c.HTTPPrefix, "http.prefix", "/api/prom", "HTTP path prefix for Cortex API.")
 
+	c.Auth.RegisterFlags(f)
 	c.API.RegisterFlags(f)
 	c.registerServerFlagsWithChangedDefaultValues(f)
 	c.Distributor.RegisterFlags(f)
@@ -188,6 +198,11 @@ func (c *Config) Validate(log log.Logger) error {
 		return errInvalidHTTPPrefix
 	}
 
+	if c.AuthEnabled {
+		if err := c.Auth.Validate(); err != nil {
+			return errors.Wrap(err, "invalid auth config")
+		}
+	}
 	if err := c.Schema.Validate(); err != nil {
 		return errors.Wrap(err, "invalid schema config")
 	}
@@ -215,6 +230,9 @@ func (c *Config) Validate(log log.Logger) error {
 	if err := c.Distributor.Validate(c.LimitsConfig); err != nil {
 		return errors.Wrap(err, "invalid distributor config")
 	}
+	if err := c.Ingester.Validate(); err != nil {
+		return errors.Wrap(err, "invalid ingester config")
+	}
 	if err := c.Querier.Validate(); err != nil {
 		return errors.Wrap(err, "invalid querier config")
 	}
@@ -242,6 +260,9 @@ func (c *Config) Validate(log log.Logger) error {
 	if err := c.Alertmanager.Validate(c.AlertmanagerStorage); err != nil {
 		return errors.Wrap(err, "invalid alertmanager config")
 	}
+	if err := c.MemberlistKV.TCPTransport.Validate(); err != nil {
+		return errors.Wrap(err, "invalid memberlist config")
+	}
 
 	if c.Storage.Engine == storage.StorageEngineBlocks && c.Querier.SecondStoreEngine != storage.StorageEngineChunks && len(c.Schema.Configs) > 0 {
 		level.Warn(log).Log("schema configuration is not used by the blocks storage engine, and will have no effect")
@@ -361,17 +382,37 @@ func New(cfg Config) (*Cortex, error) {
 
 	// Don't check auth header on TransferChunks, as we weren't originally
 	// sending it and this could cause transfers to fail on update.
-	cfg.API.HTTPAuthMiddleware = fakeauth.SetupAuthMiddleware(&cfg.Server, cfg.AuthEnabled,
-		// Also don't check auth for these gRPC methods, since single call is used for multiple users (or no user like health check).
-		[]string{
-			"/grpc.health.v1.Health/Check",
-			"/cortex.Ingester/TransferChunks",
-			"/frontend.Frontend/Process",
-			"/frontend.Frontend/NotifyClientShutdown",
-			"/schedulerpb.SchedulerForFrontend/FrontendLoop",
-			"/schedulerpb.SchedulerForQuerier/QuerierLoop",
-			"/schedulerpb.SchedulerForQuerier/NotifyQuerierShutdown",
-		})
+	// Also don't check auth for these gRPC methods, since single call is used for multiple users (or no user like health check).
+	noGRPCAuthOn := []string{
+		"/grpc.health.v1.Health/Check",
+		"/cortex.Ingester/TransferChunks",
+		"/frontend.Frontend/Process",
+		"/frontend.Frontend/NotifyClientShutdown",
+		"/schedulerpb.SchedulerForFrontend/FrontendLoop",
+		"/schedulerpb.SchedulerForQuerier/QuerierLoop",
+		"/schedulerpb.SchedulerForQuerier/NotifyQuerierShutdown",
+	}
+
+	if cfg.AuthEnabled {
+		authenticator, err := auth.NewAuthenticator(cfg.Auth, util_log.Logger)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to initialize the authentication")
+		}
+
+		// The external gRPC methods are authenticated by the built-in authentication
+		// instead of trusting the tenant in the header.
+		if authenticator != nil {
+			cfg.API.Authenticator = authenticator
+			cfg.Server.GRPCMiddleware = append(cfg.Server.GRPCMiddleware, auth.UnaryServerInterceptor(authenticator, externalGRPCMethods, util_log.Logger))
+			cfg.Server.GRPCStreamMiddleware = append(cfg.Server.GRPCStreamMiddleware, auth.StreamServerInterceptor(authenticator, externalGRPCMethods, util_log.Logger))
+
+			for method := range externalGRPCMethods {
+				noGRPCAuthOn = append(noGRPCAuthOn, method)
+			}
+		}
+	}
+
+	cfg.API.HTTPAuthMiddleware = fakeauth.SetupAuthMiddleware(&cfg.Server, cfg.AuthEnabled, noGRPCAuthOn)
 
 	cortex := &Cortex{
 		Cfg: cfg,
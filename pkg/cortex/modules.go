@@ -23,6 +23,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/alertmanager/alertstore"
 	"github.com/cortexproject/cortex/pkg/api"
 	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/chunk/cache"
 	"github.com/cortexproject/cortex/pkg/chunk/purger"
 	"github.com/cortexproject/cortex/pkg/chunk/storage"
 	"github.com/cortexproject/cortex/pkg/compactor"
@@ -38,10 +39,12 @@ import (
 	"github.com/cortexproject/cortex/pkg/querier/tenantfederation"
 	querier_worker "github.com/cortexproject/cortex/pkg/querier/worker"
 	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/ring/kv"
 	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
 	"github.com/cortexproject/cortex/pkg/ring/kv/memberlist"
 	"github.com/cortexproject/cortex/pkg/ruler"
 	"github.com/cortexproject/cortex/pkg/scheduler"
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
 	"github.com/cortexproject/cortex/pkg/storegateway"
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
 	"github.com/cortexproject/cortex/pkg/util/modules"
@@ -104,6 +107,7 @@ func (t *Cortex) initAPI() (services.Service, error) {
 
 	t.API = a
 	t.API.RegisterAPI(t.Cfg.Server.PathPrefix, t.Cfg, newDefaultConfig())
+	t.API.RegisterMultiKV(kv.MultiClientsStatusHandler())
 
 	return nil, nil
 }
@@ -369,7 +373,20 @@ func (t *Cortex) initStoreQueryables() (services.Service, error) {
 			return nil, fmt.Errorf("failed to initialize querier for engine '%s': %v", t.Cfg.Querier.SecondStoreEngine, err)
 		}
 
-		t.StoreQueryables = append(t.StoreQueryables, querier.UseBeforeTimestampQueryable(sq, time.Time(t.Cfg.Querier.UseSecondStoreBeforeTime)))
+		var progress querier.ConversionProgress
+		if t.Cfg.Querier.UseBlocksConversionProgress {
+			if t.Cfg.Storage.Engine != storage.StorageEngineBlocks {
+				return nil, fmt.Errorf("the blocks conversion progress can only be used with the '%s' primary engine", storage.StorageEngineBlocks)
+			}
+
+			bkt, err := bucket.NewClient(context.Background(), t.Cfg.BlocksStorage.Bucket, "querier-blocks-conversion-progress", util_log.Logger, prometheus.DefaultRegisterer)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to create the bucket client for the blocks conversion progress")
+			}
+			progress = querier.NewBlocksConversionProgress(bkt, util_log.Logger)
+		}
+
+		t.StoreQueryables = append(t.StoreQueryables, querier.UseBeforeTenantTimestampQueryable(sq, time.Time(t.Cfg.Querier.UseSecondStoreBeforeTime), t.Overrides, progress))
 
 		if s, ok := sq.(services.Service); ok {
 			servs = append(servs, s)
@@ -422,6 +439,8 @@ func (t *Cortex) initIngesterService() (serv services.Service, err error) {
 	t.Cfg.Ingester.LifecyclerConfig.ListenPort = t.Cfg.Server.GRPCListenPort
 	t.Cfg.Ingester.DistributorShardingStrategy = t.Cfg.Distributor.ShardingStrategy
 	t.Cfg.Ingester.DistributorShardByAllLabels = t.Cfg.Distributor.ShardByAllLabels
+	t.Cfg.Ingester.QueryIngestersWithin = t.Cfg.Querier.QueryIngestersWithin
+	t.Cfg.Ingester.CompactorBlockRanges = t.Cfg.Compactor.BlockRanges
 	t.Cfg.Ingester.StreamTypeFn = ingesterChunkStreaming(t.RuntimeConfig)
 	t.Cfg.Ingester.InstanceLimitsFn = ingesterInstanceLimits(t.RuntimeConfig)
 	t.tsdbIngesterConfig()
@@ -515,7 +534,7 @@ func (t *Cortex) initQueryFrontendTripperware() (serv services.Service, err erro
 		}
 	}
 
-	tripperware, cache, err := queryrange.NewTripperware(
+	tripperware, resultsCache, err := queryrange.NewTripperware(
 		t.Cfg.QueryRange,
 		util_log.Logger,
 		t.Overrides,
@@ -543,10 +562,14 @@ func (t *Cortex) initQueryFrontendTripperware() (serv services.Service, err erro
 
 	t.QueryFrontendTripperware = tripperware
 
+	if tenantCache, ok := resultsCache.(*cache.TenantCache); ok {
+		t.API.RegisterResultsCache(tenantCache)
+	}
+
 	return services.NewIdleService(nil, func(_ error) error {
-		if cache != nil {
-			cache.Stop()
-			cache = nil
+		if resultsCache != nil {
+			resultsCache.Stop()
+			resultsCache = nil
 		}
 		return nil
 	}), nil
@@ -561,7 +584,7 @@ func (t *Cortex) initQueryFrontend() (serv services.Service, err error) {
 	// Wrap roundtripper into Tripperware.
 	roundTripper = t.QueryFrontendTripperware(roundTripper)
 
-	handler := transport.NewHandler(t.Cfg.Frontend.Handler, roundTripper, util_log.Logger, prometheus.DefaultRegisterer)
+	handler := transport.NewHandler(t.Cfg.Frontend.Handler, roundTripper, t.Overrides, util_log.Logger, prometheus.DefaultRegisterer)
 	t.API.RegisterQueryFrontendHandler(handler)
 
 	if frontendV1 != nil {
@@ -707,6 +730,7 @@ func (t *Cortex) initConfig() (serv services.Service, err error) {
 
 func (t *Cortex) initAlertManager() (serv services.Service, err error) {
 	t.Cfg.Alertmanager.ShardingRing.ListenPort = t.Cfg.Server.GRPCListenPort
+	t.Cfg.Alertmanager.SharedConfigFn = alertmanagerSharedConfig(t.RuntimeConfig)
 
 	// Initialise the store.
 	var store alertstore.AlertStore
@@ -72,6 +72,29 @@ func TestLoadRuntimeConfig_MissingPointerFieldsAreNil(t *testing.T) {
 
 	// Ensure that when settings are omitted, the pointers are nil. See #4228
 	assert.Nil(t, actualCfg.IngesterLimits)
+	assert.Nil(t, actualCfg.AlertmanagerShared)
+}
+
+func TestLoadRuntimeConfig_ShouldLoadAlertmanagerSharedConfig(t *testing.T) {
+	yamlFile := strings.NewReader(`
+alertmanager_shared_config:
+  receivers:
+    - name: corp-slack
+      slack_configs:
+        - api_url: https://hooks.slack.com/services/secret
+          channel: '#alerts'
+  templates:
+    corp.tmpl: '{{ define "corp.title" }}title{{ end }}'
+`)
+	actual, err := loadRuntimeConfig(yamlFile)
+	require.NoError(t, err)
+
+	shared := actual.(*runtimeConfigValues).AlertmanagerShared
+	require.NotNil(t, shared)
+	require.Len(t, shared.Receivers, 1)
+	assert.Equal(t, "corp-slack", shared.Receivers[0].Name)
+	assert.Equal(t, "https://hooks.slack.com/services/secret", shared.Receivers[0].SlackConfigs[0].APIURL.String())
+	assert.Equal(t, map[string]string{"corp.tmpl": `{{ define "corp.title" }}title{{ end }}`}, shared.Templates)
 }
 
 func TestLoadRuntimeConfig_ShouldReturnErrorOnMultipleDocumentsInTheConfig(t *testing.T) {
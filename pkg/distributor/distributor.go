@@ -23,6 +23,7 @@ import (
 	"github.com/weaveworks/common/instrument"
 	"github.com/weaveworks/common/user"
 	"go.uber.org/atomic"
+	"google.golang.org/grpc/status"
 
 	"github.com/cortexproject/cortex/pkg/cortexpb"
 	ingester_client "github.com/cortexproject/cortex/pkg/ingester/client"
@@ -48,9 +49,13 @@ var (
 	errInvalidShardingStrategy = errors.New("invalid sharding strategy")
 	errInvalidTenantShardSize  = errors.New("invalid tenant shard size, the value must be greater than 0")
 
+	errInvalidIngesterBackoffPeriod = errors.New("invalid ingester backoff period, the max period must be greater than or equal to the min period")
+
 	// Distributor instance limits errors.
 	errTooManyInflightPushRequests    = errors.New("too many inflight push requests in distributor")
 	errMaxSamplesPushRateLimitReached = errors.New("distributor's samples push rate limit reached")
+
+	errIngesterBackingOff = "cannot push: ingester is under memory pressure, please retry later"
 )
 
 const (
@@ -79,6 +84,9 @@ type Distributor struct {
 	// For handling HA replicas.
 	HATracker *haTracker
 
+	// For computing the streaming aggregation rules.
+	streamingAggregator *streamingAggregator
+
 	// Per-user rate limiter.
 	ingestionRateLimiter *limiter.RateLimiter
 
@@ -91,6 +99,9 @@ type Distributor struct {
 	ingestionRate        *util_math.EwmaRate
 	inflightPushRequests atomic.Int64
 
+	// Ingesters under memory pressure, which push requests are not sent to.
+	ingesterBackoff *ingesterBackoff
+
 	// Metrics
 	queryDuration                    *instrument.HistogramCollector
 	receivedSamples                  *prometheus.CounterVec
@@ -104,6 +115,7 @@ type Distributor struct {
 	labelsHistogram                  prometheus.Histogram
 	ingesterAppends                  *prometheus.CounterVec
 	ingesterAppendFailures           *prometheus.CounterVec
+	ingesterAppendBackoffs           *prometheus.CounterVec
 	ingesterQueries                  *prometheus.CounterVec
 	ingesterQueryFailures            *prometheus.CounterVec
 	replicationFactor                prometheus.Gauge
@@ -125,9 +137,14 @@ type Config struct {
 	ShardByAllLabels bool   `yaml:"shard_by_all_labels"`
 	ExtendWrites     bool   `yaml:"extend_writes"`
 
+	IngesterBackoffMinPeriod time.Duration `yaml:"ingester_backoff_min_period"`
+	IngesterBackoffMaxPeriod time.Duration `yaml:"ingester_backoff_max_period"`
+
 	// Distributors ring
 	DistributorRing RingConfig `yaml:"ring"`
 
+	StreamingAggregation StreamingAggregationConfig `yaml:"streaming_aggregation"`
+
 	// for testing and for extending the ingester by adding calls to the client
 	IngesterClientFactory ring_client.PoolFactory `yaml:"-"`
 
@@ -152,6 +169,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.PoolConfig.RegisterFlags(f)
 	cfg.HATrackerConfig.RegisterFlags(f)
 	cfg.DistributorRing.RegisterFlags(f)
+	cfg.StreamingAggregation.RegisterFlags(f)
 
 	f.IntVar(&cfg.MaxRecvMsgSize, "distributor.max-recv-msg-size", 100<<20, "remote_write API max receive message size (bytes).")
 	f.DurationVar(&cfg.RemoteTimeout, "distributor.remote-timeout", 2*time.Second, "Timeout for downstream ingesters.")
@@ -160,6 +178,9 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.ShardingStrategy, "distributor.sharding-strategy", util.ShardingStrategyDefault, fmt.Sprintf("The sharding strategy to use. Supported values are: %s.", strings.Join(supportedShardingStrategies, ", ")))
 	f.BoolVar(&cfg.ExtendWrites, "distributor.extend-writes", true, "Try writing to an additional ingester in the presence of an ingester not in the ACTIVE state. It is useful to disable this along with -ingester.unregister-on-shutdown=false in order to not spread samples to extra ingesters during rolling restarts with consistent naming.")
 
+	f.DurationVar(&cfg.IngesterBackoffMinPeriod, "distributor.ingester-backoff-min-period", 100*time.Millisecond, "Period during which push requests are not sent to an ingester once it reported being under memory pressure. The period doubles each time the ingester is still under pressure, up to -distributor.ingester-backoff-max-period, and resets once the ingester accepts a push request. 0 to disable.")
+	f.DurationVar(&cfg.IngesterBackoffMaxPeriod, "distributor.ingester-backoff-max-period", 5*time.Second, "Max period during which push requests are not sent to an ingester under memory pressure.")
+
 	f.Float64Var(&cfg.InstanceLimits.MaxIngestionRate, "distributor.instance-limits.max-ingestion-rate", 0, "Max ingestion rate (samples/sec) that this distributor will accept. This limit is per-distributor, not per-tenant. Additional push requests will be rejected. Current ingestion rate is computed as exponentially weighted moving average, updated every second. 0 = unlimited.")
 	f.IntVar(&cfg.InstanceLimits.MaxInflightPushRequests, "distributor.instance-limits.max-inflight-push-requests", 0, "Max inflight push requests that this distributor can handle. This limit is per-distributor, not per-tenant. Additional requests will be rejected. 0 = unlimited.")
 }
@@ -174,6 +195,10 @@ func (cfg *Config) Validate(limits validation.Limits) error {
 		return errInvalidTenantShardSize
 	}
 
+	if cfg.IngesterBackoffMinPeriod > 0 && cfg.IngesterBackoffMaxPeriod < cfg.IngesterBackoffMinPeriod {
+		return errInvalidIngesterBackoffPeriod
+	}
+
 	return cfg.HATrackerConfig.Validate()
 }
 
@@ -208,20 +233,35 @@ func New(cfg Config, clientConfig ingester_client.Config, limits *validation.Ove
 	var distributorsLifeCycler *ring.Lifecycler
 	var distributorsRing *ring.Ring
 
-	if !canJoinDistributorsRing {
-		ingestionRateStrategy = newInfiniteIngestionRateStrategy()
-	} else if limits.IngestionRateStrategy() == validation.GlobalIngestionRateStrategy {
-		distributorsLifeCycler, err = ring.NewLifecycler(cfg.DistributorRing.ToLifecyclerConfig(), nil, "distributor", ring.DistributorRingKey, true, reg)
+	globalIngestionRate := limits.IngestionRateStrategy() == validation.GlobalIngestionRateStrategy
+
+	// The distributors ring is used by the global ingestion rate limit strategy, and to find
+	// the distributors owning the streaming aggregations. The distributors running as an internal
+	// dependency don't join the ring, but forward the streaming aggregation input series too.
+	if canJoinDistributorsRing && (globalIngestionRate || cfg.StreamingAggregation.Enabled) {
+		lifecyclerCfg := cfg.DistributorRing.ToLifecyclerConfig()
+		if cfg.StreamingAggregation.Enabled {
+			lifecyclerCfg.NumTokens = streamingAggregationRingNumTokens
+		}
+
+		distributorsLifeCycler, err = ring.NewLifecycler(lifecyclerCfg, nil, "distributor", ring.DistributorRingKey, true, reg)
 		if err != nil {
 			return nil, err
 		}
+		subservices = append(subservices, distributorsLifeCycler)
+	}
 
+	if distributorsLifeCycler != nil || cfg.StreamingAggregation.Enabled {
 		distributorsRing, err = ring.New(cfg.DistributorRing.ToRingConfig(), "distributor", ring.DistributorRingKey, reg)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to initialize distributors' ring client")
 		}
-		subservices = append(subservices, distributorsLifeCycler, distributorsRing)
+		subservices = append(subservices, distributorsRing)
+	}
 
+	if !canJoinDistributorsRing {
+		ingestionRateStrategy = newInfiniteIngestionRateStrategy()
+	} else if globalIngestionRate {
 		ingestionRateStrategy = newGlobalIngestionRateStrategy(limits, distributorsLifeCycler)
 	} else {
 		ingestionRateStrategy = newLocalIngestionRateStrategy(limits)
@@ -238,6 +278,7 @@ func New(cfg Config, clientConfig ingester_client.Config, limits *validation.Ove
 		ingestionRateLimiter:   limiter.NewRateLimiter(ingestionRateStrategy, 10*time.Second),
 		HATracker:              haTracker,
 		ingestionRate:          util_math.NewEWMARate(0.2, instanceIngestionRateTickInterval),
+		ingesterBackoff:        newIngesterBackoff(cfg.IngesterBackoffMinPeriod, cfg.IngesterBackoffMaxPeriod),
 
 		queryDuration: instrument.NewHistogramCollector(promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
 			Namespace: "cortex",
@@ -301,6 +342,11 @@ func New(cfg Config, clientConfig ingester_client.Config, limits *validation.Ove
 			Name:      "distributor_ingester_append_failures_total",
 			Help:      "The total number of failed batch appends sent to ingesters.",
 		}, []string{"ingester", "type"}),
+		ingesterAppendBackoffs: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "distributor_ingester_append_backoffs_total",
+			Help:      "The total number of batch appends not sent to ingesters backing off because under memory pressure.",
+		}, []string{"ingester"}),
 		ingesterQueries: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 			Namespace: "cortex",
 			Name:      "distributor_ingester_queries_total",
@@ -348,8 +394,27 @@ func New(cfg Config, clientConfig ingester_client.Config, limits *validation.Ove
 
 	d.replicationFactor.Set(float64(ingestersRing.ReplicationFactor()))
 	d.activeUsers = util.NewActiveUsersCleanupWithDefaultValues(d.cleanupInactiveUser)
-
 	subservices = append(subservices, d.ingesterPool, d.activeUsers)
+
+	var (
+		distributorPool *ring_client.Pool
+		instanceAddr    string
+	)
+	if cfg.StreamingAggregation.Enabled {
+		if cfg.StreamingAggregation.DistributorClientFactory == nil {
+			cfg.StreamingAggregation.DistributorClientFactory = newDistributorClientFactory(clientConfig.GRPCClientConfig, reg)
+		}
+		distributorPool = newDistributorClientPool(distributorsRing, cfg.StreamingAggregation.DistributorClientFactory, cfg.RemoteTimeout, log, reg)
+		subservices = append(subservices, distributorPool)
+
+		if distributorsLifeCycler != nil {
+			instanceAddr = distributorsLifeCycler.Addr
+		}
+	}
+
+	// The streaming aggregator isn't part of the subservices, since it pushes the partial
+	// aggregations when stopping, so it must be stopped before the other subservices.
+	d.streamingAggregator = newStreamingAggregator(cfg.StreamingAggregation, instanceAddr, distributorsRing, distributorPool, cfg.RemoteTimeout, limits, d.Push, reg, log)
 	d.subservices, err = services.NewManager(subservices...)
 	if err != nil {
 		return nil, err
@@ -367,7 +432,12 @@ func (d *Distributor) starting(ctx context.Context) error {
 	}
 
 	// Only report success if all sub-services start properly
-	return services.StartManagerAndAwaitHealthy(ctx, d.subservices)
+	if err := services.StartManagerAndAwaitHealthy(ctx, d.subservices); err != nil {
+		return err
+	}
+
+	d.subservicesWatcher.WatchService(d.streamingAggregator)
+	return services.StartAndAwaitRunning(ctx, d.streamingAggregator)
 }
 
 func (d *Distributor) running(ctx context.Context) error {
@@ -392,6 +462,7 @@ func (d *Distributor) cleanupInactiveUser(userID string) {
 	d.ingestersRing.CleanupShuffleShardCache(userID)
 
 	d.HATracker.cleanupHATrackerMetricsForUser(userID)
+	d.streamingAggregator.cleanupMetricsForUser(userID)
 
 	d.receivedSamples.DeleteLabelValues(userID)
 	d.receivedExemplars.DeleteLabelValues(userID)
@@ -411,51 +482,31 @@ func (d *Distributor) cleanupInactiveUser(userID string) {
 
 // Called after distributor is asked to stop via StopAsync.
 func (d *Distributor) stopping(_ error) error {
+	if err := services.StopAndAwaitTerminated(context.Background(), d.streamingAggregator); err != nil {
+		level.Warn(d.log).Log("msg", "failed to stop the streaming aggregator", "err", err)
+	}
+
 	return services.StopManagerAndAwaitStopped(context.Background(), d.subservices)
 }
 
 func (d *Distributor) tokenForLabels(userID string, labels []cortexpb.LabelAdapter) (uint32, error) {
 	if d.cfg.ShardByAllLabels {
-		return shardByAllLabels(userID, labels), nil
+		return ingester_client.ShardByAllLabels(userID, labels), nil
 	}
 
 	unsafeMetricName, err := extract.UnsafeMetricNameFromLabelAdapters(labels)
 	if err != nil {
 		return 0, err
 	}
-	return shardByMetricName(userID, unsafeMetricName), nil
+	return ingester_client.ShardByMetricName(userID, unsafeMetricName), nil
 }
 
 func (d *Distributor) tokenForMetadata(userID string, metricName string) uint32 {
 	if d.cfg.ShardByAllLabels {
-		return shardByMetricName(userID, metricName)
+		return ingester_client.ShardByMetricName(userID, metricName)
 	}
 
-	return shardByUser(userID)
-}
-
-// shardByMetricName returns the token for the given metric. The provided metricName
-// is guaranteed to not be retained.
-func shardByMetricName(userID string, metricName string) uint32 {
-	h := shardByUser(userID)
-	h = ingester_client.HashAdd32(h, metricName)
-	return h
-}
-
-func shardByUser(userID string) uint32 {
-	h := ingester_client.HashNew32()
-	h = ingester_client.HashAdd32(h, userID)
-	return h
-}
-
-// This function generates different values for different order of same labels.
-func shardByAllLabels(userID string, labels []cortexpb.LabelAdapter) uint32 {
-	h := shardByUser(userID)
-	for _, label := range labels {
-		h = ingester_client.HashAdd32(h, label.Name)
-		h = ingester_client.HashAdd32(h, label.Value)
-	}
-	return h
+	return ingester_client.ShardByUser(userID)
 }
 
 // Remove the label labelname from a slice of LabelPairs if it exists.
@@ -592,6 +643,7 @@ func (d *Distributor) Push(ctx context.Context, req *cortexpb.WriteRequest) (*co
 	seriesKeys := make([]uint32, 0, len(req.Timeseries))
 	validatedSamples := 0
 	validatedExemplars := 0
+	aggregationForwards := streamingAggregationForwards{}
 
 	if d.limits.AcceptHASamples(userID) && len(req.Timeseries) > 0 {
 		cluster, replica := findHALabels(d.limits.HAReplicaLabel(userID), d.limits.HAClusterLabel(userID), req.Timeseries[0].Labels)
@@ -685,6 +737,16 @@ func (d *Distributor) Push(ctx context.Context, req *cortexpb.WriteRequest) (*co
 			continue
 		}
 
+		// The series aggregated by streaming aggregation rules dropping the input aren't ingested.
+		drop, err := d.streamingAggregator.route(userID, validatedSeries, now, aggregationForwards)
+		if err != nil {
+			cortexpb.ReuseSlice(req.Timeseries)
+			return nil, err
+		}
+		if drop {
+			continue
+		}
+
 		seriesKeys = append(seriesKeys, key)
 		validatedTimeseries = append(validatedTimeseries, validatedSeries)
 		validatedSamples += len(ts.Samples)
@@ -706,6 +768,13 @@ func (d *Distributor) Push(ctx context.Context, req *cortexpb.WriteRequest) (*co
 		validatedMetadata = append(validatedMetadata, m)
 	}
 
+	// The input series are forwarded before being ingested, since they reference the request
+	// buffer. Retrying a failed push request doesn't aggregate the same samples twice.
+	if err := d.streamingAggregator.forward(ctx, userID, aggregationForwards); err != nil {
+		cortexpb.ReuseSlice(req.Timeseries)
+		return nil, err
+	}
+
 	d.receivedSamples.WithLabelValues(userID).Add(float64(validatedSamples))
 	d.receivedExemplars.WithLabelValues(userID).Add((float64(validatedExemplars)))
 	d.receivedMetadata.WithLabelValues(userID).Add(float64(len(validatedMetadata)))
@@ -779,6 +848,22 @@ func (d *Distributor) Push(ctx context.Context, req *cortexpb.WriteRequest) (*co
 	return &cortexpb.WriteResponse{}, firstPartialErr
 }
 
+// Aggregate implements distributorpb.DistributorServer, computing the streaming aggregations owned
+// by this distributor on the input series forwarded by the other distributors.
+func (d *Distributor) Aggregate(ctx context.Context, req *cortexpb.WriteRequest) (*cortexpb.WriteResponse, error) {
+	defer cortexpb.ReuseSlice(req.Timeseries)
+
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.streamingAggregator.aggregate(ctx, userID, req.Timeseries, time.Now()); err != nil {
+		return nil, err
+	}
+	return &cortexpb.WriteResponse{}, nil
+}
+
 func sortLabelsIfNeeded(labels []cortexpb.LabelAdapter) {
 	// no need to run sort.Slice, if labels are already sorted, which is most of the time.
 	// we can avoid extra memory allocations (mostly interface-related) this way.
@@ -802,6 +887,13 @@ func sortLabelsIfNeeded(labels []cortexpb.LabelAdapter) {
 }
 
 func (d *Distributor) send(ctx context.Context, ingester ring.InstanceDesc, timeseries []cortexpb.PreallocTimeseries, metadata []*cortexpb.MetricMetadata, source cortexpb.WriteRequest_SourceEnum) error {
+	// Don't send the request to an ingester under memory pressure, and let the
+	// client retry later if the quorum can't be reached without it.
+	if d.ingesterBackoff.backingOff(ingester.Addr, time.Now()) {
+		d.ingesterAppendBackoffs.WithLabelValues(ingester.Addr).Inc()
+		return httpgrpc.Errorf(http.StatusTooManyRequests, "%s", errIngesterBackingOff)
+	}
+
 	h, err := d.ingesterPool.GetClientFor(ingester.Addr)
 	if err != nil {
 		return err
@@ -815,6 +907,16 @@ func (d *Distributor) send(ctx context.Context, ingester ring.InstanceDesc, time
 	}
 	_, err = c.Push(ctx, &req)
 
+	// The ingester returns a memory pressure error when under memory pressure, which we
+	// convert to 429 so that the client slows down instead of failing. Other ResourceExhausted
+	// errors, like messages exceeding the gRPC max size, aren't retryable.
+	if err == nil {
+		d.ingesterBackoff.accepted(ingester.Addr)
+	} else if ingester_client.IsMemoryPressureError(err) {
+		d.ingesterBackoff.underPressure(ingester.Addr, time.Now())
+		err = httpgrpc.Errorf(http.StatusTooManyRequests, "%s", status.Convert(err).Message())
+	}
+
 	if len(metadata) > 0 {
 		d.ingesterAppends.WithLabelValues(ingester.Addr, typeMetadata).Inc()
 		if err != nil {
@@ -0,0 +1,83 @@
+package distributor
+
+import (
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/cortexproject/cortex/pkg/distributor/distributorpb"
+	"github.com/cortexproject/cortex/pkg/ring"
+	ring_client "github.com/cortexproject/cortex/pkg/ring/client"
+	"github.com/cortexproject/cortex/pkg/util/grpcclient"
+)
+
+// newDistributorClientPool returns the pool of clients used to forward the input series of the
+// streaming aggregation rules to the distributors owning their aggregation.
+func newDistributorClientPool(distributorsRing ring.ReadRing, factory ring_client.PoolFactory, remoteTimeout time.Duration, logger log.Logger, reg prometheus.Registerer) *ring_client.Pool {
+	// We prefer sane defaults instead of exposing further config options.
+	poolCfg := ring_client.PoolConfig{
+		CheckInterval:      15 * time.Second,
+		HealthCheckEnabled: true,
+		HealthCheckTimeout: remoteTimeout,
+	}
+
+	clientsCount := promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+		Name: "cortex_distributor_distributor_clients",
+		Help: "The current number of clients connected to other distributors.",
+	})
+
+	return ring_client.NewPool("distributor", poolCfg, ring_client.NewRingServiceDiscovery(distributorsRing), factory, clientsCount, logger)
+}
+
+func newDistributorClientFactory(clientCfg grpcclient.Config, reg prometheus.Registerer) ring_client.PoolFactory {
+	requestDuration := promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cortex_distributor_client_request_duration_seconds",
+		Help:    "Time spent executing requests to other distributors.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 7),
+	}, []string{"operation", "status_code"})
+
+	return func(addr string) (ring_client.PoolClient, error) {
+		return dialDistributorClient(clientCfg, addr, requestDuration)
+	}
+}
+
+func dialDistributorClient(clientCfg grpcclient.Config, addr string, requestDuration *prometheus.HistogramVec) (*distributorExtendedClient, error) {
+	opts, err := clientCfg.DialOption(grpcclient.Instrument(requestDuration))
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to dial distributor %s", addr)
+	}
+
+	return &distributorExtendedClient{
+		DistributorClient: distributorpb.NewDistributorClient(conn),
+		HealthClient:      grpc_health_v1.NewHealthClient(conn),
+		conn:              conn,
+	}, nil
+}
+
+type distributorExtendedClient struct {
+	distributorpb.DistributorClient
+	grpc_health_v1.HealthClient
+	conn *grpc.ClientConn
+}
+
+func (c *distributorExtendedClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *distributorExtendedClient) String() string {
+	return c.RemoteAddress()
+}
+
+func (c *distributorExtendedClient) RemoteAddress() string {
+	return c.conn.Target()
+}
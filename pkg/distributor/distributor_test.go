@@ -25,6 +25,7 @@ import (
 	"github.com/weaveworks/common/httpgrpc"
 	"github.com/weaveworks/common/user"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
 
@@ -620,6 +621,72 @@ func TestDistributor_PushInstanceLimits(t *testing.T) {
 	}
 }
 
+func TestDistributor_PushIngesterBackoff(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "user")
+
+	distributors, ingesters, r, regs := prepare(t, prepConfig{
+		numIngesters:     3,
+		happyIngesters:   3,
+		numDistributors:  1,
+		shardByAllLabels: true,
+	})
+	defer stopAll(distributors, r)
+
+	d := distributors[0]
+	d.ingesterBackoff = newIngesterBackoff(time.Hour, time.Hour)
+
+	setPushErr := func(err error) {
+		for i := 0; i < 2; i++ {
+			ingesters[i].Lock()
+			ingesters[i].pushErr = err
+			ingesters[i].Unlock()
+		}
+	}
+
+	// Other ResourceExhausted errors, like messages exceeding the gRPC max size, are not retryable
+	// and don't make the distributor back off from the ingesters.
+	setPushErr(status.Error(codes.ResourceExhausted, "grpc: received message larger than max"))
+
+	_, err := d.Push(ctx, makeWriteRequest(0, 1, 0))
+	require.Error(t, err)
+	_, ok := httpgrpc.HTTPResponseFromError(err)
+	assert.False(t, ok)
+	assert.False(t, d.ingesterBackoff.backingOff("0", time.Now()))
+
+	// Two ingesters out of three are under memory pressure, so the quorum can't be reached.
+	setPushErr(client.NewMemoryPressureError("heap in use"))
+
+	_, err = d.Push(ctx, makeWriteRequest(0, 1, 0))
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	assert.Equal(t, int32(http.StatusTooManyRequests), resp.Code)
+
+	// The next request is not sent to the ingesters under memory pressure.
+	setPushErr(nil)
+
+	_, err = d.Push(ctx, makeWriteRequest(0, 1, 0))
+	resp, ok = httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	assert.Equal(t, int32(http.StatusTooManyRequests), resp.Code)
+	assert.Equal(t, errIngesterBackingOff, string(resp.Body))
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, 2, ingesters[i].countCalls("Push"))
+	}
+
+	assert.NoError(t, testutil.GatherAndCompare(regs[0], strings.NewReader(`
+		# HELP cortex_distributor_ingester_append_backoffs_total The total number of batch appends not sent to ingesters backing off because under memory pressure.
+		# TYPE cortex_distributor_ingester_append_backoffs_total counter
+		cortex_distributor_ingester_append_backoffs_total{ingester="0"} 1
+		cortex_distributor_ingester_append_backoffs_total{ingester="1"} 1
+	`), "cortex_distributor_ingester_append_backoffs_total"))
+
+	// Once the backoff period has elapsed, the requests are sent to the ingesters again.
+	d.ingesterBackoff = newIngesterBackoff(time.Millisecond, time.Millisecond)
+	_, err = d.Push(ctx, makeWriteRequest(0, 1, 0))
+	require.NoError(t, err)
+}
+
 func TestDistributor_PushHAInstances(t *testing.T) {
 	ctx := user.InjectOrgID(context.Background(), "user")
 
@@ -896,6 +963,78 @@ func TestDistributor_PushQuery(t *testing.T) {
 	}
 }
 
+func TestDistributor_ZoneQuorum(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "user")
+	allSeriesMatchers := []*labels.Matcher{
+		labels.MustNewMatcher(labels.MatchRegexp, model.MetricNameLabel, ".+"),
+	}
+
+	for name, tc := range map[string]struct {
+		happyIngesters int
+		// Index of the ingester failing to ingest the series, while still answering the queries.
+		laggingIngester int
+		expectedPushErr error
+		expectedReadErr bool
+	}{
+		"all zones happy": {
+			happyIngesters:  3,
+			laggingIngester: -1,
+		},
+		"one zone missing the writes and answering first": {
+			happyIngesters:  3,
+			laggingIngester: 0,
+		},
+		"two zones happy": {
+			happyIngesters:  2,
+			laggingIngester: -1,
+		},
+		"one zone happy": {
+			happyIngesters:  1,
+			laggingIngester: -1,
+			expectedPushErr: errFail,
+			expectedReadErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Each ingester is in a different zone, and each series is replicated to all of them.
+			ds, ingesters, r, _ := prepare(t, prepConfig{
+				numIngesters:      3,
+				happyIngesters:    tc.happyIngesters,
+				numDistributors:   1,
+				shardByAllLabels:  true,
+				zoneQuorumEnabled: true,
+			})
+			defer stopAll(ds, r)
+
+			// The lagging ingester answers the queries before the others, so that the read
+			// would miss the series if it didn't wait for a majority of zones.
+			if tc.laggingIngester >= 0 {
+				for i := range ingesters {
+					if i == tc.laggingIngester {
+						ingesters[i].pushErr = errFail
+					} else {
+						ingesters[i].queryDelay = 100 * time.Millisecond
+					}
+				}
+			}
+
+			// Writes require the majority of zones to succeed.
+			_, err := ds[0].Push(ctx, makeWriteRequest(0, 5, 0))
+			assert.Equal(t, tc.expectedPushErr, err)
+
+			// Reads require the majority of zones to answer too, so that they always
+			// include the acknowledged writes.
+			res, err := ds[0].QueryStream(ctx, math.MinInt32, math.MaxInt32, allSeriesMatchers...)
+			if tc.expectedReadErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, res.Chunkseries, 5)
+		})
+	}
+}
+
 func TestDistributor_QueryStream_ShouldReturnErrorIfMaxChunksPerQueryLimitIsReached(t *testing.T) {
 	const maxChunksLimit = 30 // Chunks are duplicated due to replication factor.
 
@@ -1899,6 +2038,8 @@ type prepConfig struct {
 	maxInflightRequests          int
 	maxIngestionRate             float64
 	replicationFactor            int
+	streamingAggregation         bool
+	zoneQuorumEnabled            bool
 }
 
 func prepare(t *testing.T, cfg prepConfig) ([]*Distributor, []mockIngester, *ring.Ring, []*prometheus.Registry) {
@@ -1920,9 +2061,13 @@ func prepare(t *testing.T, cfg prepConfig) ([]*Distributor, []mockIngester, *rin
 	ingestersByAddr := map[string]*mockIngester{}
 	for i := range ingesters {
 		addr := fmt.Sprintf("%d", i)
+		zone := ""
+		if cfg.zoneQuorumEnabled {
+			zone = fmt.Sprintf("zone-%d", i%3)
+		}
 		ingesterDescs[addr] = ring.InstanceDesc{
 			Addr:                addr,
-			Zone:                "",
+			Zone:                zone,
 			State:               ring.ACTIVE,
 			Timestamp:           time.Now().Unix(),
 			RegisteredTimestamp: time.Now().Add(-2 * time.Hour).Unix(),
@@ -1951,8 +2096,10 @@ func prepare(t *testing.T, cfg prepConfig) ([]*Distributor, []mockIngester, *rin
 		KVStore: kv.Config{
 			Mock: kvStore,
 		},
-		HeartbeatTimeout:  60 * time.Minute,
-		ReplicationFactor: rf,
+		HeartbeatTimeout:     60 * time.Minute,
+		ReplicationFactor:    rf,
+		ZoneAwarenessEnabled: cfg.zoneQuorumEnabled,
+		ZoneQuorumEnabled:    cfg.zoneQuorumEnabled,
 	}, ring.IngesterRingKey, ring.IngesterRingKey, nil)
 	require.NoError(t, err)
 	require.NoError(t, services.StartAndAwaitRunning(context.Background(), ingestersRing))
@@ -1967,6 +2114,11 @@ func prepare(t *testing.T, cfg prepConfig) ([]*Distributor, []mockIngester, *rin
 
 	distributors := make([]*Distributor, 0, cfg.numDistributors)
 	registries := make([]*prometheus.Registry, 0, cfg.numDistributors)
+
+	distributorsByAddr := map[string]*Distributor{}
+	distributorFactory := func(addr string) (ring_client.PoolClient, error) {
+		return &mockDistributorClient{distributor: distributorsByAddr[addr]}, nil
+	}
 	for i := 0; i < cfg.numDistributors; i++ {
 		if cfg.limits == nil {
 			cfg.limits = &validation.Limits{}
@@ -1988,6 +2140,12 @@ func prepare(t *testing.T, cfg prepConfig) ([]*Distributor, []mockIngester, *rin
 		distributorCfg.InstanceLimits.MaxInflightPushRequests = cfg.maxInflightRequests
 		distributorCfg.InstanceLimits.MaxIngestionRate = cfg.maxIngestionRate
 
+		if cfg.streamingAggregation {
+			distributorCfg.StreamingAggregation.Enabled = true
+			distributorCfg.StreamingAggregation.DistributorClientFactory = distributorFactory
+			distributorCfg.DistributorRing.InstanceAddr = fmt.Sprintf("127.0.0.%d", i+1)
+		}
+
 		if cfg.shuffleShardEnabled {
 			distributorCfg.ShardingStrategy = util.ShardingStrategyShuffle
 			distributorCfg.ShuffleShardingLookbackPeriod = time.Hour
@@ -2005,6 +2163,10 @@ func prepare(t *testing.T, cfg prepConfig) ([]*Distributor, []mockIngester, *rin
 
 		distributors = append(distributors, d)
 		registries = append(registries, reg)
+
+		if d.distributorsLifeCycler != nil {
+			distributorsByAddr[d.distributorsLifeCycler.Addr] = d
+		}
 	}
 
 	// If the distributors ring is setup, wait until the first distributor
@@ -2146,6 +2308,7 @@ type mockIngester struct {
 	metadata   map[uint32]map[cortexpb.MetricMetadata]struct{}
 	queryDelay time.Duration
 	calls      map[string]int
+	pushErr    error
 }
 
 func (i *mockIngester) series() map[uint32]*cortexpb.PreallocTimeseries {
@@ -2182,6 +2345,10 @@ func (i *mockIngester) Push(ctx context.Context, req *cortexpb.WriteRequest, opt
 		return nil, errFail
 	}
 
+	if i.pushErr != nil {
+		return nil, i.pushErr
+	}
+
 	if i.timeseries == nil {
 		i.timeseries = map[uint32]*cortexpb.PreallocTimeseries{}
 	}
@@ -2197,7 +2364,7 @@ func (i *mockIngester) Push(ctx context.Context, req *cortexpb.WriteRequest, opt
 
 	for j := range req.Timeseries {
 		series := req.Timeseries[j]
-		hash := shardByAllLabels(orgid, series.Labels)
+		hash := client.ShardByAllLabels(orgid, series.Labels)
 		existing, ok := i.timeseries[hash]
 		if !ok {
 			// Make a copy because the request Timeseries are reused
@@ -2216,7 +2383,7 @@ func (i *mockIngester) Push(ctx context.Context, req *cortexpb.WriteRequest, opt
 	}
 
 	for _, m := range req.Metadata {
-		hash := shardByMetricName(orgid, m.MetricFamilyName)
+		hash := client.ShardByMetricName(orgid, m.MetricFamilyName)
 		set, ok := i.metadata[hash]
 		if !ok {
 			set = map[cortexpb.MetricMetadata]struct{}{}
@@ -2573,13 +2740,13 @@ func TestRemoveReplicaLabel(t *testing.T) {
 
 // This is not great, but we deal with unsorted labels when validating labels.
 func TestShardByAllLabelsReturnsWrongResultsForUnsortedLabels(t *testing.T) {
-	val1 := shardByAllLabels("test", []cortexpb.LabelAdapter{
+	val1 := client.ShardByAllLabels("test", []cortexpb.LabelAdapter{
 		{Name: "__name__", Value: "foo"},
 		{Name: "bar", Value: "baz"},
 		{Name: "sample", Value: "1"},
 	})
 
-	val2 := shardByAllLabels("test", []cortexpb.LabelAdapter{
+	val2 := client.ShardByAllLabels("test", []cortexpb.LabelAdapter{
 		{Name: "__name__", Value: "foo"},
 		{Name: "sample", Value: "1"},
 		{Name: "bar", Value: "baz"},
@@ -21,12 +21,25 @@ import (
 	"github.com/cortexproject/cortex/pkg/ring/kv"
 	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
 	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/push"
 	"github.com/cortexproject/cortex/pkg/util/services"
 )
 
 var (
 	errNegativeUpdateTimeoutJitterMax = errors.New("HA tracker max update timeout jitter shouldn't be negative")
 	errInvalidFailoverTimeout         = "HA Tracker failover timeout (%v) must be at least 1s greater than update timeout - max jitter (%v)"
+	errUnknownHACluster               = errors.New("unknown HA cluster")
+)
+
+// maxElectionHistory is the max number of past elections kept in the KV store for each cluster.
+const maxElectionHistory = 10
+
+// Reasons of the replicas elections.
+const (
+	electionReasonFirst    = "first"    // No replica was elected for the cluster.
+	electionReasonFailover = "failover" // The elected replica stopped sending samples for longer than the failover timeout.
+	electionReasonDraining = "draining" // The elected replica sent the draining hint.
+	electionReasonForced   = "forced"   // The replica has been forced through the API.
 )
 
 type haTrackerLimits interface {
@@ -254,6 +267,7 @@ func (c *haTracker) loop(ctx context.Context) error {
 	})
 
 	wg.Wait()
+	kv.StopClient(c.client)
 	return nil
 }
 
@@ -360,13 +374,15 @@ func (c *haTracker) cleanupOldReplicas(ctx context.Context, deadline time.Time)
 // and may modify the stored data, for example to failover between replicas after a certain period of time.
 // replicasNotMatchError is returned (from checkKVStore) if we shouldn't store this sample but are
 // accepting samples from another replica for the cluster, so that there isn't a bunch of error's returned
-// to customers clients.
+// to customers clients. If the request has been sent by a draining replica, see push.HADrainingHeader,
+// the replica samples are still accepted if elected, but any other replica can be elected right away.
 func (c *haTracker) checkReplica(ctx context.Context, userID, cluster, replica string, now time.Time) error {
 	// If HA tracking isn't enabled then accept the sample
 	if !c.cfg.EnableHATracker {
 		return nil
 	}
 	key := fmt.Sprintf("%s/%s", userID, cluster)
+	draining := push.IsHADraining(ctx)
 
 	c.electedLock.RLock()
 	entry, ok := c.elected[key]
@@ -377,7 +393,10 @@ func (c *haTracker) checkReplica(ctx context.Context, userID, cluster, replica s
 		if entry.Replica != replica {
 			return replicasNotMatchError{replica: replica, elected: entry.Replica}
 		}
-		return nil
+		// The draining replica must be marked as such in the KV store.
+		if !draining {
+			return nil
+		}
 	}
 
 	if !ok {
@@ -387,7 +406,7 @@ func (c *haTracker) checkReplica(ctx context.Context, userID, cluster, replica s
 		}
 	}
 
-	err := c.checkKVStore(ctx, key, replica, now)
+	err := c.checkKVStore(ctx, key, replica, draining, now)
 	c.kvCASCalls.WithLabelValues(userID, cluster).Inc()
 	if err != nil {
 		// The callback within checkKVStore will return a replicasNotMatchError if the sample is being deduped,
@@ -399,12 +418,31 @@ func (c *haTracker) checkReplica(ctx context.Context, userID, cluster, replica s
 	return err
 }
 
-func (c *haTracker) checkKVStore(ctx context.Context, key, replica string, now time.Time) error {
+func (c *haTracker) checkKVStore(ctx context.Context, key, replica string, draining bool, now time.Time) error {
+	// The timestamp of a draining replica is stored as already older than the failover timeout,
+	// so that samples from any other replica trigger the failover.
+	receivedAt := now
+	if draining {
+		receivedAt = now.Add(-c.cfg.FailoverTimeout)
+	}
+
 	return c.client.CAS(ctx, key, func(in interface{}) (out interface{}, retry bool, err error) {
-		if desc, ok := in.(*ReplicaDesc); ok && desc.DeletedAt == 0 {
+		reason := electionReasonFirst
+		desc, ok := in.(*ReplicaDesc)
+		var history []ElectionDesc
+		if ok {
+			history = desc.History
+		}
+
+		if ok && desc.DeletedAt == 0 {
 			// We don't need to CAS and update the timestamp in the KV store if the timestamp we've received
 			// this sample at is less than updateTimeout amount of time since the timestamp in the KV store.
-			if desc.Replica == replica && now.Sub(timestamp.Time(desc.ReceivedAt)) < c.cfg.UpdateTimeout+c.updateTimeoutJitter {
+			if desc.Replica == replica && !draining && now.Sub(timestamp.Time(desc.ReceivedAt)) < c.cfg.UpdateTimeout+c.updateTimeoutJitter {
+				return nil, false, nil
+			}
+
+			// Neither we need to if the draining replica has already been marked as such.
+			if desc.Replica == replica && draining && !timestamp.Time(desc.ReceivedAt).After(receivedAt) {
 				return nil, false, nil
 			}
 
@@ -413,15 +451,80 @@ func (c *haTracker) checkKVStore(ctx context.Context, key, replica string, now t
 			if desc.Replica != replica && now.Sub(timestamp.Time(desc.ReceivedAt)) < c.cfg.FailoverTimeout {
 				return nil, false, replicasNotMatchError{replica: replica, elected: desc.Replica}
 			}
+
+			// The replica is still the elected one, we just update the timestamp.
+			if desc.Replica == replica {
+				return &ReplicaDesc{
+					Replica:    replica,
+					ReceivedAt: timestamp.FromTime(receivedAt),
+					DeletedAt:  0,
+					ElectedAt:  desc.ElectedAt,
+					Draining:   draining,
+					History:    history,
+				}, true, nil
+			}
+
+			reason = electionReasonFailover
+			if desc.Draining {
+				reason = electionReasonDraining
+			}
 		}
 
 		// There was either invalid or no data for the key, so we now accept samples
 		// from this replica. Invalid could mean that the timestamp in the KV store was
 		// out of date based on the update and failover timeouts when compared to now.
+		return &ReplicaDesc{
+			Replica:    replica,
+			ReceivedAt: timestamp.FromTime(receivedAt),
+			DeletedAt:  0,
+			ElectedAt:  timestamp.FromTime(now),
+			Draining:   draining,
+			History:    addElection(history, ElectionDesc{Replica: replica, ElectedAt: timestamp.FromTime(now), Reason: reason}),
+		}, true, nil
+	})
+}
+
+// addElection returns the history with the election added first, keeping at most maxElectionHistory elections.
+func addElection(history []ElectionDesc, election ElectionDesc) []ElectionDesc {
+	out := make([]ElectionDesc, 0, maxElectionHistory)
+	out = append(out, election)
+	for _, e := range history {
+		if len(out) == maxElectionHistory {
+			break
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// forceReplica elects the replica for the cluster right away, regardless of the failover timeout.
+// Samples from other replicas are rejected until the failover timeout since the forced election, or
+// since the last sample received from the forced replica, is reached.
+func (c *haTracker) forceReplica(ctx context.Context, userID, cluster, replica string, now time.Time) error {
+	key := fmt.Sprintf("%s/%s", userID, cluster)
+
+	c.electedLock.RLock()
+	_, ok := c.elected[key]
+	c.electedLock.RUnlock()
+
+	if !ok {
+		return errUnknownHACluster
+	}
+
+	level.Info(c.logger).Log("msg", "forcing HA replica election", "user", userID, "cluster", cluster, "replica", replica)
+
+	return c.client.CAS(ctx, key, func(in interface{}) (out interface{}, retry bool, err error) {
+		var history []ElectionDesc
+		if desc, ok := in.(*ReplicaDesc); ok {
+			history = desc.History
+		}
+
 		return &ReplicaDesc{
 			Replica:    replica,
 			ReceivedAt: timestamp.FromTime(now),
 			DeletedAt:  0,
+			ElectedAt:  timestamp.FromTime(now),
+			History:    addElection(history, ElectionDesc{Replica: replica, ElectedAt: timestamp.FromTime(now), Reason: electionReasonForced}),
 		}, true, nil
 	})
 }
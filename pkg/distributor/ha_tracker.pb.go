@@ -34,6 +34,12 @@ type ReplicaDesc struct {
 	// already remove entry from memory. Actual deletion from KV store does *not* trigger
 	// "watch" notification with a key for all KV stores.
 	DeletedAt int64 `protobuf:"varint,3,opt,name=deleted_at,json=deletedAt,proto3" json:"deleted_at,omitempty"`
+	// Unix timestamp in milliseconds when the replica has been elected.
+	ElectedAt int64 `protobuf:"varint,4,opt,name=elected_at,json=electedAt,proto3" json:"elected_at,omitempty"`
+	// Whether the replica has sent the draining hint, and the next replica can be elected right away.
+	Draining bool `protobuf:"varint,5,opt,name=draining,proto3" json:"draining,omitempty"`
+	// Most recent elections of the replica's cluster, most recent first.
+	History []ElectionDesc `protobuf:"bytes,6,rep,name=history,proto3" json:"history"`
 }
 
 func (m *ReplicaDesc) Reset()      { *m = ReplicaDesc{} }
@@ -89,28 +95,118 @@ func (m *ReplicaDesc) GetDeletedAt() int64 {
 	return 0
 }
 
+func (m *ReplicaDesc) GetElectedAt() int64 {
+	if m != nil {
+		return m.ElectedAt
+	}
+	return 0
+}
+
+func (m *ReplicaDesc) GetDraining() bool {
+	if m != nil {
+		return m.Draining
+	}
+	return false
+}
+
+func (m *ReplicaDesc) GetHistory() []ElectionDesc {
+	if m != nil {
+		return m.History
+	}
+	return nil
+}
+
+// ElectionDesc is an election of a replica.
+type ElectionDesc struct {
+	Replica string `protobuf:"bytes,1,opt,name=replica,proto3" json:"replica,omitempty"`
+	// Unix timestamp in milliseconds when the replica has been elected.
+	ElectedAt int64 `protobuf:"varint,2,opt,name=elected_at,json=electedAt,proto3" json:"elected_at,omitempty"`
+	// Why the replica has been elected.
+	Reason string `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+}
+
+func (m *ElectionDesc) Reset()      { *m = ElectionDesc{} }
+func (*ElectionDesc) ProtoMessage() {}
+func (*ElectionDesc) Descriptor() ([]byte, []int) {
+	return fileDescriptor_86f0e7bcf71d860b, []int{1}
+}
+func (m *ElectionDesc) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ElectionDesc) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ElectionDesc.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *ElectionDesc) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ElectionDesc.Merge(m, src)
+}
+func (m *ElectionDesc) XXX_Size() int {
+	return m.Size()
+}
+func (m *ElectionDesc) XXX_DiscardUnknown() {
+	xxx_messageInfo_ElectionDesc.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ElectionDesc proto.InternalMessageInfo
+
+func (m *ElectionDesc) GetReplica() string {
+	if m != nil {
+		return m.Replica
+	}
+	return ""
+}
+
+func (m *ElectionDesc) GetElectedAt() int64 {
+	if m != nil {
+		return m.ElectedAt
+	}
+	return 0
+}
+
+func (m *ElectionDesc) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*ReplicaDesc)(nil), "distributor.ReplicaDesc")
+	proto.RegisterType((*ElectionDesc)(nil), "distributor.ElectionDesc")
 }
 
 func init() { proto.RegisterFile("ha_tracker.proto", fileDescriptor_86f0e7bcf71d860b) }
 
 var fileDescriptor_86f0e7bcf71d860b = []byte{
-	// 224 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0xc8, 0x48, 0x8c, 0x2f,
-	0x29, 0x4a, 0x4c, 0xce, 0x4e, 0x2d, 0xd2, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2, 0x4e, 0xc9,
-	0x2c, 0x2e, 0x29, 0xca, 0x4c, 0x2a, 0x2d, 0xc9, 0x2f, 0x92, 0xd2, 0x4d, 0xcf, 0x2c, 0xc9, 0x28,
-	0x4d, 0xd2, 0x4b, 0xce, 0xcf, 0xd5, 0x4f, 0xcf, 0x4f, 0xcf, 0xd7, 0x07, 0xab, 0x49, 0x2a, 0x4d,
-	0x03, 0xf3, 0xc0, 0x1c, 0x30, 0x0b, 0xa2, 0x57, 0x29, 0x9d, 0x8b, 0x3b, 0x28, 0xb5, 0x20, 0x27,
-	0x33, 0x39, 0xd1, 0x25, 0xb5, 0x38, 0x59, 0x48, 0x82, 0x8b, 0xbd, 0x08, 0xc2, 0x95, 0x60, 0x54,
-	0x60, 0xd4, 0xe0, 0x0c, 0x82, 0x71, 0x85, 0xe4, 0xb9, 0xb8, 0x8b, 0x52, 0x93, 0x53, 0x33, 0xcb,
-	0x52, 0x53, 0xe2, 0x13, 0x4b, 0x24, 0x98, 0x14, 0x18, 0x35, 0x98, 0x83, 0xb8, 0x60, 0x42, 0x8e,
-	0x25, 0x42, 0xb2, 0x5c, 0x5c, 0x29, 0xa9, 0x39, 0xa9, 0x25, 0x10, 0x79, 0x66, 0xb0, 0x3c, 0x27,
-	0x54, 0xc4, 0xb1, 0xc4, 0xc9, 0xe4, 0xc2, 0x43, 0x39, 0x86, 0x1b, 0x0f, 0xe5, 0x18, 0x3e, 0x3c,
-	0x94, 0x63, 0x6c, 0x78, 0x24, 0xc7, 0xb8, 0xe2, 0x91, 0x1c, 0xe3, 0x89, 0x47, 0x72, 0x8c, 0x17,
-	0x1e, 0xc9, 0x31, 0x3e, 0x78, 0x24, 0xc7, 0xf8, 0xe2, 0x91, 0x1c, 0xc3, 0x87, 0x47, 0x72, 0x8c,
-	0x13, 0x1e, 0xcb, 0x31, 0x5c, 0x78, 0x2c, 0xc7, 0x70, 0xe3, 0xb1, 0x1c, 0x43, 0x12, 0x1b, 0xd8,
-	0x95, 0xc6, 0x80, 0x00, 0x00, 0x00, 0xff, 0xff, 0xb3, 0xd1, 0xdd, 0x8d, 0xf5, 0x00, 0x00, 0x00,
+	// 315 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x90, 0x3f, 0x4e, 0xc3, 0x30,
+	0x14, 0xc6, 0xfd, 0xda, 0xd2, 0x3f, 0x0e, 0x03, 0xca, 0x80, 0x42, 0x25, 0x5e, 0xa3, 0x4e, 0x59,
+	0x48, 0x25, 0x60, 0x61, 0x6c, 0x05, 0x17, 0xc8, 0x05, 0xaa, 0xc4, 0x31, 0xa9, 0x45, 0x89, 0x2b,
+	0xc7, 0x45, 0x62, 0xe3, 0x08, 0x1c, 0x83, 0xa3, 0x74, 0xec, 0xd8, 0x05, 0x44, 0xdd, 0x85, 0xb1,
+	0x47, 0x40, 0x75, 0x52, 0x54, 0x18, 0xd8, 0xfc, 0xfb, 0x7e, 0xcf, 0xd6, 0xfb, 0x4c, 0x4f, 0x26,
+	0xf1, 0x58, 0xab, 0x98, 0x3d, 0x70, 0x15, 0xce, 0x94, 0xd4, 0xd2, 0x75, 0x52, 0x51, 0x68, 0x25,
+	0x92, 0xb9, 0x96, 0xaa, 0x7b, 0x91, 0x09, 0x3d, 0x99, 0x27, 0x21, 0x93, 0x8f, 0x83, 0x4c, 0x66,
+	0x72, 0x60, 0x67, 0x92, 0xf9, 0xbd, 0x25, 0x0b, 0xf6, 0x54, 0xde, 0xed, 0xbf, 0x03, 0x75, 0x22,
+	0x3e, 0x9b, 0x0a, 0x16, 0xdf, 0xf2, 0x82, 0xb9, 0x1e, 0x6d, 0xa9, 0x12, 0x3d, 0xf0, 0x21, 0xe8,
+	0x44, 0x7b, 0x74, 0x7b, 0xd4, 0x51, 0x9c, 0x71, 0xf1, 0xc4, 0xd3, 0x71, 0xac, 0xbd, 0x9a, 0x0f,
+	0x41, 0x3d, 0xa2, 0xfb, 0x68, 0xa8, 0xdd, 0x73, 0x4a, 0x53, 0x3e, 0xe5, 0xba, 0xf4, 0x75, 0xeb,
+	0x3b, 0x55, 0x52, 0x6a, 0x3e, 0xe5, 0xac, 0xd2, 0x8d, 0x52, 0x57, 0xc9, 0x50, 0xbb, 0x5d, 0xda,
+	0x4e, 0x55, 0x2c, 0x72, 0x91, 0x67, 0xde, 0x91, 0x0f, 0x41, 0x3b, 0xfa, 0x61, 0xf7, 0x86, 0xb6,
+	0x26, 0xa2, 0xd0, 0x52, 0x3d, 0x7b, 0x4d, 0xbf, 0x1e, 0x38, 0x97, 0x67, 0xe1, 0x41, 0xe5, 0xf0,
+	0x6e, 0xf7, 0x88, 0x90, 0xf9, 0xae, 0xc0, 0xa8, 0xb1, 0xf8, 0xe8, 0x91, 0x68, 0x3f, 0xdf, 0x1f,
+	0xd3, 0xe3, 0x43, 0xfd, 0x4f, 0xbf, 0xdf, 0xfb, 0xd5, 0xfe, 0xee, 0x77, 0x4a, 0x9b, 0x8a, 0xc7,
+	0x85, 0xcc, 0x6d, 0xb3, 0x4e, 0x54, 0xd1, 0xe8, 0x7a, 0xb9, 0x46, 0xb2, 0x5a, 0x23, 0xd9, 0xae,
+	0x11, 0x5e, 0x0c, 0xc2, 0x9b, 0x41, 0x58, 0x18, 0x84, 0xa5, 0x41, 0xf8, 0x34, 0x08, 0x5f, 0x06,
+	0xc9, 0xd6, 0x20, 0xbc, 0x6e, 0x90, 0x2c, 0x37, 0x48, 0x56, 0x1b, 0x24, 0x49, 0xd3, 0xfe, 0xfe,
+	0xd5, 0xf7, 0x00, 0x4d, 0x7f, 0xf5, 0x45, 0xcd, 0x01, 0x00, 0x00,
 }
 
 func (this *ReplicaDesc) Equal(that interface{}) bool {
@@ -141,17 +237,82 @@ func (this *ReplicaDesc) Equal(that interface{}) bool {
 	if this.DeletedAt != that1.DeletedAt {
 		return false
 	}
+	if this.ElectedAt != that1.ElectedAt {
+		return false
+	}
+	if this.Draining != that1.Draining {
+		return false
+	}
+	if len(this.History) != len(that1.History) {
+		return false
+	}
+	for i := range this.History {
+		if !this.History[i].Equal(&that1.History[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *ElectionDesc) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*ElectionDesc)
+	if !ok {
+		that2, ok := that.(ElectionDesc)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Replica != that1.Replica {
+		return false
+	}
+	if this.ElectedAt != that1.ElectedAt {
+		return false
+	}
+	if this.Reason != that1.Reason {
+		return false
+	}
 	return true
 }
 func (this *ReplicaDesc) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 7)
+	s := make([]string, 0, 10)
 	s = append(s, "&distributor.ReplicaDesc{")
 	s = append(s, "Replica: "+fmt.Sprintf("%#v", this.Replica)+",\n")
 	s = append(s, "ReceivedAt: "+fmt.Sprintf("%#v", this.ReceivedAt)+",\n")
 	s = append(s, "DeletedAt: "+fmt.Sprintf("%#v", this.DeletedAt)+",\n")
+	s = append(s, "ElectedAt: "+fmt.Sprintf("%#v", this.ElectedAt)+",\n")
+	s = append(s, "Draining: "+fmt.Sprintf("%#v", this.Draining)+",\n")
+	if this.History != nil {
+		vs := make([]ElectionDesc, len(this.History))
+		for i := range vs {
+			vs[i] = this.History[i]
+		}
+		s = append(s, "History: "+fmt.Sprintf("%#v", vs)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *ElectionDesc) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&distributor.ElectionDesc{")
+	s = append(s, "Replica: "+fmt.Sprintf("%#v", this.Replica)+",\n")
+	s = append(s, "ElectedAt: "+fmt.Sprintf("%#v", this.ElectedAt)+",\n")
+	s = append(s, "Reason: "+fmt.Sprintf("%#v", this.Reason)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -183,6 +344,35 @@ func (m *ReplicaDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.History) > 0 {
+		for iNdEx := len(m.History) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.History[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintHaTracker(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x32
+		}
+	}
+	if m.Draining {
+		i--
+		if m.Draining {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x28
+	}
+	if m.ElectedAt != 0 {
+		i = encodeVarintHaTracker(dAtA, i, uint64(m.ElectedAt))
+		i--
+		dAtA[i] = 0x20
+	}
 	if m.DeletedAt != 0 {
 		i = encodeVarintHaTracker(dAtA, i, uint64(m.DeletedAt))
 		i--
@@ -203,6 +393,48 @@ func (m *ReplicaDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *ElectionDesc) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ElectionDesc) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ElectionDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Reason) > 0 {
+		i -= len(m.Reason)
+		copy(dAtA[i:], m.Reason)
+		i = encodeVarintHaTracker(dAtA, i, uint64(len(m.Reason)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if m.ElectedAt != 0 {
+		i = encodeVarintHaTracker(dAtA, i, uint64(m.ElectedAt))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.Replica) > 0 {
+		i -= len(m.Replica)
+		copy(dAtA[i:], m.Replica)
+		i = encodeVarintHaTracker(dAtA, i, uint64(len(m.Replica)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
 func encodeVarintHaTracker(dAtA []byte, offset int, v uint64) int {
 	offset -= sovHaTracker(v)
 	base := offset
@@ -230,6 +462,38 @@ func (m *ReplicaDesc) Size() (n int) {
 	if m.DeletedAt != 0 {
 		n += 1 + sovHaTracker(uint64(m.DeletedAt))
 	}
+	if m.ElectedAt != 0 {
+		n += 1 + sovHaTracker(uint64(m.ElectedAt))
+	}
+	if m.Draining {
+		n += 2
+	}
+	if len(m.History) > 0 {
+		for _, e := range m.History {
+			l = e.Size()
+			n += 1 + l + sovHaTracker(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *ElectionDesc) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Replica)
+	if l > 0 {
+		n += 1 + l + sovHaTracker(uint64(l))
+	}
+	if m.ElectedAt != 0 {
+		n += 1 + sovHaTracker(uint64(m.ElectedAt))
+	}
+	l = len(m.Reason)
+	if l > 0 {
+		n += 1 + l + sovHaTracker(uint64(l))
+	}
 	return n
 }
 
@@ -243,10 +507,30 @@ func (this *ReplicaDesc) String() string {
 	if this == nil {
 		return "nil"
 	}
+	repeatedStringForHistory := "[]ElectionDesc{"
+	for _, f := range this.History {
+		repeatedStringForHistory += strings.Replace(strings.Replace(f.String(), "ElectionDesc", "ElectionDesc", 1), `&`, ``, 1) + ","
+	}
+	repeatedStringForHistory += "}"
 	s := strings.Join([]string{`&ReplicaDesc{`,
 		`Replica:` + fmt.Sprintf("%v", this.Replica) + `,`,
 		`ReceivedAt:` + fmt.Sprintf("%v", this.ReceivedAt) + `,`,
 		`DeletedAt:` + fmt.Sprintf("%v", this.DeletedAt) + `,`,
+		`ElectedAt:` + fmt.Sprintf("%v", this.ElectedAt) + `,`,
+		`Draining:` + fmt.Sprintf("%v", this.Draining) + `,`,
+		`History:` + repeatedStringForHistory + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ElectionDesc) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ElectionDesc{`,
+		`Replica:` + fmt.Sprintf("%v", this.Replica) + `,`,
+		`ElectedAt:` + fmt.Sprintf("%v", this.ElectedAt) + `,`,
+		`Reason:` + fmt.Sprintf("%v", this.Reason) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -358,6 +642,215 @@ func (m *ReplicaDesc) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ElectedAt", wireType)
+			}
+			m.ElectedAt = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHaTracker
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ElectedAt |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Draining", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHaTracker
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Draining = bool(v != 0)
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field History", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHaTracker
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthHaTracker
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthHaTracker
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.History = append(m.History, ElectionDesc{})
+			if err := m.History[len(m.History)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipHaTracker(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthHaTracker
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthHaTracker
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ElectionDesc) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowHaTracker
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ElectionDesc: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ElectionDesc: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Replica", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHaTracker
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthHaTracker
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHaTracker
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Replica = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ElectedAt", wireType)
+			}
+			m.ElectedAt = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHaTracker
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ElectedAt |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Reason", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHaTracker
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthHaTracker
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHaTracker
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Reason = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipHaTracker(dAtA[iNdEx:])
@@ -7,8 +7,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/prometheus/prometheus/pkg/timestamp"
 
+	"github.com/cortexproject/cortex/pkg/tenant"
 	"github.com/cortexproject/cortex/pkg/util"
 )
 
@@ -99,3 +101,86 @@ func (h *haTracker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		Now:     time.Now(),
 	}, trackerTmpl, req)
 }
+
+// ElectedReplicasHandler lists the tenant's HA clusters with their elected replica and the history of the
+// most recent elections. On POST, it forces the election of the replica given by the "replica" parameter
+// for the cluster given by the "cluster" parameter.
+func (h *haTracker) ElectedReplicasHandler(w http.ResponseWriter, req *http.Request) {
+	userID, err := tenant.TenantID(req.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if !h.cfg.EnableHATracker {
+		http.Error(w, "the HA tracker is disabled", http.StatusNotFound)
+		return
+	}
+
+	if req.Method == http.MethodPost {
+		cluster, replica := req.FormValue("cluster"), req.FormValue("replica")
+		if cluster == "" || replica == "" {
+			http.Error(w, "both the cluster and replica parameters are required", http.StatusBadRequest)
+			return
+		}
+
+		if err := h.forceReplica(req.Context(), userID, cluster, replica, time.Now()); errors.Is(err, errUnknownHACluster) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	type election struct {
+		Replica   string    `json:"replica"`
+		ElectedAt time.Time `json:"electedAt"`
+		Reason    string    `json:"reason"`
+	}
+
+	type cluster struct {
+		Cluster    string     `json:"cluster"`
+		Replica    string     `json:"replica"`
+		ElectedAt  *time.Time `json:"electedAt,omitempty"`
+		ReceivedAt time.Time  `json:"receivedAt"`
+		History    []election `json:"history"`
+	}
+
+	clusters := []cluster{}
+	h.electedLock.RLock()
+	for name := range h.clusters[userID] {
+		desc := h.elected[userID+"/"+name]
+
+		c := cluster{
+			Cluster:    name,
+			Replica:    desc.Replica,
+			ReceivedAt: timestamp.Time(desc.ReceivedAt),
+			History:    make([]election, 0, len(desc.History)),
+		}
+		// The election time is unknown for the replicas elected by older distributors.
+		if desc.ElectedAt > 0 {
+			electedAt := timestamp.Time(desc.ElectedAt)
+			c.ElectedAt = &electedAt
+		}
+		for _, e := range desc.History {
+			c.History = append(c.History, election{
+				Replica:   e.Replica,
+				ElectedAt: timestamp.Time(e.ElectedAt),
+				Reason:    e.Reason,
+			})
+		}
+		clusters = append(clusters, c)
+	}
+	h.electedLock.RUnlock()
+
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Cluster < clusters[j].Cluster
+	})
+
+	util.WriteJSONResponse(w, struct {
+		Clusters []cluster `json:"clusters"`
+	}{
+		Clusters: clusters,
+	})
+}
@@ -2,7 +2,11 @@ package distributor
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -24,6 +28,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/cortexproject/cortex/pkg/util/push"
 	"github.com/cortexproject/cortex/pkg/util/services"
 	"github.com/cortexproject/cortex/pkg/util/test"
 )
@@ -793,3 +798,198 @@ func checkReplicaDeletionState(t *testing.T, duration time.Duration, c *haTracke
 		require.Equal(t, expectedMarkedForDeletion, markedForDeletion, "KV entry marked for deletion")
 	}
 }
+
+func TestCheckReplicaDraining(t *testing.T) {
+	replica1 := "replica1"
+	replica2 := "replica2"
+	failoverTimeout := time.Second
+
+	c, err := newHATracker(HATrackerConfig{
+		EnableHATracker:        true,
+		KVStore:                kv.Config{Store: "inmemory"},
+		UpdateTimeout:          100 * time.Millisecond,
+		UpdateTimeoutJitterMax: 0,
+		FailoverTimeout:        failoverTimeout,
+	}, trackerLimits{maxClusters: 100}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
+
+	now := time.Now()
+	drainingCtx := push.ContextWithHADraining(context.Background())
+
+	err = c.checkReplica(context.Background(), "user", "draining", replica1, now)
+	assert.NoError(t, err)
+	checkReplicaTimestamp(t, time.Second, c, "user", "draining", replica1, now)
+
+	// A draining replica which is not elected is rejected.
+	err = c.checkReplica(drainingCtx, "user", "draining", replica2, now)
+	assert.Error(t, err)
+
+	// The samples of the draining elected replica are still accepted.
+	err = c.checkReplica(drainingCtx, "user", "draining", replica1, now)
+	assert.NoError(t, err)
+	checkReplicaTimestamp(t, time.Second, c, "user", "draining", replica1, now.Add(-failoverTimeout))
+
+	err = c.checkReplica(drainingCtx, "user", "draining", replica1, now)
+	assert.NoError(t, err)
+	checkReplicaTimestamp(t, time.Second, c, "user", "draining", replica1, now.Add(-failoverTimeout))
+
+	// Another replica is elected right away.
+	err = c.checkReplica(context.Background(), "user", "draining", replica2, now)
+	assert.NoError(t, err)
+	checkReplicaTimestamp(t, time.Second, c, "user", "draining", replica2, now)
+
+	err = c.checkReplica(drainingCtx, "user", "draining", replica1, now)
+	assert.Error(t, err)
+}
+
+func TestHATracker_ElectedReplicasHandler(t *testing.T) {
+	c, err := newHATracker(HATrackerConfig{
+		EnableHATracker:        true,
+		KVStore:                kv.Config{Store: "inmemory"},
+		UpdateTimeout:          100 * time.Millisecond,
+		UpdateTimeoutJitterMax: 0,
+		FailoverTimeout:        time.Second,
+	}, trackerLimits{maxClusters: 100}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
+
+	now := time.Now()
+	require.NoError(t, c.checkReplica(context.Background(), "user-elected", "test", "replica1", now))
+	require.NoError(t, c.checkReplica(context.Background(), "another-user-elected", "test", "replica1", now))
+	checkReplicaTimestamp(t, time.Second, c, "user-elected", "test", "replica1", now)
+	checkReplicaTimestamp(t, time.Second, c, "another-user-elected", "test", "replica1", now)
+
+	type response struct {
+		Clusters []struct {
+			Cluster    string     `json:"cluster"`
+			Replica    string     `json:"replica"`
+			ElectedAt  *time.Time `json:"electedAt"`
+			ReceivedAt time.Time  `json:"receivedAt"`
+			History    []struct {
+				Replica   string    `json:"replica"`
+				ElectedAt time.Time `json:"electedAt"`
+				Reason    string    `json:"reason"`
+			} `json:"history"`
+		} `json:"clusters"`
+	}
+
+	call := func(method string, form url.Values) (int, response) {
+		req := httptest.NewRequest(method, "/distributor/ha_tracker/elected", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(user.InjectOrgID(req.Context(), "user-elected"))
+
+		rec := httptest.NewRecorder()
+		c.ElectedReplicasHandler(rec, req)
+
+		res := response{}
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		}
+		return rec.Code, res
+	}
+
+	code, res := call("GET", nil)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, res.Clusters, 1)
+	assert.Equal(t, "test", res.Clusters[0].Cluster)
+	assert.Equal(t, "replica1", res.Clusters[0].Replica)
+	require.NotNil(t, res.Clusters[0].ElectedAt)
+	assert.Equal(t, timestamp.FromTime(now), timestamp.FromTime(*res.Clusters[0].ElectedAt))
+	require.Len(t, res.Clusters[0].History, 1)
+	assert.Equal(t, "replica1", res.Clusters[0].History[0].Replica)
+	assert.Equal(t, timestamp.FromTime(now), timestamp.FromTime(res.Clusters[0].History[0].ElectedAt))
+	assert.Equal(t, electionReasonFirst, res.Clusters[0].History[0].Reason)
+
+	// The election time is kept while the elected replica keeps sending samples.
+	later := now.Add(200 * time.Millisecond)
+	require.NoError(t, c.checkReplica(context.Background(), "user-elected", "test", "replica1", later))
+	checkReplicaTimestamp(t, time.Second, c, "user-elected", "test", "replica1", later)
+
+	_, res = call("GET", nil)
+	assert.Equal(t, timestamp.FromTime(later), timestamp.FromTime(res.Clusters[0].ReceivedAt))
+	assert.Equal(t, timestamp.FromTime(now), timestamp.FromTime(*res.Clusters[0].ElectedAt))
+
+	// Force the election of another replica.
+	forcedAt := time.Now()
+	code, _ = call("POST", url.Values{"cluster": {"test"}, "replica": {"replica2"}})
+	require.Equal(t, http.StatusOK, code)
+
+	test.Poll(t, time.Second, "replica2", func() interface{} {
+		_, res := call("GET", nil)
+		return res.Clusters[0].Replica
+	})
+
+	_, res = call("GET", nil)
+	require.NotNil(t, res.Clusters[0].ElectedAt)
+	assert.GreaterOrEqual(t, timestamp.FromTime(*res.Clusters[0].ElectedAt), timestamp.FromTime(forcedAt))
+	require.Len(t, res.Clusters[0].History, 2)
+	assert.Equal(t, "replica2", res.Clusters[0].History[0].Replica)
+	assert.Equal(t, electionReasonForced, res.Clusters[0].History[0].Reason)
+	assert.Equal(t, "replica1", res.Clusters[0].History[1].Replica)
+	assert.Equal(t, electionReasonFirst, res.Clusters[0].History[1].Reason)
+
+	// The previously elected replica is rejected, while the other tenant is unaffected.
+	assert.Error(t, c.checkReplica(context.Background(), "user-elected", "test", "replica1", time.Now()))
+	assert.NoError(t, c.checkReplica(context.Background(), "another-user-elected", "test", "replica1", time.Now()))
+
+	code, _ = call("POST", url.Values{"cluster": {"unknown"}, "replica": {"replica2"}})
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = call("POST", url.Values{"cluster": {"test"}})
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestHATracker_ElectionHistory(t *testing.T) {
+	failoverTimeout := time.Second
+
+	c, err := newHATracker(HATrackerConfig{
+		EnableHATracker:        true,
+		KVStore:                kv.Config{Store: "inmemory"},
+		UpdateTimeout:          100 * time.Millisecond,
+		UpdateTimeoutJitterMax: 0,
+		FailoverTimeout:        failoverTimeout,
+	}, trackerLimits{maxClusters: 100}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
+
+	getHistory := func() []ElectionDesc {
+		val, err := c.client.Get(context.Background(), "user/cluster")
+		require.NoError(t, err)
+		return val.(*ReplicaDesc).History
+	}
+
+	now := time.Now()
+	require.NoError(t, c.checkReplica(context.Background(), "user", "cluster", "replica1", now))
+
+	// The samples of the elected replica don't add elections.
+	require.NoError(t, c.checkReplica(context.Background(), "user", "cluster", "replica1", now.Add(time.Second)))
+
+	// The failover after the failover timeout.
+	now = now.Add(time.Second + failoverTimeout)
+	require.NoError(t, c.checkReplica(context.Background(), "user", "cluster", "replica2", now))
+
+	// The failover after the elected replica sent the draining hint.
+	require.NoError(t, c.checkReplica(push.ContextWithHADraining(context.Background()), "user", "cluster", "replica2", now))
+	require.NoError(t, c.checkReplica(context.Background(), "user", "cluster", "replica1", now))
+
+	assert.Equal(t, []ElectionDesc{
+		{Replica: "replica1", ElectedAt: timestamp.FromTime(now), Reason: electionReasonDraining},
+		{Replica: "replica2", ElectedAt: timestamp.FromTime(now), Reason: electionReasonFailover},
+		{Replica: "replica1", ElectedAt: timestamp.FromTime(now.Add(-time.Second - failoverTimeout)), Reason: electionReasonFirst},
+	}, getHistory())
+
+	// The history is bounded, most recent elections first.
+	for i := 0; i < maxElectionHistory; i++ {
+		now = now.Add(failoverTimeout)
+		require.NoError(t, c.checkReplica(context.Background(), "user", "cluster", fmt.Sprintf("replica-%d", i), now))
+	}
+
+	history := getHistory()
+	require.Len(t, history, maxElectionHistory)
+	assert.Equal(t, fmt.Sprintf("replica-%d", maxElectionHistory-1), history[0].Replica)
+	assert.Equal(t, "replica-0", history[maxElectionHistory-1].Replica)
+}
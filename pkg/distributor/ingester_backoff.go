@@ -0,0 +1,88 @@
+package distributor
+
+import (
+	"sync"
+	"time"
+)
+
+// ingesterBackoff tracks the ingesters under memory pressure, which push requests
+// are not sent to until their backoff period has elapsed. The backoff period
+// doubles each time an ingester is still under pressure, up to the max period,
+// and is reset as soon as an ingester accepts a push request.
+type ingesterBackoff struct {
+	minPeriod time.Duration
+	maxPeriod time.Duration
+
+	mtx    sync.RWMutex
+	states map[string]*ingesterBackoffState // Keyed by ingester address.
+}
+
+type ingesterBackoffState struct {
+	until  time.Time
+	period time.Duration
+}
+
+func newIngesterBackoff(minPeriod, maxPeriod time.Duration) *ingesterBackoff {
+	return &ingesterBackoff{
+		minPeriod: minPeriod,
+		maxPeriod: maxPeriod,
+		states:    map[string]*ingesterBackoffState{},
+	}
+}
+
+// backingOff returns whether push requests should not be sent to the ingester at the given time.
+func (b *ingesterBackoff) backingOff(addr string, now time.Time) bool {
+	if b.minPeriod <= 0 {
+		return false
+	}
+
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	s, ok := b.states[addr]
+	return ok && now.Before(s.until)
+}
+
+// underPressure records the ingester rejected a push request because under memory pressure.
+func (b *ingesterBackoff) underPressure(addr string, now time.Time) {
+	if b.minPeriod <= 0 {
+		return
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	s, ok := b.states[addr]
+	if !ok {
+		s = &ingesterBackoffState{}
+		b.states[addr] = s
+	}
+
+	s.period *= 2
+	if s.period < b.minPeriod {
+		s.period = b.minPeriod
+	}
+	if s.period > b.maxPeriod {
+		s.period = b.maxPeriod
+	}
+	s.until = now.Add(s.period)
+}
+
+// accepted records the ingester accepted a push request.
+func (b *ingesterBackoff) accepted(addr string) {
+	if b.minPeriod <= 0 {
+		return
+	}
+
+	// Most of the times the ingester is not tracked, so we avoid taking the write lock.
+	b.mtx.RLock()
+	_, ok := b.states[addr]
+	b.mtx.RUnlock()
+	if !ok {
+		return
+	}
+
+	b.mtx.Lock()
+	delete(b.states, addr)
+	b.mtx.Unlock()
+}
@@ -0,0 +1,44 @@
+package distributor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIngesterBackoff(t *testing.T) {
+	b := newIngesterBackoff(time.Second, 3*time.Second)
+	now := time.Now()
+
+	assert.False(t, b.backingOff("ingester-1", now))
+
+	// The backoff period doubles while the ingester is under pressure, up to the max period.
+	b.underPressure("ingester-1", now)
+	assert.True(t, b.backingOff("ingester-1", now.Add(999*time.Millisecond)))
+	assert.False(t, b.backingOff("ingester-1", now.Add(time.Second)))
+	assert.False(t, b.backingOff("ingester-2", now))
+
+	now = now.Add(time.Second)
+	b.underPressure("ingester-1", now)
+	assert.True(t, b.backingOff("ingester-1", now.Add(1999*time.Millisecond)))
+	assert.False(t, b.backingOff("ingester-1", now.Add(2*time.Second)))
+
+	now = now.Add(2 * time.Second)
+	b.underPressure("ingester-1", now)
+	assert.True(t, b.backingOff("ingester-1", now.Add(2999*time.Millisecond)))
+	assert.False(t, b.backingOff("ingester-1", now.Add(3*time.Second)))
+
+	// The backoff is reset once the ingester accepts a request.
+	b.accepted("ingester-1")
+	b.underPressure("ingester-1", now)
+	assert.False(t, b.backingOff("ingester-1", now.Add(time.Second)))
+}
+
+func TestIngesterBackoff_Disabled(t *testing.T) {
+	b := newIngesterBackoff(0, 0)
+	now := time.Now()
+
+	b.underPressure("ingester-1", now)
+	assert.False(t, b.backingOff("ingester-1", now))
+}
@@ -0,0 +1,592 @@
+package distributor
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/weaveworks/common/user"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/cortexproject/cortex/pkg/cortexpb"
+	"github.com/cortexproject/cortex/pkg/distributor/distributorpb"
+	ingester_client "github.com/cortexproject/cortex/pkg/ingester/client"
+	"github.com/cortexproject/cortex/pkg/ring"
+	ring_client "github.com/cortexproject/cortex/pkg/ring/client"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/extract"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+const (
+	// streamingAggregationTickInterval is how often the aggregation intervals are checked for completion.
+	streamingAggregationTickInterval = time.Second
+
+	// streamingAggregationRingNumTokens is the number of tokens registered by each distributor in the
+	// distributors ring when streaming aggregation is enabled, to evenly spread the aggregations.
+	streamingAggregationRingNumTokens = 128
+
+	// streamingAggregationRuleHeader is the gRPC metadata holding the rule the forwarded series are aggregated by.
+	streamingAggregationRuleHeader = "x-cortex-streaming-aggregation-rule"
+)
+
+var (
+	// streamingAggregationOp is the operation used to look up the distributor owning an aggregation.
+	streamingAggregationOp = ring.NewOp([]ring.InstanceState{ring.ACTIVE}, func(s ring.InstanceState) bool {
+		return s != ring.ACTIVE
+	})
+
+	errStreamingAggregatorNotRunning = errors.New("the streaming aggregator is not running")
+)
+
+// StreamingAggregationConfig configures the computation of the tenants' streaming aggregation rules.
+type StreamingAggregationConfig struct {
+	Enabled            bool          `yaml:"enabled"`
+	SeriesStaleTimeout time.Duration `yaml:"series_stale_timeout"`
+
+	// For testing.
+	DistributorClientFactory ring_client.PoolFactory `yaml:"-"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet.
+func (cfg *StreamingAggregationConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "distributor.streaming-aggregation.enabled", false, "Compute the tenants' streaming aggregation rules. The distributors join the distributors ring, and each aggregated series is computed by the distributor owning it in the ring, which the other distributors forward the input series to.")
+	f.DurationVar(&cfg.SeriesStaleTimeout, "distributor.streaming-aggregation.series-stale-timeout", 5*time.Minute, "How long the last sample received for an input series is included in the aggregation. It must be greater than the scrape interval of the input series.")
+}
+
+type pushFunc func(context.Context, *cortexpb.WriteRequest) (*cortexpb.WriteResponse, error)
+
+// streamingAggregator computes the tenants' streaming aggregation rules, and pushes the aggregated
+// series at the end of each rule interval. Each aggregated series is computed by the distributor
+// owning it in the distributors ring, which the input series are forwarded to.
+type streamingAggregator struct {
+	services.Service
+
+	cfg           StreamingAggregationConfig
+	instanceAddr  string // Empty if this distributor isn't part of the distributors ring.
+	ring          ring.ReadRing
+	pool          *ring_client.Pool
+	remoteTimeout time.Duration
+	limits        *validation.Overrides
+	push          pushFunc
+	logger        log.Logger
+
+	mtx     sync.RWMutex
+	tenants map[string]*tenantStreamingAggregations
+
+	aggregatedSamples *prometheus.CounterVec
+	forwardedSeries   *prometheus.CounterVec
+	forwardFailures   *prometheus.CounterVec
+	pushedSeries      *prometheus.CounterVec
+	failedPushes      *prometheus.CounterVec
+}
+
+// tenantStreamingAggregations holds the aggregations of a tenant owned by this distributor.
+type tenantStreamingAggregations struct {
+	mtx          sync.Mutex
+	deleted      bool                             // Whether the tenant has been removed from the aggregator.
+	aggregations map[string]*streamingAggregation // Key = rule.
+}
+
+// streamingAggregation holds the input series aggregated by a rule, and the aggregated series.
+type streamingAggregation struct {
+	rule        validation.StreamingAggregationRule
+	intervalEnd time.Time
+	series      map[uint64]*streamingAggregationSeries // Key = input series labels hash.
+	groups      map[uint64]*streamingAggregationGroup  // Key = aggregated series labels hash.
+}
+
+// streamingAggregationGroup is an aggregated series.
+type streamingAggregationGroup struct {
+	labels    labels.Labels
+	token     uint32 // The token used to find the distributor owning the aggregated series.
+	numSeries int    // The number of input series belonging to the aggregated series.
+
+	// The sum of the increases of the input series since the aggregated series has been created.
+	// Only used by counter rules, so that the aggregated series doesn't decrease when an input
+	// series is reset or removed.
+	total float64
+}
+
+// streamingAggregationSeries holds the last sample received for an input series.
+type streamingAggregationSeries struct {
+	group       *streamingAggregationGroup
+	timestampMs int64
+	value       float64
+	receivedAt  time.Time
+}
+
+// streamingAggregationForwards holds the input series to forward to the distributors owning their
+// aggregations. The first key is the distributor address, the second key is the rule.
+type streamingAggregationForwards map[string]map[string][]cortexpb.PreallocTimeseries
+
+func (f streamingAggregationForwards) add(addr, rule string, ts cortexpb.PreallocTimeseries) {
+	rules := f[addr]
+	if rules == nil {
+		rules = map[string][]cortexpb.PreallocTimeseries{}
+		f[addr] = rules
+	}
+	rules[rule] = append(rules[rule], ts)
+}
+
+func newStreamingAggregator(cfg StreamingAggregationConfig, instanceAddr string, distributorsRing ring.ReadRing, pool *ring_client.Pool, remoteTimeout time.Duration, limits *validation.Overrides, push pushFunc, reg prometheus.Registerer, logger log.Logger) *streamingAggregator {
+	a := &streamingAggregator{
+		cfg:           cfg,
+		instanceAddr:  instanceAddr,
+		ring:          distributorsRing,
+		pool:          pool,
+		remoteTimeout: remoteTimeout,
+		limits:        limits,
+		push:          push,
+		logger:        logger,
+		tenants:       map[string]*tenantStreamingAggregations{},
+
+		aggregatedSamples: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_distributor_streaming_aggregation_input_samples_total",
+			Help: "The total number of samples aggregated by streaming aggregation rules.",
+		}, []string{"user"}),
+		forwardedSeries: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_distributor_streaming_aggregation_forwarded_series_total",
+			Help: "The total number of input series forwarded to the distributors owning their aggregation.",
+		}, []string{"user"}),
+		forwardFailures: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_distributor_streaming_aggregation_forward_failures_total",
+			Help: "The total number of failed forwards of input series to the distributors owning their aggregation.",
+		}, []string{"user"}),
+		pushedSeries: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_distributor_streaming_aggregation_output_series_total",
+			Help: "The total number of aggregated series pushed by streaming aggregation rules.",
+		}, []string{"user"}),
+		failedPushes: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_distributor_streaming_aggregation_push_failures_total",
+			Help: "The total number of failed pushes of aggregated series.",
+		}, []string{"user"}),
+	}
+
+	a.Service = services.NewTimerService(streamingAggregationTickInterval, nil, a.iteration, a.stopping)
+	return a
+}
+
+// route aggregates the series, or adds it to the forwards to the distributors owning the aggregation,
+// for each of the tenant's streaming aggregation rules it matches. It returns whether the series must
+// not be ingested because dropped by any of the matching rules.
+func (a *streamingAggregator) route(userID string, ts cortexpb.PreallocTimeseries, now time.Time, forwards streamingAggregationForwards) (drop bool, _ error) {
+	if !a.cfg.Enabled || len(ts.Samples) == 0 {
+		return false, nil
+	}
+
+	rules := a.limits.StreamingAggregationRules(userID)
+	if len(rules) == 0 {
+		return false, nil
+	}
+
+	metricName, err := extract.UnsafeMetricNameFromLabelAdapters(ts.Labels)
+	if err != nil {
+		return false, nil
+	}
+
+	for _, rule := range rules {
+		if rule.Metric != metricName {
+			continue
+		}
+
+		bufDescs, bufHosts, bufZones := ring.MakeBuffersForGet()
+		set, err := a.ring.Get(streamingAggregationToken(userID, rule, ts.Labels), streamingAggregationOp, bufDescs, bufHosts, bufZones)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to find the distributor owning the streaming aggregation")
+		}
+
+		if owner := set.Instances[0].Addr; owner != a.instanceAddr {
+			forwards.add(owner, rule.String(), ts)
+		} else {
+			a.observe(userID, rule, ts, now)
+		}
+
+		drop = drop || rule.DropInput
+	}
+
+	return drop, nil
+}
+
+// streamingAggregationToken returns the token of the aggregated series the input series belongs to,
+// which is used to find the distributor owning its aggregation.
+func streamingAggregationToken(userID string, rule *validation.StreamingAggregationRule, input []cortexpb.LabelAdapter) uint32 {
+	h := ingester_client.ShardByUser(userID)
+	h = ingester_client.HashAdd32(h, rule.String())
+
+	for _, name := range rule.By {
+		for _, l := range input {
+			if l.Name == name {
+				h = ingester_client.HashAdd32(h, l.Name)
+				h = ingester_client.HashAdd32(h, l.Value)
+				break
+			}
+		}
+	}
+	return h
+}
+
+// forward sends the input series to the distributors owning their aggregation. The aggregations ignore
+// the samples not newer than the last one of each input series, so the input series forwarded more
+// than once because the push request is retried are only aggregated once.
+func (a *streamingAggregator) forward(ctx context.Context, userID string, forwards streamingAggregationForwards) error {
+	if len(forwards) == 0 {
+		return nil
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	for addr, rules := range forwards {
+		for rule, series := range rules {
+			addr, rule, series := addr, rule, series
+
+			g.Go(func() error {
+				localCtx, cancel := context.WithTimeout(ctx, a.remoteTimeout)
+				defer cancel()
+				localCtx = user.InjectOrgID(localCtx, userID)
+				localCtx = metadata.AppendToOutgoingContext(localCtx, streamingAggregationRuleHeader, rule)
+
+				err := a.forwardTo(localCtx, addr, series)
+				if err != nil {
+					a.forwardFailures.WithLabelValues(userID).Inc()
+					return errors.Wrapf(err, "failed to forward series to the distributor %s owning their streaming aggregation", addr)
+				}
+
+				a.forwardedSeries.WithLabelValues(userID).Add(float64(len(series)))
+				return nil
+			})
+		}
+	}
+
+	return g.Wait()
+}
+
+func (a *streamingAggregator) forwardTo(ctx context.Context, addr string, series []cortexpb.PreallocTimeseries) error {
+	c, err := a.pool.GetClientFor(addr)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.(distributorpb.DistributorClient).Aggregate(ctx, &cortexpb.WriteRequest{Timeseries: series, Source: cortexpb.API})
+	return err
+}
+
+// aggregate aggregates the input series forwarded by the other distributors by the rule in the request metadata.
+func (a *streamingAggregator) aggregate(ctx context.Context, userID string, series []cortexpb.PreallocTimeseries, now time.Time) error {
+	if !a.cfg.Enabled || a.State() != services.Running {
+		return errStreamingAggregatorNotRunning
+	}
+
+	var key string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(streamingAggregationRuleHeader); len(values) > 0 {
+			key = values[0]
+		}
+	}
+
+	var rule *validation.StreamingAggregationRule
+	for _, r := range a.limits.StreamingAggregationRules(userID) {
+		if r.String() == key {
+			rule = r
+			break
+		}
+	}
+
+	// The rule may be unknown while the runtime configuration is reloaded by the distributors.
+	if rule == nil {
+		return fmt.Errorf("unknown streaming aggregation rule %q", key)
+	}
+
+	for _, ts := range series {
+		if len(ts.Samples) > 0 {
+			a.observe(userID, rule, ts, now)
+		}
+	}
+	return nil
+}
+
+// observe adds the samples of the input series to the aggregation of the rule. The samples not
+// newer than the last one received for the input series are ignored.
+func (a *streamingAggregator) observe(userID string, rule *validation.StreamingAggregationRule, ts cortexpb.PreallocTimeseries, now time.Time) {
+	var (
+		seriesHash = cortexpb.FromLabelAdaptersToLabels(ts.Labels).Hash()
+		key        = rule.String()
+	)
+
+	tenant := a.lockTenant(userID)
+	defer tenant.mtx.Unlock()
+
+	agg := tenant.aggregations[key]
+	if agg == nil {
+		agg = &streamingAggregation{
+			rule:        *rule,
+			intervalEnd: nextIntervalEnd(now, time.Duration(rule.Interval)),
+			series:      map[uint64]*streamingAggregationSeries{},
+			groups:      map[uint64]*streamingAggregationGroup{},
+		}
+		tenant.aggregations[key] = agg
+	}
+
+	s, ok := agg.series[seriesHash]
+	if !ok {
+		lbls := groupLabels(rule, ts.Labels)
+		groupHash := lbls.Hash()
+
+		group := agg.groups[groupHash]
+		if group == nil {
+			group = &streamingAggregationGroup{
+				labels: lbls,
+				token:  streamingAggregationToken(userID, rule, ts.Labels),
+			}
+			agg.groups[groupHash] = group
+		}
+		group.numSeries++
+
+		// The first sample of a counter input series is only the baseline of its increases, as it
+		// is for rate(): whether it has been reset before the sample isn't known.
+		first := ts.Samples[0]
+		s = &streamingAggregationSeries{
+			group:       group,
+			timestampMs: first.TimestampMs,
+			value:       first.Value,
+		}
+		agg.series[seriesHash] = s
+	}
+
+	for _, sample := range ts.Samples {
+		if sample.TimestampMs <= s.timestampMs {
+			continue
+		}
+
+		if rule.Type == validation.StreamingAggregationCounter {
+			if sample.Value >= s.value {
+				s.group.total += sample.Value - s.value
+			} else {
+				// The counter has been reset.
+				s.group.total += sample.Value
+			}
+		}
+		s.timestampMs, s.value = sample.TimestampMs, sample.Value
+	}
+	s.receivedAt = now
+
+	a.aggregatedSamples.WithLabelValues(userID).Add(float64(len(ts.Samples)))
+}
+
+// lockTenant returns the locked aggregations of the tenant, creating them if they don't exist.
+func (a *streamingAggregator) lockTenant(userID string) *tenantStreamingAggregations {
+	for {
+		a.mtx.RLock()
+		tenant := a.tenants[userID]
+		a.mtx.RUnlock()
+
+		if tenant == nil {
+			a.mtx.Lock()
+			if tenant = a.tenants[userID]; tenant == nil {
+				tenant = &tenantStreamingAggregations{aggregations: map[string]*streamingAggregation{}}
+				a.tenants[userID] = tenant
+			}
+			a.mtx.Unlock()
+		}
+
+		tenant.mtx.Lock()
+		if !tenant.deleted {
+			return tenant
+		}
+
+		// The tenant has been removed by a concurrent flush.
+		tenant.mtx.Unlock()
+	}
+}
+
+// groupLabels returns the labels of the aggregated series the input series belongs to. The label
+// values are copied, since the input series labels are unsafely referencing the request buffer.
+func groupLabels(rule *validation.StreamingAggregationRule, input []cortexpb.LabelAdapter) labels.Labels {
+	group := make([]cortexpb.LabelAdapter, 0, len(rule.By)+1)
+	group = append(group, cortexpb.LabelAdapter{Name: labels.MetricName, Value: rule.Record})
+
+	for _, name := range rule.By {
+		for _, l := range input {
+			if l.Name == name {
+				group = append(group, l)
+				break
+			}
+		}
+	}
+
+	lbls := cortexpb.FromLabelAdaptersToLabelsWithCopy(group)
+	return labels.New(lbls...)
+}
+
+func (a *streamingAggregator) iteration(ctx context.Context) error {
+	a.flush(ctx, time.Now(), false)
+	return nil
+}
+
+// stopping pushes the partial aggregations, which would otherwise be lost.
+func (a *streamingAggregator) stopping(_ error) error {
+	a.flush(context.Background(), time.Now(), true)
+	return nil
+}
+
+// flush pushes the aggregated series of the rules whose interval has ended, or of all the
+// rules with the current timestamp if partial. The input series whose last sample has been
+// received before the stale timeout are removed from the aggregations, as well as the
+// aggregated series not owned by this distributor anymore.
+func (a *streamingAggregator) flush(ctx context.Context, now time.Time, partial bool) {
+	a.mtx.RLock()
+	userIDs := make([]string, 0, len(a.tenants))
+	for userID := range a.tenants {
+		userIDs = append(userIDs, userID)
+	}
+	a.mtx.RUnlock()
+
+	for _, userID := range userIDs {
+		series, samples := a.flushTenant(userID, now, partial)
+		if len(series) == 0 {
+			continue
+		}
+
+		req := cortexpb.ToWriteRequest(series, samples, nil, cortexpb.RULE)
+		if _, err := a.push(user.InjectOrgID(ctx, userID), req); err != nil {
+			a.failedPushes.WithLabelValues(userID).Inc()
+			level.Warn(a.logger).Log("msg", "failed to push streaming aggregation series", "user", userID, "err", err)
+			continue
+		}
+
+		a.pushedSeries.WithLabelValues(userID).Add(float64(len(series)))
+	}
+}
+
+func (a *streamingAggregator) flushTenant(userID string, now time.Time, partial bool) ([]labels.Labels, []cortexpb.Sample) {
+	a.mtx.RLock()
+	tenant := a.tenants[userID]
+	a.mtx.RUnlock()
+	if tenant == nil {
+		return nil, nil
+	}
+
+	rules := map[string]struct{}{}
+	for _, rule := range a.limits.StreamingAggregationRules(userID) {
+		rules[rule.String()] = struct{}{}
+	}
+
+	var (
+		series  []labels.Labels
+		samples []cortexpb.Sample
+	)
+
+	tenant.mtx.Lock()
+	for key, agg := range tenant.aggregations {
+		// The aggregations of the rules removed from the overrides are discarded.
+		if _, ok := rules[key]; !ok {
+			delete(tenant.aggregations, key)
+			continue
+		}
+
+		if !partial && now.Before(agg.intervalEnd) {
+			continue
+		}
+
+		timestamp := agg.intervalEnd
+		if partial {
+			timestamp = now
+		}
+
+		// The aggregated series moved to another distributor by a change of the distributors ring
+		// are removed, instead of being pushed with a partial aggregation, conflicting with the one
+		// of the new owner, until their input series are stale.
+		owned := map[*streamingAggregationGroup]bool{}
+		sums := map[*streamingAggregationGroup]float64{}
+		for seriesHash, s := range agg.series {
+			isOwned, ok := owned[s.group]
+			if !ok {
+				isOwned = a.owns(s.group.token)
+				owned[s.group] = isOwned
+			}
+
+			// The input series not received anymore are removed.
+			if !isOwned || now.Sub(s.receivedAt) > a.cfg.SeriesStaleTimeout {
+				delete(agg.series, seriesHash)
+				s.group.numSeries--
+				continue
+			}
+
+			sums[s.group] += s.value
+		}
+
+		for groupHash, group := range agg.groups {
+			if group.numSeries == 0 {
+				delete(agg.groups, groupHash)
+				continue
+			}
+
+			value := sums[group]
+			if agg.rule.Type == validation.StreamingAggregationCounter {
+				value = group.total
+			}
+
+			series = append(series, group.labels)
+			samples = append(samples, cortexpb.Sample{
+				TimestampMs: util.TimeToMillis(timestamp),
+				Value:       value,
+			})
+		}
+
+		if len(agg.series) == 0 {
+			delete(tenant.aggregations, key)
+			continue
+		}
+
+		agg.intervalEnd = nextIntervalEnd(now, time.Duration(agg.rule.Interval))
+	}
+	empty := len(tenant.aggregations) == 0
+	tenant.mtx.Unlock()
+
+	if empty {
+		a.mtx.Lock()
+		tenant.mtx.Lock()
+		if len(tenant.aggregations) == 0 {
+			tenant.deleted = true
+			delete(a.tenants, userID)
+		}
+		tenant.mtx.Unlock()
+		a.mtx.Unlock()
+	}
+
+	return series, samples
+}
+
+// owns returns whether this distributor owns the aggregated series with the token in the
+// distributors ring. The aggregated series is considered owned if the ring can't be read.
+func (a *streamingAggregator) owns(token uint32) bool {
+	bufDescs, bufHosts, bufZones := ring.MakeBuffersForGet()
+	set, err := a.ring.Get(token, streamingAggregationOp, bufDescs, bufHosts, bufZones)
+	if err != nil {
+		level.Warn(a.logger).Log("msg", "failed to find the distributor owning the streaming aggregation", "err", err)
+		return true
+	}
+	return set.Instances[0].Addr == a.instanceAddr
+}
+
+func (a *streamingAggregator) cleanupMetricsForUser(userID string) {
+	a.aggregatedSamples.DeleteLabelValues(userID)
+	a.forwardedSeries.DeleteLabelValues(userID)
+	a.forwardFailures.DeleteLabelValues(userID)
+	a.pushedSeries.DeleteLabelValues(userID)
+	a.failedPushes.DeleteLabelValues(userID)
+}
+
+// nextIntervalEnd returns the end of the interval, aligned to the interval duration, now belongs to.
+func nextIntervalEnd(now time.Time, interval time.Duration) time.Time {
+	return now.Truncate(interval).Add(interval)
+}
@@ -0,0 +1,447 @@
+package distributor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/cortexproject/cortex/pkg/cortexpb"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/cortexproject/cortex/pkg/util/test"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+func TestDistributor_StreamingAggregation(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "user")
+	now := time.Now()
+	intervalEnd := nextIntervalEnd(now, time.Minute)
+
+	input := func(name, pod, status string, value float64, ts time.Time) cortexpb.PreallocTimeseries {
+		return makeWriteRequestTimeseries([]cortexpb.LabelAdapter{
+			{Name: model.MetricNameLabel, Value: name},
+			{Name: "pod", Value: pod},
+			{Name: "status", Value: status},
+		}, util.TimeToMillis(ts), value)
+	}
+
+	tests := map[string]struct {
+		dropInput      bool
+		expectedSeries []labels.Labels
+	}{
+		"should ingest both the input and aggregated series": {
+			dropInput: false,
+			expectedSeries: []labels.Labels{
+				labels.FromStrings(model.MetricNameLabel, "http_requests_total", "pod", "pod-1", "status", "200"),
+				labels.FromStrings(model.MetricNameLabel, "http_requests_total", "pod", "pod-2", "status", "200"),
+				labels.FromStrings(model.MetricNameLabel, "http_requests_total", "pod", "pod-1", "status", "500"),
+				labels.FromStrings(model.MetricNameLabel, "other_metric", "pod", "pod-1", "status", "200"),
+				labels.FromStrings(model.MetricNameLabel, "http_requests:sum", "status", "200"),
+				labels.FromStrings(model.MetricNameLabel, "http_requests:sum", "status", "500"),
+			},
+		},
+		"should only ingest the aggregated series if the input is dropped": {
+			dropInput: true,
+			expectedSeries: []labels.Labels{
+				labels.FromStrings(model.MetricNameLabel, "other_metric", "pod", "pod-1", "status", "200"),
+				labels.FromStrings(model.MetricNameLabel, "http_requests:sum", "status", "200"),
+				labels.FromStrings(model.MetricNameLabel, "http_requests:sum", "status", "500"),
+			},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			limits := &validation.Limits{}
+			flagext.DefaultValues(limits)
+			limits.StreamingAggregationRules = []*validation.StreamingAggregationRule{{
+				Record:    "http_requests:sum",
+				Metric:    "http_requests_total",
+				Type:      validation.StreamingAggregationCounter,
+				By:        []string{"status"},
+				Interval:  model.Duration(time.Minute),
+				DropInput: testData.dropInput,
+			}}
+
+			ds, ingesters, r, _ := prepare(t, prepConfig{
+				numIngesters:         1,
+				happyIngesters:       1,
+				replicationFactor:    1,
+				numDistributors:      2,
+				shardByAllLabels:     true,
+				limits:               limits,
+				streamingAggregation: true,
+			})
+			defer stopAll(ds, r)
+			waitDistributorsRing(t, ds)
+
+			// The series of the same group are received by different distributors, and the
+			// increases of the input series since their first sample are aggregated.
+			_, err := ds[0].Push(ctx, &cortexpb.WriteRequest{Timeseries: []cortexpb.PreallocTimeseries{
+				input("http_requests_total", "pod-1", "200", 1, now.Add(-time.Second)),
+				input("http_requests_total", "pod-1", "500", 3, now.Add(-time.Second)),
+				input("other_metric", "pod-1", "200", 4, now.Add(-time.Second)),
+			}})
+			require.NoError(t, err)
+
+			_, err = ds[1].Push(ctx, &cortexpb.WriteRequest{Timeseries: []cortexpb.PreallocTimeseries{
+				input("http_requests_total", "pod-2", "200", 2, now.Add(-time.Second)),
+				input("http_requests_total", "pod-1", "200", 10, now),
+			}})
+			require.NoError(t, err)
+
+			// The input series received by the distributor not owning their aggregation are forwarded.
+			forwarded := 0.0
+			for _, d := range ds {
+				forwarded += testutil.ToFloat64(d.streamingAggregator.forwardedSeries.WithLabelValues("user"))
+			}
+			assert.Greater(t, forwarded, 0.0)
+
+			// Nothing is pushed before the end of the interval.
+			for _, d := range ds {
+				d.streamingAggregator.flush(context.Background(), intervalEnd.Add(-time.Millisecond), false)
+			}
+			for _, d := range ds {
+				d.streamingAggregator.flush(context.Background(), intervalEnd, false)
+			}
+
+			// Each aggregated series is computed by a single distributor.
+			expectedSamples := map[string]cortexpb.Sample{
+				`{__name__="http_requests:sum", status="200"}`: {Value: 9, TimestampMs: util.TimeToMillis(intervalEnd)},
+				`{__name__="http_requests:sum", status="500"}`: {Value: 0, TimestampMs: util.TimeToMillis(intervalEnd)},
+			}
+
+			var actualSeries []labels.Labels
+			for _, series := range ingesters[0].series() {
+				lbls := cortexpb.FromLabelAdaptersToLabels(series.Labels)
+				actualSeries = append(actualSeries, lbls)
+
+				if expected, ok := expectedSamples[lbls.String()]; ok {
+					assert.Equal(t, []cortexpb.Sample{expected}, series.Samples)
+				}
+			}
+			assert.ElementsMatch(t, testData.expectedSeries, actualSeries)
+		})
+	}
+}
+
+func TestDistributor_StreamingAggregation_ShouldKeepTheLastSampleUntilStale(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "user")
+	now := time.Now()
+	intervalEnd := nextIntervalEnd(now, time.Minute)
+
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.StreamingAggregationRules = []*validation.StreamingAggregationRule{{
+		Record:    "output",
+		Metric:    "input",
+		Type:      validation.StreamingAggregationGauge,
+		Interval:  model.Duration(time.Minute),
+		DropInput: true,
+	}}
+
+	ds, ingesters, r, _ := prepare(t, prepConfig{
+		numIngesters:         1,
+		happyIngesters:       1,
+		replicationFactor:    1,
+		numDistributors:      1,
+		shardByAllLabels:     true,
+		limits:               limits,
+		streamingAggregation: true,
+	})
+	defer stopAll(ds, r)
+	waitDistributorsRing(t, ds)
+
+	_, err := ds[0].Push(ctx, &cortexpb.WriteRequest{Timeseries: []cortexpb.PreallocTimeseries{
+		makeWriteRequestTimeseries([]cortexpb.LabelAdapter{{Name: model.MetricNameLabel, Value: "input"}, {Name: "pod", Value: "pod-1"}}, util.TimeToMillis(now), 1),
+		makeWriteRequestTimeseries([]cortexpb.LabelAdapter{{Name: model.MetricNameLabel, Value: "input"}, {Name: "pod", Value: "pod-2"}}, util.TimeToMillis(now), 2),
+	}})
+	require.NoError(t, err)
+
+	// The input series not received in the next interval are still aggregated.
+	a := ds[0].streamingAggregator
+	a.flush(context.Background(), intervalEnd, false)
+	a.flush(context.Background(), intervalEnd.Add(time.Minute), false)
+
+	outputSamples := func() []cortexpb.Sample {
+		for _, series := range ingesters[0].series() {
+			if cortexpb.FromLabelAdaptersToLabels(series.Labels).Get(model.MetricNameLabel) == "output" {
+				return series.Samples
+			}
+		}
+		return nil
+	}
+	assert.Equal(t, []cortexpb.Sample{
+		{Value: 3, TimestampMs: util.TimeToMillis(intervalEnd)},
+		{Value: 3, TimestampMs: util.TimeToMillis(intervalEnd.Add(time.Minute))},
+	}, outputSamples())
+
+	// The input series are removed from the aggregation once stale.
+	a.flush(context.Background(), now.Add(a.cfg.SeriesStaleTimeout+time.Minute), false)
+	assert.Len(t, outputSamples(), 2)
+	assert.Empty(t, a.tenants)
+}
+
+func TestDistributor_StreamingAggregation_ShouldPushThePartialAggregationsOnStop(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "user")
+	now := time.Now()
+
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.StreamingAggregationRules = []*validation.StreamingAggregationRule{{
+		Record:    "output",
+		Metric:    "input",
+		Type:      validation.StreamingAggregationGauge,
+		Interval:  model.Duration(time.Hour),
+		DropInput: true,
+	}}
+
+	ds, ingesters, r, _ := prepare(t, prepConfig{
+		numIngesters:         1,
+		happyIngesters:       1,
+		replicationFactor:    1,
+		numDistributors:      1,
+		shardByAllLabels:     true,
+		limits:               limits,
+		streamingAggregation: true,
+	})
+	defer stopAll(ds, r)
+	waitDistributorsRing(t, ds)
+
+	_, err := ds[0].Push(ctx, &cortexpb.WriteRequest{Timeseries: []cortexpb.PreallocTimeseries{
+		makeWriteRequestTimeseries([]cortexpb.LabelAdapter{{Name: model.MetricNameLabel, Value: "input"}}, util.TimeToMillis(now), 1),
+	}})
+	require.NoError(t, err)
+	assert.Empty(t, ingesters[0].series())
+
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), ds[0]))
+
+	series := ingesters[0].series()
+	require.Len(t, series, 1)
+	for _, s := range series {
+		assert.Equal(t, labels.FromStrings(model.MetricNameLabel, "output"), cortexpb.FromLabelAdaptersToLabels(s.Labels))
+		require.Len(t, s.Samples, 1)
+		assert.Equal(t, 1.0, s.Samples[0].Value)
+	}
+}
+
+func TestDistributor_StreamingAggregation_ShouldAccumulateTheIncreasesOfCounters(t *testing.T) {
+	now := time.Now()
+	intervalEnd := nextIntervalEnd(now, time.Minute)
+
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.StreamingAggregationRules = []*validation.StreamingAggregationRule{{
+		Record:   "output",
+		Metric:   "input",
+		Type:     validation.StreamingAggregationCounter,
+		Interval: model.Duration(time.Minute),
+	}}
+	rule := limits.StreamingAggregationRules[0]
+
+	ds, ingesters, r, _ := prepare(t, prepConfig{
+		numIngesters:         1,
+		happyIngesters:       1,
+		replicationFactor:    1,
+		numDistributors:      1,
+		shardByAllLabels:     true,
+		limits:               limits,
+		streamingAggregation: true,
+	})
+	defer stopAll(ds, r)
+	waitDistributorsRing(t, ds)
+
+	input := func(pod string, values ...float64) cortexpb.PreallocTimeseries {
+		ts := makeWriteRequestTimeseries([]cortexpb.LabelAdapter{{Name: model.MetricNameLabel, Value: "input"}, {Name: "pod", Value: pod}}, 0, 0)
+		ts.Samples = ts.Samples[:0]
+		for i, v := range values {
+			ts.Samples = append(ts.Samples, cortexpb.Sample{TimestampMs: util.TimeToMillis(now) + int64(i)*1000, Value: v})
+		}
+		return ts
+	}
+
+	outputSamples := func() []cortexpb.Sample {
+		for _, series := range ingesters[0].series() {
+			if cortexpb.FromLabelAdaptersToLabels(series.Labels).Get(model.MetricNameLabel) == "output" {
+				return series.Samples
+			}
+		}
+		return nil
+	}
+
+	// The first sample of each input series is the baseline of its increases, and a
+	// decrease of an input series is a counter reset.
+	a := ds[0].streamingAggregator
+	a.observe("user", rule, input("pod-1", 10, 15), now)
+	a.observe("user", rule, input("pod-2", 5, 7, 2), now)
+
+	// The samples already received, e.g. because the push request is retried, are ignored.
+	a.observe("user", rule, input("pod-1", 10, 15), now)
+
+	a.flush(context.Background(), intervalEnd, false)
+	assert.Equal(t, []cortexpb.Sample{
+		{Value: 9, TimestampMs: util.TimeToMillis(intervalEnd)},
+	}, outputSamples())
+
+	// The aggregated series doesn't decrease once an input series is stale.
+	a.observe("user", rule, input("pod-1", 10, 15, 20), now.Add(a.cfg.SeriesStaleTimeout))
+	a.flush(context.Background(), now.Add(a.cfg.SeriesStaleTimeout+time.Second), true)
+	assert.Equal(t, []cortexpb.Sample{
+		{Value: 9, TimestampMs: util.TimeToMillis(intervalEnd)},
+		{Value: 14, TimestampMs: util.TimeToMillis(now.Add(a.cfg.SeriesStaleTimeout + time.Second))},
+	}, outputSamples())
+}
+
+func TestDistributor_StreamingAggregation_ShouldRemoveTheAggregationsNotOwnedAnymore(t *testing.T) {
+	now := time.Now()
+
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.StreamingAggregationRules = []*validation.StreamingAggregationRule{{
+		Record:   "output",
+		Metric:   "input",
+		Type:     validation.StreamingAggregationGauge,
+		By:       []string{"pod"},
+		Interval: model.Duration(time.Minute),
+	}}
+	rule := limits.StreamingAggregationRules[0]
+
+	ds, ingesters, r, _ := prepare(t, prepConfig{
+		numIngesters:         1,
+		happyIngesters:       1,
+		replicationFactor:    1,
+		numDistributors:      2,
+		shardByAllLabels:     true,
+		limits:               limits,
+		streamingAggregation: true,
+	})
+	defer stopAll(ds, r)
+	waitDistributorsRing(t, ds)
+
+	// Each distributor aggregates a series, as if the ownership of the aggregated series
+	// has changed since the series has been received.
+	series := makeWriteRequestTimeseries([]cortexpb.LabelAdapter{{Name: model.MetricNameLabel, Value: "input"}, {Name: "pod", Value: "pod-1"}}, util.TimeToMillis(now), 1)
+	for _, d := range ds {
+		d.streamingAggregator.observe("user", rule, series, now)
+	}
+
+	// Only the distributor owning the aggregated series pushes it.
+	for _, d := range ds {
+		d.streamingAggregator.flush(context.Background(), nextIntervalEnd(now, time.Minute), false)
+	}
+
+	numTenants := 0
+	for _, d := range ds {
+		numTenants += len(d.streamingAggregator.tenants)
+	}
+	assert.Equal(t, 1, numTenants)
+
+	pushed := ingesters[0].series()
+	require.Len(t, pushed, 1)
+	for _, s := range pushed {
+		assert.Equal(t, labels.FromStrings(model.MetricNameLabel, "output", "pod", "pod-1"), cortexpb.FromLabelAdaptersToLabels(s.Labels))
+		assert.Len(t, s.Samples, 1)
+	}
+}
+
+func TestStreamingAggregator_ShouldDiscardTheAggregationsOfRemovedRules(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.StreamingAggregationRules = []*validation.StreamingAggregationRule{
+		{Record: "output", Metric: "input", Type: validation.StreamingAggregationGauge, Interval: model.Duration(time.Minute)},
+	}
+
+	ds, _, r, _ := prepare(t, prepConfig{
+		numIngesters:         1,
+		happyIngesters:       1,
+		replicationFactor:    1,
+		numDistributors:      1,
+		shardByAllLabels:     true,
+		limits:               limits,
+		streamingAggregation: true,
+	})
+	defer stopAll(ds, r)
+	waitDistributorsRing(t, ds)
+
+	var pushed []*cortexpb.WriteRequest
+	a := ds[0].streamingAggregator
+	a.push = func(_ context.Context, req *cortexpb.WriteRequest) (*cortexpb.WriteResponse, error) {
+		pushed = append(pushed, req)
+		return &cortexpb.WriteResponse{}, nil
+	}
+
+	now := time.Now()
+	series := makeWriteRequestTimeseries([]cortexpb.LabelAdapter{{Name: model.MetricNameLabel, Value: "input"}}, util.TimeToMillis(now), 1)
+
+	a.observe("user", limits.StreamingAggregationRules[0], series, now)
+	a.flush(context.Background(), nextIntervalEnd(now, time.Minute), false)
+	require.Len(t, pushed, 1)
+	require.Len(t, pushed[0].Timeseries, 1)
+	assert.Equal(t, labels.FromStrings(model.MetricNameLabel, "output"), cortexpb.FromLabelAdaptersToLabels(pushed[0].Timeseries[0].Labels))
+
+	limits.StreamingAggregationRules = nil
+	overrides, err := validation.NewOverrides(*limits, nil)
+	require.NoError(t, err)
+	a.limits = overrides
+
+	a.flush(context.Background(), nextIntervalEnd(now, time.Minute).Add(time.Minute), false)
+	assert.Len(t, pushed, 1)
+	assert.Empty(t, a.tenants)
+}
+
+// waitDistributorsRing waits until all the distributors see each other in the distributors ring.
+func waitDistributorsRing(t *testing.T, ds []*Distributor) {
+	for _, d := range ds {
+		test.Poll(t, time.Second, len(ds), func() interface{} {
+			return d.distributorsRing.InstancesCount()
+		})
+	}
+}
+
+// mockDistributorClient forwards the requests to an in-process distributor.
+type mockDistributorClient struct {
+	grpc_health_v1.HealthClient
+	distributor *Distributor
+}
+
+func (c *mockDistributorClient) Push(ctx context.Context, req *cortexpb.WriteRequest, _ ...grpc.CallOption) (*cortexpb.WriteResponse, error) {
+	return c.distributor.Push(ctx, c.copyRequest(req))
+}
+
+func (c *mockDistributorClient) Aggregate(ctx context.Context, req *cortexpb.WriteRequest, _ ...grpc.CallOption) (*cortexpb.WriteResponse, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return c.distributor.Aggregate(metadata.NewIncomingContext(ctx, md), c.copyRequest(req))
+}
+
+// copyRequest returns a copy of the request, since it's reused once handled by the distributor.
+func (c *mockDistributorClient) copyRequest(req *cortexpb.WriteRequest) *cortexpb.WriteRequest {
+	data, err := req.Marshal()
+	if err != nil {
+		panic(err)
+	}
+
+	copied := &cortexpb.PreallocWriteRequest{}
+	if err := copied.Unmarshal(data); err != nil {
+		panic(err)
+	}
+	return &copied.WriteRequest
+}
+
+func (c *mockDistributorClient) Check(context.Context, *grpc_health_v1.HealthCheckRequest, ...grpc.CallOption) (*grpc_health_v1.HealthCheckResponse, error) {
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func (c *mockDistributorClient) Close() error {
+	return nil
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/tenant"
 	"github.com/cortexproject/cortex/pkg/util"
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
 const (
@@ -57,20 +58,37 @@ type Handler struct {
 	cfg          HandlerConfig
 	log          log.Logger
 	roundTripper http.RoundTripper
+	limiter      *requestsLimiter
 
 	// Metrics.
 	querySeconds *prometheus.CounterVec
 	activeUsers  *util.ActiveUsersCleanupService
+
+	// Tracks the tenants whose requests have been discarded, to clean up their metrics.
+	discardedUsers *util.ActiveUsersCleanupService
 }
 
-// NewHandler creates a new frontend handler.
-func NewHandler(cfg HandlerConfig, roundTripper http.RoundTripper, log log.Logger, reg prometheus.Registerer) http.Handler {
+// NewHandler creates a new frontend handler. The per-tenant request rate and concurrency
+// limits are not enforced if limits is nil.
+func NewHandler(cfg HandlerConfig, roundTripper http.RoundTripper, limits Limits, log log.Logger, reg prometheus.Registerer) http.Handler {
 	h := &Handler{
 		cfg:          cfg,
 		log:          log,
 		roundTripper: roundTripper,
 	}
 
+	if limits != nil {
+		h.limiter = newRequestsLimiter(limits)
+
+		h.discardedUsers = util.NewActiveUsersCleanupWithDefaultValues(func(user string) {
+			if err := util.DeleteMatchingLabels(validation.DiscardedRequests, map[string]string{"user": user}); err != nil {
+				level.Warn(log).Log("msg", "failed to remove cortex_discarded_requests_total metric for user", "user", user, "err", err)
+			}
+		})
+		// If cleaner stops or fail, we will simply not clean the metrics for inactive users.
+		_ = h.discardedUsers.StartAsync(context.Background())
+	}
+
 	if cfg.QueryStatsEnabled {
 		h.querySeconds = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 			Name: "cortex_query_seconds_total",
@@ -110,6 +128,14 @@ func (f *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, f.cfg.MaxBodySize)
 	r.Body = ioutil.NopCloser(io.TeeReader(r.Body, &buf))
 
+	// Enforce the per-tenant limits before the request is enqueued.
+	release, err := f.acquireLimits(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer release()
+
 	startTime := time.Now()
 	resp, err := f.roundTripper.RoundTrip(r)
 	queryResponseTime := time.Since(startTime)
@@ -146,6 +172,37 @@ func (f *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// acquireLimits checks the request doesn't exceed the per-tenant request rate and concurrency limits
+// of its query class. The returned function must be called once the request has completed.
+func (f *Handler) acquireLimits(r *http.Request) (func(), error) {
+	noop := func() {}
+
+	if f.limiter == nil {
+		return noop, nil
+	}
+
+	class, ok := classifyQuery(r.URL.Path)
+	if !ok {
+		return noop, nil
+	}
+
+	// Requests without tenant are rejected later on.
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return noop, nil
+	}
+
+	release, err := f.limiter.acquire(class, tenantIDs)
+	if err != nil {
+		now := time.Now()
+		for _, tenantID := range tenantIDs {
+			f.discardedUsers.UpdateUserTimestamp(tenantID, now)
+		}
+		return nil, err
+	}
+	return release, nil
+}
+
 // reportSlowQuery reports slow queries.
 func (f *Handler) reportSlowQuery(r *http.Request, queryString url.Values, queryResponseTime time.Duration) {
 	logMessage := append([]interface{}{
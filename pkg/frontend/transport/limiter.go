@@ -0,0 +1,195 @@
+package transport
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/common/httpgrpc"
+	"golang.org/x/time/rate"
+
+	"github.com/cortexproject/cortex/pkg/util/limiter"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+const (
+	// How frequently the per-tenant rate limiters are reconfigured from the limits.
+	rateLimitRecheckPeriod = 10 * time.Second
+)
+
+// Limits needed for the query-frontend handler.
+type Limits interface {
+	MaxRangeQueriesPerSecond(userID string) float64
+	MaxInstantQueriesPerSecond(userID string) float64
+	MaxMetadataQueriesPerSecond(userID string) float64
+	MaxConcurrentRangeQueries(userID string) int
+	MaxConcurrentInstantQueries(userID string) int
+	MaxConcurrentMetadataQueries(userID string) int
+}
+
+// queryClass groups the endpoints sharing the same request rate and concurrency limits.
+type queryClass string
+
+const (
+	rangeQueries    queryClass = "range"
+	instantQueries  queryClass = "instant"
+	metadataQueries queryClass = "metadata"
+)
+
+var queryClasses = []queryClass{rangeQueries, instantQueries, metadataQueries}
+
+// classifyQuery returns the class of the query sent to the given path, or false if
+// the requests to the path are not limited.
+func classifyQuery(path string) (queryClass, bool) {
+	switch {
+	case strings.HasSuffix(path, "/api/v1/query_range"),
+		strings.HasSuffix(path, "/api/v1/query_exemplars"),
+		strings.HasSuffix(path, "/api/v1/read"):
+		return rangeQueries, true
+	case strings.HasSuffix(path, "/api/v1/query"):
+		return instantQueries, true
+	case strings.HasSuffix(path, "/api/v1/series"),
+		strings.HasSuffix(path, "/api/v1/labels"),
+		strings.HasSuffix(path, "/api/v1/metadata"),
+		strings.HasSuffix(path, "/values") && strings.Contains(path, "/api/v1/label/"):
+		return metadataQueries, true
+	default:
+		return "", false
+	}
+}
+
+func (c queryClass) rateLimitedReason() string {
+	return string(c) + "_queries_rate_limited"
+}
+
+func (c queryClass) concurrencyLimitedReason() string {
+	return string(c) + "_queries_concurrency_limited"
+}
+
+// queryRateStrategy is the strategy of the per-tenant rate limiter of a query class.
+type queryRateStrategy struct {
+	limits Limits
+	class  queryClass
+}
+
+func (s queryRateStrategy) rate(tenantID string) float64 {
+	switch s.class {
+	case rangeQueries:
+		return s.limits.MaxRangeQueriesPerSecond(tenantID)
+	case instantQueries:
+		return s.limits.MaxInstantQueriesPerSecond(tenantID)
+	default:
+		return s.limits.MaxMetadataQueriesPerSecond(tenantID)
+	}
+}
+
+func (s queryRateStrategy) Limit(tenantID string) float64 {
+	if r := s.rate(tenantID); r > 0 {
+		return r
+	}
+	return float64(rate.Inf)
+}
+
+func (s queryRateStrategy) Burst(tenantID string) int {
+	// Allow bursts of up to one second of queries. The burst is ignored when the limit is disabled.
+	return int(math.Max(1, math.Ceil(s.rate(tenantID))))
+}
+
+// requestsLimiter enforces the per-tenant request rate and concurrency limits of each query class.
+type requestsLimiter struct {
+	limits       Limits
+	rateLimiters map[queryClass]*limiter.RateLimiter
+
+	inflightMtx sync.Mutex
+	inflight    map[queryClass]map[string]int
+}
+
+func newRequestsLimiter(limits Limits) *requestsLimiter {
+	l := &requestsLimiter{
+		limits:       limits,
+		rateLimiters: map[queryClass]*limiter.RateLimiter{},
+		inflight:     map[queryClass]map[string]int{},
+	}
+
+	for _, class := range queryClasses {
+		l.rateLimiters[class] = limiter.NewRateLimiter(queryRateStrategy{limits: limits, class: class}, rateLimitRecheckPeriod)
+		l.inflight[class] = map[string]int{}
+	}
+
+	return l
+}
+
+func (l *requestsLimiter) maxConcurrent(class queryClass, tenantID string) int {
+	switch class {
+	case rangeQueries:
+		return l.limits.MaxConcurrentRangeQueries(tenantID)
+	case instantQueries:
+		return l.limits.MaxConcurrentInstantQueries(tenantID)
+	default:
+		return l.limits.MaxConcurrentMetadataQueries(tenantID)
+	}
+}
+
+// acquire checks whether a query of the class can be run for all the tenants. If so, the
+// returned function must be called once the query has completed, otherwise the query must
+// be rejected with the returned error.
+func (l *requestsLimiter) acquire(class queryClass, tenantIDs []string) (func(), error) {
+	// The concurrency is checked first since, unlike the rate, it can be rolled back if
+	// the limit is reached by one of the tenants.
+	if err := l.acquireConcurrency(class, tenantIDs); err != nil {
+		return nil, err
+	}
+
+	// The tokens of all the tenants are reserved first, so that they can be restored if
+	// the rate limit is reached by one of the tenants.
+	now := time.Now()
+	reservations := make([]*rate.Reservation, 0, len(tenantIDs))
+	for _, tenantID := range tenantIDs {
+		r := l.rateLimiters[class].ReserveN(now, tenantID, 1)
+		reservations = append(reservations, r)
+
+		if !r.OK() || r.DelayFrom(now) > 0 {
+			for _, r := range reservations {
+				r.CancelAt(now)
+			}
+			l.releaseConcurrency(class, tenantIDs)
+
+			validation.DiscardedRequests.WithLabelValues(class.rateLimitedReason(), tenantID).Inc()
+			return nil, httpgrpc.Errorf(http.StatusTooManyRequests, "too many %s queries per second for tenant %s (limit: %v)", class, tenantID, l.rateLimiters[class].Limit(now, tenantID))
+		}
+	}
+
+	return func() { l.releaseConcurrency(class, tenantIDs) }, nil
+}
+
+func (l *requestsLimiter) acquireConcurrency(class queryClass, tenantIDs []string) error {
+	l.inflightMtx.Lock()
+	defer l.inflightMtx.Unlock()
+
+	inflight := l.inflight[class]
+	for _, tenantID := range tenantIDs {
+		if max := l.maxConcurrent(class, tenantID); max > 0 && inflight[tenantID] >= max {
+			validation.DiscardedRequests.WithLabelValues(class.concurrencyLimitedReason(), tenantID).Inc()
+			return httpgrpc.Errorf(http.StatusTooManyRequests, "too many concurrent %s queries for tenant %s (limit: %d)", class, tenantID, max)
+		}
+	}
+
+	for _, tenantID := range tenantIDs {
+		inflight[tenantID]++
+	}
+	return nil
+}
+
+func (l *requestsLimiter) releaseConcurrency(class queryClass, tenantIDs []string) {
+	l.inflightMtx.Lock()
+	defer l.inflightMtx.Unlock()
+
+	inflight := l.inflight[class]
+	for _, tenantID := range tenantIDs {
+		if inflight[tenantID]--; inflight[tenantID] <= 0 {
+			delete(inflight, tenantID)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package transport
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+type mockLimits struct {
+	rangeRate, instantRate, metadataRate                      float64
+	rangeConcurrency, instantConcurrency, metadataConcurrency int
+}
+
+func (m mockLimits) MaxRangeQueriesPerSecond(string) float64    { return m.rangeRate }
+func (m mockLimits) MaxInstantQueriesPerSecond(string) float64  { return m.instantRate }
+func (m mockLimits) MaxMetadataQueriesPerSecond(string) float64 { return m.metadataRate }
+func (m mockLimits) MaxConcurrentRangeQueries(string) int       { return m.rangeConcurrency }
+func (m mockLimits) MaxConcurrentInstantQueries(string) int     { return m.instantConcurrency }
+func (m mockLimits) MaxConcurrentMetadataQueries(string) int    { return m.metadataConcurrency }
+
+func TestClassifyQuery(t *testing.T) {
+	tests := map[string]struct {
+		expectedClass queryClass
+		expectedOK    bool
+	}{
+		"/prometheus/api/v1/query_range":            {rangeQueries, true},
+		"/prometheus/api/v1/query_exemplars":        {rangeQueries, true},
+		"/prometheus/api/v1/read":                   {rangeQueries, true},
+		"/prometheus/api/v1/query":                  {instantQueries, true},
+		"/prometheus/api/v1/series":                 {metadataQueries, true},
+		"/prometheus/api/v1/labels":                 {metadataQueries, true},
+		"/prometheus/api/v1/label/job/values":       {metadataQueries, true},
+		"/prometheus/api/v1/metadata":               {metadataQueries, true},
+		"/api/prom/api/v1/query_range":              {rangeQueries, true},
+		"/prometheus/api/v1/rules":                  {"", false},
+		"/prometheus/api/v1/status/buildinfo":       {"", false},
+		"/prometheus/api/v1/label/values/something": {"", false},
+	}
+
+	for path, testData := range tests {
+		t.Run(path, func(t *testing.T) {
+			class, ok := classifyQuery(path)
+			assert.Equal(t, testData.expectedClass, class)
+			assert.Equal(t, testData.expectedOK, ok)
+		})
+	}
+}
+
+func TestRequestsLimiter_Concurrency(t *testing.T) {
+	l := newRequestsLimiter(mockLimits{rangeConcurrency: 2})
+
+	release1, err := l.acquire(rangeQueries, []string{"user-1"})
+	require.NoError(t, err)
+	release2, err := l.acquire(rangeQueries, []string{"user-1", "user-2"})
+	require.NoError(t, err)
+
+	// The limit is reached for user-1.
+	_, err = l.acquire(rangeQueries, []string{"user-1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "too many concurrent range queries for tenant user-1 (limit: 2)")
+
+	// A federated query rejected for user-1 must not count towards the user-2 concurrency.
+	_, err = l.acquire(rangeQueries, []string{"user-2", "user-1"})
+	require.Error(t, err)
+
+	release3, err := l.acquire(rangeQueries, []string{"user-2"})
+	require.NoError(t, err)
+	release4, err := l.acquire(instantQueries, []string{"user-1"})
+	require.NoError(t, err)
+
+	// The limit is now reached for user-2 too.
+	_, err = l.acquire(rangeQueries, []string{"user-2"})
+	require.Error(t, err)
+
+	release1()
+	release5, err := l.acquire(rangeQueries, []string{"user-1"})
+	require.NoError(t, err)
+
+	for _, release := range []func(){release2, release3, release4, release5} {
+		release()
+	}
+	for _, class := range queryClasses {
+		assert.Empty(t, l.inflight[class])
+	}
+}
+
+func TestRequestsLimiter_Rate(t *testing.T) {
+	l := newRequestsLimiter(mockLimits{instantRate: 2})
+
+	// The burst allows up to one second of queries.
+	for i := 0; i < 2; i++ {
+		release, err := l.acquire(instantQueries, []string{"user-1"})
+		require.NoError(t, err)
+		release()
+	}
+
+	_, err := l.acquire(instantQueries, []string{"user-1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "too many instant queries per second for tenant user-1 (limit: 2)")
+
+	// The rejected query must not count towards the concurrency.
+	assert.Empty(t, l.inflight[instantQueries])
+
+	// Other classes and tenants are not affected.
+	_, err = l.acquire(rangeQueries, []string{"user-1"})
+	require.NoError(t, err)
+	_, err = l.acquire(instantQueries, []string{"user-2"})
+	require.NoError(t, err)
+}
+
+func TestRequestsLimiter_Rate_ShouldNotConsumeTokensOfRejectedFederatedQueries(t *testing.T) {
+	l := newRequestsLimiter(mockLimits{instantRate: 2})
+
+	for i := 0; i < 2; i++ {
+		_, err := l.acquire(instantQueries, []string{"user-2"})
+		require.NoError(t, err)
+	}
+
+	// The federated query is rejected because of user-2, without consuming the tokens of user-1.
+	_, err := l.acquire(instantQueries, []string{"user-1", "user-2"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "for tenant user-2")
+
+	for i := 0; i < 2; i++ {
+		_, err := l.acquire(instantQueries, []string{"user-1"})
+		require.NoError(t, err)
+	}
+}
+
+func TestHandler_Limits(t *testing.T) {
+	validation.DiscardedRequests.Reset()
+
+	roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+	})
+	handler := NewHandler(HandlerConfig{MaxBodySize: 1024}, roundTripper, mockLimits{metadataRate: 1}, log.NewNopLogger(), nil)
+
+	send := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req = req.WithContext(user.InjectOrgID(context.Background(), "user-1"))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send("/prometheus/api/v1/series"))
+	assert.Equal(t, http.StatusTooManyRequests, send("/prometheus/api/v1/labels"))
+	assert.Equal(t, http.StatusOK, send("/prometheus/api/v1/query_range"))
+
+	assert.NoError(t, testutil.CollectAndCompare(validation.DiscardedRequests, strings.NewReader(`
+		# HELP cortex_discarded_requests_total The total number of query requests that were discarded.
+		# TYPE cortex_discarded_requests_total counter
+		cortex_discarded_requests_total{reason="metadata_queries_rate_limited",user="user-1"} 1
+	`)))
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,27 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// memoryPressureMessagePrefix is the prefix of the message of the errors returned by the ingesters
+// rejecting a push request because under memory pressure. It tells them apart from the other
+// ResourceExhausted errors, like the ones returned by gRPC for messages exceeding the max size.
+const memoryPressureMessagePrefix = "cannot push: ingester is under memory pressure"
+
+// NewMemoryPressureError returns the error returned by an ingester rejecting a push request
+// because under memory pressure, for the given reason.
+func NewMemoryPressureError(reason string) error {
+	return status.Error(codes.ResourceExhausted, fmt.Sprintf("%s (%s)", memoryPressureMessagePrefix, reason))
+}
+
+// IsMemoryPressureError returns whether the error has been returned by an ingester rejecting
+// a push request because under memory pressure.
+func IsMemoryPressureError(err error) bool {
+	s, ok := status.FromError(err)
+	return ok && s.Code() == codes.ResourceExhausted && strings.HasPrefix(s.Message(), memoryPressureMessagePrefix)
+}
@@ -0,0 +1,31 @@
+package client
+
+import (
+	"github.com/cortexproject/cortex/pkg/cortexpb"
+)
+
+// ShardByMetricName returns the token for the given metric. The provided metricName
+// is guaranteed to not be retained.
+func ShardByMetricName(userID string, metricName string) uint32 {
+	h := ShardByUser(userID)
+	h = HashAdd32(h, metricName)
+	return h
+}
+
+// ShardByUser returns the token for the given tenant.
+func ShardByUser(userID string) uint32 {
+	h := HashNew32()
+	h = HashAdd32(h, userID)
+	return h
+}
+
+// ShardByAllLabels returns the token for the given series. It generates different values
+// for different order of same labels, so the labels are expected to be sorted.
+func ShardByAllLabels(userID string, labels []cortexpb.LabelAdapter) uint32 {
+	h := ShardByUser(userID)
+	for _, label := range labels {
+		h = HashAdd32(h, label.Name)
+		h = HashAdd32(h, label.Value)
+	}
+	return h
+}
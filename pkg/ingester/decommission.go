@@ -0,0 +1,206 @@
+package ingester
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+const decommissionTpl = `
+<!DOCTYPE html>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<title>Cortex Ingester Decommissioning</title>
+	</head>
+	<body>
+		<h1>Cortex Ingester Decommissioning</h1>
+		<p>Current time: {{ .Now }}</p>
+		<p>Ring state: {{ .RingState }}</p>
+		{{ if .Status }}
+		<table border="1">
+			<tr><th>Phase</th><td>{{ .Status.Phase }}</td></tr>
+			<tr><th>Started at</th><td>{{ .Status.StartedAt }}</td></tr>
+			{{ if not .Status.WaitUntil.IsZero }}
+			<tr><th>Leaving the ring at</th><td>{{ .Status.WaitUntil }}</td></tr>
+			{{ end }}
+			{{ if .Status.Error }}
+			<tr><th>Error</th><td>{{ .Status.Error }}</td></tr>
+			{{ end }}
+		</table>
+		{{ else }}
+		<p>
+			Decommissioning excludes the ingester from writes while it keeps serving reads, ships all
+			in-memory series to the storage, waits until queriers don't need to query it anymore and
+			finally leaves the ring and shuts down.
+		</p>
+		<form action="" method="POST">
+			<button type="submit">Decommission</button>
+		</form>
+		{{ end }}
+	</body>
+</html>`
+
+var decommissionTmpl *template.Template
+
+func init() {
+	decommissionTmpl = template.Must(template.New("decommission").Parse(decommissionTpl))
+}
+
+var errDecommissionNotRunning = errors.New("the ingester is not running")
+
+// decommissionPhase is a phase of the ingester decommissioning.
+type decommissionPhase string
+
+const (
+	decommissionExcludingFromWrites decommissionPhase = "excluding from writes"
+	decommissionShippingBlocks      decommissionPhase = "shipping blocks"
+	decommissionWaitingForQueriers  decommissionPhase = "waiting for queriers"
+	decommissionLeavingRing         decommissionPhase = "leaving the ring"
+	decommissionDone                decommissionPhase = "done"
+	decommissionFailed              decommissionPhase = "failed"
+)
+
+// decommissionStatus holds the progress of the ingester decommissioning.
+type decommissionStatus struct {
+	Phase     decommissionPhase `json:"phase"`
+	StartedAt time.Time         `json:"startedAt"`
+	WaitUntil time.Time         `json:"waitUntil"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// DecommissionHandler starts the ingester decommissioning on POST, and reports its progress.
+func (i *Ingester) DecommissionHandler(w http.ResponseWriter, r *http.Request) {
+	if !i.cfg.BlocksStorageEnabled {
+		http.Error(w, "decommissioning is only supported by the blocks storage", http.StatusNotImplemented)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if err := i.startDecommission(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	i.decommissionMtx.Lock()
+	var status *decommissionStatus
+	if i.decommissionStatus != nil {
+		copied := *i.decommissionStatus
+		status = &copied
+	}
+	i.decommissionMtx.Unlock()
+
+	util.RenderHTTPResponse(w, struct {
+		Status    *decommissionStatus `json:"status"`
+		RingState string              `json:"ringState"`
+		Now       time.Time           `json:"now"`
+	}{
+		Status:    status,
+		RingState: i.lifecycler.GetState().String(),
+		Now:       time.Now(),
+	}, decommissionTmpl, r)
+}
+
+// startDecommission starts the decommissioning in the background, unless already started.
+func (i *Ingester) startDecommission() error {
+	ctx := i.BasicService.ServiceContext()
+	if i.State() != services.Running || ctx == nil {
+		return errDecommissionNotRunning
+	}
+
+	i.decommissionMtx.Lock()
+	defer i.decommissionMtx.Unlock()
+
+	if i.decommissionStatus != nil {
+		return nil
+	}
+
+	level.Info(i.logger).Log("msg", "decommissioning ingester")
+	i.decommissionStatus = &decommissionStatus{
+		Phase:     decommissionExcludingFromWrites,
+		StartedAt: time.Now(),
+	}
+
+	go i.decommission(ctx)
+	return nil
+}
+
+// decommission excludes the ingester from writes, ships all the in-memory series to the storage
+// and waits until the queriers don't query the ingester for the shipped series anymore. Then
+// it leaves the ring and shuts down, flushing the series received in the meanwhile.
+func (i *Ingester) decommission(ctx context.Context) {
+	// The LEAVING instances are excluded from writes but still queried.
+	if err := i.lifecycler.ChangeState(ctx, ring.LEAVING); err != nil {
+		i.failDecommission(errors.Wrap(err, "exclude from writes"))
+		return
+	}
+
+	i.setDecommissionPhase(decommissionShippingBlocks, time.Time{})
+	if !i.flushBlocks(ctx, nil) {
+		i.failDecommission(errors.New("the ingester stopped before shipping the blocks"))
+		return
+	}
+
+	waitUntil := time.Now().Add(i.decommissionWaitPeriod())
+	i.setDecommissionPhase(decommissionWaitingForQueriers, waitUntil)
+
+	select {
+	case <-time.After(time.Until(waitUntil)):
+	case <-ctx.Done():
+		i.failDecommission(errors.New("the ingester stopped while waiting for queriers"))
+		return
+	}
+
+	i.setDecommissionPhase(decommissionLeavingRing, waitUntil)
+
+	// Flush the series received since the blocks have been shipped.
+	i.lifecycler.SetFlushOnShutdown(true)
+	i.lifecycler.SetUnregisterOnShutdown(true)
+
+	if err := services.StopAndAwaitTerminated(context.Background(), i); err != nil {
+		i.failDecommission(errors.Wrap(err, "shut down"))
+		return
+	}
+
+	i.setDecommissionPhase(decommissionDone, waitUntil)
+	level.Info(i.logger).Log("msg", "ingester decommissioned")
+}
+
+// decommissionWaitPeriod returns how long to wait, once the blocks have been shipped, before leaving the ring.
+func (i *Ingester) decommissionWaitPeriod() time.Duration {
+	// Queriers don't query ingesters for the series older than the query-ingesters-within period.
+	if i.cfg.QueryIngestersWithin > 0 {
+		return i.cfg.QueryIngestersWithin
+	}
+
+	// If disabled, queriers always query ingesters, so we wait until the shipped blocks
+	// have been discovered by the queriers and store-gateways instead.
+	return i.cfg.BlocksStorageConfig.BucketStore.SyncInterval
+}
+
+func (i *Ingester) setDecommissionPhase(phase decommissionPhase, waitUntil time.Time) {
+	i.decommissionMtx.Lock()
+	defer i.decommissionMtx.Unlock()
+
+	level.Info(i.logger).Log("msg", "ingester decommissioning progress", "phase", phase)
+	i.decommissionStatus.Phase = phase
+	i.decommissionStatus.WaitUntil = waitUntil
+}
+
+func (i *Ingester) failDecommission(err error) {
+	i.decommissionMtx.Lock()
+	defer i.decommissionMtx.Unlock()
+
+	level.Error(i.logger).Log("msg", "ingester decommissioning failed", "phase", i.decommissionStatus.Phase, "err", err)
+	i.decommissionStatus.Phase = decommissionFailed
+	i.decommissionStatus.Error = err.Error()
+}
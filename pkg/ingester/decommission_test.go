@@ -0,0 +1,109 @@
+package ingester
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/cortexproject/cortex/pkg/util/test"
+)
+
+func TestIngester_Decommission(t *testing.T) {
+	cfg := defaultIngesterTestConfig()
+	cfg.LifecyclerConfig.JoinAfter = 0
+	cfg.BlocksStorageConfig.TSDB.ShipConcurrency = 1
+	cfg.BlocksStorageConfig.TSDB.ShipInterval = 1 * time.Minute // Long enough to not be reached during the test.
+	cfg.QueryIngestersWithin = time.Second
+
+	reg := prometheus.NewPedanticRegistry()
+	i, err := prepareIngesterWithBlocksStorage(t, cfg, reg)
+	require.NoError(t, err)
+
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+	t.Cleanup(func() {
+		_ = services.StopAndAwaitTerminated(context.Background(), i)
+	})
+
+	test.Poll(t, 1*time.Second, ring.ACTIVE, func() interface{} {
+		return i.lifecycler.GetState()
+	})
+
+	pushSingleSampleWithMetadata(t, i)
+
+	getStatus := func(method string) *decommissionStatus {
+		req := httptest.NewRequest(method, "/ingester/decommission", nil)
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+		i.DecommissionHandler(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		res := struct {
+			Status *decommissionStatus `json:"status"`
+		}{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return res.Status
+	}
+
+	// Not started yet.
+	assert.Nil(t, getStatus("GET"))
+
+	status := getStatus("POST")
+	require.NotNil(t, status)
+
+	// The ingester keeps serving reads once the blocks have been shipped.
+	test.Poll(t, 5*time.Second, decommissionWaitingForQueriers, func() interface{} {
+		return getStatus("GET").Phase
+	})
+	assert.Equal(t, ring.LEAVING, i.lifecycler.GetState())
+	assert.Equal(t, services.Running, i.State())
+	require.NoError(t, testutil.GatherAndCompare(reg, bytes.NewBufferString(`
+		# HELP cortex_ingester_shipper_uploads_total Total number of uploaded TSDB blocks
+		# TYPE cortex_ingester_shipper_uploads_total counter
+		cortex_ingester_shipper_uploads_total 1
+	`), "cortex_ingester_shipper_uploads_total"))
+
+	// Starting the decommissioning again is a no-op.
+	assert.Equal(t, decommissionWaitingForQueriers, getStatus("POST").Phase)
+
+	// Then the ingester leaves the ring and shuts down.
+	test.Poll(t, 5*time.Second, decommissionDone, func() interface{} {
+		return getStatus("GET").Phase
+	})
+	assert.Equal(t, services.Terminated, i.State())
+
+	desc, err := cfg.LifecyclerConfig.RingConfig.KVStore.Mock.Get(context.Background(), ring.IngesterRingKey)
+	require.NoError(t, err)
+	assert.NotContains(t, desc.(*ring.Desc).Ingesters, i.lifecycler.ID)
+}
+
+func TestIngester_DecommissionHandler_ChunksStorage(t *testing.T) {
+	i := &Ingester{}
+
+	rec := httptest.NewRecorder()
+	i.DecommissionHandler(rec, httptest.NewRequest("POST", "/ingester/decommission", nil))
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+}
+
+func TestIngester_decommissionWaitPeriod(t *testing.T) {
+	cfg := defaultIngesterTestConfig()
+	cfg.BlocksStorageConfig.BucketStore.SyncInterval = 15 * time.Minute
+	i := &Ingester{cfg: cfg}
+
+	// Queriers always query the ingesters if the query-ingesters-within is disabled.
+	i.cfg.QueryIngestersWithin = 0
+	assert.Equal(t, 15*time.Minute, i.decommissionWaitPeriod())
+
+	i.cfg.QueryIngestersWithin = time.Hour
+	assert.Equal(t, time.Hour, i.decommissionWaitPeriod())
+}
@@ -24,6 +24,16 @@ func makeLimitError(errorType string, err error) error {
 	}
 }
 
+// makeFairShareLimitError returns an error for requests of a tenant over its fair share of
+// the instance limits. Unlike the other limits, the request can be retried later on.
+func makeFairShareLimitError(errorType string, err error) error {
+	return &validationError{
+		errorType: errorType,
+		err:       err,
+		code:      http.StatusTooManyRequests,
+	}
+}
+
 func makeNoReportError(errorType string) error {
 	return &validationError{
 		errorType: errorType,
@@ -13,7 +13,11 @@ import (
 	"golang.org/x/time/rate"
 
 	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/cortexpb"
+	"github.com/cortexproject/cortex/pkg/ingester/client"
+	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/concurrency"
 	"github.com/cortexproject/cortex/pkg/util/log"
 )
 
@@ -60,6 +64,52 @@ func (i *Ingester) FlushHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// FlushBeforeTokensRebalance implements ring.TokensRebalanceFlusher. With the chunks storage, the
+// series falling in the range given away to another ingester would be split across ingesters, so
+// their chunks are flushed, returning once they've been written to the store. With the blocks
+// storage, the in-memory series keep being queried and shipped as usual, so there's nothing to do.
+func (i *Ingester) FlushBeforeTokensRebalance(ctx context.Context, start, end uint32) error {
+	if i.cfg.BlocksStorageEnabled || i.chunkStore == nil {
+		return nil
+	}
+
+	type userSeries struct {
+		userID string
+		fp     model.Fingerprint
+	}
+
+	var jobs []interface{}
+	for id, state := range i.userStates.cp() {
+		for pair := range state.fpToSeries.iter() {
+			if ring.TokenInRange(i.seriesToken(id, pair.series.metric), start, end) {
+				jobs = append(jobs, userSeries{userID: id, fp: pair.fp})
+			}
+		}
+	}
+
+	level.Info(i.logger).Log("msg", "flushing the chunks of the series in the token range given away by rebalancing tokens", "start", start, "end", end, "series", len(jobs))
+	err := concurrency.ForEach(ctx, jobs, i.cfg.ConcurrentFlushes, func(_ context.Context, job interface{}) error {
+		s := job.(userSeries)
+		outcome, err := i.flushUserSeries(int(uint64(s.fp)%uint64(i.cfg.ConcurrentFlushes)), s.userID, s.fp, true)
+		i.metrics.seriesDequeuedOutcome.WithLabelValues(outcome.String()).Inc()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	level.Info(i.logger).Log("msg", "flushed the chunks before rebalancing tokens", "series", len(jobs))
+	return nil
+}
+
+// seriesToken returns the token the distributors use to shard the series.
+func (i *Ingester) seriesToken(userID string, metric labels.Labels) uint32 {
+	if i.cfg.DistributorShardByAllLabels {
+		return client.ShardByAllLabels(userID, cortexpb.FromLabelsToLabelAdapters(metric))
+	}
+	return client.ShardByMetricName(userID, metric.Get(model.MetricNameLabel))
+}
+
 type flushOp struct {
 	from      model.Time
 	userID    string
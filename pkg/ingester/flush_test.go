@@ -99,6 +99,44 @@ func TestFlushPanicIssue2743(t *testing.T) {
 	time.Sleep(2 * time.Second)
 }
 
+func TestFlushBeforeTokensRebalance(t *testing.T) {
+	cfg := emptyIngesterConfig()
+	cfg.FlushCheckPeriod = 1 * time.Hour // We don't want the periodic flush to kick in.
+	cfg.RetainPeriod = 1 * time.Hour
+	cfg.DistributorShardByAllLabels = true
+
+	// The store is slow to make sure we wait for the flush to complete.
+	st := &sleepyStoreWithErrors{d: 500 * time.Millisecond}
+	ing := createTestIngester(t, cfg, st)
+
+	handedOff := labels.Labels{{Name: labels.MetricName, Value: "handed_off"}}
+	kept := labels.Labels{{Name: labels.MetricName, Value: "kept"}}
+	token := ing.seriesToken(userID, handedOff)
+	require.NotEqual(t, token, ing.seriesToken(userID, kept))
+
+	push := func(metric labels.Labels, ts int64) {
+		_, err := ing.Push(user.InjectOrgID(context.Background(), userID), cortexpb.ToWriteRequest([]labels.Labels{metric}, []cortexpb.Sample{{TimestampMs: ts}}, nil, cortexpb.API))
+		require.NoError(t, err)
+	}
+
+	push(handedOff, 1)
+	push(handedOff, 2)
+	push(kept, 1)
+
+	// Only the chunks of the series in the range are flushed, and they're in the store once it returns.
+	require.NoError(t, ing.FlushBeforeTokensRebalance(context.Background(), token, token+1))
+	require.Equal(t, int64(2), st.samples.Load())
+
+	// The errors are returned, and the failed chunks flushed the next time.
+	push(handedOff, 3)
+	st.errorsToGenerate.Store(1)
+	require.Error(t, ing.FlushBeforeTokensRebalance(context.Background(), token, token+1))
+	require.Equal(t, int64(2), st.samples.Load())
+
+	require.NoError(t, ing.FlushBeforeTokensRebalance(context.Background(), token, token+1))
+	require.Equal(t, int64(3), st.samples.Load())
+}
+
 func pushSample(t *testing.T, ing *Ingester, sample cortexpb.Sample) {
 	_, err := ing.Push(user.InjectOrgID(context.Background(), userID), cortexpb.ToWriteRequest(singleTestLabel, []cortexpb.Sample{sample}, nil, cortexpb.API))
 	require.NoError(t, err)
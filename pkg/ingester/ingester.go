@@ -96,6 +96,14 @@ type Config struct {
 	DistributorShardingStrategy string `yaml:"-"`
 	DistributorShardByAllLabels bool   `yaml:"-"`
 
+	// Injected at runtime and read from the querier config, required to know
+	// for how long the ingester is queried once it stopped receiving writes.
+	QueryIngestersWithin time.Duration `yaml:"-"`
+
+	// Injected at runtime and read from the compactor config, required to validate
+	// the per-tenant TSDB block range period.
+	CompactorBlockRanges tsdb.DurationList `yaml:"-"`
+
 	DefaultLimits    InstanceLimits         `yaml:"instance_limits"`
 	InstanceLimitsFn func() *InstanceLimits `yaml:"-"`
 
@@ -134,10 +142,19 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.Int64Var(&cfg.DefaultLimits.MaxInMemoryTenants, "ingester.instance-limits.max-tenants", 0, "Max users that this ingester can hold. Requests from additional users will be rejected. This limit only works when using blocks engine. 0 = unlimited.")
 	f.Int64Var(&cfg.DefaultLimits.MaxInMemorySeries, "ingester.instance-limits.max-series", 0, "Max series that this ingester can hold (across all tenants). Requests to create additional series will be rejected. This limit only works when using blocks engine. 0 = unlimited.")
 	f.Int64Var(&cfg.DefaultLimits.MaxInflightPushRequests, "ingester.instance-limits.max-inflight-push-requests", 0, "Max inflight push requests that this ingester can handle (across all tenants). Additional requests will be rejected. 0 = unlimited.")
+	f.Float64Var(&cfg.DefaultLimits.FairShareThreshold, "ingester.instance-limits.fair-share-threshold", 0, "When the ingester's series or ingestion rate reach this fraction of the max series or max ingestion rate limits, only the tenants over their fair share of the limit are rejected, instead of all tenants once the limit is reached. The limits are shared among the tenants proportionally to their local limits, derived from their global limits, shard size and the replication factor. This limit only works when using blocks engine. 0 = disabled.")
+	f.Int64Var(&cfg.DefaultLimits.MaxHeapInuseBytes, "ingester.instance-limits.max-heap-inuse-bytes", 0, "Max bytes of Go heap in use, mostly holding the TSDB heads, above which the ingester is considered under memory pressure. Push requests are rejected with a retryable error while under memory pressure, and the distributor backs off from the ingester. This limit only works when using blocks engine. 0 = unlimited.")
+	f.Int64Var(&cfg.DefaultLimits.MaxWALBacklogBytes, "ingester.instance-limits.max-wal-backlog-bytes", 0, "Max size of the TSDB WAL segments not truncated yet, across all tenants, above which the ingester is considered under memory pressure. This limit only works when using blocks engine. 0 = unlimited.")
+	f.Float64Var(&cfg.DefaultLimits.MaxGCCPUFraction, "ingester.instance-limits.max-gc-cpu-fraction", 0, "Max fraction of the available CPU time used by the garbage collector during the last second, above which the ingester is considered under memory pressure. This limit only works when using blocks engine. 0 = unlimited.")
 
 	f.StringVar(&cfg.IgnoreSeriesLimitForMetricNames, "ingester.ignore-series-limit-for-metric-names", "", "Comma-separated list of metric names, for which -ingester.max-series-per-metric and -ingester.max-global-series-per-metric limits will be ignored. Does not affect max-series-per-user or max-global-series-per-metric limits.")
 }
 
+// Validate the config.
+func (cfg *Config) Validate() error {
+	return cfg.LifecyclerConfig.Validate()
+}
+
 func (cfg *Config) getIgnoreSeriesLimitForMetricNamesMap() map[string]struct{} {
 	if cfg.IgnoreSeriesLimitForMetricNames == "" {
 		return nil
@@ -207,6 +224,13 @@ type Ingester struct {
 	// Rate of pushed samples. Only used by V2-ingester to limit global samples push rate.
 	ingestionRate        *util_math.EwmaRate
 	inflightPushRequests atomic.Int64
+
+	// Memory pressure signals. Only used by V2-ingester to reject push requests under memory pressure.
+	memoryPressure *memoryPressure
+
+	// Progress of the decommissioning, nil if not started. Only used by V2-ingester.
+	decommissionMtx    sync.Mutex
+	decommissionStatus *decommissionStatus
 }
 
 // ChunkStore is the interface we need to store chunks
@@ -114,9 +114,17 @@ type userTSDB struct {
 	seriesInMetric *metricCounter
 	limiter        *Limiter
 
+	// Options the TSDB has been opened with.
+	tsdbConfig userTSDBConfig
+
 	instanceSeriesCount *atomic.Int64 // Shared across all userTSDB instances created by ingester.
 	instanceLimitsFn    func() *InstanceLimits
 
+	// The tenant's fair share of the ingester's max series and max ingestion rate limits,
+	// periodically updated. Zero if not computed yet.
+	seriesFairShare        atomic.Float64
+	ingestionRateFairShare atomic.Float64
+
 	stateMtx       sync.RWMutex
 	state          tsdbState
 	pushesInFlight sync.WaitGroup // Increased with stateMtx read lock held, only if state == active or activeShipping.
@@ -233,9 +241,18 @@ func (u *userTSDB) PreCreation(metric labels.Labels) error {
 	// Verify ingester's global limit
 	gl := u.instanceLimitsFn()
 	if gl != nil && gl.MaxInMemorySeries > 0 {
-		if series := u.instanceSeriesCount.Load(); series >= gl.MaxInMemorySeries {
+		series := u.instanceSeriesCount.Load()
+		if series >= gl.MaxInMemorySeries {
 			return errMaxSeriesLimitReached
 		}
+
+		// When the ingester is close to its limit, only the tenants over their
+		// fair share of the limit are prevented from adding more series.
+		if gl.fairShareEnabled(float64(series), float64(gl.MaxInMemorySeries)) {
+			if share := u.seriesFairShare.Load(); share > 0 && float64(u.Head().NumSeries()) >= share {
+				return errMaxSeriesFairShareReached
+			}
+		}
 	}
 
 	// Total series limit.
@@ -483,15 +500,16 @@ func NewV2(cfg Config, clientConfig client.Config, limits *validation.Overrides,
 	}
 
 	i := &Ingester{
-		cfg:           cfg,
-		clientConfig:  clientConfig,
-		limits:        limits,
-		chunkStore:    nil,
-		usersMetadata: map[string]*userMetricsMetadata{},
-		wal:           &noopWAL{},
-		TSDBState:     newTSDBState(bucketClient, registerer),
-		logger:        logger,
-		ingestionRate: util_math.NewEWMARate(0.2, instanceIngestionRateTickInterval),
+		cfg:            cfg,
+		clientConfig:   clientConfig,
+		limits:         limits,
+		chunkStore:     nil,
+		usersMetadata:  map[string]*userMetricsMetadata{},
+		wal:            &noopWAL{},
+		TSDBState:      newTSDBState(bucketClient, registerer),
+		logger:         logger,
+		ingestionRate:  util_math.NewEWMARate(0.2, instanceIngestionRateTickInterval),
+		memoryPressure: newMemoryPressure(registerer),
 	}
 	i.metrics = newIngesterMetrics(registerer, false, cfg.ActiveSeriesMetricsEnabled, i.getInstanceLimits, i.ingestionRate, &i.inflightPushRequests)
 
@@ -658,6 +676,9 @@ func (i *Ingester) updateLoop(ctx context.Context) error {
 	ingestionRateTicker := time.NewTicker(instanceIngestionRateTickInterval)
 	defer ingestionRateTicker.Stop()
 
+	memoryPressureTicker := time.NewTicker(memoryPressureUpdateInterval)
+	defer memoryPressureTicker.Stop()
+
 	var activeSeriesTickerChan <-chan time.Time
 	if i.cfg.ActiveSeriesMetricsEnabled {
 		t := time.NewTicker(i.cfg.ActiveSeriesMetricsUpdatePeriod)
@@ -675,6 +696,10 @@ func (i *Ingester) updateLoop(ctx context.Context) error {
 			i.purgeUserMetricsMetadata()
 		case <-ingestionRateTicker.C:
 			i.ingestionRate.Tick()
+		case <-memoryPressureTicker.C:
+			if limits := i.getInstanceLimits(); limits != nil && limits.memoryPressureEnabled() {
+				i.memoryPressure.updateMemStats(time.Now())
+			}
 		case <-rateUpdateTicker.C:
 			i.userStatesMtx.RLock()
 			for _, db := range i.TSDBState.dbs {
@@ -683,6 +708,9 @@ func (i *Ingester) updateLoop(ctx context.Context) error {
 			}
 			i.userStatesMtx.RUnlock()
 
+			i.updateInstanceLimitsFairShares()
+			i.updateWALBacklog()
+
 		case <-activeSeriesTickerChan:
 			i.v2UpdateActiveSeries()
 
@@ -694,6 +722,93 @@ func (i *Ingester) updateLoop(ctx context.Context) error {
 	}
 }
 
+// updateInstanceLimitsFairShares splits the ingester's max series and max ingestion rate limits
+// among the tenants, proportionally to the local limits derived from their global limits, shard
+// size and the replication factor.
+func (i *Ingester) updateInstanceLimitsFairShares() {
+	il := i.getInstanceLimits()
+	enabled := il != nil && il.FairShareThreshold > 0 && i.limiter != nil
+
+	i.userStatesMtx.RLock()
+	defer i.userStatesMtx.RUnlock()
+
+	var (
+		seriesWeights = map[string]float64{}
+		rateWeights   = map[string]float64{}
+	)
+	for userID := range i.TSDBState.dbs {
+		if enabled && il.MaxInMemorySeries > 0 {
+			seriesWeights[userID] = i.limiter.seriesFairShareWeight(userID, il.MaxInMemorySeries)
+		}
+		if enabled && il.MaxIngestionRate > 0 {
+			rateWeights[userID] = i.limiter.ingestionRateFairShareWeight(userID, il.MaxIngestionRate)
+		}
+	}
+
+	var seriesShares, rateShares map[string]float64
+	if len(seriesWeights) > 0 {
+		seriesShares = fairShares(float64(il.MaxInMemorySeries), seriesWeights)
+	}
+	if len(rateWeights) > 0 {
+		rateShares = fairShares(il.MaxIngestionRate, rateWeights)
+	}
+
+	for userID, db := range i.TSDBState.dbs {
+		db.seriesFairShare.Store(seriesShares[userID])
+		db.ingestionRateFairShare.Store(rateShares[userID])
+
+		if share, ok := seriesShares[userID]; ok {
+			i.metrics.instanceLimitsFairShare.WithLabelValues(userID, "max_series").Set(share)
+		} else {
+			i.metrics.instanceLimitsFairShare.DeleteLabelValues(userID, "max_series")
+		}
+		if share, ok := rateShares[userID]; ok {
+			i.metrics.instanceLimitsFairShare.WithLabelValues(userID, "max_ingestion_rate").Set(share)
+		} else {
+			i.metrics.instanceLimitsFairShare.DeleteLabelValues(userID, "max_ingestion_rate")
+		}
+	}
+}
+
+// updateWALBacklog updates the size of the WAL segments across all tenants, if the
+// WAL backlog limit is set.
+func (i *Ingester) updateWALBacklog() {
+	if limits := i.getInstanceLimits(); limits == nil || limits.MaxWALBacklogBytes <= 0 {
+		return
+	}
+
+	backlog := int64(0)
+	for _, userID := range i.getTSDBUsers() {
+		db := i.getTSDB(userID)
+		if db == nil {
+			continue
+		}
+
+		size, err := walSize(db.db.Dir())
+		if err != nil {
+			level.Debug(i.logger).Log("msg", "failed to compute the TSDB WAL size", "user", userID, "err", err)
+			continue
+		}
+		backlog += size
+	}
+
+	i.memoryPressure.walBacklogBytes.Store(backlog)
+}
+
+// checkIngestionRateFairShare returns an error if the ingester is close to its max ingestion
+// rate limit and the tenant is over its fair share of the limit.
+func (i *Ingester) checkIngestionRateFairShare(db *userTSDB, il *InstanceLimits) error {
+	if il == nil || !il.fairShareEnabled(i.ingestionRate.Rate(), il.MaxIngestionRate) {
+		return nil
+	}
+
+	share := db.ingestionRateFairShare.Load()
+	if share > 0 && db.ingestedAPISamples.Rate()+db.ingestedRuleSamples.Rate() >= share {
+		return errMaxIngestionRateFairShareReached
+	}
+	return nil
+}
+
 func (i *Ingester) v2UpdateActiveSeries() {
 	purgeTime := time.Now().Add(-i.cfg.ActiveSeriesMetricsIdleTimeout)
 
@@ -734,11 +849,24 @@ func (i *Ingester) v2Push(ctx context.Context, req *cortexpb.WriteRequest) (*cor
 		}
 	}
 
+	if err := i.memoryPressure.check(il); err != nil {
+		return nil, err
+	}
+
 	db, err := i.getOrCreateTSDB(userID, false)
 	if err != nil {
 		return nil, wrapWithUser(err, userID)
 	}
 
+	if err := i.checkIngestionRateFairShare(db, il); err != nil {
+		numSamples := 0
+		for _, ts := range req.Timeseries {
+			numSamples += len(ts.Samples)
+		}
+		validation.DiscardedSamples.WithLabelValues(instanceIngestionRateFairShare, userID).Add(float64(numSamples))
+		return nil, httpgrpc.Errorf(http.StatusTooManyRequests, wrapWithUser(err, userID).Error())
+	}
+
 	// Ensure the ingester shutdown procedure hasn't started
 	i.userStatesMtx.RLock()
 	if i.stopped {
@@ -759,16 +887,17 @@ func (i *Ingester) v2Push(ctx context.Context, req *cortexpb.WriteRequest) (*cor
 	// Keep track of some stats which are tracked only if the samples will be
 	// successfully committed
 	var (
-		succeededSamplesCount     = 0
-		failedSamplesCount        = 0
-		succeededExemplarsCount   = 0
-		failedExemplarsCount      = 0
-		startAppend               = time.Now()
-		sampleOutOfBoundsCount    = 0
-		sampleOutOfOrderCount     = 0
-		newValueForTimestampCount = 0
-		perUserSeriesLimitCount   = 0
-		perMetricSeriesLimitCount = 0
+		succeededSamplesCount        = 0
+		failedSamplesCount           = 0
+		succeededExemplarsCount      = 0
+		failedExemplarsCount         = 0
+		startAppend                  = time.Now()
+		sampleOutOfBoundsCount       = 0
+		sampleOutOfOrderCount        = 0
+		newValueForTimestampCount    = 0
+		perUserSeriesLimitCount      = 0
+		perMetricSeriesLimitCount    = 0
+		instanceSeriesFairShareCount = 0
 
 		updateFirstPartial = func(errFn func() error) {
 			if firstPartialErr == nil {
@@ -837,6 +966,11 @@ func (i *Ingester) v2Push(ctx context.Context, req *cortexpb.WriteRequest) (*cor
 				updateFirstPartial(func() error { return makeLimitError(perUserSeriesLimit, i.limiter.FormatError(userID, cause)) })
 				continue
 
+			case errMaxSeriesFairShareReached:
+				instanceSeriesFairShareCount++
+				updateFirstPartial(func() error { return makeFairShareLimitError(instanceSeriesFairShare, cause) })
+				continue
+
 			case errMaxSeriesPerMetricLimitExceeded:
 				perMetricSeriesLimitCount++
 				updateFirstPartial(func() error {
@@ -860,7 +994,7 @@ func (i *Ingester) v2Push(ctx context.Context, req *cortexpb.WriteRequest) (*cor
 			})
 		}
 
-		if i.cfg.BlocksStorageConfig.TSDB.MaxExemplars > 0 {
+		if db.tsdbConfig.maxExemplars > 0 {
 			// app.AppendExemplar currently doesn't create the series, it must
 			// already exist.  If it does not then drop.
 			if ref == 0 && len(ts.Exemplars) > 0 {
@@ -930,6 +1064,9 @@ func (i *Ingester) v2Push(ctx context.Context, req *cortexpb.WriteRequest) (*cor
 	if perMetricSeriesLimitCount > 0 {
 		validation.DiscardedSamples.WithLabelValues(perMetricSeriesLimit, userID).Add(float64(perMetricSeriesLimitCount))
 	}
+	if instanceSeriesFairShareCount > 0 {
+		validation.DiscardedSamples.WithLabelValues(instanceSeriesFairShare, userID).Add(float64(instanceSeriesFairShareCount))
+	}
 
 	// Distributor counts both samples and metadata, so for consistency ingester does the same.
 	i.ingestionRate.Add(int64(succeededSamplesCount + ingestedMetadata))
@@ -1553,10 +1690,11 @@ func (i *Ingester) createTSDB(userID string) (*userTSDB, error) {
 	udir := i.cfg.BlocksStorageConfig.TSDB.BlocksDir(userID)
 	userLogger := logutil.WithUserID(userID, i.logger)
 
-	blockRanges := i.cfg.BlocksStorageConfig.TSDB.BlockRanges.ToMilliseconds()
+	tsdbConfig := i.getUserTSDBConfig(userID, userLogger)
 
 	userDB := &userTSDB{
 		userID:              userID,
+		tsdbConfig:          tsdbConfig,
 		activeSeries:        NewActiveSeries(),
 		seriesInMetric:      newMetricCounter(i.limiter, i.cfg.getIgnoreSeriesLimitForMetricNamesMap()),
 		ingestedAPISamples:  util_math.NewEWMARate(0.2, i.cfg.RateUpdatePeriod),
@@ -1569,16 +1707,16 @@ func (i *Ingester) createTSDB(userID string) (*userTSDB, error) {
 	// Create a new user database
 	db, err := tsdb.Open(udir, userLogger, tsdbPromReg, &tsdb.Options{
 		RetentionDuration:         i.cfg.BlocksStorageConfig.TSDB.Retention.Milliseconds(),
-		MinBlockDuration:          blockRanges[0],
-		MaxBlockDuration:          blockRanges[len(blockRanges)-1],
+		MinBlockDuration:          tsdbConfig.blockRanges[0],
+		MaxBlockDuration:          tsdbConfig.blockRanges[len(tsdbConfig.blockRanges)-1],
 		NoLockfile:                true,
-		StripeSize:                i.cfg.BlocksStorageConfig.TSDB.StripeSize,
-		HeadChunksWriteBufferSize: i.cfg.BlocksStorageConfig.TSDB.HeadChunksWriteBufferSize,
-		WALCompression:            i.cfg.BlocksStorageConfig.TSDB.WALCompressionEnabled,
+		StripeSize:                tsdbConfig.stripeSize,
+		HeadChunksWriteBufferSize: tsdbConfig.headChunksWriteBufferSize,
+		WALCompression:            tsdbConfig.walCompression,
 		WALSegmentSize:            i.cfg.BlocksStorageConfig.TSDB.WALSegmentSizeBytes,
 		SeriesLifecycleCallback:   userDB,
 		BlocksToDelete:            userDB.blocksToDelete,
-		MaxExemplars:              i.cfg.BlocksStorageConfig.TSDB.MaxExemplars,
+		MaxExemplars:              tsdbConfig.maxExemplars,
 	})
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to open TSDB: %s", udir)
@@ -1964,12 +2102,12 @@ func (i *Ingester) compactBlocks(ctx context.Context, force bool, allowed *util.
 		switch {
 		case force:
 			reason = "forced"
-			err = userDB.compactHead(i.cfg.BlocksStorageConfig.TSDB.BlockRanges[0].Milliseconds())
+			err = userDB.compactHead(userDB.tsdbConfig.headBlockRange())
 
 		case i.TSDBState.compactionIdleTimeout > 0 && userDB.isIdle(time.Now(), i.TSDBState.compactionIdleTimeout):
 			reason = "idle"
 			level.Info(i.logger).Log("msg", "TSDB is idle, forcing compaction", "user", userID)
-			err = userDB.compactHead(i.cfg.BlocksStorageConfig.TSDB.BlockRanges[0].Milliseconds())
+			err = userDB.compactHead(userDB.tsdbConfig.headBlockRange())
 
 		default:
 			reason = "regular"
@@ -2126,50 +2264,7 @@ func (i *Ingester) v2FlushHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		compactionCallbackCh := make(chan struct{})
-
-		level.Info(i.logger).Log("msg", "flushing TSDB blocks: triggering compaction")
-		select {
-		case i.TSDBState.forceCompactTrigger <- requestWithUsersAndCallback{users: allowedUsers, callback: compactionCallbackCh}:
-			// Compacting now.
-		case <-ingCtx.Done():
-			level.Warn(i.logger).Log("msg", "failed to compact TSDB blocks, ingester not running anymore")
-			return
-		}
-
-		// Wait until notified about compaction being finished.
-		select {
-		case <-compactionCallbackCh:
-			level.Info(i.logger).Log("msg", "finished compacting TSDB blocks")
-		case <-ingCtx.Done():
-			level.Warn(i.logger).Log("msg", "failed to compact TSDB blocks, ingester not running anymore")
-			return
-		}
-
-		if i.cfg.BlocksStorageConfig.TSDB.IsBlocksShippingEnabled() {
-			shippingCallbackCh := make(chan struct{}) // must be new channel, as compactionCallbackCh is closed now.
-
-			level.Info(i.logger).Log("msg", "flushing TSDB blocks: triggering shipping")
-
-			select {
-			case i.TSDBState.shipTrigger <- requestWithUsersAndCallback{users: allowedUsers, callback: shippingCallbackCh}:
-				// shipping now
-			case <-ingCtx.Done():
-				level.Warn(i.logger).Log("msg", "failed to ship TSDB blocks, ingester not running anymore")
-				return
-			}
-
-			// Wait until shipping finished.
-			select {
-			case <-shippingCallbackCh:
-				level.Info(i.logger).Log("msg", "shipping of TSDB blocks finished")
-			case <-ingCtx.Done():
-				level.Warn(i.logger).Log("msg", "failed to ship TSDB blocks, ingester not running anymore")
-				return
-			}
-		}
-
-		level.Info(i.logger).Log("msg", "flushing TSDB blocks: finished")
+		i.flushBlocks(ingCtx, allowedUsers)
 	}
 
 	if len(r.Form[waitParam]) > 0 && r.Form[waitParam][0] == "true" {
@@ -2182,6 +2277,56 @@ func (i *Ingester) v2FlushHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// flushBlocks compacts the head of the allowed tenants and ships the blocks to the storage.
+// Returns false if the ingester stopped before the blocks were flushed.
+func (i *Ingester) flushBlocks(ctx context.Context, allowed *util.AllowedTenants) bool {
+	compactionCallbackCh := make(chan struct{})
+
+	level.Info(i.logger).Log("msg", "flushing TSDB blocks: triggering compaction")
+	select {
+	case i.TSDBState.forceCompactTrigger <- requestWithUsersAndCallback{users: allowed, callback: compactionCallbackCh}:
+		// Compacting now.
+	case <-ctx.Done():
+		level.Warn(i.logger).Log("msg", "failed to compact TSDB blocks, ingester not running anymore")
+		return false
+	}
+
+	// Wait until notified about compaction being finished.
+	select {
+	case <-compactionCallbackCh:
+		level.Info(i.logger).Log("msg", "finished compacting TSDB blocks")
+	case <-ctx.Done():
+		level.Warn(i.logger).Log("msg", "failed to compact TSDB blocks, ingester not running anymore")
+		return false
+	}
+
+	if i.cfg.BlocksStorageConfig.TSDB.IsBlocksShippingEnabled() {
+		shippingCallbackCh := make(chan struct{}) // must be new channel, as compactionCallbackCh is closed now.
+
+		level.Info(i.logger).Log("msg", "flushing TSDB blocks: triggering shipping")
+
+		select {
+		case i.TSDBState.shipTrigger <- requestWithUsersAndCallback{users: allowed, callback: shippingCallbackCh}:
+			// shipping now
+		case <-ctx.Done():
+			level.Warn(i.logger).Log("msg", "failed to ship TSDB blocks, ingester not running anymore")
+			return false
+		}
+
+		// Wait until shipping finished.
+		select {
+		case <-shippingCallbackCh:
+			level.Info(i.logger).Log("msg", "shipping of TSDB blocks finished")
+		case <-ctx.Done():
+			level.Warn(i.logger).Log("msg", "failed to ship TSDB blocks, ingester not running anymore")
+			return false
+		}
+	}
+
+	level.Info(i.logger).Log("msg", "flushing TSDB blocks: finished")
+	return true
+}
+
 // metadataQueryRange returns the best range to query for metadata queries based on the timerange in the ingester.
 func metadataQueryRange(queryStart, queryEnd int64, db *userTSDB) (mint, maxt int64, err error) {
 	// Ingesters are run with limited retention and we don't support querying the store-gateway for labels yet.
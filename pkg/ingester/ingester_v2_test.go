@@ -40,6 +40,8 @@ import (
 	"github.com/weaveworks/common/user"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/cortexproject/cortex/pkg/chunk/encoding"
 	"github.com/cortexproject/cortex/pkg/cortexpb"
@@ -3753,6 +3755,183 @@ func TestIngester_v2PushInstanceLimits(t *testing.T) {
 	}
 }
 
+func TestIngester_v2PushInstanceLimitsFairShare(t *testing.T) {
+	validation.DiscardedSamples.Reset()
+	defaultInstanceLimits = nil
+
+	limits := InstanceLimits{MaxInMemorySeries: 10, FairShareThreshold: 0.5}
+
+	cfg := defaultIngesterTestConfig()
+	cfg.LifecyclerConfig.JoinAfter = 0
+	cfg.InstanceLimitsFn = func() *InstanceLimits {
+		return &limits
+	}
+
+	registry := prometheus.NewRegistry()
+	i, err := prepareIngesterWithBlocksStorage(t, cfg, registry)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+	defer services.StopAndAwaitTerminated(context.Background(), i) //nolint:errcheck
+
+	// Wait until the ingester is ACTIVE
+	test.Poll(t, 100*time.Millisecond, ring.ACTIVE, func() interface{} {
+		return i.lifecycler.GetState()
+	})
+
+	push := func(userID, metricName string) error {
+		req := cortexpb.ToWriteRequest(
+			[]labels.Labels{labels.FromStrings(labels.MetricName, metricName)},
+			[]cortexpb.Sample{{Value: 1, TimestampMs: 9}},
+			nil,
+			cortexpb.API)
+		_, err := i.Push(user.InjectOrgID(context.Background(), userID), req)
+		return err
+	}
+
+	// Both tenants have the same limits, so they get an even share of the max series.
+	require.NoError(t, push("user-1", "series_0"))
+	require.NoError(t, push("user-2", "series_0"))
+	i.updateInstanceLimitsFairShares()
+
+	// The ingester is over the threshold once user-1 reaches 5 series, which is its fair share.
+	for n := 1; n < 5; n++ {
+		require.NoError(t, push("user-1", fmt.Sprintf("series_%d", n)))
+	}
+
+	err = push("user-1", "series_5")
+	require.Error(t, err)
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusTooManyRequests, int(resp.Code))
+	assert.Equal(t, wrapWithUser(errMaxSeriesFairShareReached, "user-1").Error(), string(resp.Body))
+
+	// Samples for the existing series are still accepted.
+	require.NoError(t, push("user-1", "series_0"))
+
+	// The tenant under its fair share can still add series, until the instance limit is reached.
+	for n := 1; n < 5; n++ {
+		require.NoError(t, push("user-2", fmt.Sprintf("series_%d", n)))
+	}
+	assert.Equal(t, wrapWithUser(errMaxSeriesLimitReached, "user-2"), push("user-2", "series_5"))
+
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+		# HELP cortex_ingester_instance_limits_fair_share Tenant's fair share of the instance limits, enforced once the ingester is close to the limit.
+		# TYPE cortex_ingester_instance_limits_fair_share gauge
+		cortex_ingester_instance_limits_fair_share{limit="max_series",user="user-1"} 5
+		cortex_ingester_instance_limits_fair_share{limit="max_series",user="user-2"} 5
+	`), "cortex_ingester_instance_limits_fair_share"))
+
+	assert.NoError(t, testutil.CollectAndCompare(validation.DiscardedSamples, strings.NewReader(`
+		# HELP cortex_discarded_samples_total The total number of samples that were discarded.
+		# TYPE cortex_discarded_samples_total counter
+		cortex_discarded_samples_total{reason="instance_series_fair_share",user="user-1"} 1
+	`)))
+}
+
+func TestIngester_v2PushMemoryPressure(t *testing.T) {
+	defaultInstanceLimits = nil
+
+	limits := InstanceLimits{MaxWALBacklogBytes: 1000}
+
+	cfg := defaultIngesterTestConfig()
+	cfg.LifecyclerConfig.JoinAfter = 0
+	cfg.InstanceLimitsFn = func() *InstanceLimits {
+		return &limits
+	}
+
+	i, err := prepareIngesterWithBlocksStorage(t, cfg, nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+	defer services.StopAndAwaitTerminated(context.Background(), i) //nolint:errcheck
+
+	// Wait until the ingester is ACTIVE
+	test.Poll(t, 100*time.Millisecond, ring.ACTIVE, func() interface{} {
+		return i.lifecycler.GetState()
+	})
+
+	i.memoryPressure.walBacklogBytes.Store(1000)
+
+	ctx := user.InjectOrgID(context.Background(), "test")
+	push := func() error {
+		req, _, _, _ := mockWriteRequest(t, labels.Labels{{Name: labels.MetricName, Value: "test"}}, 1, 9)
+		_, err := i.Push(ctx, req)
+		return err
+	}
+
+	assert.Equal(t, codes.ResourceExhausted, status.Code(push()))
+
+	// Once the ingester is no longer under pressure, the push requests are accepted.
+	i.memoryPressure.walBacklogBytes.Store(999)
+	assert.NoError(t, push())
+}
+
+func TestIngester_v2PushInstanceLimitsIngestionRateFairShare(t *testing.T) {
+	validation.DiscardedSamples.Reset()
+	defaultInstanceLimits = nil
+
+	limits := InstanceLimits{MaxIngestionRate: 100, FairShareThreshold: 0.5}
+
+	cfg := defaultIngesterTestConfig()
+	cfg.LifecyclerConfig.JoinAfter = 0
+	// Use the same period of the instance ingestion rate, to easily compare the rates.
+	cfg.RateUpdatePeriod = instanceIngestionRateTickInterval
+	cfg.InstanceLimitsFn = func() *InstanceLimits {
+		return &limits
+	}
+
+	i, err := prepareIngesterWithBlocksStorage(t, cfg, nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+	defer services.StopAndAwaitTerminated(context.Background(), i) //nolint:errcheck
+
+	// Wait until the ingester is ACTIVE
+	test.Poll(t, 100*time.Millisecond, ring.ACTIVE, func() interface{} {
+		return i.lifecycler.GetState()
+	})
+
+	push := func(userID string, numSamples int) error {
+		samples := make([]cortexpb.Sample, 0, numSamples)
+		for n := 0; n < numSamples; n++ {
+			samples = append(samples, cortexpb.Sample{Value: 1, TimestampMs: int64(n)})
+		}
+		req := &cortexpb.WriteRequest{
+			Timeseries: []cortexpb.PreallocTimeseries{{TimeSeries: &cortexpb.TimeSeries{
+				Labels:  []cortexpb.LabelAdapter{{Name: labels.MetricName, Value: "test"}},
+				Samples: samples,
+			}}},
+		}
+		_, err := i.Push(user.InjectOrgID(context.Background(), userID), req)
+		return err
+	}
+
+	require.NoError(t, push("user-1", 1))
+	require.NoError(t, push("user-2", 1))
+	i.updateInstanceLimitsFairShares()
+
+	// Make user-1 push over its fair share, bringing the ingester over the threshold
+	// but still under the limit.
+	require.NoError(t, push("user-1", 80))
+	i.ingestionRate.Tick()
+	i.getTSDB("user-1").ingestedAPISamples.Tick()
+	i.getTSDB("user-2").ingestedAPISamples.Tick()
+
+	err = push("user-1", 10)
+	require.Error(t, err)
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusTooManyRequests, int(resp.Code))
+	assert.Equal(t, wrapWithUser(errMaxIngestionRateFairShareReached, "user-1").Error(), string(resp.Body))
+
+	// The tenant under its fair share is not affected.
+	require.NoError(t, push("user-2", 1))
+
+	assert.NoError(t, testutil.CollectAndCompare(validation.DiscardedSamples, strings.NewReader(`
+		# HELP cortex_discarded_samples_total The total number of samples that were discarded.
+		# TYPE cortex_discarded_samples_total counter
+		cortex_discarded_samples_total{reason="instance_ingestion_rate_fair_share",user="user-1"} 10
+	`)))
+}
+
 func TestIngester_instanceLimitsMetrics(t *testing.T) {
 	reg := prometheus.NewRegistry()
 
@@ -8,6 +8,9 @@ var (
 	errMaxUsersLimitReached           = errors.New("cannot create TSDB: ingesters's max tenants limit reached")
 	errMaxSeriesLimitReached          = errors.New("cannot add series: ingesters's max series limit reached")
 	errTooManyInflightPushRequests    = errors.New("cannot push: too many inflight push requests in ingester")
+
+	errMaxSeriesFairShareReached        = errors.New("cannot add series: tenant's fair share of ingester's max series limit reached")
+	errMaxIngestionRateFairShareReached = errors.New("cannot push more samples: tenant's fair share of ingester's samples push rate limit reached")
 )
 
 // InstanceLimits describes limits used by ingester. Reaching any of these will result in Push method to return
@@ -17,6 +20,12 @@ type InstanceLimits struct {
 	MaxInMemoryTenants      int64   `yaml:"max_tenants"`
 	MaxInMemorySeries       int64   `yaml:"max_series"`
 	MaxInflightPushRequests int64   `yaml:"max_inflight_push_requests"`
+	FairShareThreshold      float64 `yaml:"fair_share_threshold"`
+
+	// Memory pressure limits. Reaching any of these will result in Push method to return a retryable error.
+	MaxHeapInuseBytes  int64   `yaml:"max_heap_inuse_bytes"`
+	MaxWALBacklogBytes int64   `yaml:"max_wal_backlog_bytes"`
+	MaxGCCPUFraction   float64 `yaml:"max_gc_cpu_fraction"`
 }
 
 // Sets default limit values for unmarshalling.
@@ -30,3 +39,27 @@ func (l *InstanceLimits) UnmarshalYAML(unmarshal func(interface{}) error) error
 	type plain InstanceLimits // type indirection to make sure we don't go into recursive loop
 	return unmarshal((*plain)(l))
 }
+
+// fairShareEnabled returns whether the tenants over their fair share of the instance limit
+// should be rejected, given the current usage of the ingester.
+func (l *InstanceLimits) fairShareEnabled(usage, limit float64) bool {
+	return l.FairShareThreshold > 0 && limit > 0 && usage >= l.FairShareThreshold*limit
+}
+
+// fairShares splits the limit among the tenants proportionally to their weights.
+func fairShares(limit float64, weights map[string]float64) map[string]float64 {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+
+	shares := make(map[string]float64, len(weights))
+	for userID, w := range weights {
+		if total > 0 {
+			shares[userID] = limit * w / total
+		} else {
+			shares[userID] = limit / float64(len(weights))
+		}
+	}
+	return shares
+}
@@ -3,6 +3,7 @@ package ingester
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
 )
@@ -27,3 +28,48 @@ max_tenants: 50000
 	require.Equal(t, int64(30), l.MaxInMemorySeries)       // default value
 	require.Equal(t, int64(40), l.MaxInflightPushRequests) // default value
 }
+
+func TestFairShares(t *testing.T) {
+	tests := map[string]struct {
+		limit    float64
+		weights  map[string]float64
+		expected map[string]float64
+	}{
+		"no tenants": {
+			limit:    100,
+			weights:  map[string]float64{},
+			expected: map[string]float64{},
+		},
+		"same weights": {
+			limit:    100,
+			weights:  map[string]float64{"user-1": 10, "user-2": 10},
+			expected: map[string]float64{"user-1": 50, "user-2": 50},
+		},
+		"different weights": {
+			limit:    100,
+			weights:  map[string]float64{"user-1": 30, "user-2": 10},
+			expected: map[string]float64{"user-1": 75, "user-2": 25},
+		},
+		"zero weights": {
+			limit:    100,
+			weights:  map[string]float64{"user-1": 0, "user-2": 0},
+			expected: map[string]float64{"user-1": 50, "user-2": 50},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, fairShares(testData.limit, testData.weights))
+		})
+	}
+}
+
+func TestInstanceLimits_fairShareEnabled(t *testing.T) {
+	l := InstanceLimits{FairShareThreshold: 0.8}
+	assert.False(t, l.fairShareEnabled(79, 100))
+	assert.True(t, l.fairShareEnabled(80, 100))
+	assert.False(t, l.fairShareEnabled(80, 0))
+
+	l = InstanceLimits{}
+	assert.False(t, l.fairShareEnabled(100, 100))
+}
@@ -245,7 +245,7 @@ func (l *Limiter) convertGlobalToLocalLimit(userID string, globalLimit int) int
 	// topology changes) and we prefer to always be in favor of the tenant,
 	// we can use a per-ingester limit equal to:
 	// (global limit / number of ingesters) * replication factor
-	numIngesters := l.ring.HealthyInstancesCount()
+	numIngesters := l.getNumIngesters(userID)
 
 	// May happen because the number of ingesters is asynchronously updated.
 	// If happens, we just temporarily ignore the global limit.
@@ -253,15 +253,49 @@ func (l *Limiter) convertGlobalToLocalLimit(userID string, globalLimit int) int
 		return 0
 	}
 
+	return int((float64(globalLimit) / float64(numIngesters)) * float64(l.replicationFactor))
+}
+
+// getNumIngesters returns the number of ingesters the tenant's series are written to,
+// or 0 if the number of healthy ingesters is unknown yet.
+func (l *Limiter) getNumIngesters(userID string) int {
+	numIngesters := l.ring.HealthyInstancesCount()
+
 	// If the number of available ingesters is greater than the tenant's shard
 	// size, then we should honor the shard size because series/metadata won't
 	// be written to more ingesters than it.
-	if shardSize := l.getShardSize(userID); shardSize > 0 {
+	if shardSize := l.getShardSize(userID); numIngesters > 0 && shardSize > 0 {
 		// We use Min() to protect from the case the expected shard size is > available ingesters.
 		numIngesters = util_math.Min(numIngesters, util.ShuffleShardExpectedInstances(shardSize, l.getNumZones()))
 	}
 
-	return int((float64(globalLimit) / float64(numIngesters)) * float64(l.replicationFactor))
+	return numIngesters
+}
+
+// seriesFairShareWeight returns the weight of the tenant when splitting the ingester's max
+// series limit among the tenants, which is the tenant's local series limit. Tenants without
+// series limit weigh as much as the instance limit.
+func (l *Limiter) seriesFairShareWeight(userID string, instanceLimit int64) float64 {
+	return math.Min(float64(l.maxSeriesPerUser(userID)), float64(instanceLimit))
+}
+
+// ingestionRateFairShareWeight returns the weight of the tenant when splitting the ingester's
+// max ingestion rate limit among the tenants, which is the share of the tenant's ingestion
+// rate limit expected to be pushed to this ingester. Tenants without ingestion rate limit
+// weigh as much as the instance limit.
+func (l *Limiter) ingestionRateFairShareWeight(userID string, instanceLimit float64) float64 {
+	rate := l.limits.IngestionRate(userID)
+	if rate <= 0 {
+		return instanceLimit
+	}
+
+	numIngesters := l.getNumIngesters(userID)
+	if numIngesters == 0 {
+		return instanceLimit
+	}
+
+	local := rate * float64(l.replicationFactor) / float64(numIngesters)
+	return math.Min(local, instanceLimit)
 }
 
 func (l *Limiter) getShardSize(userID string) int {
@@ -520,6 +520,69 @@ func TestLimiter_FormatError(t *testing.T) {
 	assert.Equal(t, input, actual)
 }
 
+func TestLimiter_FairShareWeights(t *testing.T) {
+	tests := map[string]struct {
+		shardingStrategy            string
+		shardSize                   int
+		maxGlobalSeriesPerUser      int
+		ingestionRate               float64
+		expectedSeriesWeight        float64
+		expectedIngestionRateWeight float64
+	}{
+		"unlimited tenant weighs as the instance limits": {
+			shardingStrategy:            util.ShardingStrategyDefault,
+			expectedSeriesWeight:        1000,
+			expectedIngestionRateWeight: 500,
+		},
+		"limits are converted to local limits across all ingesters": {
+			shardingStrategy:            util.ShardingStrategyDefault,
+			maxGlobalSeriesPerUser:      1000,
+			ingestionRate:               200,
+			expectedSeriesWeight:        300,
+			expectedIngestionRateWeight: 60,
+		},
+		"limits are converted to local limits across the tenant's shard": {
+			shardingStrategy:            util.ShardingStrategyShuffle,
+			shardSize:                   5,
+			maxGlobalSeriesPerUser:      1000,
+			ingestionRate:               200,
+			expectedSeriesWeight:        600,
+			expectedIngestionRateWeight: 120,
+		},
+		"local limits are capped to the instance limits": {
+			shardingStrategy:            util.ShardingStrategyShuffle,
+			shardSize:                   3,
+			maxGlobalSeriesPerUser:      10000,
+			ingestionRate:               1000,
+			expectedSeriesWeight:        1000,
+			expectedIngestionRateWeight: 500,
+		},
+	}
+
+	for testName, testData := range tests {
+		testData := testData
+
+		t.Run(testName, func(t *testing.T) {
+			// Mock the ring
+			ring := &ringCountMock{}
+			ring.On("HealthyInstancesCount").Return(10)
+			ring.On("ZonesCount").Return(1)
+
+			// Mock limits
+			limits, err := validation.NewOverrides(validation.Limits{
+				MaxGlobalSeriesPerUser:   testData.maxGlobalSeriesPerUser,
+				IngestionRate:            testData.ingestionRate,
+				IngestionTenantShardSize: testData.shardSize,
+			}, nil)
+			require.NoError(t, err)
+
+			limiter := NewLimiter(limits, ring, testData.shardingStrategy, true, 3, false)
+			assert.Equal(t, testData.expectedSeriesWeight, limiter.seriesFairShareWeight("test", 1000))
+			assert.InDelta(t, testData.expectedIngestionRateWeight, limiter.ingestionRateFairShareWeight("test", 500), 0.001)
+		})
+	}
+}
+
 func TestLimiter_minNonZero(t *testing.T) {
 	t.Parallel()
 
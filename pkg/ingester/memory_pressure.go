@@ -0,0 +1,154 @@
+package ingester
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/atomic"
+
+	"github.com/cortexproject/cortex/pkg/ingester/client"
+)
+
+const (
+	// How frequently the heap and GC pressure is updated.
+	memoryPressureUpdateInterval = time.Second
+
+	heapInuseReason     = "heap_inuse"
+	walBacklogReason    = "wal_backlog"
+	gcCPUFractionReason = "gc_cpu_fraction"
+)
+
+var (
+	// We don't include values in the message to avoid leaking Cortex cluster configuration to users.
+	errHeapInusePressure     = client.NewMemoryPressureError("heap in use")
+	errWALBacklogPressure    = client.NewMemoryPressureError("WAL backlog")
+	errGCCPUFractionPressure = client.NewMemoryPressureError("GC CPU usage")
+
+	// Approximation of the process start time, used to compute the recent GC CPU fraction.
+	processStartTime = time.Now()
+)
+
+// memoryPressure tracks the signals used to detect whether the ingester is under memory pressure.
+type memoryPressure struct {
+	heapInuseBytes  atomic.Int64
+	walBacklogBytes atomic.Int64
+	gcCPUFraction   atomic.Float64
+
+	// Cumulative GC CPU time at the last update, only accessed by the update loop.
+	lastGCCPUTime float64
+	lastUpdate    time.Time
+
+	rejectedRequests *prometheus.CounterVec
+}
+
+func newMemoryPressure(reg prometheus.Registerer) *memoryPressure {
+	p := &memoryPressure{
+		rejectedRequests: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ingester_memory_pressure_rejected_requests_total",
+			Help: "The total number of push requests rejected because the ingester was under memory pressure.",
+		}, []string{"reason"}),
+	}
+
+	promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "cortex_ingester_wal_backlog_bytes",
+		Help: "The size of the TSDB WAL segments not truncated yet, across all tenants.",
+	}, func() float64 {
+		return float64(p.walBacklogBytes.Load())
+	})
+	promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "cortex_ingester_gc_cpu_fraction",
+		Help: "The fraction of the available CPU time used by the garbage collector during the last second.",
+	}, func() float64 {
+		return p.gcCPUFraction.Load()
+	})
+
+	// Initialise the metrics for each reason.
+	for _, reason := range []string{heapInuseReason, walBacklogReason, gcCPUFractionReason} {
+		p.rejectedRequests.WithLabelValues(reason)
+	}
+
+	return p
+}
+
+// updateMemStats updates the heap in use and the GC CPU fraction since the previous update.
+func (p *memoryPressure) updateMemStats(now time.Time) {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	p.heapInuseBytes.Store(int64(stats.HeapInuse))
+
+	// The runtime only reports the GC CPU fraction since the process started, so we
+	// compute the fraction over the last interval from the cumulative GC CPU time.
+	gcCPUTime := stats.GCCPUFraction * now.Sub(processStartTime).Seconds()
+	if !p.lastUpdate.IsZero() {
+		if elapsed := now.Sub(p.lastUpdate).Seconds(); elapsed > 0 {
+			p.gcCPUFraction.Store(clampFraction((gcCPUTime - p.lastGCCPUTime) / elapsed))
+		}
+	}
+
+	p.lastGCCPUTime = gcCPUTime
+	p.lastUpdate = now
+}
+
+// check returns a retryable gRPC error if any of the memory pressure limits is exceeded.
+func (p *memoryPressure) check(l *InstanceLimits) error {
+	if l == nil {
+		return nil
+	}
+
+	var (
+		err    error
+		reason string
+	)
+
+	switch {
+	case l.MaxHeapInuseBytes > 0 && p.heapInuseBytes.Load() >= l.MaxHeapInuseBytes:
+		err, reason = errHeapInusePressure, heapInuseReason
+	case l.MaxWALBacklogBytes > 0 && p.walBacklogBytes.Load() >= l.MaxWALBacklogBytes:
+		err, reason = errWALBacklogPressure, walBacklogReason
+	case l.MaxGCCPUFraction > 0 && p.gcCPUFraction.Load() >= l.MaxGCCPUFraction:
+		err, reason = errGCCPUFractionPressure, gcCPUFractionReason
+	default:
+		return nil
+	}
+
+	p.rejectedRequests.WithLabelValues(reason).Inc()
+
+	// The distributor backs off from the ingester on this error, and the request can be retried.
+	return err
+}
+
+// memoryPressureEnabled returns whether any of the memory pressure limits is set.
+func (l *InstanceLimits) memoryPressureEnabled() bool {
+	return l.MaxHeapInuseBytes > 0 || l.MaxWALBacklogBytes > 0 || l.MaxGCCPUFraction > 0
+}
+
+// walSize returns the size of the WAL segments in the TSDB WAL directory, excluding checkpoints.
+func walSize(dir string) (int64, error) {
+	files, err := ioutil.ReadDir(filepath.Join(dir, "wal"))
+	if err != nil {
+		return 0, err
+	}
+
+	size := int64(0)
+	for _, f := range files {
+		if f.Mode().IsRegular() {
+			size += f.Size()
+		}
+	}
+	return size, nil
+}
+
+func clampFraction(f float64) float64 {
+	if f < 0 {
+		return 0
+	}
+	if f > 1 {
+		return 1
+	}
+	return f
+}
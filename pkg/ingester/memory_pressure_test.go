@@ -0,0 +1,108 @@
+package ingester
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/cortexproject/cortex/pkg/ingester/client"
+)
+
+func TestMemoryPressure_check(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	p := newMemoryPressure(reg)
+	p.heapInuseBytes.Store(1000)
+	p.walBacklogBytes.Store(2000)
+	p.gcCPUFraction.Store(0.3)
+
+	tests := map[string]struct {
+		limits      *InstanceLimits
+		expectedErr error
+	}{
+		"no limits": {
+			limits: nil,
+		},
+		"limits not reached": {
+			limits: &InstanceLimits{MaxHeapInuseBytes: 1001, MaxWALBacklogBytes: 2001, MaxGCCPUFraction: 0.4},
+		},
+		"heap in use limit reached": {
+			limits:      &InstanceLimits{MaxHeapInuseBytes: 1000},
+			expectedErr: errHeapInusePressure,
+		},
+		"WAL backlog limit reached": {
+			limits:      &InstanceLimits{MaxWALBacklogBytes: 2000},
+			expectedErr: errWALBacklogPressure,
+		},
+		"GC CPU fraction limit reached": {
+			limits:      &InstanceLimits{MaxGCCPUFraction: 0.3},
+			expectedErr: errGCCPUFractionPressure,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			err := p.check(testData.limits)
+			if testData.expectedErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+			assert.Equal(t, testData.expectedErr, err)
+			assert.True(t, client.IsMemoryPressureError(err))
+		})
+	}
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_ingester_memory_pressure_rejected_requests_total The total number of push requests rejected because the ingester was under memory pressure.
+		# TYPE cortex_ingester_memory_pressure_rejected_requests_total counter
+		cortex_ingester_memory_pressure_rejected_requests_total{reason="gc_cpu_fraction"} 1
+		cortex_ingester_memory_pressure_rejected_requests_total{reason="heap_inuse"} 1
+		cortex_ingester_memory_pressure_rejected_requests_total{reason="wal_backlog"} 1
+
+		# HELP cortex_ingester_wal_backlog_bytes The size of the TSDB WAL segments not truncated yet, across all tenants.
+		# TYPE cortex_ingester_wal_backlog_bytes gauge
+		cortex_ingester_wal_backlog_bytes 2000
+	`), "cortex_ingester_memory_pressure_rejected_requests_total", "cortex_ingester_wal_backlog_bytes"))
+}
+
+func TestMemoryPressure_updateMemStats(t *testing.T) {
+	p := newMemoryPressure(nil)
+
+	now := time.Now()
+	p.updateMemStats(now)
+	assert.Greater(t, p.heapInuseBytes.Load(), int64(0))
+	assert.Zero(t, p.gcCPUFraction.Load())
+
+	p.updateMemStats(now.Add(time.Second))
+	assert.GreaterOrEqual(t, p.gcCPUFraction.Load(), float64(0))
+	assert.LessOrEqual(t, p.gcCPUFraction.Load(), float64(1))
+}
+
+func TestWALSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal-size")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	_, err = walSize(dir)
+	require.Error(t, err)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "wal", "checkpoint.00000001"), os.ModePerm))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "wal", "00000002"), make([]byte, 100), os.ModePerm))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "wal", "00000003"), make([]byte, 50), os.ModePerm))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "wal", "checkpoint.00000001", "00000000"), make([]byte, 1000), os.ModePerm))
+
+	size, err := walSize(dir)
+	require.NoError(t, err)
+	assert.Equal(t, int64(150), size)
+}
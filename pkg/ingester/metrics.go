@@ -59,6 +59,9 @@ type ingesterMetrics struct {
 
 	activeSeriesPerUser *prometheus.GaugeVec
 
+	// Per-tenant fair share of the instance limits.
+	instanceLimitsFairShare *prometheus.GaugeVec
+
 	// Global limit metrics
 	maxUsersGauge           prometheus.GaugeFunc
 	maxSeriesGauge          prometheus.GaugeFunc
@@ -287,6 +290,11 @@ func newIngesterMetrics(r prometheus.Registerer, createMetricsConflictingWithTSD
 			return 0
 		}),
 
+		instanceLimitsFairShare: promauto.With(r).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_ingester_instance_limits_fair_share",
+			Help: "Tenant's fair share of the instance limits, enforced once the ingester is close to the limit.",
+		}, []string{"user", limitLabel}),
+
 		// Not registered automatically, but only if activeSeriesEnabled is true.
 		activeSeriesPerUser: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Name: "cortex_ingester_active_series",
@@ -317,6 +325,8 @@ func (m *ingesterMetrics) deletePerUserMetrics(userID string) {
 	m.memMetadataCreatedTotal.DeleteLabelValues(userID)
 	m.memMetadataRemovedTotal.DeleteLabelValues(userID)
 	m.activeSeriesPerUser.DeleteLabelValues(userID)
+	m.instanceLimitsFairShare.DeleteLabelValues(userID, "max_series")
+	m.instanceLimitsFairShare.DeleteLabelValues(userID, "max_ingestion_rate")
 
 	if m.memSeriesCreatedTotal != nil {
 		m.memSeriesCreatedTotal.DeleteLabelValues(userID)
@@ -63,6 +63,9 @@ type userState struct {
 const (
 	perUserSeriesLimit   = "per_user_series_limit"
 	perMetricSeriesLimit = "per_metric_series_limit"
+
+	instanceSeriesFairShare        = "instance_series_fair_share"
+	instanceIngestionRateFairShare = "instance_ingestion_rate_fair_share"
 )
 
 func newUserStates(limiter *Limiter, cfg Config, metrics *ingesterMetrics, logger log.Logger) *userStates {
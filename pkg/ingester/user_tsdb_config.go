@@ -0,0 +1,115 @@
+package ingester
+
+import (
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+)
+
+// Supported values of the per-tenant TSDB WAL compression.
+const (
+	walCompressionNone   = "none"
+	walCompressionSnappy = "snappy"
+)
+
+// userTSDBConfig holds the options a tenant's TSDB has been opened with. The options can be
+// overridden per-tenant, but can't be changed once the TSDB is open.
+type userTSDBConfig struct {
+	blockRanges               []int64
+	headChunksWriteBufferSize int
+	stripeSize                int
+	maxExemplars              int
+	walCompression            bool
+}
+
+// headBlockRange returns the range of the blocks the head is compacted to, in milliseconds.
+func (c userTSDBConfig) headBlockRange() int64 {
+	return c.blockRanges[0]
+}
+
+// getUserTSDBConfig returns the options to open the TSDB of the tenant with, applying the per-tenant
+// overrides to the ingester's config. Invalid overrides are ignored, since the overrides can be
+// changed at runtime without being validated.
+func (i *Ingester) getUserTSDBConfig(userID string, logger log.Logger) userTSDBConfig {
+	cfg := i.cfg.BlocksStorageConfig.TSDB
+	c := userTSDBConfig{
+		blockRanges:               cfg.BlockRanges.ToMilliseconds(),
+		headChunksWriteBufferSize: cfg.HeadChunksWriteBufferSize,
+		stripeSize:                cfg.StripeSize,
+		maxExemplars:              cfg.MaxExemplars,
+		walCompression:            cfg.WALCompressionEnabled,
+	}
+
+	if i.limits == nil {
+		return c
+	}
+
+	if period := i.limits.IngesterTSDBBlockRangePeriod(userID); period > 0 && i.isValidBlockRangePeriod(period, logger) {
+		c.blockRanges = []int64{period.Milliseconds()}
+
+		// The largest block range is kept, so that the local blocks keep being compacted up to it.
+		if last := cfg.BlockRanges[len(cfg.BlockRanges)-1].Milliseconds(); last > c.blockRanges[0] {
+			c.blockRanges = append(c.blockRanges, last)
+		}
+	}
+
+	if size := i.limits.IngesterTSDBHeadChunksWriteBufferSize(userID); size > 0 {
+		if size >= chunks.MinWriteBufferSize && size <= chunks.MaxWriteBufferSize && size%1024 == 0 {
+			c.headChunksWriteBufferSize = size
+		} else {
+			level.Warn(logger).Log("msg", "ignoring invalid per-tenant TSDB head chunks write buffer size", "value", size)
+		}
+	}
+
+	if size := i.limits.IngesterTSDBStripeSize(userID); size > 0 {
+		if size > 1 && size&(size-1) == 0 {
+			c.stripeSize = size
+		} else {
+			level.Warn(logger).Log("msg", "ignoring invalid per-tenant TSDB stripe size", "value", size)
+		}
+	}
+
+	if max := i.limits.IngesterTSDBMaxExemplars(userID); max != 0 {
+		c.maxExemplars = max
+	}
+
+	switch compression := i.limits.IngesterTSDBWALCompression(userID); compression {
+	case "":
+	case walCompressionNone:
+		c.walCompression = false
+	case walCompressionSnappy:
+		c.walCompression = true
+	default:
+		level.Warn(logger).Log("msg", "ignoring invalid per-tenant TSDB WAL compression", "value", compression)
+	}
+
+	return c
+}
+
+// isValidBlockRangePeriod returns whether the per-tenant block range period is compatible with the
+// ingester and compactor config, logging the reason if it's not.
+func (i *Ingester) isValidBlockRangePeriod(period time.Duration, logger log.Logger) bool {
+	// The blocks are kept in the ingester for the retention period once shipped.
+	if retention := i.cfg.BlocksStorageConfig.TSDB.Retention; period > retention {
+		level.Warn(logger).Log("msg", "ignoring per-tenant TSDB block range period larger than the TSDB retention period", "value", period, "retention", retention)
+		return false
+	}
+
+	// The samples not shipped yet must be queried from the ingesters.
+	if within := i.cfg.QueryIngestersWithin; within > 0 && period >= within {
+		level.Warn(logger).Log("msg", "ignoring per-tenant TSDB block range period not lower than the query ingesters within period", "value", period, "query_ingesters_within", within)
+		return false
+	}
+
+	// The blocks must be aligned to the compactor block ranges, in order to be compacted.
+	for _, r := range i.cfg.CompactorBlockRanges {
+		if r > period && r%period != 0 {
+			level.Warn(logger).Log("msg", "ignoring per-tenant TSDB block range period not dividing the compactor block ranges", "value", period, "compactor_block_range", r)
+			return false
+		}
+	}
+
+	return true
+}
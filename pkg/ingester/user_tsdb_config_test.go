@@ -0,0 +1,144 @@
+package ingester
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+func TestIngester_getUserTSDBConfig(t *testing.T) {
+	defaultConfig := userTSDBConfig{
+		blockRanges:               []int64{2 * time.Hour.Milliseconds(), 12 * time.Hour.Milliseconds()},
+		headChunksWriteBufferSize: 4 * 1024 * 1024,
+		stripeSize:                16384,
+		maxExemplars:              100,
+		walCompression:            true,
+	}
+
+	tests := map[string]struct {
+		config   func(*Config)
+		setup    func(*validation.Limits)
+		expected func(*userTSDBConfig)
+	}{
+		"should use the ingester config if not overridden": {
+			setup:    func(*validation.Limits) {},
+			expected: func(*userTSDBConfig) {},
+		},
+		"should apply the overrides": {
+			setup: func(l *validation.Limits) {
+				l.IngesterTSDBBlockRangePeriod = model.Duration(time.Hour)
+				l.IngesterTSDBHeadChunksWriteBufferSize = 1024 * 1024
+				l.IngesterTSDBStripeSize = 256
+				l.IngesterTSDBMaxExemplars = 10
+				l.IngesterTSDBWALCompression = walCompressionNone
+			},
+			expected: func(c *userTSDBConfig) {
+				c.blockRanges = []int64{time.Hour.Milliseconds(), 12 * time.Hour.Milliseconds()}
+				c.headChunksWriteBufferSize = 1024 * 1024
+				c.stripeSize = 256
+				c.maxExemplars = 10
+				c.walCompression = false
+			},
+		},
+		"should not keep the largest block range if lower than the overridden period": {
+			config: func(cfg *Config) {
+				cfg.BlocksStorageConfig.TSDB.Retention = 48 * time.Hour
+			},
+			setup: func(l *validation.Limits) {
+				l.IngesterTSDBBlockRangePeriod = model.Duration(24 * time.Hour)
+			},
+			expected: func(c *userTSDBConfig) {
+				c.blockRanges = []int64{24 * time.Hour.Milliseconds()}
+			},
+		},
+		"should ignore a block range period larger than the retention": {
+			config: func(cfg *Config) {
+				cfg.BlocksStorageConfig.TSDB.Retention = 6 * time.Hour
+			},
+			setup: func(l *validation.Limits) {
+				l.IngesterTSDBBlockRangePeriod = model.Duration(12 * time.Hour)
+			},
+			expected: func(*userTSDBConfig) {},
+		},
+		"should ignore a block range period not lower than the query ingesters within": {
+			config: func(cfg *Config) {
+				cfg.QueryIngestersWithin = time.Hour
+			},
+			setup: func(l *validation.Limits) {
+				l.IngesterTSDBBlockRangePeriod = model.Duration(time.Hour)
+			},
+			expected: func(*userTSDBConfig) {},
+		},
+		"should ignore a block range period not dividing the compactor block ranges": {
+			config: func(cfg *Config) {
+				cfg.CompactorBlockRanges = cortex_tsdb.DurationList{2 * time.Hour, 12 * time.Hour, 24 * time.Hour}
+			},
+			setup: func(l *validation.Limits) {
+				l.IngesterTSDBBlockRangePeriod = model.Duration(5 * time.Hour)
+			},
+			expected: func(*userTSDBConfig) {},
+		},
+		"should apply a block range period dividing the compactor block ranges": {
+			config: func(cfg *Config) {
+				cfg.QueryIngestersWithin = 13 * time.Hour
+				cfg.CompactorBlockRanges = cortex_tsdb.DurationList{2 * time.Hour, 12 * time.Hour, 24 * time.Hour}
+			},
+			setup: func(l *validation.Limits) {
+				l.IngesterTSDBBlockRangePeriod = model.Duration(4 * time.Hour)
+			},
+			expected: func(c *userTSDBConfig) {
+				c.blockRanges = []int64{4 * time.Hour.Milliseconds(), 12 * time.Hour.Milliseconds()}
+			},
+		},
+		"should disable exemplars on negative max exemplars": {
+			setup: func(l *validation.Limits) {
+				l.IngesterTSDBMaxExemplars = -1
+			},
+			expected: func(c *userTSDBConfig) {
+				c.maxExemplars = -1
+			},
+		},
+		"should ignore invalid overrides": {
+			setup: func(l *validation.Limits) {
+				l.IngesterTSDBHeadChunksWriteBufferSize = 1000
+				l.IngesterTSDBStripeSize = 100
+				l.IngesterTSDBWALCompression = "gzip"
+			},
+			expected: func(*userTSDBConfig) {},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			limits := defaultLimitsTestConfig()
+			testData.setup(&limits)
+			overrides, err := validation.NewOverrides(limits, nil)
+			require.NoError(t, err)
+
+			cfg := defaultIngesterTestConfig()
+			cfg.BlocksStorageConfig.TSDB.BlockRanges = cortex_tsdb.DurationList{2 * time.Hour, 12 * time.Hour}
+			cfg.BlocksStorageConfig.TSDB.HeadChunksWriteBufferSize = defaultConfig.headChunksWriteBufferSize
+			cfg.BlocksStorageConfig.TSDB.StripeSize = defaultConfig.stripeSize
+			cfg.BlocksStorageConfig.TSDB.MaxExemplars = defaultConfig.maxExemplars
+			cfg.BlocksStorageConfig.TSDB.WALCompressionEnabled = defaultConfig.walCompression
+			if testData.config != nil {
+				testData.config(&cfg)
+			}
+
+			i := &Ingester{cfg: cfg, limits: overrides}
+
+			expected := defaultConfig
+			expected.blockRanges = append([]int64(nil), defaultConfig.blockRanges...)
+			testData.expected(&expected)
+
+			assert.Equal(t, expected, i.getUserTSDBConfig("user-1", log.NewNopLogger()))
+		})
+	}
+}
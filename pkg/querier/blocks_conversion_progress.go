@@ -0,0 +1,79 @@
+package querier
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/cortexproject/cortex/pkg/storage/tsdb"
+)
+
+// blocksConversionProgressRefreshInterval is how often the progress of a tenant is reloaded.
+// The blocksconvert scheduler updates it every scan, by default every 5 minutes.
+const blocksConversionProgressRefreshInterval = 5 * time.Minute
+
+// ConversionProgress provides the time since which a tenant's data has been converted from the
+// second store to the primary one, or zero time if unknown.
+type ConversionProgress interface {
+	ConvertedSince(ctx context.Context, userID string) time.Time
+}
+
+type cachedConversionProgress struct {
+	convertedSince time.Time
+	loadedAt       time.Time
+}
+
+// BlocksConversionProgress reads the tenants' blocks conversion progress, written to the bucket by
+// the blocksconvert scheduler. The progress of each tenant is loaded on its first query, and reloaded
+// once older than the refresh interval.
+type BlocksConversionProgress struct {
+	bkt             objstore.Bucket
+	refreshInterval time.Duration
+	logger          log.Logger
+
+	progressMx sync.Mutex
+	progress   map[string]cachedConversionProgress
+}
+
+// NewBlocksConversionProgress makes a new BlocksConversionProgress.
+func NewBlocksConversionProgress(bkt objstore.Bucket, logger log.Logger) *BlocksConversionProgress {
+	return &BlocksConversionProgress{
+		bkt:             bkt,
+		refreshInterval: blocksConversionProgressRefreshInterval,
+		logger:          logger,
+		progress:        map[string]cachedConversionProgress{},
+	}
+}
+
+// ConvertedSince implements ConversionProgress.
+func (p *BlocksConversionProgress) ConvertedSince(ctx context.Context, userID string) time.Time {
+	p.progressMx.Lock()
+	cached, ok := p.progress[userID]
+	p.progressMx.Unlock()
+
+	if ok && time.Since(cached.loadedAt) < p.refreshInterval {
+		return cached.convertedSince
+	}
+
+	// If the progress can't be read, the previously loaded one is kept. Until a progress is
+	// loaded, the second store is queried as if no data has been converted.
+	progress, err := tsdb.ReadBlocksConversionProgress(ctx, p.bkt, userID)
+	if err != nil {
+		level.Warn(p.logger).Log("msg", "failed to read blocks conversion progress", "user", userID, "err", err)
+	} else if progress != nil {
+		cached.convertedSince = progress.ConvertedSinceTime()
+	} else {
+		cached.convertedSince = time.Time{}
+	}
+	cached.loadedAt = time.Now()
+
+	p.progressMx.Lock()
+	p.progress[userID] = cached
+	p.progressMx.Unlock()
+
+	return cached.convertedSince
+}
@@ -0,0 +1,38 @@
+package querier
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/cortexproject/cortex/pkg/storage/tsdb"
+)
+
+func TestBlocksConversionProgress_ConvertedSince(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+	day := time.Date(2021, 6, 22, 0, 0, 0, 0, time.UTC)
+
+	p := NewBlocksConversionProgress(bkt, log.NewNopLogger())
+
+	// The progress of a tenant without any converted data is zero.
+	assert.True(t, p.ConvertedSince(ctx, "user-1").IsZero())
+
+	// The progress is cached until the refresh interval expires.
+	require.NoError(t, tsdb.WriteBlocksConversionProgress(ctx, bkt, "user-1", nil, tsdb.NewBlocksConversionProgress(day)))
+	assert.True(t, p.ConvertedSince(ctx, "user-1").IsZero())
+
+	p.refreshInterval = 0
+	assert.Equal(t, day, p.ConvertedSince(ctx, "user-1"))
+	assert.True(t, p.ConvertedSince(ctx, "user-2").IsZero())
+
+	// The previously loaded progress is kept if the progress can't be read.
+	require.NoError(t, bkt.Upload(ctx, "user-1/"+tsdb.BlocksConversionProgressPath, strings.NewReader("invalid")))
+	assert.Equal(t, day, p.ConvertedSince(ctx, "user-1"))
+}
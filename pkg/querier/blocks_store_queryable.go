@@ -130,6 +130,9 @@ type BlocksStoreQueryable struct {
 	metrics         *blocksStoreQueryableMetrics
 	limits          BlocksStoreLimits
 
+	// KV client backing the store-gateway ring, if sharding is enabled.
+	storesRingBackend kv.Client
+
 	// Subservices manager.
 	subservices        *services.Manager
 	subservicesWatcher *services.FailureWatcher
@@ -196,9 +199,10 @@ func NewBlocksStoreQueryableFromConfig(querierCfg Config, gatewayCfg storegatewa
 		}, bucketClient, limits, logger, reg)
 	}
 
+	var storesRingBackend kv.Client
 	if gatewayCfg.ShardingEnabled {
 		storesRingCfg := gatewayCfg.ShardingRing.ToRingConfig()
-		storesRingBackend, err := kv.NewClient(
+		storesRingBackend, err = kv.NewClient(
 			storesRingCfg.KVStore,
 			ring.GetCodec(),
 			kv.RegistererWithKVName(reg, "querier-store-gateway"),
@@ -240,7 +244,13 @@ func NewBlocksStoreQueryableFromConfig(querierCfg Config, gatewayCfg storegatewa
 		reg,
 	)
 
-	return NewBlocksStoreQueryable(stores, finder, consistency, limits, querierCfg.QueryStoreAfter, logger, reg)
+	q, err := NewBlocksStoreQueryable(stores, finder, consistency, limits, querierCfg.QueryStoreAfter, logger, reg)
+	if err != nil {
+		return nil, err
+	}
+
+	q.storesRingBackend = storesRingBackend
+	return q, nil
 }
 
 func (q *BlocksStoreQueryable) starting(ctx context.Context) error {
@@ -265,7 +275,13 @@ func (q *BlocksStoreQueryable) running(ctx context.Context) error {
 }
 
 func (q *BlocksStoreQueryable) stopping(_ error) error {
-	return services.StopManagerAndAwaitStopped(context.Background(), q.subservices)
+	err := services.StopManagerAndAwaitStopped(context.Background(), q.subservices)
+
+	// The store-gateway ring doesn't stop a KV client it hasn't created.
+	if q.storesRingBackend != nil {
+		kv.StopClient(q.storesRingBackend)
+	}
+	return err
 }
 
 // Querier returns a new Querier on the storage.
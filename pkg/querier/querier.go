@@ -68,8 +68,9 @@ type Config struct {
 	StoreGatewayAddresses string       `yaml:"store_gateway_addresses"`
 	StoreGatewayClient    ClientConfig `yaml:"store_gateway_client"`
 
-	SecondStoreEngine        string       `yaml:"second_store_engine"`
-	UseSecondStoreBeforeTime flagext.Time `yaml:"use_second_store_before_time"`
+	SecondStoreEngine           string       `yaml:"second_store_engine"`
+	UseSecondStoreBeforeTime    flagext.Time `yaml:"use_second_store_before_time"`
+	UseBlocksConversionProgress bool         `yaml:"use_blocks_conversion_progress"`
 
 	ShuffleShardingIngestersLookbackPeriod time.Duration `yaml:"shuffle_sharding_ingesters_lookback_period"`
 }
@@ -100,6 +101,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&cfg.LookbackDelta, "querier.lookback-delta", 5*time.Minute, "Time since the last sample after which a time series is considered stale and ignored by expression evaluations.")
 	f.StringVar(&cfg.SecondStoreEngine, "querier.second-store-engine", "", "Second store engine to use for querying. Empty = disabled.")
 	f.Var(&cfg.UseSecondStoreBeforeTime, "querier.use-second-store-before-time", "If specified, second store is only used for queries before this timestamp. Default value 0 means secondary store is always queried.")
+	f.BoolVar(&cfg.UseBlocksConversionProgress, "querier.use-blocks-conversion-progress", false, "If enabled, the chunks second store is only used for each tenant's queries before the time since which the tenant's data has been converted to blocks, as verified by the blocksconvert scheduler, when earlier than -querier.use-second-store-before-time. Requires the blocks storage as the primary store engine.")
 	f.DurationVar(&cfg.ShuffleShardingIngestersLookbackPeriod, "querier.shuffle-sharding-ingesters-lookback-period", 0, "When distributor's sharding strategy is shuffle-sharding and this setting is > 0, queriers fetch in-memory series from the minimum set of required ingesters, selecting only ingesters which may have received series since 'now - lookback period'. The lookback period should be greater or equal than the configured 'query store after' and 'query ingesters within'. If this setting is 0, queriers always query all ingesters (ingesters shuffle sharding on read path is disabled).")
 }
 
@@ -598,6 +600,56 @@ func UseBeforeTimestampQueryable(queryable storage.Queryable, ts time.Time) Quer
 	}
 }
 
+type useBeforeTenantTimestampQueryable struct {
+	storage.Queryable
+	ts       time.Time
+	limits   *validation.Overrides
+	progress ConversionProgress // Can be nil.
+}
+
+// UseQueryable always returns true, since the timestamp depends on the tenant, which is
+// only known when the querier is created.
+func (u useBeforeTenantTimestampQueryable) UseQueryable(_ time.Time, _, _ int64) bool {
+	return true
+}
+
+func (u useBeforeTenantTimestampQueryable) Querier(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := u.ts
+	if u.progress != nil {
+		// Data converted to the primary store doesn't need to be queried from this one.
+		if since := u.progress.ConvertedSince(ctx, userID); !since.IsZero() && (ts.IsZero() || since.Before(ts)) {
+			ts = since
+		}
+	}
+	if override := u.limits.UseSecondStoreBeforeTime(userID); !override.IsZero() {
+		ts = override
+	}
+	if !ts.IsZero() && mint >= util.TimeToMillis(ts) {
+		return storage.NoopQuerier(), nil
+	}
+
+	return u.Queryable.Querier(ctx, mint, maxt)
+}
+
+// UseBeforeTenantTimestampQueryable returns QueryableWithFilter, that is used only if query starts
+// before the tenant's timestamp. The timestamp is configured by the per-tenant limits. If not
+// configured, it's the time since which the tenant's data has been converted according to the
+// given progress, if any and earlier than the given timestamp, otherwise the given timestamp.
+// If the timestamp is zero, queryable is always used.
+func UseBeforeTenantTimestampQueryable(queryable storage.Queryable, ts time.Time, limits *validation.Overrides, progress ConversionProgress) QueryableWithFilter {
+	return useBeforeTenantTimestampQueryable{
+		Queryable: queryable,
+		ts:        ts,
+		limits:    limits,
+		progress:  progress,
+	}
+}
+
 func validateQueryTimeRange(ctx context.Context, userID string, startMs, endMs int64, limits *validation.Overrides, maxQueryIntoFuture time.Duration) (int64, int64, error) {
 	now := model.Now()
 	startTime := model.Time(startMs)
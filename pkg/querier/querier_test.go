@@ -140,6 +140,7 @@ var (
 func TestQuerier(t *testing.T) {
 	var cfg Config
 	flagext.DefaultValues(&cfg)
+	cfg.ActiveQueryTrackerDir = t.TempDir()
 
 	const chunks = 24
 
@@ -356,6 +357,7 @@ func TestQuerier_ValidateQueryTimeRange_MaxQueryIntoFuture(t *testing.T) {
 
 	cfg := Config{}
 	flagext.DefaultValues(&cfg)
+	cfg.ActiveQueryTrackerDir = t.TempDir()
 
 	for _, ingesterStreaming := range []bool{true, false} {
 		cfg.IngesterStreaming = ingesterStreaming
@@ -437,6 +439,7 @@ func TestQuerier_ValidateQueryTimeRange_MaxQueryLength(t *testing.T) {
 		t.Run(testName, func(t *testing.T) {
 			var cfg Config
 			flagext.DefaultValues(&cfg)
+			cfg.ActiveQueryTrackerDir = t.TempDir()
 
 			limits := defaultLimitsConfig()
 			limits.MaxQueryLength = model.Duration(maxQueryLength)
@@ -558,6 +561,7 @@ func TestQuerier_ValidateQueryTimeRange_MaxQueryLookback(t *testing.T) {
 
 				var cfg Config
 				flagext.DefaultValues(&cfg)
+				cfg.ActiveQueryTrackerDir = t.TempDir()
 				cfg.IngesterStreaming = ingesterStreaming
 
 				limits := defaultLimitsConfig()
@@ -863,6 +867,7 @@ func TestShortTermQueryToLTS(t *testing.T) {
 
 	cfg := Config{}
 	flagext.DefaultValues(&cfg)
+	cfg.ActiveQueryTrackerDir = t.TempDir()
 
 	for _, ingesterStreaming := range []bool{true, false} {
 		cfg.IngesterStreaming = ingesterStreaming
@@ -925,6 +930,77 @@ func TestUseBeforeTimestamp(t *testing.T) {
 	require.False(t, m.useQueryableCalled) // UseBeforeTimestampQueryable wraps Queryable, and not QueryableWithFilter.
 }
 
+func TestUseBeforeTenantTimestamp(t *testing.T) {
+	now := time.Now()
+	ctx := user.InjectOrgID(context.Background(), "user-1")
+
+	tests := map[string]struct {
+		defaultTs      time.Time
+		tenantTs       time.Time
+		convertedSince time.Time
+		expectedUse    map[time.Duration]bool // Query start time relative to now -> whether the queryable is queried.
+	}{
+		"should always use the queryable if no timestamp is set": {
+			expectedUse: map[time.Duration]bool{-2 * time.Hour: true, -5 * time.Minute: true},
+		},
+		"should use the queryable before the default timestamp if not overridden": {
+			defaultTs:   now.Add(-1 * time.Hour),
+			expectedUse: map[time.Duration]bool{-2 * time.Hour: true, -1 * time.Hour: false, -5 * time.Minute: false},
+		},
+		"should use the queryable before the tenant timestamp if overridden": {
+			defaultTs:   now.Add(-1 * time.Hour),
+			tenantTs:    now.Add(-3 * time.Hour),
+			expectedUse: map[time.Duration]bool{-4 * time.Hour: true, -2 * time.Hour: false, -5 * time.Minute: false},
+		},
+		"should use the queryable before the converted since time if earlier than the default timestamp": {
+			defaultTs:      now.Add(-1 * time.Hour),
+			convertedSince: now.Add(-3 * time.Hour),
+			expectedUse:    map[time.Duration]bool{-4 * time.Hour: true, -2 * time.Hour: false, -5 * time.Minute: false},
+		},
+		"should use the queryable before the default timestamp if earlier than the converted since time": {
+			defaultTs:      now.Add(-3 * time.Hour),
+			convertedSince: now.Add(-1 * time.Hour),
+			expectedUse:    map[time.Duration]bool{-4 * time.Hour: true, -2 * time.Hour: false, -5 * time.Minute: false},
+		},
+		"should use the queryable before the converted since time if no default timestamp": {
+			convertedSince: now.Add(-1 * time.Hour),
+			expectedUse:    map[time.Duration]bool{-2 * time.Hour: true, -1 * time.Hour: false, -5 * time.Minute: false},
+		},
+		"should use the queryable before the tenant timestamp even if data has been converted": {
+			tenantTs:       now.Add(-1 * time.Hour),
+			convertedSince: now.Add(-3 * time.Hour),
+			expectedUse:    map[time.Duration]bool{-2 * time.Hour: true, -1 * time.Hour: false, -5 * time.Minute: false},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			limits := defaultLimitsConfig()
+			limits.UseSecondStoreBeforeTime = flagext.Time(testData.tenantTs)
+			overrides, err := validation.NewOverrides(limits, nil)
+			require.NoError(t, err)
+
+			progress := mockConversionProgress{"user-1": testData.convertedSince}
+			qwf := UseBeforeTenantTimestampQueryable(&mockQueryableWithFilter{}, testData.defaultTs, overrides, progress)
+			require.True(t, qwf.UseQueryable(now, 0, util.TimeToMillis(now)))
+
+			for start, expected := range testData.expectedUse {
+				q, err := qwf.Querier(ctx, util.TimeToMillis(now.Add(start)), util.TimeToMillis(now))
+				require.NoError(t, err)
+
+				// The mocked queryable returns a nil querier.
+				assert.Equal(t, expected, q == nil, "query start: %s", start)
+			}
+		})
+	}
+}
+
+type mockConversionProgress map[string]time.Time
+
+func (m mockConversionProgress) ConvertedSince(_ context.Context, userID string) time.Time {
+	return m[userID]
+}
+
 func TestStoreQueryable(t *testing.T) {
 	m := &mockQueryableWithFilter{}
 	now := time.Now()
@@ -31,6 +31,10 @@ type Limits interface {
 	// MaxCacheFreshness returns the period after which results are cacheable,
 	// to prevent caching of very recent results.
 	MaxCacheFreshness(string) time.Duration
+
+	// ResultsCacheMaxBytes returns the maximum number of bytes of query results
+	// a tenant can store in the results cache.
+	ResultsCacheMaxBytes(string) int
 }
 
 type limitsMiddleware struct {
@@ -187,9 +187,10 @@ func TestLimitsMiddleware_MaxQueryLength(t *testing.T) {
 }
 
 type mockLimits struct {
-	maxQueryLookback  time.Duration
-	maxQueryLength    time.Duration
-	maxCacheFreshness time.Duration
+	maxQueryLookback     time.Duration
+	maxQueryLength       time.Duration
+	maxCacheFreshness    time.Duration
+	resultsCacheMaxBytes int
 }
 
 func (m mockLimits) MaxQueryLookback(string) time.Duration {
@@ -208,6 +209,10 @@ func (m mockLimits) MaxCacheFreshness(string) time.Duration {
 	return m.maxCacheFreshness
 }
 
+func (m mockLimits) ResultsCacheMaxBytes(string) int {
+	return m.resultsCacheMaxBytes
+}
+
 type mockHandler struct {
 	mock.Mock
 }
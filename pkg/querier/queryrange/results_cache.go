@@ -44,10 +44,15 @@ type CacheGenNumberLoader interface {
 	GetResultsCacheGenNumber(tenantIDs []string) string
 }
 
+var errTenantIsolationWithoutValidity = errors.New("the results cache tenant isolation requires the cache entries to expire, set -frontend.default-validity or the expiration of the cache backend")
+
 // ResultsCacheConfig is the config for the results cache.
 type ResultsCacheConfig struct {
 	CacheConfig cache.Config `yaml:"cache"`
 	Compression string       `yaml:"compression"`
+
+	TenantIsolationEnabled       bool          `yaml:"tenant_isolation_enabled"`
+	TenantNamespaceRefreshPeriod time.Duration `yaml:"tenant_namespace_refresh_period"`
 }
 
 // RegisterFlags registers flags.
@@ -55,6 +60,8 @@ func (cfg *ResultsCacheConfig) RegisterFlags(f *flag.FlagSet) {
 	cfg.CacheConfig.RegisterFlagsWithPrefix("frontend.", "", f)
 
 	f.StringVar(&cfg.Compression, "frontend.compression", "", "Use compression in results cache. Supported values are: 'snappy' and '' (disable compression).")
+	f.BoolVar(&cfg.TenantIsolationEnabled, "frontend.results-cache.tenant-isolation-enabled", false, "Experimental: namespace the results cache entries by tenant, account the bytes stored by each tenant to enforce the per-tenant results_cache_max_bytes limit, and allow to invalidate the entries of a tenant through the /frontend/results_cache/invalidate endpoint. Enabling it invalidates the entries stored so far. Requires the cache entries to expire, through -frontend.default-validity or the expiration of the cache backend.")
+	f.DurationVar(&cfg.TenantNamespaceRefreshPeriod, "frontend.results-cache.tenant-namespace-refresh-period", time.Minute, "How frequently the tenants namespaces are re-read from the results cache. This is the maximum time it takes for the invalidation of a tenant to be picked up by the other query-frontends.")
 	flagext.DeprecatedFlag(f, "frontend.cache-split-interval", "Deprecated: The maximum interval expected for each request, results will be cached per single interval. This behavior is now determined by querier.split-queries-by-interval.")
 }
 
@@ -66,6 +73,11 @@ func (cfg *ResultsCacheConfig) Validate() error {
 		return errors.Errorf("unsupported compression type: %s", cfg.Compression)
 	}
 
+	// The bytes stored by each tenant are accounted until the entries expire.
+	if cfg.TenantIsolationEnabled && cacheValidity(cfg.CacheConfig) <= 0 {
+		return errTenantIsolationWithoutValidity
+	}
+
 	return cfg.CacheConfig.Validate()
 }
 
@@ -168,6 +180,10 @@ func NewResultsCacheMiddleware(
 		c = cache.NewCacheGenNumMiddleware(c)
 	}
 
+	if cfg.TenantIsolationEnabled {
+		c = cache.NewTenantCache("frontend", c, tenantCacheLimits{limits}, cacheValidity(cfg.CacheConfig), cfg.TenantNamespaceRefreshPeriod, reg, logger)
+	}
+
 	return MiddlewareFunc(func(next Handler) Handler {
 		return &resultsCache{
 			logger:               logger,
@@ -185,6 +201,26 @@ func NewResultsCacheMiddleware(
 	}), c, nil
 }
 
+// tenantCacheLimits adapts Limits to the limits of the cache.TenantCache.
+type tenantCacheLimits struct {
+	Limits
+}
+
+func (l tenantCacheLimits) MaxCacheBytes(userID string) int {
+	return l.ResultsCacheMaxBytes(userID)
+}
+
+// cacheValidity returns how long entries stay in the results cache, 0 if they don't expire.
+func cacheValidity(cfg cache.Config) time.Duration {
+	validity := cfg.DefaultValidity
+	for _, v := range []time.Duration{cfg.Memcache.Expiration, cfg.Redis.Expiration, cfg.Fifocache.Validity} {
+		if v > validity {
+			validity = v
+		}
+	}
+	return validity
+}
+
 func (s resultsCache) Do(ctx context.Context, r Request) (Response, error) {
 	tenantIDs, err := tenant.TenantIDs(ctx)
 	if err != nil {
@@ -781,6 +781,77 @@ func TestResultsCache(t *testing.T) {
 	require.Equal(t, 2, calls)
 }
 
+func TestResultsCacheConfig_Validate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		cfg      ResultsCacheConfig
+		expected error
+	}{
+		"tenant isolation disabled": {
+			cfg: ResultsCacheConfig{},
+		},
+		"tenant isolation without validity": {
+			cfg:      ResultsCacheConfig{TenantIsolationEnabled: true},
+			expected: errTenantIsolationWithoutValidity,
+		},
+		"tenant isolation with the default validity": {
+			cfg: ResultsCacheConfig{
+				CacheConfig:            cache.Config{DefaultValidity: time.Hour},
+				TenantIsolationEnabled: true,
+			},
+		},
+		"tenant isolation with the memcached expiration": {
+			cfg: ResultsCacheConfig{
+				CacheConfig:            cache.Config{Memcache: cache.MemcachedConfig{Expiration: time.Hour}},
+				TenantIsolationEnabled: true,
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.cfg.Validate())
+		})
+	}
+}
+
+func TestResultsCache_TenantIsolation(t *testing.T) {
+	calls := 0
+	cfg := ResultsCacheConfig{
+		CacheConfig: cache.Config{
+			Cache: cache.NewMockCache(),
+		},
+		TenantIsolationEnabled: true,
+	}
+	rcm, c, err := NewResultsCacheMiddleware(
+		log.NewNopLogger(),
+		cfg,
+		constSplitter(day),
+		mockLimits{},
+		PrometheusCodec,
+		PrometheusResponseExtractor{},
+		nil,
+		nil,
+		nil,
+	)
+	require.NoError(t, err)
+	require.IsType(t, &cache.TenantCache{}, c)
+
+	rc := rcm.Wrap(HandlerFunc(func(_ context.Context, req Request) (Response, error) {
+		calls++
+		return parsedResponse, nil
+	}))
+	ctx := user.InjectOrgID(context.Background(), "1")
+	_, err = rc.Do(ctx, parsedRequest)
+	require.NoError(t, err)
+	_, err = rc.Do(ctx, parsedRequest)
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+
+	// Invalidating the tenant entries should query again.
+	c.(*cache.TenantCache).Invalidate(ctx, "1")
+	_, err = rc.Do(ctx, parsedRequest)
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+}
+
 func TestResultsCacheRecent(t *testing.T) {
 	var cfg ResultsCacheConfig
 	flagext.DefaultValues(&cfg)
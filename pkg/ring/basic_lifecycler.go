@@ -52,6 +52,9 @@ type BasicLifecyclerConfig struct {
 	HeartbeatPeriod     time.Duration
 	TokensObservePeriod time.Duration
 	NumTokens           int
+
+	// TokenGenerator generates the instance tokens. Random tokens are generated if nil.
+	TokenGenerator TokenGenerator
 }
 
 // BasicLifecycler is a basic ring lifecycler which allows to hook custom
@@ -83,6 +86,10 @@ type BasicLifecycler struct {
 
 // NewBasicLifecycler makes a new BasicLifecycler.
 func NewBasicLifecycler(cfg BasicLifecyclerConfig, ringName, ringKey string, store kv.Client, delegate BasicLifecyclerDelegate, logger log.Logger, reg prometheus.Registerer) (*BasicLifecycler, error) {
+	if cfg.TokenGenerator == nil {
+		cfg.TokenGenerator = RandomTokenGenerator{}
+	}
+
 	l := &BasicLifecycler{
 		cfg:       cfg,
 		ringName:  ringName,
@@ -134,6 +141,12 @@ func (l *BasicLifecycler) GetTokens() Tokens {
 	return l.currInstanceDesc.GetTokens()
 }
 
+// GenerateTokens returns numTokens new tokens for the instance, none of which is expected to
+// clash with takenTokens, using the configured TokenGenerator.
+func (l *BasicLifecycler) GenerateTokens(numTokens int, takenTokens []uint32) Tokens {
+	return l.cfg.TokenGenerator.GenerateTokens(numTokens, takenTokens)
+}
+
 // GetRegisteredAt returns the timestamp when the instance has been registered to the ring
 // or a zero value if the lifecycler hasn't been started yet or was already registered and its
 // timestamp is unknown.
@@ -259,6 +272,12 @@ func (l *BasicLifecycler) registerInstance(ctx context.Context) error {
 		// Ensure tokens are sorted.
 		sort.Sort(tokens)
 
+		// Deterministically generated tokens may clash with the ones owned by other instances.
+		_, takenTokens := ringDesc.TokensFor(l.cfg.ID)
+		if err := l.cfg.TokenGenerator.CanJoin(tokens, takenTokens); err != nil {
+			return nil, false, err
+		}
+
 		// If the instance didn't already exist, then we can safely set the registered timestamp to "now",
 		// otherwise we have to honor the previous value (even if it was zero, because means it was unknown
 		// but it's definitely not "now").
@@ -342,7 +361,11 @@ func (l *BasicLifecycler) verifyTokens(ctx context.Context) bool {
 		needTokens := l.cfg.NumTokens - len(actualTokens)
 
 		level.Info(l.logger).Log("msg", "generating new tokens", "count", needTokens, "ring", l.ringName)
-		newTokens := GenerateTokens(needTokens, takenTokens)
+		newTokens := l.cfg.TokenGenerator.GenerateTokens(needTokens, takenTokens)
+		if err := l.cfg.TokenGenerator.CanJoin(newTokens, takenTokens); err != nil {
+			level.Error(l.logger).Log("msg", "unable to replace the tokens of the instance", "ring", l.ringName, "err", err)
+			return false
+		}
 
 		actualTokens = append(actualTokens, newTokens...)
 		sort.Sort(actualTokens)
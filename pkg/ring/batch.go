@@ -26,6 +26,12 @@ type itemTracker struct {
 	maxFailures int
 	succeeded   atomic.Int32
 	failed      atomic.Int32
+
+	// Tracks the results per zone when the replication set has been built with
+	// the zone quorum, in which case minSuccess and maxFailures are not used.
+	zonesMtx  sync.Mutex
+	zones     *zoneAwareResultTracker
+	zonesDone bool
 }
 
 // DoBatch request against a set of keys in the ring, handling replication and
@@ -56,8 +62,12 @@ func DoBatch(ctx context.Context, op Operation, r ReadRing, keys []uint32, callb
 		if err != nil {
 			return err
 		}
-		itemTrackers[i].minSuccess = len(replicationSet.Instances) - replicationSet.MaxErrors
-		itemTrackers[i].maxFailures = replicationSet.MaxErrors
+		if replicationSet.MaxUnavailableZones > 0 {
+			itemTrackers[i].zones = newZoneAwareResultTracker(replicationSet.Instances, replicationSet.MaxUnavailableZones)
+		} else {
+			itemTrackers[i].minSuccess = len(replicationSet.Instances) - replicationSet.MaxErrors
+			itemTrackers[i].maxFailures = replicationSet.MaxErrors
+		}
 
 		for _, desc := range replicationSet.Instances {
 			curr, found := instances[desc.Addr]
@@ -85,7 +95,7 @@ func DoBatch(ctx context.Context, op Operation, r ReadRing, keys []uint32, callb
 	for _, i := range instances {
 		go func(i instance) {
 			err := callback(i.desc, i.indexes)
-			tracker.record(i.itemTrackers, err)
+			tracker.record(&i.desc, i.itemTrackers, err)
 			wg.Done()
 		}(i)
 	}
@@ -107,7 +117,7 @@ func DoBatch(ctx context.Context, op Operation, r ReadRing, keys []uint32, callb
 	}
 }
 
-func (b *batchTracker) record(sampleTrackers []*itemTracker, err error) {
+func (b *batchTracker) record(desc *InstanceDesc, sampleTrackers []*itemTracker, err error) {
 	// If we succeed, decrement each sample's pending count by one.  If we reach
 	// the required number of successful puts on this sample, then decrement the
 	// number of pending samples by one.  If we successfully push all samples to
@@ -118,6 +128,17 @@ func (b *batchTracker) record(sampleTrackers []*itemTracker, err error) {
 	// The use of atomic increments here guarantees only a single sendSamples
 	// goroutine will write to either channel.
 	for i := range sampleTrackers {
+		if sampleTrackers[i].zones != nil {
+			succeeded, failed := sampleTrackers[i].recordZone(desc, err)
+			if failed && b.rpcsFailed.Inc() == 1 {
+				b.err <- err
+			}
+			if succeeded && b.rpcsPending.Dec() == 0 {
+				b.done <- struct{}{}
+			}
+			continue
+		}
+
 		if err != nil {
 			if sampleTrackers[i].failed.Inc() <= int32(sampleTrackers[i].maxFailures) {
 				continue
@@ -135,3 +156,25 @@ func (b *batchTracker) record(sampleTrackers []*itemTracker, err error) {
 		}
 	}
 }
+
+// recordZone records the result of an instance for an item tracked per zone, and returns
+// whether the item has just succeeded or failed. Each item succeeds or fails only once.
+func (t *itemTracker) recordZone(desc *InstanceDesc, err error) (succeeded, failed bool) {
+	t.zonesMtx.Lock()
+	defer t.zonesMtx.Unlock()
+
+	if t.zonesDone {
+		return false, false
+	}
+
+	t.zones.done(desc, err)
+	if t.zones.failed() {
+		t.zonesDone = true
+		return false, true
+	}
+	if t.zones.succeeded() {
+		t.zonesDone = true
+		return true, false
+	}
+	return false, false
+}
@@ -18,6 +18,24 @@ type FlushTransferer interface {
 	TransferOut(ctx context.Context) error
 }
 
+// TokensRebalanceFlusher can be optionally implemented by the FlushTransferer, in order to
+// persist the in-memory data before the lifecycler moves one of its tokens when rebalancing
+// tokens, which gives the [start, end) token range to another instance. The range may wrap
+// around the ring, in which case end is lower than start. Called from the "actor loop", while
+// the ring entry is in ACTIVE state: the token is moved only once FlushBeforeTokensRebalance
+// returns, so it must block until the data has been persisted. The lifecycler keeps
+// heartbeating the ring in the meanwhile.
+type TokensRebalanceFlusher interface {
+	FlushBeforeTokensRebalance(ctx context.Context, start, end uint32) error
+}
+
+// TokenInRange returns whether the token falls within the [start, end) range, which
+// may wrap around the ring.
+func TokenInRange(token, start, end uint32) bool {
+	// The unsigned subtraction wraps around the ring.
+	return token-start < end-start
+}
+
 // NoopFlushTransferer is a FlushTransferer which does nothing and can
 // be used in cases we don't need one
 type NoopFlushTransferer struct{}
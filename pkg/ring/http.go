@@ -25,6 +25,32 @@ const pageContent = `
 	<body>
 		<h1>Cortex Ring Status</h1>
 		<p>Current time: {{ .Now }}</p>
+		{{ if .Zones }}
+		<h2>Ownership per zone</h2>
+		<table border="1">
+			<thead>
+				<tr>
+					<th>Availability Zone</th>
+					<th>Instances</th>
+					<th>Min Ownership</th>
+					<th>Max Ownership</th>
+					<th>Imbalance</th>
+				</tr>
+			</thead>
+			<tbody>
+				{{ range .Zones }}
+				<tr>
+					<td>{{ .Zone }}</td>
+					<td>{{ .Instances }}</td>
+					<td>{{ printf "%.2f" .MinOwnership }}%</td>
+					<td>{{ printf "%.2f" .MaxOwnership }}%</td>
+					<td>{{ printf "%.2f" .Imbalance }}%</td>
+				</tr>
+				{{ end }}
+			</tbody>
+		</table>
+		<br>
+		{{ end }}
 		<form action="" method="POST">
 			<input type="hidden" name="csrf_token" value="$__CSRF_TOKEN_PLACEHOLDER__">
 			<table width="100%" border="1">
@@ -38,6 +64,7 @@ const pageContent = `
 						<th>Last Heartbeat</th>
 						<th>Tokens</th>
 						<th>Ownership</th>
+						<th>Zone Ownership</th>
 						<th>Actions</th>
 					</tr>
 				</thead>
@@ -56,6 +83,7 @@ const pageContent = `
 						<td>{{ .HeartbeatTimestamp }}</td>
 						<td>{{ .NumTokens }}</td>
 						<td>{{ .Ownership }}%</td>
+						<td>{{ .ZoneOwnership }}%</td>
 						<td><button name="forget" value="{{ .ID }}" type="submit">Forget</button></td>
 					</tr>
 					{{ end }}
@@ -135,6 +163,7 @@ func (r *Ring) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	now := time.Now()
 	ingesters := []interface{}{}
 	_, owned := r.countTokens()
+	zoneOwnership, zones := r.zonesOwnership()
 	for _, id := range ingesterIDs {
 		ing := r.ringDesc.Ingesters[id]
 		heartbeatTimestamp := time.Unix(ing.Timestamp, 0)
@@ -158,7 +187,8 @@ func (r *Ring) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			Zone                string   `json:"zone"`
 			Tokens              []uint32 `json:"tokens"`
 			NumTokens           int      `json:"-"`
-			Ownership           float64  `json:"-"`
+			Ownership           float64  `json:"ownership"`
+			ZoneOwnership       float64  `json:"zone_ownership"`
 		}{
 			ID:                  id,
 			State:               state,
@@ -169,17 +199,20 @@ func (r *Ring) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			Zone:                ing.Zone,
 			NumTokens:           len(ing.Tokens),
 			Ownership:           (float64(owned[id]) / float64(math.MaxUint32)) * 100,
+			ZoneOwnership:       zoneOwnership[id],
 		})
 	}
 
 	tokensParam := req.URL.Query().Get("tokens")
 
 	util.RenderHTTPResponse(w, struct {
-		Ingesters  []interface{} `json:"shards"`
-		Now        time.Time     `json:"now"`
-		ShowTokens bool          `json:"-"`
+		Ingesters  []interface{}   `json:"shards"`
+		Zones      []ZoneOwnership `json:"zones"`
+		Now        time.Time       `json:"now"`
+		ShowTokens bool            `json:"-"`
 	}{
 		Ingesters:  ingesters,
+		Zones:      zones,
 		Now:        now,
 		ShowTokens: tokensParam == "true",
 	}, pageTemplate, req)
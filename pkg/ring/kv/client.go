@@ -204,5 +204,18 @@ func buildMultiClient(cfg StoreConfig, codec codec.Codec, reg prometheus.Registe
 		{client: secondary, name: cfg.Multi.Secondary},
 	}
 
-	return NewMultiClient(cfg.Multi, clients), nil
+	return NewMultiClient(cfg.Multi, clients, reg), nil
+}
+
+// StopClient stops the background work of the client, if any. It's safe to call it multiple
+// times, and on clients shared with other components, which can keep using the client.
+func StopClient(c Client) {
+	switch c := c.(type) {
+	case *MultiClient:
+		c.Stop()
+	case *prefixedKVClient:
+		StopClient(c.client)
+	case *metrics:
+		StopClient(c.c)
+	}
 }
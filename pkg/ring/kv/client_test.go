@@ -2,6 +2,7 @@ package kv
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -102,6 +103,11 @@ func typeToRoleMap(t *testing.T, reg prometheus.Gatherer) map[string]string {
 	require.NoError(t, err)
 	result := map[string]string{}
 	for _, mf := range mfs {
+		// The multi client metrics don't track the KV requests.
+		if strings.HasPrefix(mf.GetName(), "cortex_multikv_") {
+			continue
+		}
+
 		for _, m := range mf.GetMetric() {
 			backendType := ""
 			role := ""
@@ -6,14 +6,12 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-kit/kit/log"
-	"github.com/hashicorp/memberlist"
 	"go.uber.org/atomic"
 
 	"github.com/cortexproject/cortex/pkg/util"
@@ -96,6 +94,13 @@ func (kvs *KVInitService) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	kv.ServeHTTP(w, req)
+}
+
+// ServeHTTP renders the memberlist status page, listing the cluster members, the keys in the KV store
+// and the recent sent and received messages. The same information is returned as JSON when the
+// request has the "Accept: application/json" header.
+func (m *KV) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	const (
 		downloadKeyParam    = "downloadKey"
 		viewKeyParam        = "viewKey"
@@ -105,12 +110,12 @@ func (kvs *KVInitService) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 	if err := req.ParseForm(); err == nil {
 		if req.Form[downloadKeyParam] != nil {
-			downloadKey(w, kv, kv.storeCopy(), req.Form[downloadKeyParam][0]) // Use first value, ignore the rest.
+			downloadKey(w, m, m.storeCopy(), req.Form[downloadKeyParam][0]) // Use first value, ignore the rest.
 			return
 		}
 
 		if req.Form[viewKeyParam] != nil {
-			viewKey(w, kv.storeCopy(), req.Form[viewKeyParam][0], getFormat(req))
+			viewKey(w, m.storeCopy(), req.Form[viewKeyParam][0], getFormat(req))
 			return
 		}
 
@@ -121,11 +126,11 @@ func (kvs *KVInitService) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 				return
 			}
 
-			sent, received := kv.getSentAndReceivedMessages()
+			sent, received := m.getSentAndReceivedMessages()
 
-			for _, m := range append(sent, received...) {
-				if m.ID == msgID {
-					viewMessage(w, kv, m, getFormat(req))
+			for _, msg := range append(sent, received...) {
+				if msg.ID == msgID {
+					viewMessage(w, m, msg, getFormat(req))
 					return
 				}
 			}
@@ -135,7 +140,7 @@ func (kvs *KVInitService) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		}
 
 		if len(req.Form[deleteMessagesParam]) > 0 && req.Form[deleteMessagesParam][0] == "true" {
-			kv.deleteSentReceivedMessages()
+			m.deleteSentReceivedMessages()
 
 			// Redirect back.
 			w.Header().Set("Location", "?"+deleteMessagesParam+"=false")
@@ -144,20 +149,9 @@ func (kvs *KVInitService) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
-	members := kv.memberlist.Members()
-	sort.Slice(members, func(i, j int) bool {
-		return members[i].Name < members[j].Name
-	})
-
-	sent, received := kv.getSentAndReceivedMessages()
-
 	util.RenderHTTPResponse(w, pageData{
-		Now:              time.Now(),
-		Memberlist:       kv.memberlist,
-		SortedMembers:    members,
-		Store:            kv.storeCopy(),
-		SentMessages:     sent,
-		ReceivedMessages: received,
+		Now:      time.Now(),
+		KVStatus: m.Status(),
 	}, pageTemplate, req)
 }
 
@@ -249,12 +243,8 @@ func downloadKey(w http.ResponseWriter, kv *KV, store map[string]valueDesc, key
 }
 
 type pageData struct {
-	Now              time.Time
-	Memberlist       *memberlist.Memberlist
-	SortedMembers    []*memberlist.Node
-	Store            map[string]valueDesc
-	SentMessages     []message
-	ReceivedMessages []message
+	Now time.Time `json:"now"`
+	KVStatus
 }
 
 var pageTemplate = template.Must(template.New("webpage").Funcs(template.FuncMap{
@@ -273,8 +263,8 @@ const pageContent = `
 		<p>Current time: {{ .Now }}</p>
 
 		<ul>
-		<li>Health Score: {{ .Memberlist.GetHealthScore }} (lower = better, 0 = healthy)</li>
-		<li>Members: {{ .Memberlist.NumMembers }}</li>
+		<li>Health Score: {{ .HealthScore }} (lower = better, 0 = healthy)</li>
+		<li>Members: {{ len .Members }}</li>
 		</ul>
 
 		<h2>KV Store</h2>
@@ -283,28 +273,32 @@ const pageContent = `
 			<thead>
 				<tr>
 					<th>Key</th>
-					<th>Value Details</th>
+					<th>Version</th>
+					<th>Codec</th>
+					<th>Size</th>
 					<th>Actions</th>
 				</tr>
 			</thead>
 
 			<tbody>
-				{{ range $k, $v := .Store }}
+				{{ range .Keys }}
 				<tr>
-					<td>{{ $k }}</td>
-					<td>{{ $v }}</td>
+					<td>{{ .Key }}</td>
+					<td>{{ .Version }}</td>
+					<td>{{ .Codec }}</td>
+					<td>{{ if .Error }}{{ .Error }}{{ else }}{{ .Size }}{{ end }}</td>
 					<td>
-						<a href="?viewKey={{ $k }}&format=json">json</a>
-						| <a href="?viewKey={{ $k }}&format=json-pretty">json-pretty</a>
-						| <a href="?viewKey={{ $k }}&format=struct">struct</a>
-						| <a href="?downloadKey={{ $k }}">download</a>
+						<a href="?viewKey={{ .Key }}&format=json">json</a>
+						| <a href="?viewKey={{ .Key }}&format=json-pretty">json-pretty</a>
+						| <a href="?viewKey={{ .Key }}&format=struct">struct</a>
+						| <a href="?downloadKey={{ .Key }}">download</a>
 					</td>
 				</tr>
 				{{ end }}
 			</tbody>
 		</table>
 
-		<p>Note that value "version" is node-specific. It starts with 0 (on restart), and increases on each received update. Size is in bytes.</p> 
+		<p>Note that value "version" is node-specific. It starts with 0 (on restart), and increases on each received update. Size is in bytes.</p>
 
 		<h2>Memberlist Cluster Members</h2>
 
@@ -318,7 +312,7 @@ const pageContent = `
 			</thead>
 
 			<tbody>
-				{{ range .SortedMembers }}
+				{{ range .Members }}
 				<tr>
 					<td>{{ .Name }}</td>
 					<td>{{ .Address }}</td>
@@ -328,8 +322,6 @@ const pageContent = `
 			</tbody>
 		</table>
 
-		<p>State: 0 = Alive, 1 = Suspect, 2 = Dead, 3 = Left</p>
-
 		<h2>Received Messages</h2>
 
 		<a href="?deleteMessages=true">Delete All Messages (received and sent)</a>
@@ -352,8 +344,8 @@ const pageContent = `
 				<tr>
 					<td>{{ .ID }}</td>
 					<td>{{ .Time.Format "15:04:05.000" }}</td>
-					<td>{{ .Pair.Key }}</td>
-					<td>size: {{ .Pair.Value | len }}, codec: {{ .Pair.Codec }}</td>
+					<td>{{ .Key }}</td>
+					<td>size: {{ .Size }}, codec: {{ .Codec }}</td>
 					<td>{{ .Version }}</td>
 					<td>{{ StringsJoin .Changes ", " }}</td> 
 					<td>
@@ -388,8 +380,8 @@ const pageContent = `
 				<tr>
 					<td>{{ .ID }}</td>
 					<td>{{ .Time.Format "15:04:05.000" }}</td>
-					<td>{{ .Pair.Key }}</td>
-					<td>size: {{ .Pair.Value | len }}, codec: {{ .Pair.Codec }}</td>
+					<td>{{ .Key }}</td>
+					<td>size: {{ .Size }}, codec: {{ .Codec }}</td>
 					<td>{{ .Version }}</td>
 					<td>{{ StringsJoin .Changes ", " }}</td> 
 					<td>
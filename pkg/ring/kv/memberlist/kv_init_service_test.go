@@ -2,55 +2,113 @@ package memberlist
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
-	"github.com/hashicorp/memberlist"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/cortexproject/cortex/pkg/util/services"
 )
 
 func TestPage(t *testing.T) {
-	conf := memberlist.DefaultLANConfig()
-	ml, err := memberlist.Create(conf)
+	require.NoError(t, pageTemplate.Execute(&bytes.Buffer{}, pageData{
+		Now: time.Now(),
+		KVStatus: KVStatus{
+			Members: []MemberStatus{{Name: "member-1", Address: "127.0.0.1:7946", State: "alive"}},
+			Keys: []KeyStatus{
+				{Key: "hello", Version: 2, Codec: "codec", Size: 5},
+				{Key: "broken", Version: 1, Codec: "unknown", Error: "unknown codec"},
+			},
+			ReceivedMessages: []MessageStatus{{
+				ID:      10,
+				Time:    time.Now(),
+				Size:    50,
+				Key:     "hello",
+				Codec:   "codec",
+				Version: 20,
+				Changes: []string{"A", "B", "C"},
+			}},
+			SentMessages: []MessageStatus{{
+				ID:      10,
+				Time:    time.Now(),
+				Size:    50,
+				Key:     "hello",
+				Codec:   "codec",
+				Version: 20,
+				Changes: []string{"A", "B", "C"},
+			}},
+		},
+	}))
+}
+
+func TestKV_ServeHTTP(t *testing.T) {
+	c := dataCodec{}
+
+	var cfg KVConfig
+	flagext.DefaultValues(&cfg)
+	cfg.NodeName = "member-1"
+	cfg.RandomizeNodeName = false
+	cfg.TCPTransport = TCPTransportConfig{BindAddrs: []string{"localhost"}}
+	cfg.Codecs = []codec.Codec{c}
+	cfg.MessageHistoryBufferBytes = 1024 * 1024
+
+	mkv := NewKV(cfg, log.NewNopLogger())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), mkv))
+	defer services.StopAndAwaitTerminated(context.Background(), mkv) //nolint:errcheck
+
+	kv, err := NewClient(mkv, c)
 	require.NoError(t, err)
+	cas(t, kv, "test", updateFn("ingester-1"))
 
-	t.Cleanup(func() {
-		_ = ml.Shutdown()
+	t.Run("JSON", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/memberlist", nil)
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+		mkv.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var status struct {
+			Members []MemberStatus `json:"members"`
+			Keys    []struct {
+				KeyStatus
+				Value *data `json:"value"`
+			} `json:"keys"`
+			SentMessages []MessageStatus `json:"sent_messages"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+
+		require.Len(t, status.Members, 1)
+		assert.Equal(t, "member-1", status.Members[0].Name)
+		assert.Equal(t, "alive", status.Members[0].State)
+
+		require.Len(t, status.Keys, 1)
+		assert.Equal(t, "test", status.Keys[0].Key)
+		assert.Equal(t, c.CodecID(), status.Keys[0].Codec)
+		assert.Equal(t, uint(1), status.Keys[0].Version)
+		assert.Greater(t, status.Keys[0].Size, 0)
+		require.NotNil(t, status.Keys[0].Value)
+		assert.Contains(t, status.Keys[0].Value.Members, "ingester-1")
+
+		require.Len(t, status.SentMessages, 1)
+		assert.Equal(t, "test", status.SentMessages[0].Key)
+		assert.Equal(t, c.CodecID(), status.SentMessages[0].Codec)
 	})
 
-	require.NoError(t, pageTemplate.Execute(&bytes.Buffer{}, pageData{
-		Now:           time.Now(),
-		Memberlist:    ml,
-		SortedMembers: ml.Members(),
-		Store:         nil,
-		ReceivedMessages: []message{{
-			ID:   10,
-			Time: time.Now(),
-			Size: 50,
-			Pair: KeyValuePair{
-				Key:   "hello",
-				Value: []byte("world"),
-				Codec: "codec",
-			},
-			Version: 20,
-			Changes: []string{"A", "B", "C"},
-		}},
-
-		SentMessages: []message{{
-			ID:   10,
-			Time: time.Now(),
-			Size: 50,
-			Pair: KeyValuePair{
-				Key:   "hello",
-				Value: []byte("world"),
-				Codec: "codec",
-			},
-			Version: 20,
-			Changes: []string{"A", "B", "C"},
-		}},
-	}))
+	t.Run("HTML", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		mkv.ServeHTTP(rec, httptest.NewRequest("GET", "/memberlist", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "member-1")
+		assert.Contains(t, rec.Body.String(), "?viewKey=test&format=json")
+	})
 }
 
 func TestStop(t *testing.T) {
@@ -9,6 +9,7 @@ import (
 	"flag"
 	"fmt"
 	"math"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -1246,6 +1247,138 @@ func (m *KV) storeCopy() map[string]valueDesc {
 	}
 	return result
 }
+
+// KVStatus is a snapshot of the memberlist cluster and of the KV store, as seen by this node.
+type KVStatus struct {
+	HealthScore      int             `json:"health_score"`
+	Members          []MemberStatus  `json:"members"`
+	Keys             []KeyStatus     `json:"keys"`
+	SentMessages     []MessageStatus `json:"sent_messages"`
+	ReceivedMessages []MessageStatus `json:"received_messages"`
+}
+
+// MemberStatus describes a member of the memberlist cluster.
+type MemberStatus struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	State   string `json:"state"`
+}
+
+// KeyStatus describes a key in the KV store. Version is node-specific: it starts with 0 on
+// restart and increases on each received update. Size is the size of the encoded value in bytes.
+type KeyStatus struct {
+	Key     string      `json:"key"`
+	Version uint        `json:"version"`
+	Codec   string      `json:"codec"`
+	Size    int         `json:"size"`
+	Value   interface{} `json:"value,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// MessageStatus describes a message sent or received by this node, kept for troubleshooting.
+type MessageStatus struct {
+	ID      int       `json:"id"`
+	Time    time.Time `json:"time"`
+	Key     string    `json:"key"`
+	Codec   string    `json:"codec"`
+	Size    int       `json:"size"`
+	Version uint      `json:"version"`
+	Changes []string  `json:"changes"`
+}
+
+// Status returns the members of the memberlist cluster with their state, the keys in the KV store
+// with their value decoded (if the codec is known) and the recent sent and received messages.
+func (m *KV) Status() KVStatus {
+	m.initWG.Wait()
+
+	status := KVStatus{
+		HealthScore: m.memberlist.GetHealthScore(),
+	}
+
+	members := m.memberlist.Members()
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Name < members[j].Name
+	})
+	for _, n := range members {
+		status.Members = append(status.Members, MemberStatus{
+			Name:    n.Name,
+			Address: n.Address(),
+			State:   memberStateName(n.State),
+		})
+	}
+
+	store := m.storeCopy()
+	keys := make([]string, 0, len(store))
+	for k := range store {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		status.Keys = append(status.Keys, m.keyStatus(k, store[k]))
+	}
+
+	sent, received := m.getSentAndReceivedMessages()
+	status.SentMessages = messagesStatus(sent)
+	status.ReceivedMessages = messagesStatus(received)
+
+	return status
+}
+
+func (m *KV) keyStatus(key string, v valueDesc) KeyStatus {
+	ks := KeyStatus{Key: key, Version: v.version, Codec: v.codecID}
+	if v.value == nil {
+		return ks
+	}
+
+	c := m.GetCodec(v.codecID)
+	if c == nil {
+		ks.Error = "unknown codec"
+		return ks
+	}
+
+	encoded, err := c.Encode(v.value)
+	if err != nil {
+		ks.Error = fmt.Sprintf("failed to encode: %v", err)
+		return ks
+	}
+
+	ks.Size = len(encoded)
+	ks.Value = v.value
+	return ks
+}
+
+func messagesStatus(msgs []message) []MessageStatus {
+	result := make([]MessageStatus, 0, len(msgs))
+	for _, msg := range msgs {
+		result = append(result, MessageStatus{
+			ID:      msg.ID,
+			Time:    msg.Time,
+			Key:     msg.Pair.Key,
+			Codec:   msg.Pair.Codec,
+			Size:    msg.Size,
+			Version: msg.Version,
+			Changes: msg.Changes,
+		})
+	}
+	return result
+}
+
+func memberStateName(state memberlist.NodeStateType) string {
+	switch state {
+	case memberlist.StateAlive:
+		return "alive"
+	case memberlist.StateSuspect:
+		return "suspect"
+	case memberlist.StateDead:
+		return "dead"
+	case memberlist.StateLeft:
+		return "left"
+	default:
+		return fmt.Sprintf("unknown (%d)", state)
+	}
+}
+
 func (m *KV) addReceivedMessage(msg message) {
 	if m.cfg.MessageHistoryBufferBytes == 0 {
 		return
@@ -30,8 +30,15 @@ const (
 	_ messageType = iota // don't use 0
 	packet
 	stream
+
+	// Same as packet and stream, but the message type is followed by the sender's cluster label.
+	packetWithClusterLabel
+	streamWithClusterLabel
 )
 
+// maxClusterLabelLength is the max length of the cluster label, which is sent prefixed by its length as a single byte.
+const maxClusterLabelLength = 255
+
 const zeroZeroZeroZero = "0.0.0.0"
 
 // TCPTransportConfig is a configuration structure for creating new TCPTransport.
@@ -56,8 +63,12 @@ type TCPTransportConfig struct {
 	MetricsRegisterer prometheus.Registerer `yaml:"-"`
 	MetricsNamespace  string                `yaml:"-"`
 
-	TLSEnabled bool                 `yaml:"tls_enabled"`
-	TLS        tlsutil.ClientConfig `yaml:",inline"`
+	TLSEnabled           bool                 `yaml:"tls_enabled"`
+	TLS                  tlsutil.ClientConfig `yaml:",inline"`
+	TLSRequireClientCert bool                 `yaml:"tls_require_client_cert"`
+
+	ClusterLabel                     string `yaml:"cluster_label"`
+	ClusterLabelVerificationDisabled bool   `yaml:"cluster_label_verification_disabled"`
 }
 
 // RegisterFlags registers flags.
@@ -71,6 +82,21 @@ func (cfg *TCPTransportConfig) RegisterFlags(f *flag.FlagSet, prefix string) {
 
 	f.BoolVar(&cfg.TLSEnabled, prefix+"memberlist.tls-enabled", false, "Enable TLS on the memberlist transport layer.")
 	cfg.TLS.RegisterFlagsWithPrefix(prefix+"memberlist", f)
+	f.BoolVar(&cfg.TLSRequireClientCert, prefix+"memberlist.tls-require-client-cert", false, "Require the other members to present a client certificate signed by the CA configured with -"+prefix+"memberlist.tls-ca-path when connecting to this member (mutual TLS). The certificate configured with -"+prefix+"memberlist.tls-cert-path is used both as server and client certificate.")
+
+	f.StringVar(&cfg.ClusterLabel, prefix+"memberlist.cluster-label", "", "The cluster label sent with every packet and stream, and verified on the received ones, in order to reject the messages of members of other clusters. Members of the same cluster must use the same label. Empty to disable the cluster label.")
+	f.BoolVar(&cfg.ClusterLabelVerificationDisabled, prefix+"memberlist.cluster-label-verification-disabled", false, "Send the cluster label, but accept messages whatever their cluster label is. Useful to add or change the cluster label of a running cluster: first configure the label with verification disabled on all members, then enable the verification.")
+}
+
+// Validate the config.
+func (cfg *TCPTransportConfig) Validate() error {
+	if len(cfg.ClusterLabel) > maxClusterLabelLength {
+		return fmt.Errorf("the memberlist cluster label can't be longer than %d characters", maxClusterLabelLength)
+	}
+	if cfg.TLSEnabled && cfg.TLSRequireClientCert && cfg.TLS.CAPath == "" {
+		return errors.New("a CA is required to verify the memberlist members client certificates")
+	}
+	return nil
 }
 
 // TCPTransport is a memberlist.Transport implementation that uses TCP for both packet and stream
@@ -95,6 +121,8 @@ type TCPTransport struct {
 	outgoingStreams      prometheus.Counter
 	outgoingStreamErrors prometheus.Counter
 
+	clusterLabelMismatches prometheus.Counter
+
 	receivedPackets       prometheus.Counter
 	receivedPacketsBytes  prometheus.Counter
 	receivedPacketsErrors prometheus.Counter
@@ -120,12 +148,23 @@ func NewTCPTransport(config TCPTransportConfig, logger log.Logger) (*TCPTranspor
 		connCh:   make(chan net.Conn),
 	}
 
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
 	var err error
+	var serverTLSConfig *tls.Config
 	if config.TLSEnabled {
 		t.tlsConfig, err = config.TLS.GetTLSConfig()
 		if err != nil {
 			return nil, errors.Wrap(err, "unable to create TLS config")
 		}
+
+		serverTLSConfig = t.tlsConfig.Clone()
+		if config.TLSRequireClientCert {
+			serverTLSConfig.ClientCAs = serverTLSConfig.RootCAs
+			serverTLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
 	}
 
 	t.registerMetrics()
@@ -146,7 +185,7 @@ func NewTCPTransport(config TCPTransportConfig, logger log.Logger) (*TCPTranspor
 
 		var tcpLn net.Listener
 		if config.TLSEnabled {
-			tcpLn, err = tls.Listen("tcp", tcpAddr.String(), t.tlsConfig)
+			tcpLn, err = tls.Listen("tcp", tcpAddr.String(), serverTLSConfig)
 			if err != nil {
 				return nil, errors.Wrapf(err, "failed to start TLS TCP listener on %q port %d", addr, port)
 			}
@@ -248,6 +287,23 @@ func (t *TCPTransport) handleConnection(conn net.Conn) {
 		return
 	}
 
+	// The message type may be followed by the cluster label of the sender.
+	clusterLabel := ""
+	if messageType(msgType[0]) == packetWithClusterLabel || messageType(msgType[0]) == streamWithClusterLabel {
+		msgType[0] -= byte(packetWithClusterLabel - packet)
+
+		if clusterLabel, err = readShortString(conn); err != nil {
+			level.Error(t.logger).Log("msg", "TCPTransport: failed to read cluster label", "err", err)
+			return
+		}
+	}
+
+	if !t.cfg.ClusterLabelVerificationDisabled && clusterLabel != t.cfg.ClusterLabel {
+		t.clusterLabelMismatches.Inc()
+		level.Warn(t.logger).Log("msg", "TCPTransport: rejected message from a member of another cluster", "remote", conn.RemoteAddr(), "cluster_label", clusterLabel, "expected_cluster_label", t.cfg.ClusterLabel)
+		return
+	}
+
 	if messageType(msgType[0]) == stream {
 		t.incomingStreams.Inc()
 
@@ -259,16 +315,7 @@ func (t *TCPTransport) handleConnection(conn net.Conn) {
 		t.receivedPackets.Inc()
 
 		// before reading packet, read the address
-		b := []byte{0}
-		_, err := io.ReadFull(conn, b)
-		if err != nil {
-			t.receivedPacketsErrors.Inc()
-			level.Error(t.logger).Log("msg", "TCPTransport: error while reading address:", "err", err)
-			return
-		}
-
-		addrBuf := make([]byte, b[0])
-		_, err = io.ReadFull(conn, addrBuf)
+		addrBuf, err := readShortString(conn)
 		if err != nil {
 			t.receivedPacketsErrors.Inc()
 			level.Error(t.logger).Log("msg", "TCPTransport: error while reading address:", "err", err)
@@ -314,6 +361,32 @@ func (t *TCPTransport) handleConnection(conn net.Conn) {
 	}
 }
 
+// readShortString reads a string prefixed by its length as a single byte.
+func readShortString(r io.Reader) (string, error) {
+	b := []byte{0}
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, b[0])
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// writeMessageHeader writes the message type, followed by our cluster label if configured.
+func (t *TCPTransport) writeMessageHeader(buf *bytes.Buffer, msgType messageType) {
+	if t.cfg.ClusterLabel == "" {
+		buf.WriteByte(byte(msgType))
+		return
+	}
+
+	buf.WriteByte(byte(msgType + packetWithClusterLabel - packet))
+	buf.WriteByte(byte(len(t.cfg.ClusterLabel)))
+	buf.WriteString(t.cfg.ClusterLabel)
+}
+
 type addr string
 
 func (a addr) Network() string {
@@ -448,7 +521,7 @@ func (t *TCPTransport) writeTo(b []byte, addr string) error {
 	}
 
 	buf := bytes.Buffer{}
-	buf.WriteByte(byte(packet))
+	t.writeMessageHeader(&buf, packet)
 
 	// We need to send our address to the other side, otherwise other side can only see IP and port from TCP header.
 	// But that doesn't match our node address (new TCP connection has new random port), which confuses memberlist.
@@ -513,7 +586,10 @@ func (t *TCPTransport) DialTimeout(addr string, timeout time.Duration) (net.Conn
 		return nil, err
 	}
 
-	_, err = c.Write([]byte{byte(stream)})
+	buf := bytes.Buffer{}
+	t.writeMessageHeader(&buf, stream)
+
+	_, err = c.Write(buf.Bytes())
 	if err != nil {
 		t.outgoingStreamErrors.Inc()
 		_ = c.Close()
@@ -569,6 +645,13 @@ func (t *TCPTransport) registerMetrics() {
 		Help:      "Number of errors when opening memberlist stream to another node",
 	})
 
+	t.clusterLabelMismatches = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: t.cfg.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "cluster_label_mismatches_total",
+		Help:      "Number of received memberlist packets and streams rejected because sent by a member of another cluster",
+	})
+
 	t.receivedPackets = prometheus.NewCounter(prometheus.CounterOpts{
 		Namespace: t.cfg.MetricsNamespace,
 		Subsystem: subsystem,
@@ -626,6 +709,7 @@ func (t *TCPTransport) registerMetrics() {
 		t.incomingStreams,
 		t.outgoingStreams,
 		t.outgoingStreamErrors,
+		t.clusterLabelMismatches,
 		t.receivedPackets,
 		t.receivedPacketsBytes,
 		t.receivedPacketsErrors,
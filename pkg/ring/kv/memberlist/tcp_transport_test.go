@@ -0,0 +1,186 @@
+package memberlist
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/integration/ca"
+	tlsutil "github.com/cortexproject/cortex/pkg/util/tls"
+)
+
+func newTestTCPTransport(t *testing.T, cfg TCPTransportConfig) *TCPTransport {
+	t.Helper()
+
+	cfg.BindAddrs = []string{"127.0.0.1"}
+	cfg.BindPort = 0
+	cfg.PacketDialTimeout = time.Second
+	cfg.PacketWriteTimeout = time.Second
+
+	tr, err := NewTCPTransport(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = tr.Shutdown() })
+
+	_, _, err = tr.FinalAdvertiseAddr("127.0.0.1", tr.GetAutoBindPort())
+	require.NoError(t, err)
+	return tr
+}
+
+func receivePacket(tr *TCPTransport) []byte {
+	select {
+	case p := <-tr.PacketCh():
+		return p.Buf
+	case <-time.After(500 * time.Millisecond):
+		return nil
+	}
+}
+
+func receiveStream(tr *TCPTransport) net.Conn {
+	select {
+	case c := <-tr.StreamCh():
+		return c
+	case <-time.After(500 * time.Millisecond):
+		return nil
+	}
+}
+
+func TestTCPTransport_ClusterLabel(t *testing.T) {
+	tests := map[string]struct {
+		senderLabel          string
+		receiverLabel        string
+		verificationDisabled bool
+		expectedAccepted     bool
+	}{
+		"no cluster label": {
+			expectedAccepted: true,
+		},
+		"same cluster label": {
+			senderLabel:      "cluster-a",
+			receiverLabel:    "cluster-a",
+			expectedAccepted: true,
+		},
+		"different cluster label": {
+			senderLabel:   "cluster-b",
+			receiverLabel: "cluster-a",
+		},
+		"sender without cluster label": {
+			receiverLabel: "cluster-a",
+		},
+		"receiver without cluster label": {
+			senderLabel: "cluster-a",
+		},
+		"different cluster label but verification disabled": {
+			senderLabel:          "cluster-b",
+			receiverLabel:        "cluster-a",
+			verificationDisabled: true,
+			expectedAccepted:     true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			sender := newTestTCPTransport(t, TCPTransportConfig{ClusterLabel: testData.senderLabel})
+			receiver := newTestTCPTransport(t, TCPTransportConfig{
+				ClusterLabel:                     testData.receiverLabel,
+				ClusterLabelVerificationDisabled: testData.verificationDisabled,
+			})
+
+			// Packets.
+			_, err := sender.WriteTo([]byte("hello"), receiver.getAdvertisedAddr())
+			require.NoError(t, err)
+
+			if testData.expectedAccepted {
+				assert.Equal(t, []byte("hello"), receivePacket(receiver))
+			} else {
+				assert.Nil(t, receivePacket(receiver))
+			}
+
+			// Streams, used by the push/pull.
+			conn, err := sender.DialTimeout(receiver.getAdvertisedAddr(), time.Second)
+			require.NoError(t, err)
+			defer conn.Close() //nolint:errcheck
+
+			_, err = conn.Write([]byte("push/pull"))
+			require.NoError(t, err)
+
+			received := receiveStream(receiver)
+			if testData.expectedAccepted {
+				require.NotNil(t, received)
+				defer received.Close() //nolint:errcheck
+
+				buf := make([]byte, len("push/pull"))
+				_, err = received.Read(buf)
+				require.NoError(t, err)
+				assert.Equal(t, "push/pull", string(buf))
+				assert.Equal(t, float64(0), testutil.ToFloat64(receiver.clusterLabelMismatches))
+			} else {
+				assert.Nil(t, received)
+				assert.Equal(t, float64(2), testutil.ToFloat64(receiver.clusterLabelMismatches))
+			}
+		})
+	}
+}
+
+func TestTCPTransport_MutualTLS(t *testing.T) {
+	certsDir, err := ioutil.TempDir("", "memberlist-tls")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(certsDir) })
+
+	caFile := filepath.Join(certsDir, "ca.crt")
+	certFile := filepath.Join(certsDir, "member.crt")
+	keyFile := filepath.Join(certsDir, "member.key")
+
+	testCA := ca.New("memberlist-ca")
+	require.NoError(t, testCA.WriteCACertificate(caFile))
+	require.NoError(t, testCA.WriteCertificate(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "member"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}, certFile, keyFile))
+
+	memberCfg := TCPTransportConfig{
+		TLSEnabled:           true,
+		TLSRequireClientCert: true,
+		TLS:                  tlsutil.ClientConfig{CertPath: certFile, KeyPath: keyFile, CAPath: caFile},
+	}
+
+	t.Run("should accept members presenting a certificate signed by the CA", func(t *testing.T) {
+		sender := newTestTCPTransport(t, memberCfg)
+		receiver := newTestTCPTransport(t, memberCfg)
+
+		_, err := sender.WriteTo([]byte("hello"), receiver.getAdvertisedAddr())
+		require.NoError(t, err)
+		assert.Equal(t, []byte("hello"), receivePacket(receiver))
+	})
+
+	t.Run("should reject members without a client certificate", func(t *testing.T) {
+		sender := newTestTCPTransport(t, memberCfg)
+		receiver := newTestTCPTransport(t, memberCfg)
+
+		// The sender dials without presenting its certificate.
+		sender.tlsConfig = sender.tlsConfig.Clone()
+		sender.tlsConfig.Certificates = nil
+
+		_, err := sender.WriteTo([]byte("hello"), receiver.getAdvertisedAddr())
+		require.NoError(t, err)
+		assert.Nil(t, receivePacket(receiver))
+	})
+
+	t.Run("should fail to start without a CA", func(t *testing.T) {
+		cfg := memberCfg
+		cfg.TLS.CAPath = ""
+		_, err := NewTCPTransport(cfg, log.NewNopLogger())
+		require.Error(t, err)
+	})
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/go-kit/kit/log"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"go.uber.org/atomic"
 
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
@@ -16,32 +17,6 @@ import (
 	"github.com/go-kit/kit/log/level"
 )
 
-var (
-	primaryStoreGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "cortex_multikv_primary_store",
-		Help: "Selected primary KV store",
-	}, []string{"store"})
-
-	mirrorEnabledGauge = prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "cortex_multikv_mirror_enabled",
-		Help: "Is mirroring to secondary store enabled",
-	})
-
-	mirrorWritesCounter = prometheus.NewCounter(prometheus.CounterOpts{
-		Name: "cortex_multikv_mirror_writes_total",
-		Help: "Number of mirror-writes to secondary store",
-	})
-
-	mirrorFailuresCounter = prometheus.NewCounter(prometheus.CounterOpts{
-		Name: "cortex_multikv_mirror_write_errors_total",
-		Help: "Number of failures to mirror-write to secondary store",
-	})
-)
-
-func init() {
-	prometheus.MustRegister(primaryStoreGauge, mirrorEnabledGauge, mirrorWritesCounter, mirrorFailuresCounter)
-}
-
 // MultiConfig is a configuration for MultiClient.
 type MultiConfig struct {
 	Primary   string `yaml:"primary"`
@@ -50,6 +25,10 @@ type MultiConfig struct {
 	MirrorEnabled bool          `yaml:"mirror_enabled"`
 	MirrorTimeout time.Duration `yaml:"mirror_timeout"`
 
+	VerifyEnabled   bool          `yaml:"verify_enabled"`
+	VerifyPeriod    time.Duration `yaml:"verify_period"`
+	AutoSwitchAfter time.Duration `yaml:"auto_switch_after"`
+
 	// ConfigProvider returns channel with MultiRuntimeConfig updates.
 	ConfigProvider func() <-chan MultiRuntimeConfig `yaml:"-"`
 }
@@ -60,6 +39,9 @@ func (cfg *MultiConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string)
 	f.StringVar(&cfg.Secondary, prefix+"multi.secondary", "", "Secondary backend storage used by multi-client.")
 	f.BoolVar(&cfg.MirrorEnabled, prefix+"multi.mirror-enabled", false, "Mirror writes to secondary store.")
 	f.DurationVar(&cfg.MirrorTimeout, prefix+"multi.mirror-timeout", 2*time.Second, "Timeout for storing value to secondary store.")
+	f.BoolVar(&cfg.VerifyEnabled, prefix+"multi.verify-enabled", false, "Periodically compare the values of the keys in the primary and secondary stores. The outcome is exposed by the metrics and the /multikv status page.")
+	f.DurationVar(&cfg.VerifyPeriod, prefix+"multi.verify-period", time.Minute, "How frequently the primary and secondary stores are compared, when verification is enabled.")
+	f.DurationVar(&cfg.AutoSwitchAfter, prefix+"multi.auto-switch-after", 0, "If verification is enabled and the stores have continuously matched for this duration, the secondary store becomes the primary one. The switch is not persisted: the primary store set in the runtime config, if any, takes precedence once the runtime config is reloaded. 0 to disable.")
 }
 
 // MultiRuntimeConfig has values that can change in runtime (via overrides)
@@ -91,6 +73,16 @@ type MultiClient struct {
 	mirrorTimeout    time.Duration
 	mirroringEnabled *atomic.Bool
 
+	verifyEnabled   bool
+	autoSwitchAfter time.Duration
+
+	// Keys accessed through this client, which are compared across the stores by the verification.
+	keysMtx sync.Mutex
+	keys    map[string]struct{}
+
+	verifyMtx    sync.Mutex
+	verifyStatus multiVerifyStatus
+
 	// logger with "multikv" component
 	logger log.Logger
 
@@ -105,11 +97,18 @@ type MultiClient struct {
 	// so we use this map instead.
 	inProgress    map[int]clientInProgress
 	inProgressCnt int
+
+	primaryStoreGauge     *prometheus.GaugeVec
+	mirrorEnabledGauge    prometheus.Gauge
+	mirrorWritesCounter   prometheus.Counter
+	mirrorFailuresCounter prometheus.Counter
+	verifyKeysCounter     *prometheus.CounterVec
+	autoSwitchesCounter   prometheus.Counter
 }
 
 // NewMultiClient creates new MultiClient with given KV Clients.
 // First client in the slice is the primary client.
-func NewMultiClient(cfg MultiConfig, clients []kvclient) *MultiClient {
+func NewMultiClient(cfg MultiConfig, clients []kvclient, reg prometheus.Registerer) *MultiClient {
 	c := &MultiClient{
 		clients:    clients,
 		primaryID:  atomic.NewInt32(0),
@@ -118,7 +117,40 @@ func NewMultiClient(cfg MultiConfig, clients []kvclient) *MultiClient {
 		mirrorTimeout:    cfg.MirrorTimeout,
 		mirroringEnabled: atomic.NewBool(cfg.MirrorEnabled),
 
+		verifyEnabled:   cfg.VerifyEnabled,
+		autoSwitchAfter: cfg.AutoSwitchAfter,
+		keys:            map[string]struct{}{},
+
 		logger: log.With(util_log.Logger, "component", "multikv"),
+
+		primaryStoreGauge: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_multikv_primary_store",
+			Help: "Selected primary KV store",
+		}, []string{"store"}),
+		mirrorEnabledGauge: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_multikv_mirror_enabled",
+			Help: "Is mirroring to secondary store enabled",
+		}),
+		mirrorWritesCounter: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_multikv_mirror_writes_total",
+			Help: "Number of mirror-writes to secondary store",
+		}),
+		mirrorFailuresCounter: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_multikv_mirror_write_errors_total",
+			Help: "Number of failures to mirror-write to secondary store",
+		}),
+		verifyKeysCounter: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_multikv_verify_keys_total",
+			Help: "Number of keys compared between the primary and secondary stores, by result",
+		}, []string{"result"}),
+		autoSwitchesCounter: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_multikv_auto_switches_total",
+			Help: "Number of times the primary store has been switched after the stores matched for the configured time",
+		}),
+	}
+
+	for _, result := range []string{verifyResultInSync, verifyResultDifferent, verifyResultMissingInPrimary, verifyResultMissingInSecondary, verifyResultError} {
+		c.verifyKeysCounter.WithLabelValues(result)
 	}
 
 	ctx, cancelFn := context.WithCancel(context.Background())
@@ -128,11 +160,24 @@ func NewMultiClient(cfg MultiConfig, clients []kvclient) *MultiClient {
 		go c.watchConfigChannel(ctx, cfg.ConfigProvider())
 	}
 
+	if cfg.VerifyEnabled && cfg.VerifyPeriod > 0 {
+		go c.verifyLoop(ctx, cfg.VerifyPeriod)
+	}
+
+	registerMultiClient(c)
+
 	c.updatePrimaryStoreGauge()
 	c.updateMirrorEnabledGauge()
 	return c
 }
 
+// Stop stops the background work of the client and removes it from the status page. The client
+// can still be used after being stopped, but it doesn't switch the primary store anymore.
+func (m *MultiClient) Stop() {
+	m.cancel()
+	unregisterMultiClient(m)
+}
+
 func (m *MultiClient) watchConfigChannel(ctx context.Context, configChannel <-chan MultiRuntimeConfig) {
 	for {
 		select {
@@ -213,15 +258,15 @@ func (m *MultiClient) updatePrimaryStoreGauge() {
 			value = 1
 		}
 
-		primaryStoreGauge.WithLabelValues(kv.name).Set(value)
+		m.primaryStoreGauge.WithLabelValues(kv.name).Set(value)
 	}
 }
 
 func (m *MultiClient) updateMirrorEnabledGauge() {
 	if m.mirroringEnabled.Load() {
-		mirrorEnabledGauge.Set(1)
+		m.mirrorEnabledGauge.Set(1)
 	} else {
-		mirrorEnabledGauge.Set(0)
+		m.mirrorEnabledGauge.Set(0)
 	}
 }
 
@@ -291,18 +336,21 @@ func (m *MultiClient) List(ctx context.Context, prefix string) ([]string, error)
 
 // Get is a part of kv.Client interface.
 func (m *MultiClient) Get(ctx context.Context, key string) (interface{}, error) {
+	m.trackKey(key)
 	_, kv := m.getPrimaryClient()
 	return kv.client.Get(ctx, key)
 }
 
 // Delete is a part of the kv.Client interface.
 func (m *MultiClient) Delete(ctx context.Context, key string) error {
+	m.untrackKey(key)
 	_, kv := m.getPrimaryClient()
 	return kv.client.Delete(ctx, key)
 }
 
 // CAS is a part of kv.Client interface.
 func (m *MultiClient) CAS(ctx context.Context, key string, f func(in interface{}) (out interface{}, retry bool, err error)) error {
+	m.trackKey(key)
 	_, kv := m.getPrimaryClient()
 
 	updatedValue := interface{}(nil)
@@ -321,6 +369,7 @@ func (m *MultiClient) CAS(ctx context.Context, key string, f func(in interface{}
 
 // WatchKey is a part of kv.Client interface.
 func (m *MultiClient) WatchKey(ctx context.Context, key string, f func(interface{}) bool) {
+	m.trackKey(key)
 	_ = m.runWithPrimaryClient(ctx, func(newCtx context.Context, primary kvclient) error {
 		primary.client.WatchKey(newCtx, key, f)
 		return newCtx.Err()
@@ -348,14 +397,14 @@ func (m *MultiClient) writeToSecondary(ctx context.Context, primary kvclient, ke
 			continue
 		}
 
-		mirrorWritesCounter.Inc()
+		m.mirrorWritesCounter.Inc()
 		err := kvc.client.CAS(ctx, key, func(in interface{}) (out interface{}, retry bool, err error) {
 			// try once
 			return newValue, false, nil
 		})
 
 		if err != nil {
-			mirrorFailuresCounter.Inc()
+			m.mirrorFailuresCounter.Inc()
 			level.Warn(m.logger).Log("msg", "failed to update value in secondary store", "key", key, "err", err, "primary", primary.name, "secondary", kvc.name)
 		} else {
 			level.Debug(m.logger).Log("msg", "stored updated value to secondary store", "key", key, "primary", primary.name, "secondary", kvc.name)
@@ -1,10 +1,22 @@
 package kv
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
+	"github.com/cortexproject/cortex/pkg/ring/kv/consul"
 )
 
 func boolPtr(b bool) *bool {
@@ -30,3 +42,126 @@ func TestMultiRuntimeConfigWithVariousEnabledValues(t *testing.T) {
 		})
 	}
 }
+
+func TestMultiClient_VerifyAndAutoSwitch(t *testing.T) {
+	ctx := context.Background()
+	primary := consul.NewInMemoryClient(codec.String{})
+	secondary := consul.NewInMemoryClient(codec.String{})
+	reg := prometheus.NewPedanticRegistry()
+
+	m := NewMultiClient(MultiConfig{MirrorEnabled: true, AutoSwitchAfter: time.Minute}, []kvclient{
+		{client: primary, name: "primary"},
+		{client: secondary, name: "secondary"},
+	}, reg)
+
+	set := func(t *testing.T, c Client, value string) {
+		require.NoError(t, c.CAS(ctx, "key", func(in interface{}) (interface{}, bool, error) {
+			return value, false, nil
+		}))
+	}
+
+	verify := func(t *testing.T, now time.Time, expectedResult string) multiClientStatus {
+		m.verify(ctx, now)
+		status := m.status()
+		require.Len(t, status.Keys, 1)
+		assert.Equal(t, multiVerifyKeyResult{Key: "key", Secondary: "secondary", Result: expectedResult}, status.Keys[0])
+		return status
+	}
+
+	// Stores are not in sync until a key has been compared.
+	now := time.Now()
+	m.verify(ctx, now)
+	assert.Nil(t, m.status().InSyncSince)
+
+	// Values written through the multi client are mirrored.
+	set(t, m, "value")
+	status := verify(t, now, verifyResultInSync)
+	require.NotNil(t, status.InSyncSince)
+	assert.Equal(t, now, *status.InSyncSince)
+
+	set(t, secondary, "different")
+	status = verify(t, now.Add(10*time.Second), verifyResultDifferent)
+	assert.Nil(t, status.InSyncSince)
+
+	require.NoError(t, secondary.Delete(ctx, "key"))
+	verify(t, now.Add(20*time.Second), verifyResultMissingInSecondary)
+
+	// Once the stores have matched for the configured time, the secondary store becomes the primary one.
+	set(t, m, "value")
+	status = verify(t, now.Add(30*time.Second), verifyResultInSync)
+	assert.Equal(t, "primary", status.Primary)
+
+	status = verify(t, now.Add(90*time.Second), verifyResultInSync)
+	assert.Equal(t, "secondary", status.Primary)
+	assert.Nil(t, status.InSyncSince)
+
+	// The new primary is then compared with the previous one, and never switched back.
+	m.verify(ctx, now.Add(200*time.Second))
+	status = m.status()
+	assert.Equal(t, "secondary", status.Primary)
+	assert.Equal(t, []multiVerifyKeyResult{{Key: "key", Secondary: "primary", Result: verifyResultInSync}}, status.Keys)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_multikv_primary_store Selected primary KV store
+		# TYPE cortex_multikv_primary_store gauge
+		cortex_multikv_primary_store{store="primary"} 0
+		cortex_multikv_primary_store{store="secondary"} 1
+
+		# HELP cortex_multikv_auto_switches_total Number of times the primary store has been switched after the stores matched for the configured time
+		# TYPE cortex_multikv_auto_switches_total counter
+		cortex_multikv_auto_switches_total 1
+
+		# HELP cortex_multikv_verify_keys_total Number of keys compared between the primary and secondary stores, by result
+		# TYPE cortex_multikv_verify_keys_total counter
+		cortex_multikv_verify_keys_total{result="different"} 1
+		cortex_multikv_verify_keys_total{result="error"} 0
+		cortex_multikv_verify_keys_total{result="in-sync"} 4
+		cortex_multikv_verify_keys_total{result="missing-in-primary"} 0
+		cortex_multikv_verify_keys_total{result="missing-in-secondary"} 1
+	`), "cortex_multikv_primary_store", "cortex_multikv_auto_switches_total", "cortex_multikv_verify_keys_total"))
+}
+
+func TestMultiClientsStatusHandler(t *testing.T) {
+	m := NewMultiClient(MultiConfig{MirrorEnabled: true}, []kvclient{
+		{client: consul.NewInMemoryClient(codec.String{}), name: "status-primary"},
+		{client: consul.NewInMemoryClient(codec.String{}), name: "status-secondary"},
+	}, nil)
+	m.verify(context.Background(), time.Now())
+
+	req := httptest.NewRequest(http.MethodGet, "/multikv", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	MultiClientsStatusHandler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Clients []multiClientStatus `json:"clients"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	found := false
+	for _, c := range resp.Clients {
+		if c.Primary == "status-primary" {
+			found = true
+			assert.Equal(t, []string{"status-primary", "status-secondary"}, c.Stores)
+			assert.True(t, c.MirrorEnabled)
+			assert.NotNil(t, c.LastVerification)
+		}
+	}
+	assert.True(t, found)
+
+	// The HTML page is rendered too.
+	w = httptest.NewRecorder()
+	MultiClientsStatusHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/multikv", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "status-secondary")
+
+	// Stopped clients aren't listed anymore, even if wrapped.
+	StopClient(PrefixClient(m, "prefix/"))
+	StopClient(m)
+
+	w = httptest.NewRecorder()
+	MultiClientsStatusHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/multikv", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "status-secondary")
+}
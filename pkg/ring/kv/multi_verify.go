@@ -0,0 +1,318 @@
+package kv
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+const (
+	verifyResultInSync             = "in-sync"
+	verifyResultDifferent          = "different"
+	verifyResultMissingInPrimary   = "missing-in-primary"
+	verifyResultMissingInSecondary = "missing-in-secondary"
+	verifyResultError              = "error"
+)
+
+var (
+	// All the MultiClients created in the process, listed by the status page.
+	multiClientsMtx sync.Mutex
+	multiClients    []*MultiClient
+)
+
+func registerMultiClient(c *MultiClient) {
+	multiClientsMtx.Lock()
+	defer multiClientsMtx.Unlock()
+
+	multiClients = append(multiClients, c)
+}
+
+func unregisterMultiClient(c *MultiClient) {
+	multiClientsMtx.Lock()
+	defer multiClientsMtx.Unlock()
+
+	for i, other := range multiClients {
+		if other == c {
+			multiClients = append(multiClients[:i], multiClients[i+1:]...)
+			return
+		}
+	}
+}
+
+// multiVerifyStatus is the outcome of the last comparison between the stores.
+type multiVerifyStatus struct {
+	LastRun     time.Time
+	InSyncSince time.Time
+	Keys        []multiVerifyKeyResult
+}
+
+type multiVerifyKeyResult struct {
+	Key       string `json:"key"`
+	Secondary string `json:"secondary"`
+	Result    string `json:"result"`
+	Error     string `json:"error,omitempty"`
+}
+
+func (m *MultiClient) trackKey(key string) {
+	m.keysMtx.Lock()
+	defer m.keysMtx.Unlock()
+
+	m.keys[key] = struct{}{}
+}
+
+func (m *MultiClient) untrackKey(key string) {
+	m.keysMtx.Lock()
+	defer m.keysMtx.Unlock()
+
+	delete(m.keys, key)
+}
+
+func (m *MultiClient) trackedKeys() []string {
+	m.keysMtx.Lock()
+	defer m.keysMtx.Unlock()
+
+	keys := make([]string, 0, len(m.keys))
+	for key := range m.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (m *MultiClient) verifyLoop(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.verify(ctx, time.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// verify compares the values of the tracked keys in the primary and secondary stores and, if
+// auto switching is enabled and the stores have matched for long enough, switches the primary
+// store from the configured primary to the secondary one.
+func (m *MultiClient) verify(ctx context.Context, now time.Time) {
+	primaryID, primary := m.getPrimaryClient()
+	keys := m.trackedKeys()
+
+	// Stores can't be considered in sync until at least a key has been compared.
+	inSync := len(keys) > 0
+	results := make([]multiVerifyKeyResult, 0, len(keys))
+
+	for _, key := range keys {
+		for id, secondary := range m.clients {
+			if id == primaryID {
+				continue
+			}
+
+			result := compareKey(ctx, primary, secondary, key)
+			m.verifyKeysCounter.WithLabelValues(result.Result).Inc()
+			if result.Result != verifyResultInSync {
+				inSync = false
+				level.Debug(m.logger).Log("msg", "key differs between the KV stores", "key", key, "primary", primary.name, "secondary", secondary.name, "result", result.Result, "err", result.Error)
+			}
+			results = append(results, result)
+		}
+	}
+
+	m.verifyMtx.Lock()
+	m.verifyStatus.LastRun = now
+	m.verifyStatus.Keys = results
+	if !inSync {
+		m.verifyStatus.InSyncSince = time.Time{}
+	} else if m.verifyStatus.InSyncSince.IsZero() {
+		m.verifyStatus.InSyncSince = now
+	}
+
+	// We only switch away from the configured primary store, so that the stores never flip-flop.
+	switchTo := ""
+	if inSync && m.autoSwitchAfter > 0 && primaryID == 0 && now.Sub(m.verifyStatus.InSyncSince) >= m.autoSwitchAfter {
+		switchTo = m.clients[1].name
+	}
+	m.verifyMtx.Unlock()
+
+	if switchTo == "" {
+		return
+	}
+
+	switched, err := m.setNewPrimaryClient(switchTo)
+	if err != nil {
+		level.Error(m.logger).Log("msg", "failed to switch primary KV store", "primary", switchTo, "err", err)
+		return
+	}
+	if switched {
+		level.Info(m.logger).Log("msg", "switched primary KV store after the stores matched", "primary", switchTo, "matched_for", m.autoSwitchAfter)
+		m.autoSwitchesCounter.Inc()
+
+		// The stores are now compared the other way round.
+		m.verifyMtx.Lock()
+		m.verifyStatus.InSyncSince = time.Time{}
+		m.verifyMtx.Unlock()
+	}
+}
+
+func compareKey(ctx context.Context, primary, secondary kvclient, key string) multiVerifyKeyResult {
+	result := multiVerifyKeyResult{Key: key, Secondary: secondary.name}
+
+	primaryValue, err := primary.client.Get(ctx, key)
+	if err == nil {
+		var secondaryValue interface{}
+		if secondaryValue, err = secondary.client.Get(ctx, key); err == nil {
+			result.Result = compareValues(primaryValue, secondaryValue)
+			return result
+		}
+	}
+
+	result.Result = verifyResultError
+	result.Error = err.Error()
+	return result
+}
+
+func compareValues(primary, secondary interface{}) string {
+	switch {
+	case primary == nil && secondary == nil:
+		return verifyResultInSync
+	case primary == nil:
+		return verifyResultMissingInPrimary
+	case secondary == nil:
+		return verifyResultMissingInSecondary
+	}
+
+	// Generated protobuf messages implement Equal(), which is aware of their semantics.
+	if eq, ok := primary.(interface{ Equal(interface{}) bool }); ok {
+		if eq.Equal(secondary) {
+			return verifyResultInSync
+		}
+		return verifyResultDifferent
+	}
+
+	if reflect.DeepEqual(primary, secondary) {
+		return verifyResultInSync
+	}
+	return verifyResultDifferent
+}
+
+type multiClientStatus struct {
+	Primary          string                 `json:"primary"`
+	Stores           []string               `json:"stores"`
+	MirrorEnabled    bool                   `json:"mirror_enabled"`
+	VerifyEnabled    bool                   `json:"verify_enabled"`
+	AutoSwitchAfter  string                 `json:"auto_switch_after"`
+	LastVerification *time.Time             `json:"last_verification,omitempty"`
+	InSyncSince      *time.Time             `json:"in_sync_since,omitempty"`
+	Keys             []multiVerifyKeyResult `json:"keys"`
+}
+
+func (m *MultiClient) status() multiClientStatus {
+	_, primary := m.getPrimaryClient()
+
+	s := multiClientStatus{
+		Primary:         primary.name,
+		MirrorEnabled:   m.mirroringEnabled.Load(),
+		VerifyEnabled:   m.verifyEnabled,
+		AutoSwitchAfter: m.autoSwitchAfter.String(),
+	}
+	for _, c := range m.clients {
+		s.Stores = append(s.Stores, c.name)
+	}
+
+	m.verifyMtx.Lock()
+	defer m.verifyMtx.Unlock()
+
+	if !m.verifyStatus.LastRun.IsZero() {
+		lastRun := m.verifyStatus.LastRun
+		s.LastVerification = &lastRun
+	}
+	if !m.verifyStatus.InSyncSince.IsZero() {
+		inSyncSince := m.verifyStatus.InSyncSince
+		s.InSyncSince = &inSyncSince
+	}
+	s.Keys = append([]multiVerifyKeyResult{}, m.verifyStatus.Keys...)
+	return s
+}
+
+const multiStatusPageContent = `
+<!DOCTYPE html>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<title>Cortex Multi KV Status</title>
+	</head>
+	<body>
+		<h1>Cortex Multi KV Status</h1>
+		<p>Current time: {{ .Now }}</p>
+		{{ if not .Clients }}
+			<p>No multi KV store is in use.</p>
+		{{ end }}
+		{{ range $i, $c := .Clients }}
+			<h2>Multi KV client #{{ $i }}</h2>
+			<p>
+				Stores: {{ range $c.Stores }}{{ . }} {{ end }}<br />
+				Primary store: {{ $c.Primary }}<br />
+				Mirroring enabled: {{ $c.MirrorEnabled }}<br />
+				Verification enabled: {{ $c.VerifyEnabled }}<br />
+				Auto switch after: {{ $c.AutoSwitchAfter }}<br />
+				Last verification: {{ if $c.LastVerification }}{{ $c.LastVerification }}{{ else }}never{{ end }}<br />
+				Stores in sync since: {{ if $c.InSyncSince }}{{ $c.InSyncSince }}{{ else }}not in sync{{ end }}
+			</p>
+			<table width="100%" border="1">
+				<thead>
+					<tr>
+						<th>Key</th>
+						<th>Compared with</th>
+						<th>Result</th>
+						<th>Error</th>
+					</tr>
+				</thead>
+				<tbody>
+					{{ range $k := $c.Keys }}
+					<tr>
+						<td>{{ $k.Key }}</td>
+						<td>{{ $k.Secondary }}</td>
+						<td>{{ $k.Result }}</td>
+						<td>{{ $k.Error }}</td>
+					</tr>
+					{{ end }}
+				</tbody>
+			</table>
+		{{ end }}
+	</body>
+</html>`
+
+var multiStatusPageTemplate = template.Must(template.New("webpage").Parse(multiStatusPageContent))
+
+// MultiClientsStatusHandler returns an HTTP handler rendering the status of all the multi KV
+// clients in the process, including the outcome of the last comparison between their stores.
+func MultiClientsStatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		multiClientsMtx.Lock()
+		clients := append([]*MultiClient{}, multiClients...)
+		multiClientsMtx.Unlock()
+
+		statuses := make([]multiClientStatus, 0, len(clients))
+		for _, c := range clients {
+			statuses = append(statuses, c.status())
+		}
+
+		util.RenderHTTPResponse(w, struct {
+			Now     time.Time           `json:"now"`
+			Clients []multiClientStatus `json:"clients"`
+		}{
+			Now:     time.Now(),
+			Clients: statuses,
+		}, multiStatusPageTemplate, r)
+	})
+}
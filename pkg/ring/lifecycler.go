@@ -36,6 +36,10 @@ var (
 		Name: "cortex_member_ring_tokens_to_own",
 		Help: "The number of tokens to own in the ring.",
 	}, []string{"name"})
+	tokensRebalanced = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_member_ring_tokens_rebalanced_total",
+		Help: "The total number of tokens moved to rebalance the ring ownership.",
+	}, []string{"name"})
 	shutdownDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "cortex_shutdown_duration_seconds",
 		Help:    "Duration (in seconds) of cortex shutdown procedure (ie transfer or flush).",
@@ -59,6 +63,12 @@ type LifecyclerConfig struct {
 	Zone                 string        `yaml:"availability_zone"`
 	UnregisterOnShutdown bool          `yaml:"unregister_on_shutdown"`
 
+	TokensRebalanceEnabled   bool          `yaml:"tokens_rebalance_enabled"`
+	TokensRebalancePeriod    time.Duration `yaml:"tokens_rebalance_period"`
+	TokensRebalanceThreshold float64       `yaml:"tokens_rebalance_threshold"`
+
+	TokenGenerator TokenGeneratorConfig `yaml:",inline"`
+
 	// For testing, you can override the address and ID of this ingester
 	Addr string `yaml:"address" doc:"hidden"`
 	Port int    `doc:"hidden"`
@@ -104,6 +114,15 @@ func (cfg *LifecyclerConfig) RegisterFlagsWithPrefix(prefix string, f *flag.Flag
 	f.StringVar(&cfg.ID, prefix+"lifecycler.ID", hostname, "ID to register in the ring.")
 	f.StringVar(&cfg.Zone, prefix+"availability-zone", "", "The availability zone where this instance is running.")
 	f.BoolVar(&cfg.UnregisterOnShutdown, prefix+"unregister-on-shutdown", true, "Unregister from the ring upon clean shutdown. It can be useful to disable for rolling restarts with consistent naming in conjunction with -distributor.extend-writes=false.")
+	f.BoolVar(&cfg.TokensRebalanceEnabled, prefix+"tokens-rebalance-enabled", false, "Experimental: True to periodically move one of the instance tokens when it owns more than its share of the ring (computed within its zone if zone-awareness is enabled), in order to even out the ownership without restarting instances.")
+	f.DurationVar(&cfg.TokensRebalancePeriod, prefix+"tokens-rebalance-period", 15*time.Minute, "How frequently a token is moved when tokens rebalancing is enabled.")
+	f.Float64Var(&cfg.TokensRebalanceThreshold, prefix+"tokens-rebalance-threshold", 0.1, "Tokens are rebalanced when the instance owns more than its ideal share of the ring plus this fraction of it (eg. 0.1 for 10%).")
+	cfg.TokenGenerator.RegisterFlagsWithPrefix(prefix, f)
+}
+
+// Validate the config.
+func (cfg *LifecyclerConfig) Validate() error {
+	return cfg.TokenGenerator.Validate(cfg.Zone)
 }
 
 // Lifecycler is responsible for managing the lifecycle of entries in the ring.
@@ -112,6 +131,7 @@ type Lifecycler struct {
 
 	cfg             LifecyclerConfig
 	flushTransferer FlushTransferer
+	tokenGenerator  TokenGenerator
 	KVStore         kv.Client
 
 	actorChan chan func()
@@ -168,6 +188,11 @@ func NewLifecycler(cfg LifecyclerConfig, flushTransferer FlushTransferer, ringNa
 		log.WarnExperimentalUse("Zone aware replication")
 	}
 
+	tokenGenerator, err := cfg.TokenGenerator.NewTokenGenerator(cfg.ID, zone)
+	if err != nil {
+		return nil, err
+	}
+
 	// We do allow a nil FlushTransferer, but to keep the ring logic easier we assume
 	// it's always set, so we use a noop FlushTransferer
 	if flushTransferer == nil {
@@ -177,6 +202,7 @@ func NewLifecycler(cfg LifecyclerConfig, flushTransferer FlushTransferer, ringNa
 	l := &Lifecycler{
 		cfg:             cfg,
 		flushTransferer: flushTransferer,
+		tokenGenerator:  tokenGenerator,
 		KVStore:         store,
 
 		Addr:                 fmt.Sprintf("%s:%d", addr, port),
@@ -396,6 +422,13 @@ func (i *Lifecycler) loop(ctx context.Context) error {
 	heartbeatTickerStop, heartbeatTickerChan := util.NewDisableableTicker(i.cfg.HeartbeatPeriod)
 	defer heartbeatTickerStop()
 
+	var rebalancePeriod time.Duration
+	if i.cfg.TokensRebalanceEnabled {
+		rebalancePeriod = i.cfg.TokensRebalancePeriod
+	}
+	rebalanceTickerStop, rebalanceTickerChan := util.NewDisableableTicker(rebalancePeriod)
+	defer rebalanceTickerStop()
+
 	for {
 		select {
 		case <-autoJoinAfter:
@@ -449,6 +482,15 @@ func (i *Lifecycler) loop(ctx context.Context) error {
 				level.Error(log.Logger).Log("msg", "failed to write to the KV store, sleeping", "ring", i.RingName, "err", err)
 			}
 
+		case <-rebalanceTickerChan:
+			if i.GetState() != ACTIVE {
+				continue
+			}
+
+			if err := i.rebalanceTokens(context.Background()); err != nil {
+				level.Error(log.Logger).Log("msg", "failed to rebalance tokens", "ring", i.RingName, "err", err)
+			}
+
 		case f := <-i.actorChan:
 			f()
 
@@ -464,6 +506,8 @@ func (i *Lifecycler) loop(ctx context.Context) error {
 // - otherwise, flush chunks to the chunk store.
 // - remove config from Consul.
 func (i *Lifecycler) stopping(runningError error) error {
+	defer kv.StopClient(i.KVStore)
+
 	if runningError != nil {
 		// previously lifecycler just called os.Exit (from loop method)...
 		// now it stops more gracefully, but also without doing any cleanup
@@ -473,10 +517,13 @@ func (i *Lifecycler) stopping(runningError error) error {
 	heartbeatTickerStop, heartbeatTickerChan := util.NewDisableableTicker(i.cfg.HeartbeatPeriod)
 	defer heartbeatTickerStop()
 
-	// Mark ourselved as Leaving so no more samples are send to us.
-	err := i.changeState(context.Background(), LEAVING)
-	if err != nil {
-		level.Error(log.Logger).Log("msg", "failed to set state to LEAVING", "ring", i.RingName, "err", err)
+	// Mark ourselved as Leaving so no more samples are send to us. The instance
+	// may already be LEAVING if it has been decommissioned.
+	if i.GetState() != LEAVING {
+		err := i.changeState(context.Background(), LEAVING)
+		if err != nil {
+			level.Error(log.Logger).Log("msg", "failed to set state to LEAVING", "ring", i.RingName, "err", err)
+		}
 	}
 
 	// Do the transferring / flushing on a background goroutine so we can continue
@@ -622,7 +669,10 @@ func (i *Lifecycler) verifyTokens(ctx context.Context) bool {
 			needTokens := i.cfg.NumTokens - len(ringTokens)
 
 			level.Info(log.Logger).Log("msg", "generating new tokens", "count", needTokens, "ring", i.RingName)
-			newTokens := GenerateTokens(needTokens, takenTokens)
+			newTokens := i.tokenGenerator.GenerateTokens(needTokens, takenTokens)
+			if err := i.tokenGenerator.CanJoin(newTokens, takenTokens); err != nil {
+				return nil, false, err
+			}
 
 			ringTokens = append(ringTokens, newTokens...)
 			sort.Sort(ringTokens)
@@ -665,7 +715,7 @@ func (i *Lifecycler) compareTokens(fromRing Tokens) bool {
 	return true
 }
 
-// autoJoin selects random tokens & moves state to targetState
+// autoJoin selects tokens & moves state to targetState
 func (i *Lifecycler) autoJoin(ctx context.Context, targetState InstanceState) error {
 	var ringDesc *Desc
 
@@ -682,7 +732,10 @@ func (i *Lifecycler) autoJoin(ctx context.Context, targetState InstanceState) er
 			level.Error(log.Logger).Log("msg", "tokens already exist for this instance - wasn't expecting any!", "num_tokens", len(myTokens), "ring", i.RingName)
 		}
 
-		newTokens := GenerateTokens(i.cfg.NumTokens-len(myTokens), takenTokens)
+		newTokens := i.tokenGenerator.GenerateTokens(i.cfg.NumTokens-len(myTokens), takenTokens)
+		if err := i.tokenGenerator.CanJoin(newTokens, takenTokens); err != nil {
+			return nil, false, err
+		}
 		i.setState(targetState)
 
 		myTokens = append(myTokens, newTokens...)
@@ -739,6 +792,97 @@ func (i *Lifecycler) updateConsul(ctx context.Context) error {
 	return err
 }
 
+// rebalanceTokens moves one of our tokens if we own more than our share of the ring.
+// NB this must be called from loop()!
+func (i *Lifecycler) rebalanceTokens(ctx context.Context) error {
+	in, err := i.KVStore.Get(ctx, i.RingKey)
+	if err != nil || in == nil {
+		return err
+	}
+
+	zoneAwarenessEnabled := i.cfg.RingConfig.ZoneAwarenessEnabled && i.Zone != ""
+	from, to, ok := planTokenRebalance(in.(*Desc), i.ID, zoneAwarenessEnabled, i.cfg.TokensRebalanceThreshold, i.cfg.RingConfig.HeartbeatTimeout, time.Now())
+	if !ok {
+		return nil
+	}
+
+	// Give the chance to persist the data belonging to the range we're going to give away:
+	// moving the token backward hands off the keys between its new and old value.
+	if flusher, ok := i.flushTransferer.(TokensRebalanceFlusher); ok {
+		if err := i.flushBeforeTokensRebalance(ctx, flusher, to, from); err != nil {
+			return perrors.Wrap(err, "failed to flush before rebalancing tokens")
+		}
+	}
+
+	var tokens Tokens
+	err = i.KVStore.CAS(ctx, i.RingKey, func(in interface{}) (out interface{}, retry bool, err error) {
+		tokens = nil
+		if in == nil {
+			return nil, false, fmt.Errorf("found empty ring when trying to rebalance tokens")
+		}
+
+		ringDesc := in.(*Desc)
+		instanceDesc, ok := ringDesc.Ingesters[i.ID]
+		if !ok {
+			return nil, false, fmt.Errorf("instance not found in the ring when trying to rebalance tokens")
+		}
+
+		// The ring may have changed in the meanwhile, so we check the move is still valid.
+		if !tokensContain(instanceDesc.Tokens, from) || tokensContain(ringDesc.GetTokens(), to) {
+			return nil, false, nil
+		}
+
+		tokens = make(Tokens, 0, len(instanceDesc.Tokens))
+		for _, token := range instanceDesc.Tokens {
+			if token != from {
+				tokens = append(tokens, token)
+			}
+		}
+		tokens = append(tokens, to)
+		sort.Sort(tokens)
+
+		instanceDesc.Tokens = tokens
+		instanceDesc.Timestamp = time.Now().Unix()
+		ringDesc.Ingesters[i.ID] = instanceDesc
+		return ringDesc, true, nil
+	})
+	if err != nil || tokens == nil {
+		return err
+	}
+
+	level.Info(log.Logger).Log("msg", "moved token to rebalance the ring ownership", "ring", i.RingName, "from", from, "to", to)
+	tokensRebalanced.WithLabelValues(i.RingName).Inc()
+	i.setTokens(tokens)
+	return nil
+}
+
+// flushBeforeTokensRebalance waits until the flusher has persisted the in-memory data in the
+// [start, end) token range, while keeping to heartbeat the ring, given the flush may take
+// longer than the heartbeat timeout.
+// NB this must be called from loop()!
+func (i *Lifecycler) flushBeforeTokensRebalance(ctx context.Context, flusher TokensRebalanceFlusher, start, end uint32) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- flusher.FlushBeforeTokensRebalance(ctx, start, end)
+	}()
+
+	heartbeatTickerStop, heartbeatTickerChan := util.NewDisableableTicker(i.cfg.HeartbeatPeriod)
+	defer heartbeatTickerStop()
+
+	for {
+		select {
+		case err := <-done:
+			return err
+
+		case <-heartbeatTickerChan:
+			consulHeartbeats.WithLabelValues(i.RingName).Inc()
+			if err := i.updateConsul(ctx); err != nil {
+				level.Error(log.Logger).Log("msg", "failed to write to the KV store while flushing before rebalancing tokens", "ring", i.RingName, "err", err)
+			}
+		}
+	}
+}
+
 // changeState updates consul with state transitions for us.  NB this must be
 // called from loop()!  Use ChangeState for calls from outside of loop().
 func (i *Lifecycler) changeState(ctx context.Context, state InstanceState) error {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"sort"
 	"testing"
@@ -557,3 +558,131 @@ func TestRestoreOfZoneWhenOverwritten(t *testing.T) {
 
 	})
 }
+
+type rebalanceFlushTransferer struct {
+	noopFlushTransferer
+	flushes    int
+	start, end uint32
+
+	// If set, the flush blocks until it's closed.
+	release chan struct{}
+}
+
+func (f *rebalanceFlushTransferer) FlushBeforeTokensRebalance(_ context.Context, start, end uint32) error {
+	f.flushes++
+	f.start, f.end = start, end
+	if f.release != nil {
+		<-f.release
+	}
+	return nil
+}
+
+func TestLifecycler_RebalanceTokens(t *testing.T) {
+	var ringConfig Config
+	flagext.DefaultValues(&ringConfig)
+	ringConfig.KVStore.Mock = consul.NewInMemoryClient(GetCodec())
+
+	ctx := context.Background()
+	now := time.Now()
+
+	// instance-1 owns 3/4 of the ring, while instance-2 owns 1/4.
+	require.NoError(t, ringConfig.KVStore.Mock.CAS(ctx, IngesterRingKey, func(in interface{}) (interface{}, bool, error) {
+		desc := NewDesc()
+		desc.AddIngester("instance-1", "127.0.0.1", "zone1", []uint32{1 << 30, 3 << 30}, ACTIVE, now)
+		desc.AddIngester("instance-2", "127.0.0.2", "zone1", []uint32{(3 << 30) + (1 << 29)}, ACTIVE, now)
+		return desc, true, nil
+	}))
+
+	cfg := testLifecyclerConfig(ringConfig, "instance-1")
+	cfg.TokensRebalanceEnabled = true
+	flusher := &rebalanceFlushTransferer{}
+
+	l, err := NewLifecycler(cfg, flusher, "ingester", IngesterRingKey, true, nil)
+	require.NoError(t, err)
+	l.setState(ACTIVE)
+
+	require.NoError(t, l.rebalanceTokens(ctx))
+	assert.Equal(t, 1, flusher.flushes)
+
+	in, err := ringConfig.KVStore.Mock.Get(ctx, IngesterRingKey)
+	require.NoError(t, err)
+	desc := in.(*Desc)
+
+	// The flushed range is the one between the new and the old value of the moved token.
+	assert.Equal(t, uint32(3<<30), flusher.end)
+	assert.Contains(t, desc.Ingesters["instance-1"].Tokens, flusher.start)
+	assert.Equal(t, Tokens(desc.Ingesters["instance-1"].Tokens), l.getTokens())
+	assert.Contains(t, desc.Ingesters["instance-1"].Tokens, uint32(1<<30))
+	assert.NotContains(t, desc.Ingesters["instance-1"].Tokens, uint32(3<<30))
+	assert.True(t, sort.IsSorted(Tokens(desc.Ingesters["instance-1"].Tokens)))
+
+	_, owned := tokensOwnership(desc.GetTokens(), desc.getTokensInfo())
+	assert.Less(t, owned["instance-1"], uint32(3<<30))
+}
+
+func TestLifecycler_RebalanceTokensWaitsForFlush(t *testing.T) {
+	var ringConfig Config
+	flagext.DefaultValues(&ringConfig)
+	ringConfig.KVStore.Mock = consul.NewInMemoryClient(GetCodec())
+
+	ctx := context.Background()
+	lastHeartbeat := time.Now().Add(-time.Minute)
+
+	// instance-1 owns 3/4 of the ring, while instance-2 owns 1/4.
+	require.NoError(t, ringConfig.KVStore.Mock.CAS(ctx, IngesterRingKey, func(in interface{}) (interface{}, bool, error) {
+		desc := NewDesc()
+		desc.AddIngester("instance-1", "127.0.0.1", "zone1", []uint32{1 << 30, 3 << 30}, ACTIVE, lastHeartbeat)
+		desc.AddIngester("instance-2", "127.0.0.2", "zone1", []uint32{(3 << 30) + (1 << 29)}, ACTIVE, time.Now())
+		return desc, true, nil
+	}))
+
+	cfg := testLifecyclerConfig(ringConfig, "instance-1")
+	cfg.TokensRebalanceEnabled = true
+	cfg.HeartbeatPeriod = 10 * time.Millisecond
+	flusher := &rebalanceFlushTransferer{release: make(chan struct{})}
+
+	l, err := NewLifecycler(cfg, flusher, "ingester", IngesterRingKey, true, nil)
+	require.NoError(t, err)
+	l.setState(ACTIVE)
+
+	done := make(chan error)
+	go func() {
+		done <- l.rebalanceTokens(ctx)
+	}()
+
+	getInstance := func() InstanceDesc {
+		in, err := ringConfig.KVStore.Mock.Get(ctx, IngesterRingKey)
+		require.NoError(t, err)
+		return in.(*Desc).Ingesters["instance-1"]
+	}
+
+	// The instance keeps heartbeating the ring, but doesn't move the token until the flush is done.
+	test.Poll(t, time.Second, true, func() interface{} {
+		return getInstance().Timestamp > lastHeartbeat.Unix()
+	})
+	assert.Equal(t, []uint32{1 << 30, 3 << 30}, getInstance().Tokens)
+
+	select {
+	case <-done:
+		t.Fatal("tokens rebalanced before the flush completed")
+	default:
+	}
+
+	close(flusher.release)
+	require.NoError(t, <-done)
+	assert.Equal(t, 1, flusher.flushes)
+	assert.NotContains(t, getInstance().Tokens, uint32(3<<30))
+}
+
+func TestTokenInRange(t *testing.T) {
+	assert.True(t, TokenInRange(10, 10, 20))
+	assert.True(t, TokenInRange(19, 10, 20))
+	assert.False(t, TokenInRange(20, 10, 20))
+	assert.False(t, TokenInRange(9, 10, 20))
+
+	// The range wraps around the ring.
+	assert.True(t, TokenInRange(math.MaxUint32, math.MaxUint32-10, 10))
+	assert.True(t, TokenInRange(0, math.MaxUint32-10, 10))
+	assert.False(t, TokenInRange(10, math.MaxUint32-10, 10))
+	assert.False(t, TokenInRange(math.MaxUint32-11, math.MaxUint32-10, 10))
+}
@@ -0,0 +1,171 @@
+package ring
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// tokensOwnership returns, for each instance, the number of tokens and the size of the
+// token ranges it owns among the input sorted tokens. Consistently with Ring.Get(), each
+// token owns the range between the previous token (included) and itself (excluded).
+func tokensOwnership(tokens []uint32, instanceByToken map[uint32]instanceInfo) (numTokens, owned map[string]uint32) {
+	numTokens = map[string]uint32{}
+	owned = map[string]uint32{}
+
+	for i, token := range tokens {
+		info := instanceByToken[token]
+		numTokens[info.InstanceID]++
+		owned[info.InstanceID] += tokenRangeSize(tokens, i)
+	}
+
+	return numTokens, owned
+}
+
+// tokenRangeSize returns the size of the range owned by the i-th token of the sorted tokens.
+func tokenRangeSize(tokens []uint32, i int) uint32 {
+	if len(tokens) == 1 {
+		return math.MaxUint32
+	}
+
+	// The unsigned subtraction wraps around the ring for the first token.
+	prev := tokens[(i+len(tokens)-1)%len(tokens)]
+	return tokens[i] - prev
+}
+
+// ZoneOwnership summarises the ownership of the instances within a zone. The ownership of
+// each instance in the zone is computed on the zone's tokens only, because each zone holds
+// a full replica of the data when zone-awareness is enabled.
+type ZoneOwnership struct {
+	Zone      string `json:"zone"`
+	Instances int    `json:"instances"`
+
+	// Min and max ownership of the instances within the zone, in percent.
+	MinOwnership float64 `json:"min_ownership"`
+	MaxOwnership float64 `json:"max_ownership"`
+
+	// How much the most loaded instance exceeds the ideal ownership, in percent.
+	Imbalance float64 `json:"imbalance"`
+}
+
+// zonesOwnership returns the ownership of each instance within its zone, in percent, and the
+// ownership summary of each zone, sorted by zone.
+func (r *Ring) zonesOwnership() (map[string]float64, []ZoneOwnership) {
+	ownership := map[string]float64{}
+	zones := make([]ZoneOwnership, 0, len(r.ringTokensByZone))
+
+	for zone, tokens := range r.ringTokensByZone {
+		_, owned := tokensOwnership(tokens, r.ringInstanceByToken)
+		if len(owned) == 0 {
+			continue
+		}
+
+		summary := ZoneOwnership{Zone: zone, Instances: len(owned), MinOwnership: 100}
+		for id, size := range owned {
+			percent := (float64(size) / float64(math.MaxUint32)) * 100
+			ownership[id] = percent
+			summary.MinOwnership = math.Min(summary.MinOwnership, percent)
+			summary.MaxOwnership = math.Max(summary.MaxOwnership, percent)
+		}
+
+		ideal := 100 / float64(len(owned))
+		summary.Imbalance = ((summary.MaxOwnership - ideal) / ideal) * 100
+		zones = append(zones, summary)
+	}
+
+	sort.Slice(zones, func(i, j int) bool { return zones[i].Zone < zones[j].Zone })
+	return ownership, zones
+}
+
+// planTokenRebalance looks for a token of the instance which can be moved in order to give part of its
+// owned range to an instance owning less, when the instance owns more than the ideal share of the ring
+// plus the given threshold (eg. 0.1 for 10%). If zone-awareness is enabled, the ownership is computed
+// within the instance's zone. No token is moved while any instance taken into account is not ACTIVE
+// or healthy. Returns the token to move and its new value.
+func planTokenRebalance(desc *Desc, instanceID string, zoneAwarenessEnabled bool, threshold float64, heartbeatTimeout time.Duration, now time.Time) (from, to uint32, ok bool) {
+	instance, exists := desc.Ingesters[instanceID]
+	if !exists || len(instance.Tokens) == 0 {
+		return 0, 0, false
+	}
+
+	var tokens []uint32
+	if zoneAwarenessEnabled {
+		tokens = desc.getTokensByZone()[instance.Zone]
+	} else {
+		tokens = desc.GetTokens()
+	}
+
+	instanceByToken := desc.getTokensInfo()
+	_, owned := tokensOwnership(tokens, instanceByToken)
+	if len(owned) < 2 {
+		return 0, 0, false
+	}
+
+	for id := range owned {
+		peer := desc.Ingesters[id]
+		if peer.State != ACTIVE || !peer.IsHealthy(Write, heartbeatTimeout, now) {
+			return 0, 0, false
+		}
+	}
+
+	ideal := float64(math.MaxUint32) / float64(len(owned))
+	if float64(owned[instanceID]) <= ideal*(1+threshold) {
+		return 0, 0, false
+	}
+	excess := owned[instanceID] - uint32(ideal)
+
+	// Moving a token backward gives the range between its new and old value to the owner
+	// of the next token. We pick the token giving the largest range to an instance owning
+	// less than us, giving at most half of the difference, so that the ownership of the
+	// instances evens out over the time.
+	var shift uint32
+	for i, token := range tokens {
+		if instanceByToken[token].InstanceID != instanceID {
+			continue
+		}
+
+		receiver := instanceByToken[tokens[(i+1)%len(tokens)]].InstanceID
+		if receiver == instanceID || owned[receiver] >= owned[instanceID] {
+			continue
+		}
+
+		candidate := tokenRangeSize(tokens, i) / 2
+		if candidate > excess {
+			candidate = excess
+		}
+		if diff := (owned[instanceID] - owned[receiver]) / 2; candidate > diff {
+			candidate = diff
+		}
+
+		if candidate > shift {
+			from, shift = token, candidate
+		}
+	}
+
+	if shift == 0 {
+		return 0, 0, false
+	}
+
+	// Tokens must be unique across the whole ring, so we look for the closest free one.
+	taken := make(map[uint32]struct{}, len(instanceByToken))
+	for token := range instanceByToken {
+		taken[token] = struct{}{}
+	}
+
+	for to = from - shift; to != from; to++ {
+		if _, exists := taken[to]; !exists {
+			return from, to, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+func tokensContain(tokens []uint32, token uint32) bool {
+	for _, t := range tokens {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
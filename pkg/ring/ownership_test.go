@@ -0,0 +1,135 @@
+package ring
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokensOwnership(t *testing.T) {
+	instanceByToken := map[uint32]instanceInfo{
+		100: {InstanceID: "instance-1"},
+		200: {InstanceID: "instance-2"},
+		400: {InstanceID: "instance-1"},
+	}
+
+	numTokens, owned := tokensOwnership([]uint32{100, 200, 400}, instanceByToken)
+	assert.Equal(t, map[string]uint32{"instance-1": 2, "instance-2": 1}, numTokens)
+
+	// Each token owns the range between the previous token and itself, wrapping around the ring.
+	assert.Equal(t, map[string]uint32{
+		"instance-1": (math.MaxUint32 - 400 + 100 + 1) + 200,
+		"instance-2": 100,
+	}, owned)
+
+	_, owned = tokensOwnership([]uint32{100}, instanceByToken)
+	assert.Equal(t, map[string]uint32{"instance-1": math.MaxUint32}, owned)
+}
+
+func TestRing_ZonesOwnership(t *testing.T) {
+	desc := NewDesc()
+	desc.AddIngester("instance-1", "127.0.0.1", "zone-a", []uint32{1 << 30, 3 << 30}, ACTIVE, time.Now())
+	desc.AddIngester("instance-2", "127.0.0.2", "zone-a", []uint32{2 << 30, math.MaxUint32}, ACTIVE, time.Now())
+	desc.AddIngester("instance-3", "127.0.0.3", "zone-b", []uint32{5}, ACTIVE, time.Now())
+
+	r := Ring{
+		ringDesc:            desc,
+		ringTokensByZone:    desc.getTokensByZone(),
+		ringInstanceByToken: desc.getTokensInfo(),
+	}
+
+	ownership, zones := r.zonesOwnership()
+	assert.InDelta(t, 50, ownership["instance-1"], 0.01)
+	assert.InDelta(t, 50, ownership["instance-2"], 0.01)
+	assert.InDelta(t, 100, ownership["instance-3"], 0.01)
+
+	require.Len(t, zones, 2)
+	assert.Equal(t, "zone-a", zones[0].Zone)
+	assert.Equal(t, 2, zones[0].Instances)
+	assert.InDelta(t, 0, zones[0].Imbalance, 0.01)
+	assert.Equal(t, "zone-b", zones[1].Zone)
+	assert.Equal(t, 1, zones[1].Instances)
+}
+
+func TestPlanTokenRebalance(t *testing.T) {
+	now := time.Now()
+
+	t.Run("should move a token of the most loaded instance towards the least loaded one", func(t *testing.T) {
+		desc := NewDesc()
+		desc.AddIngester("instance-1", "127.0.0.1", "", []uint32{1 << 30, 3 << 30}, ACTIVE, now)
+		desc.AddIngester("instance-2", "127.0.0.2", "", []uint32{(3 << 30) + (1 << 29)}, ACTIVE, now)
+
+		from, to, ok := planTokenRebalance(desc, "instance-1", false, 0.1, time.Minute, now)
+		require.True(t, ok)
+		assert.Equal(t, uint32(3<<30), from)
+		assert.Less(t, to, from)
+
+		// The least loaded instance has nothing to give.
+		_, _, ok = planTokenRebalance(desc, "instance-2", false, 0.1, time.Minute, now)
+		assert.False(t, ok)
+	})
+
+	t.Run("should not move tokens while an instance is not ACTIVE", func(t *testing.T) {
+		desc := NewDesc()
+		desc.AddIngester("instance-1", "127.0.0.1", "", []uint32{1 << 30, 3 << 30}, ACTIVE, now)
+		desc.AddIngester("instance-2", "127.0.0.2", "", []uint32{(3 << 30) + (1 << 29)}, LEAVING, now)
+
+		_, _, ok := planTokenRebalance(desc, "instance-1", false, 0.1, time.Minute, now)
+		assert.False(t, ok)
+	})
+
+	t.Run("should compute the ownership within the zone when zone-awareness is enabled", func(t *testing.T) {
+		desc := NewDesc()
+		desc.AddIngester("instance-1", "127.0.0.1", "zone-a", []uint32{1 << 30, 3 << 30}, ACTIVE, now)
+		desc.AddIngester("instance-2", "127.0.0.2", "zone-b", []uint32{(3 << 30) + (1 << 29)}, ACTIVE, now)
+
+		_, _, ok := planTokenRebalance(desc, "instance-1", true, 0.1, time.Minute, now)
+		assert.False(t, ok)
+	})
+
+	t.Run("should converge to a balanced ring", func(t *testing.T) {
+		desc := NewDesc()
+		var takenTokens []uint32
+		for i := 0; i < 6; i++ {
+			tokens := GenerateTokens(16, takenTokens)
+			takenTokens = append(takenTokens, tokens...)
+			desc.AddIngester(fmt.Sprintf("instance-%d", i), fmt.Sprintf("127.0.0.%d", i), "", tokens, ACTIVE, now)
+		}
+
+		maxOwnership := func() float64 {
+			_, owned := tokensOwnership(desc.GetTokens(), desc.getTokensInfo())
+			max := 0.0
+			for _, size := range owned {
+				max = math.Max(max, float64(size)/(float64(math.MaxUint32)/float64(len(owned))))
+			}
+			return max
+		}
+
+		for step := 0; step < 1000; step++ {
+			moved := false
+			for id, instance := range desc.Ingesters {
+				from, to, ok := planTokenRebalance(desc, id, false, 0.05, time.Minute, now)
+				if !ok {
+					continue
+				}
+
+				for i, token := range instance.Tokens {
+					if token == from {
+						instance.Tokens[i] = to
+					}
+				}
+				desc.Ingesters[id] = instance
+				moved = true
+			}
+			if !moved {
+				break
+			}
+		}
+
+		assert.LessOrEqual(t, maxOwnership(), 1.05)
+	})
+}
@@ -65,6 +65,37 @@ func (s *defaultReplicationStrategy) Filter(instances []InstanceDesc, op Operati
 	return instances, len(instances) - minSuccess, nil
 }
 
+// filterZoneQuorum decides, given the set of instances eligible for a key across
+// different zones, which instances you will try and write to and how many zones
+// can fail. An operation succeeds once all instances in a majority of zones succeed.
+// - Filters out unhealthy instances.
+// - Checks there are enough zones with healthy instances for an operation to succeed.
+// The instances argument may be overwritten. A zone is available as long as it has at
+// least one healthy instance, given the replica set is extended within the same zone.
+func filterZoneQuorum(instances []InstanceDesc, op Operation, heartbeatTimeout time.Duration) ([]InstanceDesc, int, error) {
+	now := time.Now()
+	zones := map[string]struct{}{}
+	availableZones := map[string]struct{}{}
+
+	for i := 0; i < len(instances); {
+		zones[instances[i].Zone] = struct{}{}
+
+		if instances[i].IsHealthy(op, heartbeatTimeout, now) {
+			availableZones[instances[i].Zone] = struct{}{}
+			i++
+		} else {
+			instances = append(instances[:i], instances[i+1:]...)
+		}
+	}
+
+	minSuccessZones := (len(zones) / 2) + 1
+	if len(availableZones) < minSuccessZones {
+		return nil, 0, fmt.Errorf("at least %d availability zones with live replicas required, could only find %d", minSuccessZones, len(availableZones))
+	}
+
+	return instances, len(availableZones) - minSuccessZones, nil
+}
+
 type ignoreUnhealthyInstancesReplicationStrategy struct{}
 
 func NewIgnoreUnhealthyInstancesReplicationStrategy() ReplicationStrategy {
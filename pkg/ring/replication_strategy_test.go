@@ -163,3 +163,70 @@ func TestIgnoreUnhealthyInstancesReplicationStrategy(t *testing.T) {
 		})
 	}
 }
+
+func TestFilterZoneQuorum(t *testing.T) {
+	now := time.Now().Unix()
+	dead := time.Now().Add(-time.Hour).Unix()
+
+	for name, tc := range map[string]struct {
+		instances                   []InstanceDesc
+		expectedAddrs               []string
+		expectedMaxUnavailableZones int
+		expectedError               string
+	}{
+		"all zones healthy": {
+			instances: []InstanceDesc{
+				{Addr: "a-1", Zone: "zone-a", State: ACTIVE, Timestamp: now},
+				{Addr: "b-1", Zone: "zone-b", State: ACTIVE, Timestamp: now},
+				{Addr: "c-1", Zone: "zone-c", State: ACTIVE, Timestamp: now},
+			},
+			expectedAddrs:               []string{"a-1", "b-1", "c-1"},
+			expectedMaxUnavailableZones: 1,
+		},
+		"one zone down and one instance leaving in another zone": {
+			instances: []InstanceDesc{
+				{Addr: "a-1", Zone: "zone-a", State: ACTIVE, Timestamp: dead},
+				{Addr: "b-1", Zone: "zone-b", State: LEAVING, Timestamp: now},
+				{Addr: "b-2", Zone: "zone-b", State: ACTIVE, Timestamp: now},
+				{Addr: "c-1", Zone: "zone-c", State: ACTIVE, Timestamp: now},
+			},
+			expectedAddrs:               []string{"b-2", "c-1"},
+			expectedMaxUnavailableZones: 0,
+		},
+		"two zones down": {
+			instances: []InstanceDesc{
+				{Addr: "a-1", Zone: "zone-a", State: ACTIVE, Timestamp: dead},
+				{Addr: "b-1", Zone: "zone-b", State: ACTIVE, Timestamp: dead},
+				{Addr: "c-1", Zone: "zone-c", State: ACTIVE, Timestamp: now},
+			},
+			expectedError: "at least 2 availability zones with live replicas required, could only find 1",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			healthy, maxUnavailableZones, err := filterZoneQuorum(tc.instances, Write, time.Minute)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedMaxUnavailableZones, maxUnavailableZones)
+
+			var addrs []string
+			for _, instance := range healthy {
+				addrs = append(addrs, instance.Addr)
+			}
+			assert.Equal(t, tc.expectedAddrs, addrs)
+		})
+	}
+
+	t.Run("the default strategy requires a majority of instances instead", func(t *testing.T) {
+		_, _, err := NewDefaultReplicationStrategy().Filter([]InstanceDesc{
+			{Addr: "a-1", Zone: "zone-a", State: ACTIVE, Timestamp: dead},
+			{Addr: "b-1", Zone: "zone-b", State: LEAVING, Timestamp: now},
+			{Addr: "b-2", Zone: "zone-b", State: ACTIVE, Timestamp: now},
+			{Addr: "c-1", Zone: "zone-c", State: ACTIVE, Timestamp: now},
+		}, Write, 3, time.Minute, true)
+		assert.Error(t, err)
+	})
+}
@@ -132,6 +132,7 @@ type Config struct {
 	HeartbeatTimeout     time.Duration `yaml:"heartbeat_timeout"`
 	ReplicationFactor    int           `yaml:"replication_factor"`
 	ZoneAwarenessEnabled bool          `yaml:"zone_awareness_enabled"`
+	ZoneQuorumEnabled    bool          `yaml:"zone_quorum_enabled"`
 
 	// Whether the shuffle-sharding subring cache is disabled. This option is set
 	// internally and never exposed to the user.
@@ -150,6 +151,7 @@ func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.DurationVar(&cfg.HeartbeatTimeout, prefix+"ring.heartbeat-timeout", time.Minute, "The heartbeat timeout after which ingesters are skipped for reads/writes. 0 = never (timeout disabled).")
 	f.IntVar(&cfg.ReplicationFactor, prefix+"distributor.replication-factor", 3, "The number of ingesters to write to and read from.")
 	f.BoolVar(&cfg.ZoneAwarenessEnabled, prefix+"distributor.zone-awareness-enabled", false, "True to enable the zone-awareness and replicate ingested samples across different availability zones.")
+	f.BoolVar(&cfg.ZoneQuorumEnabled, prefix+"distributor.zone-quorum-enabled", false, "Experimental: True to enable the zone quorum, which requires zone-awareness. Both writes and reads succeed once all instances in a majority of zones have acknowledged them, so that reads always include the acknowledged writes.")
 }
 
 type instanceInfo struct {
@@ -166,6 +168,10 @@ type Ring struct {
 	KVClient kv.Client
 	strategy ReplicationStrategy
 
+	// Whether the KV client has been created by the ring. A KV client passed
+	// by the caller may be shared with other components and is not stopped.
+	ownsKVClient bool
+
 	mtx              sync.RWMutex
 	ringDesc         *Desc
 	ringTokens       []uint32
@@ -212,7 +218,14 @@ func New(cfg Config, name, key string, reg prometheus.Registerer) (*Ring, error)
 		return nil, err
 	}
 
-	return NewWithStoreClientAndStrategy(cfg, name, key, store, NewDefaultReplicationStrategy())
+	r, err := NewWithStoreClientAndStrategy(cfg, name, key, store, NewDefaultReplicationStrategy())
+	if err != nil {
+		return nil, err
+	}
+
+	// The ring created the KV client, so it's in charge of stopping it.
+	r.ownsKVClient = true
+	return r, nil
 }
 
 func NewWithStoreClientAndStrategy(cfg Config, name, key string, store kv.Client, strategy ReplicationStrategy) (*Ring, error) {
@@ -259,7 +272,7 @@ func NewWithStoreClientAndStrategy(cfg Config, name, key string, store kv.Client
 		),
 	}
 
-	r.Service = services.NewBasicService(r.starting, r.loop, nil).WithName(fmt.Sprintf("%s ring client", name))
+	r.Service = services.NewBasicService(r.starting, r.loop, r.stopping).WithName(fmt.Sprintf("%s ring client", name))
 	return r, nil
 }
 
@@ -293,6 +306,13 @@ func (r *Ring) loop(ctx context.Context) error {
 	return nil
 }
 
+func (r *Ring) stopping(_ error) error {
+	if r.ownsKVClient {
+		kv.StopClient(r.KVClient)
+	}
+	return nil
+}
+
 func (r *Ring) updateRingState(ringDesc *Desc) {
 	r.mtx.RLock()
 	prevRing := r.ringDesc
@@ -388,6 +408,18 @@ func (r *Ring) Get(key uint32, op Operation, bufDescs []InstanceDesc, bufHosts,
 		instances = append(instances, instance)
 	}
 
+	if r.cfg.ZoneAwarenessEnabled && r.cfg.ZoneQuorumEnabled {
+		healthyInstances, maxUnavailableZones, err := filterZoneQuorum(instances, op, r.cfg.HeartbeatTimeout)
+		if err != nil {
+			return ReplicationSet{}, err
+		}
+
+		return ReplicationSet{
+			Instances:           healthyInstances,
+			MaxUnavailableZones: maxUnavailableZones,
+		}, nil
+	}
+
 	healthyInstances, maxFailure, err := r.strategy.Filter(instances, op, r.cfg.ReplicationFactor, r.cfg.HeartbeatTimeout, r.cfg.ZoneAwarenessEnabled)
 	if err != nil {
 		return ReplicationSet{}, err
@@ -516,22 +548,7 @@ func (r *Ring) Describe(ch chan<- *prometheus.Desc) {
 // countTokens returns the number of tokens and tokens within the range for each instance.
 // The ring read lock must be already taken when calling this function.
 func (r *Ring) countTokens() (map[string]uint32, map[string]uint32) {
-	owned := map[string]uint32{}
-	numTokens := map[string]uint32{}
-	for i, token := range r.ringTokens {
-		var diff uint32
-
-		// Compute how many tokens are within the range.
-		if i+1 == len(r.ringTokens) {
-			diff = (math.MaxUint32 - token) + r.ringTokens[0]
-		} else {
-			diff = r.ringTokens[i+1] - token
-		}
-
-		info := r.ringInstanceByToken[token]
-		numTokens[info.InstanceID] = numTokens[info.InstanceID] + 1
-		owned[info.InstanceID] = owned[info.InstanceID] + diff
-	}
+	numTokens, owned := tokensOwnership(r.ringTokens, r.ringInstanceByToken)
 
 	// Set to 0 the number of owned tokens by instances which don't have tokens yet.
 	for id := range r.ringDesc.Ingesters {
@@ -2,6 +2,7 @@ package ring
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
@@ -99,6 +100,54 @@ func TestDoBatchZeroInstances(t *testing.T) {
 	require.Error(t, DoBatch(ctx, Write, &r, keys, callback, cleanup))
 }
 
+func TestDoBatch_ZoneQuorum(t *testing.T) {
+	desc := NewDesc()
+	var takenTokens []uint32
+	for _, zone := range []string{"zone-a", "zone-b", "zone-c"} {
+		for i := 0; i < 2; i++ {
+			tokens := GenerateTokens(128, takenTokens)
+			takenTokens = append(takenTokens, tokens...)
+			desc.AddIngester(fmt.Sprintf("%s-%d", zone, i), fmt.Sprintf("%s-%d", zone, i), zone, tokens, ACTIVE, time.Now())
+		}
+	}
+
+	// An instance is leaving, so the replica set is extended within its zone.
+	leaving := desc.Ingesters["zone-b-0"]
+	leaving.State = LEAVING
+	desc.Ingesters["zone-b-0"] = leaving
+
+	r := Ring{
+		cfg: Config{
+			HeartbeatTimeout:     time.Minute,
+			ReplicationFactor:    3,
+			ZoneAwarenessEnabled: true,
+			ZoneQuorumEnabled:    true,
+		},
+		ringDesc:            desc,
+		ringTokens:          desc.GetTokens(),
+		ringTokensByZone:    desc.getTokensByZone(),
+		ringInstanceByToken: desc.getTokensInfo(),
+		ringZones:           getZones(desc.getTokensByZone()),
+		strategy:            NewDefaultReplicationStrategy(),
+	}
+
+	keys := make([]uint32, 100)
+	generateKeys(rand.New(rand.NewSource(time.Now().UnixNano())), len(keys), keys)
+
+	failingZones := func(zones ...string) func(InstanceDesc, []int) error {
+		return func(desc InstanceDesc, _ []int) error {
+			if util.StringsContain(zones, desc.Zone) {
+				return errors.New("zone failure")
+			}
+			return nil
+		}
+	}
+
+	// The writes succeed as long as all instances in a majority of zones succeed.
+	require.NoError(t, DoBatch(context.Background(), Write, &r, keys, failingZones("zone-a"), func() {}))
+	require.Error(t, DoBatch(context.Background(), Write, &r, keys, failingZones("zone-a", "zone-b"), func() {}))
+}
+
 func TestAddIngester(t *testing.T) {
 	r := NewDesc()
 
@@ -132,6 +181,37 @@ func TestAddIngesterReplacesExistingTokens(t *testing.T) {
 	require.Equal(t, newTokens, r.Ingesters[ing1Name].Tokens)
 }
 
+func TestRing_CountTokens(t *testing.T) {
+	desc := NewDesc()
+	desc.AddIngester("instance-1", "127.0.0.1", "", []uint32{100}, ACTIVE, time.Now())
+	desc.AddIngester("instance-2", "127.0.0.2", "", []uint32{1000}, ACTIVE, time.Now())
+	desc.AddIngester("instance-3", "127.0.0.3", "", nil, JOINING, time.Now())
+
+	r := Ring{
+		cfg:                 Config{HeartbeatTimeout: time.Minute, ReplicationFactor: 1},
+		ringDesc:            desc,
+		ringTokens:          desc.GetTokens(),
+		ringTokensByZone:    desc.getTokensByZone(),
+		ringInstanceByToken: desc.getTokensInfo(),
+		ringZones:           getZones(desc.getTokensByZone()),
+		strategy:            NewDefaultReplicationStrategy(),
+	}
+
+	// Each token owns the range preceding it, so instance-2 owns [100, 1000)
+	// while instance-1 owns the rest of the ring, wrapping around.
+	numTokens, owned := r.countTokens()
+	assert.Equal(t, map[string]uint32{"instance-1": 1, "instance-2": 1, "instance-3": 0}, numTokens)
+	assert.Equal(t, map[string]uint32{"instance-1": math.MaxUint32 - 899, "instance-2": 900, "instance-3": 0}, owned)
+
+	// The ownership is consistent with how keys are sharded.
+	for key, expected := range map[uint32]string{50: "127.0.0.1", 100: "127.0.0.2", 500: "127.0.0.2", 1000: "127.0.0.1", math.MaxUint32: "127.0.0.1"} {
+		set, err := r.Get(key, Write, nil, nil, nil)
+		require.NoError(t, err)
+		require.Len(t, set.Instances, 1)
+		assert.Equal(t, expected, set.Instances[0].Addr, "key: %d", key)
+	}
+}
+
 func TestRing_Get_ZoneAwarenessWithIngesterLeaving(t *testing.T) {
 	const testCount = 10000
 
@@ -525,6 +605,8 @@ func TestRing_GetReplicationSetForOperation_WithZoneAwarenessEnabled(t *testing.
 		unhealthyInstances          []string
 		expectedAddresses           []string
 		replicationFactor           int
+		zoneQuorumEnabled           bool
+		op                          Operation
 		expectedError               error
 		expectedMaxErrors           int
 		expectedMaxUnavailableZones int
@@ -787,6 +869,75 @@ func TestRing_GetReplicationSetForOperation_WithZoneAwarenessEnabled(t *testing.
 			replicationFactor:  5,
 			expectedError:      ErrTooManyUnhealthyInstances,
 		},
+		"RF=3, 3 zones, one instance per zone, zone quorum": {
+			ringInstances: map[string]InstanceDesc{
+				"instance-1": {Addr: "127.0.0.1", Zone: "zone-a", Tokens: GenerateTokens(128, nil)},
+				"instance-2": {Addr: "127.0.0.2", Zone: "zone-b", Tokens: GenerateTokens(128, nil)},
+				"instance-3": {Addr: "127.0.0.3", Zone: "zone-c", Tokens: GenerateTokens(128, nil)},
+			},
+			expectedAddresses:           []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"},
+			replicationFactor:           3,
+			zoneQuorumEnabled:           true,
+			expectedMaxUnavailableZones: 1,
+		},
+		"RF=3, 3 zones, one instance per zone, one instance unhealthy, zone quorum": {
+			ringInstances: map[string]InstanceDesc{
+				"instance-1": {Addr: "127.0.0.1", Zone: "zone-a", Tokens: GenerateTokens(128, nil)},
+				"instance-2": {Addr: "127.0.0.2", Zone: "zone-b", Tokens: GenerateTokens(128, nil)},
+				"instance-3": {Addr: "127.0.0.3", Zone: "zone-c", Tokens: GenerateTokens(128, nil)},
+			},
+			unhealthyInstances:          []string{"instance-2"},
+			expectedAddresses:           []string{"127.0.0.1", "127.0.0.3"},
+			replicationFactor:           3,
+			zoneQuorumEnabled:           true,
+			expectedMaxUnavailableZones: 0,
+		},
+		"RF=3, 3 zones, one instance per zone, two instances unhealthy in separate zones, zone quorum": {
+			ringInstances: map[string]InstanceDesc{
+				"instance-1": {Addr: "127.0.0.1", Zone: "zone-a", Tokens: GenerateTokens(128, nil)},
+				"instance-2": {Addr: "127.0.0.2", Zone: "zone-b", Tokens: GenerateTokens(128, nil)},
+				"instance-3": {Addr: "127.0.0.3", Zone: "zone-c", Tokens: GenerateTokens(128, nil)},
+			},
+			unhealthyInstances: []string{"instance-2", "instance-3"},
+			replicationFactor:  3,
+			zoneQuorumEnabled:  true,
+			expectedError:      ErrTooManyUnhealthyInstances,
+		},
+		"RF=3, 3 zones, one instance per zone, all instances unhealthy, zone quorum": {
+			ringInstances: map[string]InstanceDesc{
+				"instance-1": {Addr: "127.0.0.1", Zone: "zone-a", Tokens: GenerateTokens(128, nil)},
+				"instance-2": {Addr: "127.0.0.2", Zone: "zone-b", Tokens: GenerateTokens(128, nil)},
+				"instance-3": {Addr: "127.0.0.3", Zone: "zone-c", Tokens: GenerateTokens(128, nil)},
+			},
+			unhealthyInstances: []string{"instance-1", "instance-2", "instance-3"},
+			replicationFactor:  3,
+			zoneQuorumEnabled:  true,
+			expectedError:      ErrTooManyUnhealthyInstances,
+		},
+		"RF=3, 3 zones, one instance per zone, zone quorum, write": {
+			ringInstances: map[string]InstanceDesc{
+				"instance-1": {Addr: "127.0.0.1", Zone: "zone-a", Tokens: GenerateTokens(128, nil)},
+				"instance-2": {Addr: "127.0.0.2", Zone: "zone-b", Tokens: GenerateTokens(128, nil)},
+				"instance-3": {Addr: "127.0.0.3", Zone: "zone-c", Tokens: GenerateTokens(128, nil)},
+			},
+			op:                          Write,
+			expectedAddresses:           []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"},
+			replicationFactor:           3,
+			zoneQuorumEnabled:           true,
+			expectedMaxUnavailableZones: 1,
+		},
+		"RF=3, 3 zones, one instance per zone, two instances unhealthy in separate zones, zone quorum, write": {
+			ringInstances: map[string]InstanceDesc{
+				"instance-1": {Addr: "127.0.0.1", Zone: "zone-a", Tokens: GenerateTokens(128, nil)},
+				"instance-2": {Addr: "127.0.0.2", Zone: "zone-b", Tokens: GenerateTokens(128, nil)},
+				"instance-3": {Addr: "127.0.0.3", Zone: "zone-c", Tokens: GenerateTokens(128, nil)},
+			},
+			op:                 Write,
+			unhealthyInstances: []string{"instance-2", "instance-3"},
+			replicationFactor:  3,
+			zoneQuorumEnabled:  true,
+			expectedError:      ErrTooManyUnhealthyInstances,
+		},
 	}
 
 	for testName, testData := range tests {
@@ -812,6 +963,7 @@ func TestRing_GetReplicationSetForOperation_WithZoneAwarenessEnabled(t *testing.
 				cfg: Config{
 					HeartbeatTimeout:     time.Minute,
 					ZoneAwarenessEnabled: true,
+					ZoneQuorumEnabled:    testData.zoneQuorumEnabled,
 					ReplicationFactor:    testData.replicationFactor,
 				},
 				ringDesc:            ringDesc,
@@ -823,7 +975,11 @@ func TestRing_GetReplicationSetForOperation_WithZoneAwarenessEnabled(t *testing.
 			}
 
 			// Check the replication set has the correct settings
-			replicationSet, err := ring.GetReplicationSetForOperation(Read)
+			op := testData.op
+			if op == 0 {
+				op = Read
+			}
+			replicationSet, err := ring.GetReplicationSetForOperation(op)
 			if testData.expectedError == nil {
 				require.NoError(t, err)
 			} else {
@@ -0,0 +1,281 @@
+package ring
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+const (
+	// RandomTokenGeneration is the token generation strategy picking random tokens.
+	RandomTokenGeneration = "random"
+
+	// SpreadMinimizingTokenGeneration is the token generation strategy deriving the tokens
+	// from the instance ordinal and zone, in order to minimize the ownership spread.
+	SpreadMinimizingTokenGeneration = "spread-minimizing"
+)
+
+var (
+	supportedTokenGenerationStrategies = []string{RandomTokenGeneration, SpreadMinimizingTokenGeneration}
+
+	instanceOrdinalRegexp = regexp.MustCompile(`(\d+)$`)
+)
+
+// TokenGenerator generates the tokens of an instance joining the ring.
+type TokenGenerator interface {
+	// GenerateTokens returns numTokens unique tokens, sorted. Tokens are expected to not
+	// clash with takenTokens, but a deterministic generator may return clashing tokens:
+	// CanJoin must be called to check whether they can be used.
+	GenerateTokens(numTokens int, takenTokens []uint32) Tokens
+
+	// CanJoin returns an error if the instance can't join the ring with the given tokens,
+	// because some of them are already owned by other instances.
+	CanJoin(tokens Tokens, takenTokens []uint32) error
+}
+
+// TokenGeneratorConfig configures the strategy used to generate the tokens of an instance.
+type TokenGeneratorConfig struct {
+	Strategy              string                 `yaml:"tokens_generation_strategy"`
+	SpreadMinimizingZones flagext.StringSliceCSV `yaml:"spread_minimizing_zones"`
+}
+
+// RegisterFlagsWithPrefix adds the flags required to config this to the given FlagSet.
+func (cfg *TokenGeneratorConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Strategy, prefix+"tokens-generation-strategy", RandomTokenGeneration, fmt.Sprintf("Experimental: strategy used to generate the tokens of the instance. Supported values are: %s. The %s strategy derives the tokens from the ordinal suffixing the instance ID (eg. 3 in ingester-zone-a-3) and from the instance zone, so that any first N instances of each zone own the ring almost evenly.", strings.Join(supportedTokenGenerationStrategies, ", "), SpreadMinimizingTokenGeneration))
+	f.Var(&cfg.SpreadMinimizingZones, prefix+"spread-minimizing-zones", fmt.Sprintf("Comma-separated list of all the availability zones of the ring, used by the %s strategy to generate tokens which don't clash across zones. Must be the same on all the instances. Leave empty if zone-awareness is disabled.", SpreadMinimizingTokenGeneration))
+}
+
+// Validate the config, given the availability zone of the instance.
+func (cfg *TokenGeneratorConfig) Validate(zone string) error {
+	switch cfg.Strategy {
+	case "", RandomTokenGeneration:
+		return nil
+	case SpreadMinimizingTokenGeneration:
+		return validateSpreadMinimizingZones(zone, cfg.SpreadMinimizingZones)
+	default:
+		return fmt.Errorf("unsupported tokens generation strategy %q, supported values are: %s", cfg.Strategy, strings.Join(supportedTokenGenerationStrategies, ", "))
+	}
+}
+
+// NewTokenGenerator returns the TokenGenerator for the given instance, based on the config.
+func (cfg *TokenGeneratorConfig) NewTokenGenerator(instanceID, zone string) (TokenGenerator, error) {
+	switch cfg.Strategy {
+	case "", RandomTokenGeneration:
+		return RandomTokenGenerator{}, nil
+	case SpreadMinimizingTokenGeneration:
+		return NewSpreadMinimizingTokenGenerator(instanceID, zone, cfg.SpreadMinimizingZones)
+	default:
+		return nil, cfg.Validate(zone)
+	}
+}
+
+// validateSpreadMinimizingZones checks the zones are unique and include the instance zone, if any
+// zone is given.
+func validateSpreadMinimizingZones(zone string, zones []string) error {
+	if len(zones) == 0 {
+		return nil
+	}
+
+	found := false
+	for i, z := range zones {
+		for _, other := range zones[:i] {
+			if z == other {
+				return fmt.Errorf("the %s zones %v contain the zone %q more than once", SpreadMinimizingTokenGeneration, zones, z)
+			}
+		}
+		found = found || z == zone
+	}
+
+	if !found {
+		return fmt.Errorf("the instance zone %q is not one of the %s zones %v", zone, SpreadMinimizingTokenGeneration, zones)
+	}
+	return nil
+}
+
+// RandomTokenGenerator generates random tokens.
+type RandomTokenGenerator struct{}
+
+// GenerateTokens implements TokenGenerator.
+func (RandomTokenGenerator) GenerateTokens(numTokens int, takenTokens []uint32) Tokens {
+	return GenerateTokens(numTokens, takenTokens)
+}
+
+// CanJoin implements TokenGenerator. Random tokens never clash with the taken ones.
+func (RandomTokenGenerator) CanJoin(_ Tokens, _ []uint32) error {
+	return nil
+}
+
+// SpreadMinimizingTokenGenerator deterministically generates the tokens of an instance from its
+// ordinal within its zone. The tokens of the instance with ordinal N are computed by simulating
+// the instances 0..N joining the ring one after the other: the first instance gets evenly spaced
+// tokens, and each following instance takes its ideal share of the ring from the largest ranges
+// of the instances owning the most. This way any set of instances with ordinals 0..N-1 owns the
+// ring almost evenly. Tokens of an instance in the i-th zone are all equal to i modulo the number
+// of zones, so that tokens never clash across zones.
+type SpreadMinimizingTokenGenerator struct {
+	ordinal   int
+	zoneIndex uint32
+	numZones  uint32
+}
+
+// NewSpreadMinimizingTokenGenerator returns a SpreadMinimizingTokenGenerator for the given instance.
+// The instance ID must end with the instance ordinal, and the zone must be one of the given unique
+// zones (if any).
+func NewSpreadMinimizingTokenGenerator(instanceID, zone string, zones []string) (*SpreadMinimizingTokenGenerator, error) {
+	match := instanceOrdinalRegexp.FindStringSubmatch(instanceID)
+	if match == nil {
+		return nil, fmt.Errorf("the %s tokens generation strategy requires the instance ID to end with the instance ordinal, got %q", SpreadMinimizingTokenGeneration, instanceID)
+	}
+
+	ordinal, err := strconv.Atoi(match[1])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid ordinal in the instance ID %q", instanceID)
+	}
+
+	if err := validateSpreadMinimizingZones(zone, zones); err != nil {
+		return nil, err
+	}
+
+	g := &SpreadMinimizingTokenGenerator{ordinal: ordinal, numZones: 1}
+	if len(zones) == 0 {
+		return g, nil
+	}
+
+	g.numZones = uint32(len(zones))
+	for i, z := range zones {
+		if z == zone {
+			g.zoneIndex = uint32(i)
+		}
+	}
+	return g, nil
+}
+
+// GenerateTokens implements TokenGenerator. The generated tokens only depend on the instance
+// ordinal and zone, and on numTokens. If the ring is too crowded to derive numTokens tokens, the
+// missing tokens are picked randomly, avoiding takenTokens.
+func (g *SpreadMinimizingTokenGenerator) GenerateTokens(numTokens int, takenTokens []uint32) Tokens {
+	if numTokens <= 0 {
+		return Tokens{}
+	}
+
+	// The size of the range owned by each token, and the tokens of each simulated instance.
+	ranges := make(map[uint32]uint32, numTokens*(g.ordinal+1))
+	owned := make([]uint64, 0, g.ordinal+1)
+	instances := make([]Tokens, 0, g.ordinal+1)
+
+	// The first instance gets evenly spaced tokens.
+	first := make(Tokens, 0, numTokens)
+	step := uint64(math.MaxUint32+1) / uint64(numTokens)
+	for i := 0; i < numTokens; i++ {
+		// Tokens are increasing, but may be aligned to the same token in a crowded ring.
+		token := g.alignToZone(uint32(uint64(i) * step))
+		if len(first) == 0 || first[len(first)-1] != token {
+			first = append(first, token)
+		}
+	}
+	for i := range first {
+		ranges[first[i]] = tokenRangeSize(first, i)
+	}
+	instances = append(instances, first)
+	owned = append(owned, math.MaxUint32+1)
+
+	for ordinal := 1; ordinal <= g.ordinal; ordinal++ {
+		// The new instance ideally owns this share of the ring.
+		ideal := uint64(math.MaxUint32+1) / uint64(ordinal+1)
+
+		tokens := make(Tokens, 0, numTokens)
+		var total uint64
+		for len(tokens) < numTokens {
+			// Take part of the largest range of the instance owning the most.
+			donor := 0
+			for i := range owned {
+				if owned[i] > owned[donor] {
+					donor = i
+				}
+			}
+
+			largest := instances[donor][0]
+			for _, token := range instances[donor] {
+				if ranges[token] > ranges[largest] {
+					largest = token
+				}
+			}
+
+			// Each token takes an even share of what the new instance still misses, leaving
+			// at least a part of the range to the donor's token.
+			size := ranges[largest]
+			var amount uint32
+			if total < ideal {
+				amount = uint32((ideal - total) / uint64(numTokens-len(tokens)))
+			}
+			if amount > size-size/4 {
+				amount = size - size/4
+			}
+
+			// The new token owns the range between the previous token and itself, while the
+			// donor's token keeps the rest of its range.
+			prev := largest - size
+			token := g.alignToZone(prev + amount)
+			taken := token - prev
+			if taken == 0 || taken >= size {
+				// The ring is too crowded to split the range any further.
+				break
+			}
+
+			ranges[token] = taken
+			ranges[largest] = size - taken
+			owned[donor] -= uint64(taken)
+			tokens = append(tokens, token)
+			total += uint64(taken)
+		}
+
+		instances = append(instances, tokens)
+		owned = append(owned, total)
+	}
+
+	result := append(Tokens{}, instances[g.ordinal]...)
+	if missing := numTokens - len(result); missing > 0 {
+		taken := append(append(make([]uint32, 0, len(takenTokens)+len(result)), takenTokens...), result...)
+		result = append(result, GenerateTokens(missing, taken)...)
+	}
+
+	sort.Sort(result)
+	return result
+}
+
+// alignToZone returns the closest token lower or equal to the given one which belongs to the zone.
+func (g *SpreadMinimizingTokenGenerator) alignToZone(token uint32) uint32 {
+	aligned := token - token%g.numZones + g.zoneIndex
+	if aligned > token && aligned >= g.numZones {
+		aligned -= g.numZones
+	}
+	return aligned
+}
+
+// CanJoin implements TokenGenerator.
+func (g *SpreadMinimizingTokenGenerator) CanJoin(tokens Tokens, takenTokens []uint32) error {
+	taken := make(map[uint32]struct{}, len(takenTokens))
+	for _, token := range takenTokens {
+		taken[token] = struct{}{}
+	}
+
+	conflicts := 0
+	for _, token := range tokens {
+		if _, ok := taken[token]; ok {
+			conflicts++
+		}
+	}
+
+	if conflicts > 0 {
+		return fmt.Errorf("%d of the tokens generated by the %s strategy are already owned by other instances in the ring, the instance ordinal may not be unique within its zone", conflicts, SpreadMinimizingTokenGeneration)
+	}
+	return nil
+}
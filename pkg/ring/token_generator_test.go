@@ -0,0 +1,220 @@
+package ring
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+func TestTokenGeneratorConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		cfg         TokenGeneratorConfig
+		zone        string
+		expectedErr string
+	}{
+		"default strategy": {},
+		"random strategy ignores the zones": {
+			cfg: TokenGeneratorConfig{Strategy: RandomTokenGeneration, SpreadMinimizingZones: []string{"zone-a", "zone-a"}},
+		},
+		"unsupported strategy": {
+			cfg:         TokenGeneratorConfig{Strategy: "xxx"},
+			expectedErr: `unsupported tokens generation strategy "xxx", supported values are: random, spread-minimizing`,
+		},
+		"spread-minimizing strategy without zones": {
+			cfg: TokenGeneratorConfig{Strategy: SpreadMinimizingTokenGeneration},
+		},
+		"spread-minimizing strategy with the instance zone": {
+			cfg:  TokenGeneratorConfig{Strategy: SpreadMinimizingTokenGeneration, SpreadMinimizingZones: []string{"zone-a", "zone-b"}},
+			zone: "zone-b",
+		},
+		"spread-minimizing strategy without the instance zone": {
+			cfg:         TokenGeneratorConfig{Strategy: SpreadMinimizingTokenGeneration, SpreadMinimizingZones: []string{"zone-a", "zone-b"}},
+			zone:        "zone-c",
+			expectedErr: `the instance zone "zone-c" is not one of the spread-minimizing zones [zone-a zone-b]`,
+		},
+		"spread-minimizing strategy with duplicate zones": {
+			cfg:         TokenGeneratorConfig{Strategy: SpreadMinimizingTokenGeneration, SpreadMinimizingZones: []string{"zone-a", "zone-b", "zone-a"}},
+			zone:        "zone-b",
+			expectedErr: `the spread-minimizing zones [zone-a zone-b zone-a] contain the zone "zone-a" more than once`,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			err := testData.cfg.Validate(testData.zone)
+			if testData.expectedErr != "" {
+				assert.EqualError(t, err, testData.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewSpreadMinimizingTokenGenerator(t *testing.T) {
+	tests := map[string]struct {
+		instanceID        string
+		zone              string
+		zones             []string
+		expectedOrdinal   int
+		expectedZoneIndex uint32
+		expectedErr       bool
+	}{
+		"instance ID with ordinal and no zones": {
+			instanceID:      "ingester-12",
+			expectedOrdinal: 12,
+		},
+		"instance ID with ordinal and zones": {
+			instanceID:        "ingester-zone-b-3",
+			zone:              "zone-b",
+			zones:             []string{"zone-a", "zone-b", "zone-c"},
+			expectedOrdinal:   3,
+			expectedZoneIndex: 1,
+		},
+		"instance ID without ordinal": {
+			instanceID:  "ingester",
+			expectedErr: true,
+		},
+		"zone not in the configured zones": {
+			instanceID:  "ingester-zone-d-0",
+			zone:        "zone-d",
+			zones:       []string{"zone-a", "zone-b", "zone-c"},
+			expectedErr: true,
+		},
+		"duplicate configured zones": {
+			instanceID:  "ingester-zone-a-0",
+			zone:        "zone-a",
+			zones:       []string{"zone-a", "zone-b", "zone-a"},
+			expectedErr: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			g, err := NewSpreadMinimizingTokenGenerator(testData.instanceID, testData.zone, testData.zones)
+			if testData.expectedErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedOrdinal, g.ordinal)
+			assert.Equal(t, testData.expectedZoneIndex, g.zoneIndex)
+		})
+	}
+}
+
+func TestSpreadMinimizingTokenGenerator_GenerateTokens(t *testing.T) {
+	const (
+		numTokens    = 128
+		numInstances = 12
+	)
+
+	zones := []string{"zone-a", "zone-b", "zone-c"}
+	instanceByToken := map[uint32]instanceInfo{}
+	tokensByZone := map[string]Tokens{}
+
+	for _, zone := range zones {
+		for ordinal := 0; ordinal < numInstances; ordinal++ {
+			instanceID := fmt.Sprintf("ingester-%s-%d", zone, ordinal)
+			g, err := NewSpreadMinimizingTokenGenerator(instanceID, zone, zones)
+			require.NoError(t, err)
+
+			tokens := g.GenerateTokens(numTokens, nil)
+			require.Len(t, tokens, numTokens)
+			require.True(t, sort.SliceIsSorted(tokens, func(i, j int) bool { return tokens[i] < tokens[j] }))
+
+			// Tokens are deterministic.
+			require.Equal(t, tokens, g.GenerateTokens(numTokens, nil))
+
+			for _, token := range tokens {
+				_, exists := instanceByToken[token]
+				require.False(t, exists, "token %d generated twice", token)
+				instanceByToken[token] = instanceInfo{InstanceID: instanceID, Zone: zone}
+			}
+
+			// Any prefix of the instances of the zone owns the ring almost evenly.
+			tokensByZone[zone] = append(tokensByZone[zone], tokens...)
+			sort.Sort(tokensByZone[zone])
+
+			_, owned := tokensOwnership(tokensByZone[zone], instanceByToken)
+			require.Len(t, owned, ordinal+1)
+			if ordinal == 0 {
+				// The size of the whole ring overflows the uint32 ownership.
+				continue
+			}
+
+			ideal := float64(math.MaxUint32) / float64(ordinal+1)
+			for id, size := range owned {
+				assert.InDelta(t, ideal, float64(size), ideal*0.02, "instance: %s, instances in the zone: %d", id, ordinal+1)
+			}
+		}
+	}
+}
+
+func TestSpreadMinimizingTokenGenerator_GenerateTokens_CrowdedRing(t *testing.T) {
+	const numTokens = 32
+
+	// With this many zones, only 16 tokens of the ring belong to each zone, so the
+	// missing tokens are picked randomly.
+	takenTokens := []uint32{1, 2, 3}
+	for ordinal := 0; ordinal < 3; ordinal++ {
+		g := &SpreadMinimizingTokenGenerator{ordinal: ordinal, numZones: 1 << 28}
+
+		tokens := g.GenerateTokens(numTokens, takenTokens)
+		require.Len(t, tokens, numTokens, "ordinal: %d", ordinal)
+		require.True(t, sort.SliceIsSorted(tokens, func(i, j int) bool { return tokens[i] < tokens[j] }))
+
+		unique := map[uint32]struct{}{}
+		for _, token := range tokens {
+			unique[token] = struct{}{}
+		}
+		assert.Len(t, unique, numTokens, "ordinal: %d", ordinal)
+		assert.NoError(t, g.CanJoin(tokens, takenTokens), "ordinal: %d", ordinal)
+	}
+}
+
+func TestSpreadMinimizingTokenGenerator_CanJoin(t *testing.T) {
+	g, err := NewSpreadMinimizingTokenGenerator("ingester-1", "", nil)
+	require.NoError(t, err)
+
+	tokens := g.GenerateTokens(16, nil)
+	assert.NoError(t, g.CanJoin(tokens, []uint32{tokens[0] + 1}))
+	assert.Error(t, g.CanJoin(tokens, []uint32{tokens[3]}))
+}
+
+func TestBasicLifecycler_ShouldFailToRegisterWithConflictingTokens(t *testing.T) {
+	ctx := context.Background()
+	cfg := prepareBasicLifecyclerConfig()
+	cfg.ID = "instance-1"
+
+	g, err := NewSpreadMinimizingTokenGenerator(cfg.ID, cfg.Zone, nil)
+	require.NoError(t, err)
+	cfg.TokenGenerator = g
+
+	lifecycler, delegate, store, err := prepareBasicLifecycler(cfg)
+	require.NoError(t, err)
+	delegate.onRegister = func(l *BasicLifecycler, _ Desc, _ bool, _ string, _ InstanceDesc) (InstanceState, Tokens) {
+		return ACTIVE, l.GenerateTokens(cfg.NumTokens, nil)
+	}
+
+	// Another instance, with the same ordinal, already owns the tokens.
+	require.NoError(t, store.CAS(ctx, testRingKey, func(in interface{}) (interface{}, bool, error) {
+		desc := NewDesc()
+		desc.AddIngester("another-instance-1", "1.1.1.1", cfg.Zone, g.GenerateTokens(cfg.NumTokens, nil), ACTIVE, time.Now())
+		return desc, true, nil
+	}))
+
+	assert.Error(t, services.StartAndAwaitRunning(ctx, lifecycler))
+
+	_, exists := getInstanceFromStore(t, store, cfg.ID)
+	assert.False(t, exists)
+}
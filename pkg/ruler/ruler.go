@@ -132,6 +132,11 @@ func (cfg *Config) Validate(limits validation.Limits, log log.Logger) error {
 	if err := cfg.StoreConfig.Validate(); err != nil {
 		return errors.Wrap(err, "invalid storage config")
 	}
+	if cfg.EnableSharding {
+		if err := cfg.Ring.Validate(); err != nil {
+			return errors.Wrap(err, "invalid ring config")
+		}
+	}
 	if err := cfg.ClientTLSConfig.Validate(log); err != nil {
 		return errors.Wrap(err, "invalid ruler gRPC client config")
 	}
@@ -225,6 +230,7 @@ type Ruler struct {
 	cfg        Config
 	lifecycler *ring.BasicLifecycler
 	ring       *ring.Ring
+	ringStore  kv.Client
 	store      rulestore.RuleStore
 	manager    MultiTenantManager
 	limits     RulesLimits
@@ -283,6 +289,7 @@ func NewRuler(cfg Config, manager MultiTenantManager, reg prometheus.Registerer,
 		if err != nil {
 			return nil, errors.Wrap(err, "create KV store client")
 		}
+		ruler.ringStore = ringStore
 
 		if err = enableSharding(ruler, ringStore); err != nil {
 			return nil, errors.Wrap(err, "setup ruler sharding ring")
@@ -352,6 +359,11 @@ func (r *Ruler) stopping(_ error) error {
 	if r.subservices != nil {
 		_ = services.StopManagerAndAwaitStopped(context.Background(), r.subservices)
 	}
+
+	// The KV client is shared by the ring and the lifecycler, so we stop it once both are stopped.
+	if r.ringStore != nil {
+		kv.StopClient(r.ringStore)
+	}
 	return nil
 }
 
@@ -40,6 +40,8 @@ type RingConfig struct {
 	InstanceAddr           string   `yaml:"instance_addr" doc:"hidden"`
 	NumTokens              int      `yaml:"num_tokens"`
 
+	TokenGenerator ring.TokenGeneratorConfig `yaml:",inline"`
+
 	// Injected internally
 	ListenPort int `yaml:"-"`
 
@@ -66,6 +68,12 @@ func (cfg *RingConfig) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.InstancePort, "ruler.ring.instance-port", 0, "Port to advertise in the ring (defaults to server.grpc-listen-port).")
 	f.StringVar(&cfg.InstanceID, "ruler.ring.instance-id", hostname, "Instance ID to register in the ring.")
 	f.IntVar(&cfg.NumTokens, "ruler.ring.num-tokens", 128, "Number of tokens for each ruler.")
+	cfg.TokenGenerator.RegisterFlagsWithPrefix("ruler.ring.", f)
+}
+
+// Validate the config.
+func (cfg *RingConfig) Validate() error {
+	return cfg.TokenGenerator.Validate("")
 }
 
 // ToLifecyclerConfig returns a LifecyclerConfig based on the ruler
@@ -78,12 +86,18 @@ func (cfg *RingConfig) ToLifecyclerConfig() (ring.BasicLifecyclerConfig, error)
 
 	instancePort := ring.GetInstancePort(cfg.InstancePort, cfg.ListenPort)
 
+	tokenGenerator, err := cfg.TokenGenerator.NewTokenGenerator(cfg.InstanceID, "")
+	if err != nil {
+		return ring.BasicLifecyclerConfig{}, err
+	}
+
 	return ring.BasicLifecyclerConfig{
 		ID:                  cfg.InstanceID,
 		Addr:                fmt.Sprintf("%s:%d", instanceAddr, instancePort),
 		HeartbeatPeriod:     cfg.HeartbeatPeriod,
 		TokensObservePeriod: 0,
 		NumTokens:           cfg.NumTokens,
+		TokenGenerator:      tokenGenerator,
 	}, nil
 }
 
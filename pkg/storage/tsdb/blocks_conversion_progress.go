@@ -0,0 +1,84 @@
+package tsdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+// Relative to user-specific prefix.
+const BlocksConversionProgressPath = "markers/blocks-conversion-progress.json"
+
+// BlocksConversionProgress tracks the conversion of a tenant's chunks to blocks. It's written
+// by the blocksconvert scheduler and read by queriers to decide which store to query.
+type BlocksConversionProgress struct {
+	// Unix timestamp of the start of the oldest day from which all the tenant's days have been
+	// converted to blocks, and the blocks verified to exist in the storage. Zero if none.
+	ConvertedSince int64 `json:"converted_since"`
+}
+
+// ConvertedSinceTime returns the converted since timestamp as time, or zero time if the tenant
+// has no converted day.
+func (p *BlocksConversionProgress) ConvertedSinceTime() time.Time {
+	if p.ConvertedSince == 0 {
+		return time.Time{}
+	}
+	return time.Unix(p.ConvertedSince, 0).UTC()
+}
+
+func NewBlocksConversionProgress(convertedSince time.Time) *BlocksConversionProgress {
+	p := &BlocksConversionProgress{}
+	if !convertedSince.IsZero() {
+		p.ConvertedSince = convertedSince.Unix()
+	}
+	return p
+}
+
+// Uploads blocks conversion progress to the tenant location in the bucket.
+func WriteBlocksConversionProgress(ctx context.Context, bkt objstore.Bucket, userID string, cfgProvider bucket.TenantConfigProvider, progress *BlocksConversionProgress) error {
+	bkt = bucket.NewUserBucketClient(userID, bkt, cfgProvider)
+
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return errors.Wrap(err, "serialize blocks conversion progress")
+	}
+
+	return errors.Wrap(bkt.Upload(ctx, BlocksConversionProgressPath, bytes.NewReader(data)), "upload blocks conversion progress")
+}
+
+// Returns blocks conversion progress for given user, if it exists. If it doesn't exist, returns nil progress, and no error.
+func ReadBlocksConversionProgress(ctx context.Context, bkt objstore.BucketReader, userID string) (*BlocksConversionProgress, error) {
+	progressFile := path.Join(userID, BlocksConversionProgressPath)
+
+	r, err := bkt.Get(ctx, progressFile)
+	if err != nil {
+		if bkt.IsObjNotFoundErr(err) {
+			return nil, nil
+		}
+
+		return nil, errors.Wrapf(err, "failed to read blocks conversion progress object: %s", progressFile)
+	}
+
+	progress := &BlocksConversionProgress{}
+	err = json.NewDecoder(r).Decode(progress)
+
+	// Close reader before dealing with decode error.
+	if closeErr := r.Close(); closeErr != nil {
+		level.Warn(util_log.Logger).Log("msg", "failed to close bucket reader", "err", closeErr)
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode blocks conversion progress object: %s", progressFile)
+	}
+
+	return progress, nil
+}
@@ -0,0 +1,36 @@
+package tsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+)
+
+func TestBlocksConversionProgress(t *testing.T) {
+	const username = "user"
+
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+
+	// Reading a progress which doesn't exist returns no error.
+	progress, err := ReadBlocksConversionProgress(ctx, bkt, username)
+	require.NoError(t, err)
+	require.Nil(t, progress)
+
+	convertedSince := time.Date(2021, 6, 22, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, WriteBlocksConversionProgress(ctx, bkt, username, nil, NewBlocksConversionProgress(convertedSince)))
+
+	progress, err = ReadBlocksConversionProgress(ctx, bkt, username)
+	require.NoError(t, err)
+	require.Equal(t, convertedSince, progress.ConvertedSinceTime())
+
+	// Zero time means that no day has been converted yet.
+	require.NoError(t, WriteBlocksConversionProgress(ctx, bkt, username, nil, NewBlocksConversionProgress(time.Time{})))
+
+	progress, err = ReadBlocksConversionProgress(ctx, bkt, username)
+	require.NoError(t, err)
+	require.True(t, progress.ConvertedSinceTime().IsZero())
+}
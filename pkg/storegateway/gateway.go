@@ -74,6 +74,10 @@ func (cfg *Config) Validate(limits validation.Limits) error {
 		if cfg.ShardingStrategy == util.ShardingStrategyShuffle && limits.StoreGatewayTenantShardSize <= 0 {
 			return errInvalidTenantShardSize
 		}
+
+		if err := cfg.ShardingRing.Validate(); err != nil {
+			return errors.Wrap(err, "invalid sharding ring config")
+		}
 	}
 
 	return nil
@@ -93,6 +97,7 @@ type StoreGateway struct {
 	// Ring used for sharding blocks.
 	ringLifecycler *ring.BasicLifecycler
 	ring           *ring.Ring
+	ringStore      kv.Client
 
 	// Subservices manager (ring, lifecycler)
 	subservices        *services.Manager
@@ -130,6 +135,7 @@ func newStoreGateway(gatewayCfg Config, storageCfg cortex_tsdb.BlocksStorageConf
 		gatewayCfg: gatewayCfg,
 		storageCfg: storageCfg,
 		logger:     logger,
+		ringStore:  ringStore,
 		bucketSync: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 			Name: "cortex_storegateway_bucket_sync_total",
 			Help: "Total number of times the bucket sync operation triggered.",
@@ -315,10 +321,16 @@ func (g *StoreGateway) running(ctx context.Context) error {
 }
 
 func (g *StoreGateway) stopping(_ error) error {
+	var err error
 	if g.subservices != nil {
-		return services.StopManagerAndAwaitStopped(context.Background(), g.subservices)
+		err = services.StopManagerAndAwaitStopped(context.Background(), g.subservices)
 	}
-	return nil
+
+	// The KV client is shared by the ring and the lifecycler, so we stop it once both are stopped.
+	if g.ringStore != nil {
+		kv.StopClient(g.ringStore)
+	}
+	return err
 }
 
 func (g *StoreGateway) syncStores(ctx context.Context, reason string) {
@@ -346,7 +358,7 @@ func (g *StoreGateway) LabelValues(ctx context.Context, req *storepb.LabelValues
 	return g.stores.LabelValues(ctx, req)
 }
 
-func (g *StoreGateway) OnRingInstanceRegister(_ *ring.BasicLifecycler, ringDesc ring.Desc, instanceExists bool, instanceID string, instanceDesc ring.InstanceDesc) (ring.InstanceState, ring.Tokens) {
+func (g *StoreGateway) OnRingInstanceRegister(lifecycler *ring.BasicLifecycler, ringDesc ring.Desc, instanceExists bool, instanceID string, instanceDesc ring.InstanceDesc) (ring.InstanceState, ring.Tokens) {
 	// When we initialize the store-gateway instance in the ring we want to start from
 	// a clean situation, so whatever is the state we set it JOINING, while we keep existing
 	// tokens (if any) or the ones loaded from file.
@@ -356,7 +368,7 @@ func (g *StoreGateway) OnRingInstanceRegister(_ *ring.BasicLifecycler, ringDesc
 	}
 
 	takenTokens := ringDesc.GetTokens()
-	newTokens := ring.GenerateTokens(RingNumTokens-len(tokens), takenTokens)
+	newTokens := lifecycler.GenerateTokens(RingNumTokens-len(tokens), takenTokens)
 
 	// Tokens sorting will be enforced by the parent caller.
 	tokens = append(tokens, newTokens...)
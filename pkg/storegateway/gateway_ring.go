@@ -77,6 +77,8 @@ type RingConfig struct {
 	InstanceAddr           string   `yaml:"instance_addr" doc:"hidden"`
 	InstanceZone           string   `yaml:"instance_availability_zone"`
 
+	TokenGenerator ring.TokenGeneratorConfig `yaml:",inline"`
+
 	// Injected internally
 	ListenPort      int           `yaml:"-"`
 	RingCheckPeriod time.Duration `yaml:"-"`
@@ -111,11 +113,17 @@ func (cfg *RingConfig) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.InstancePort, ringFlagsPrefix+"instance-port", 0, "Port to advertise in the ring (defaults to server.grpc-listen-port).")
 	f.StringVar(&cfg.InstanceID, ringFlagsPrefix+"instance-id", hostname, "Instance ID to register in the ring.")
 	f.StringVar(&cfg.InstanceZone, ringFlagsPrefix+"instance-availability-zone", "", "The availability zone where this instance is running. Required if zone-awareness is enabled.")
+	cfg.TokenGenerator.RegisterFlagsWithPrefix(ringFlagsPrefix, f)
 
 	// Defaults for internal settings.
 	cfg.RingCheckPeriod = 5 * time.Second
 }
 
+// Validate the config.
+func (cfg *RingConfig) Validate() error {
+	return cfg.TokenGenerator.Validate(cfg.InstanceZone)
+}
+
 func (cfg *RingConfig) ToRingConfig() ring.Config {
 	rc := ring.Config{}
 	flagext.DefaultValues(&rc)
@@ -137,6 +145,11 @@ func (cfg *RingConfig) ToLifecyclerConfig() (ring.BasicLifecyclerConfig, error)
 
 	instancePort := ring.GetInstancePort(cfg.InstancePort, cfg.ListenPort)
 
+	tokenGenerator, err := cfg.TokenGenerator.NewTokenGenerator(cfg.InstanceID, cfg.InstanceZone)
+	if err != nil {
+		return ring.BasicLifecyclerConfig{}, err
+	}
+
 	return ring.BasicLifecyclerConfig{
 		ID:                  cfg.InstanceID,
 		Addr:                fmt.Sprintf("%s:%d", instanceAddr, instancePort),
@@ -144,5 +157,6 @@ func (cfg *RingConfig) ToLifecyclerConfig() (ring.BasicLifecyclerConfig, error)
 		HeartbeatPeriod:     cfg.HeartbeatPeriod,
 		TokensObservePeriod: 0,
 		NumTokens:           RingNumTokens,
+		TokenGenerator:      tokenGenerator,
 	}, nil
 }
@@ -0,0 +1,613 @@
+package correctness
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/cortexpb"
+	"github.com/cortexproject/cortex/pkg/util/spanlogger"
+)
+
+const (
+	proberMetricName  = "prober_series"
+	proberSeriesLabel = "series"
+	proberIDLabel     = "prober_id"
+
+	checkRangeQuery   = "range_query"
+	checkInstantQuery = "instant_query"
+	checkSeries       = "series"
+
+	timeRangeIngester     = "ingester"
+	timeRangeStoreGateway = "store-gateway"
+	timeRangeMixed        = "mixed"
+)
+
+// ProberConfig is the config of the Prober.
+type ProberConfig struct {
+	Enabled              bool
+	RemoteWriteURL       string
+	ProberID             string
+	NumSeries            int
+	WriteInterval        time.Duration
+	WriteTimeout         time.Duration
+	QueryInterval        time.Duration
+	QueryRange           time.Duration
+	Lookbacks            durationList
+	QueryStoreAfter      time.Duration
+	QueryIngestersWithin time.Duration
+	timeQueryStart       TimeValue
+	durationQuerySince   time.Duration
+
+	PrometheusAddr string
+	UserID         string
+}
+
+func (cfg *ProberConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "prober-enabled", false, "Enable the prober, which writes known series through remote write and continuously checks they are correctly returned by the query APIs.")
+	f.StringVar(&cfg.RemoteWriteURL, "prober-remote-write-url", "", "URL of the remote write endpoint where the prober writes its series, ie. http://distributor/api/v1/push.")
+	f.StringVar(&cfg.ProberID, "prober-id", "test-exporter", "Value of the prober_id label added to the series written by the prober. Must be unique among the probers writing to the same tenant.")
+	f.IntVar(&cfg.NumSeries, "prober-series", 10, "Number of series written by the prober.")
+	f.DurationVar(&cfg.WriteInterval, "prober-write-interval", 15*time.Second, "Interval at which the prober writes a sample for each series.")
+	f.DurationVar(&cfg.WriteTimeout, "prober-write-timeout", 10*time.Second, "Timeout of the prober remote write requests.")
+	f.DurationVar(&cfg.QueryInterval, "prober-query-interval", time.Minute, "Interval at which the prober runs the checks.")
+	f.DurationVar(&cfg.QueryRange, "prober-query-range", 10*time.Minute, "Length of the time range queried by each check.")
+
+	cfg.Lookbacks = durationList{0, time.Hour, 12*time.Hour + 30*time.Minute, 24 * time.Hour}
+	f.Var(&cfg.Lookbacks, "prober-lookbacks", "Comma separated list of how far in the past the time ranges queried by the checks end. The checks of the time ranges starting before the prober started writing, or before -prober-test-query-start or -prober-test-query-since if set, are skipped.")
+	f.DurationVar(&cfg.QueryStoreAfter, "prober-query-store-after", 12*time.Hour, "The -querier.query-store-after of the queried Cortex cluster, used to classify the time ranges queried by the checks.")
+	f.DurationVar(&cfg.QueryIngestersWithin, "prober-query-ingesters-within", 13*time.Hour, "The -querier.query-ingesters-within of the queried Cortex cluster, used to classify the time ranges queried by the checks.")
+
+	// By default, we only query for values from when this process started writing.
+	f.Var(&cfg.timeQueryStart, "prober-test-query-start", "Minimum start date for queries. Set it to the time the prober started writing series, in order to check the series written before a restart. By default, only the series written since the prober started are checked.")
+	f.DurationVar(&cfg.durationQuerySince, "prober-test-query-since", 0, "Duration in the past to test.  Overrides -prober-test-query-start")
+}
+
+func (cfg *ProberConfig) Validate() error {
+	if cfg.RemoteWriteURL == "" {
+		return errors.New("the prober remote write URL is required")
+	}
+	if cfg.NumSeries <= 0 {
+		return errors.New("the number of prober series must be greater than 0")
+	}
+	if cfg.WriteInterval <= 0 || cfg.QueryInterval <= 0 {
+		return errors.New("the prober write and query intervals must be greater than 0")
+	}
+	if cfg.QueryRange < cfg.WriteInterval {
+		return errors.New("the prober query range must be greater than or equal to the write interval")
+	}
+	return nil
+}
+
+// Prober writes known series through remote write at a steady rate and checks they're
+// returned by the range query, instant query and series APIs, querying time ranges served
+// by the ingesters, the store-gateways or both.
+type Prober struct {
+	cfg        ProberConfig
+	client     v1.API
+	httpClient *http.Client
+	logger     log.Logger
+	selector   string
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+
+	mtx sync.Mutex
+	// Timestamp of the first and last samples written.
+	firstWrite, lastWrite time.Time
+	// Timestamps of the samples whose write failed. They're not expected to be queried back.
+	failedWrites map[int64]struct{}
+
+	writesTotal         *prometheus.CounterVec
+	writtenSamplesTotal prometheus.Counter
+	checksTotal         *prometheus.CounterVec
+	checkDuration       *prometheus.HistogramVec
+	missingSamplesTotal *prometheus.CounterVec
+	wrongSamplesTotal   *prometheus.CounterVec
+	missingSeriesTotal  *prometheus.CounterVec
+	extraSeriesTotal    *prometheus.CounterVec
+	lastCheckSuccess    *prometheus.GaugeVec
+}
+
+// NewProber makes a new Prober and starts writing and checking series.
+func NewProber(cfg ProberConfig, logger log.Logger, reg prometheus.Registerer) (*Prober, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	client, err := newPrometheusClient(cfg.PrometheusAddr, cfg.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	p := newProber(cfg, client, logger, reg)
+	p.wg.Add(2)
+	go p.writeLoop()
+	go p.checkLoop()
+	return p, nil
+}
+
+func newProber(cfg ProberConfig, client v1.API, logger log.Logger, reg prometheus.Registerer) *Prober {
+	return &Prober{
+		cfg:          cfg,
+		client:       client,
+		httpClient:   &http.Client{Timeout: cfg.WriteTimeout},
+		logger:       logger,
+		selector:     fmt.Sprintf("%s{%s=%q}", prometheus.BuildFQName(namespace, subsystem, proberMetricName), proberIDLabel, cfg.ProberID),
+		quit:         make(chan struct{}),
+		failedWrites: map[int64]struct{}{},
+
+		writesTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "prober_writes_total",
+			Help:      "Total number of remote write requests sent by the prober, by result.",
+		}, []string{"result"}),
+		writtenSamplesTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "prober_written_samples_total",
+			Help:      "Total number of samples successfully written by the prober.",
+		}),
+		checksTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "prober_checks_total",
+			Help:      "Total number of prober checks, by check, queried time range and result.",
+		}, []string{"check", "time_range", "result"}),
+		checkDuration: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "prober_check_duration_seconds",
+			Help:      "Time spent running the prober checks.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"check", "time_range"}),
+		missingSamplesTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "prober_missing_samples_total",
+			Help:      "Total number of samples successfully written by the prober but missing from the query results.",
+		}, []string{"check", "time_range"}),
+		wrongSamplesTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "prober_wrong_samples_total",
+			Help:      "Total number of samples with an unexpected value or timestamp in the query results.",
+		}, []string{"check", "time_range"}),
+		missingSeriesTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "prober_missing_series_total",
+			Help:      "Total number of series written by the prober but missing from the query results.",
+		}, []string{"check", "time_range"}),
+		extraSeriesTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "prober_extra_series_total",
+			Help:      "Total number of unexpected series in the query results.",
+		}, []string{"check", "time_range"}),
+		lastCheckSuccess: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "prober_last_check_success_timestamp_seconds",
+			Help:      "Timestamp of the last successful prober check, by check and queried time range.",
+		}, []string{"check", "time_range"}),
+	}
+}
+
+// Stop the prober.
+func (p *Prober) Stop() {
+	close(p.quit)
+	p.wg.Wait()
+}
+
+func (p *Prober) writeLoop() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.cfg.WriteInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.quit:
+			return
+		case now := <-ticker.C:
+			if err := p.write(context.Background(), now); err != nil {
+				level.Warn(p.logger).Log("msg", "prober failed to write series", "err", err)
+			}
+		}
+	}
+}
+
+func (p *Prober) checkLoop() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.cfg.QueryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.quit:
+			return
+		case now := <-ticker.C:
+			p.check(context.Background(), now)
+		}
+	}
+}
+
+// expectedValue returns the value written by the prober for the series at the timestamp.
+func expectedValue(series int, ts model.Time) model.SampleValue {
+	return model.SampleValue(float64(ts.Unix()) + float64(series))
+}
+
+// write writes a sample for each series, at the timestamp aligned to the write interval.
+func (p *Prober) write(ctx context.Context, now time.Time) error {
+	ts := now.Truncate(p.cfg.WriteInterval)
+	err := p.push(ctx, model.TimeFromUnixNano(ts.UnixNano()))
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.firstWrite.IsZero() {
+		p.firstWrite = ts
+	}
+	p.lastWrite = ts
+
+	if err != nil {
+		p.writesTotal.WithLabelValues(fail).Inc()
+		p.failedWrites[ts.UnixNano()] = struct{}{}
+		return err
+	}
+
+	p.writesTotal.WithLabelValues(success).Inc()
+	p.writtenSamplesTotal.Add(float64(p.cfg.NumSeries))
+
+	// Forget the failed writes which are not queried anymore.
+	minTime := ts.Add(-p.maxLookback() - p.cfg.QueryRange)
+	for failed := range p.failedWrites {
+		if failed < minTime.UnixNano() {
+			delete(p.failedWrites, failed)
+		}
+	}
+	return nil
+}
+
+func (p *Prober) push(ctx context.Context, ts model.Time) error {
+	lbls := make([]labels.Labels, 0, p.cfg.NumSeries)
+	samples := make([]cortexpb.Sample, 0, p.cfg.NumSeries)
+	for i := 0; i < p.cfg.NumSeries; i++ {
+		lbls = append(lbls, p.seriesLabels(i))
+		samples = append(samples, cortexpb.Sample{TimestampMs: int64(ts), Value: float64(expectedValue(i, ts))})
+	}
+
+	writeReq := cortexpb.ToWriteRequest(lbls, samples, nil, cortexpb.API)
+	data, err := writeReq.Marshal()
+	cortexpb.ReuseSlice(writeReq.Timeseries)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.RemoteWriteURL, bytes.NewReader(snappy.Encode(nil, data)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if p.cfg.UserID != "" {
+		req.Header.Set(user.OrgIDHeaderName, p.cfg.UserID)
+	}
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("remote write failed with status code %d: %s", res.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (p *Prober) seriesLabels(series int) labels.Labels {
+	return labels.FromStrings(
+		labels.MetricName, prometheus.BuildFQName(namespace, subsystem, proberMetricName),
+		proberIDLabel, p.cfg.ProberID,
+		proberSeriesLabel, strconv.Itoa(series),
+	)
+}
+
+func (p *Prober) maxLookback() time.Duration {
+	max := time.Duration(0)
+	for _, l := range p.cfg.Lookbacks {
+		if l > max {
+			max = l
+		}
+	}
+	return max
+}
+
+// probeRange is a time range queried by the prober checks.
+type probeRange struct {
+	start, end time.Time
+	kind       string
+
+	// Timestamps of the samples expected in the time range, start and end included.
+	expected []model.Time
+}
+
+// ranges returns the time ranges to check, one per lookback. The time ranges starting before
+// the prober started writing are skipped, unless the min query time is configured in order
+// to check the series written before a restart.
+func (p *Prober) ranges(now time.Time) []probeRange {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.firstWrite.IsZero() {
+		return nil
+	}
+
+	minTime := p.firstWrite
+	if p.cfg.durationQuerySince != 0 || p.cfg.timeQueryStart.set {
+		minTime = calculateMinQueryTime(p.cfg.durationQuerySince, p.cfg.timeQueryStart)
+	}
+
+	var ranges []probeRange
+	for _, lookback := range p.cfg.Lookbacks {
+		end := now.Add(-lookback).Truncate(p.cfg.WriteInterval)
+		if end.After(p.lastWrite) {
+			end = p.lastWrite
+		}
+		start := end.Add(-p.cfg.QueryRange)
+		if start.Before(minTime) {
+			continue
+		}
+
+		r := probeRange{start: start, end: end, kind: p.classify(now, start, end)}
+		for ts := start; !ts.After(end); ts = ts.Add(p.cfg.WriteInterval) {
+			if _, failed := p.failedWrites[ts.UnixNano()]; !failed {
+				r.expected = append(r.expected, model.TimeFromUnixNano(ts.UnixNano()))
+			}
+		}
+		ranges = append(ranges, r)
+	}
+
+	return ranges
+}
+
+// classify returns whether the time range is served by the ingesters, the store-gateways or both.
+func (p *Prober) classify(now, start, end time.Time) string {
+	switch {
+	case p.cfg.QueryStoreAfter > 0 && !start.Before(now.Add(-p.cfg.QueryStoreAfter)):
+		return timeRangeIngester
+	case p.cfg.QueryIngestersWithin > 0 && end.Before(now.Add(-p.cfg.QueryIngestersWithin)):
+		return timeRangeStoreGateway
+	default:
+		return timeRangeMixed
+	}
+}
+
+// checkResult is the outcome of a check.
+type checkResult struct {
+	missingSamples int
+	wrongSamples   int
+	missingSeries  int
+	extraSeries    int
+}
+
+func (r checkResult) failed() bool {
+	return r.missingSamples > 0 || r.wrongSamples > 0 || r.missingSeries > 0 || r.extraSeries > 0
+}
+
+// check runs all checks on each time range.
+func (p *Prober) check(ctx context.Context, now time.Time) {
+	for _, r := range p.ranges(now) {
+		p.runCheck(ctx, checkRangeQuery, r, p.checkRangeQuery)
+		p.runCheck(ctx, checkInstantQuery, r, p.checkInstantQuery)
+		p.runCheck(ctx, checkSeries, r, p.checkSeries)
+	}
+}
+
+func (p *Prober) runCheck(ctx context.Context, check string, r probeRange, f func(context.Context, probeRange) (checkResult, error)) {
+	log, ctx := spanlogger.New(ctx, "Prober.check")
+	defer log.Finish()
+
+	start := time.Now()
+	res, err := f(ctx, r)
+	p.checkDuration.WithLabelValues(check, r.kind).Observe(time.Since(start).Seconds())
+
+	p.missingSamplesTotal.WithLabelValues(check, r.kind).Add(float64(res.missingSamples))
+	p.wrongSamplesTotal.WithLabelValues(check, r.kind).Add(float64(res.wrongSamples))
+	p.missingSeriesTotal.WithLabelValues(check, r.kind).Add(float64(res.missingSeries))
+	p.extraSeriesTotal.WithLabelValues(check, r.kind).Add(float64(res.extraSeries))
+
+	if err != nil || res.failed() {
+		p.checksTotal.WithLabelValues(check, r.kind, fail).Inc()
+		level.Error(log).Log("msg", "prober check failed", "check", check, "time_range", r.kind, "start", r.start, "end", r.end,
+			"missing_samples", res.missingSamples, "wrong_samples", res.wrongSamples, "missing_series", res.missingSeries, "extra_series", res.extraSeries, "err", err)
+		return
+	}
+
+	p.checksTotal.WithLabelValues(check, r.kind, success).Inc()
+	p.lastCheckSuccess.WithLabelValues(check, r.kind).SetToCurrentTime()
+}
+
+// checkRangeQuery checks the series value at each step of a range query, with the step
+// aligned to the written samples.
+func (p *Prober) checkRangeQuery(ctx context.Context, r probeRange) (checkResult, error) {
+	value, _, err := p.client.QueryRange(ctx, p.selector, v1.Range{Start: r.start, End: r.end, Step: p.cfg.WriteInterval})
+	if err != nil {
+		return checkResult{}, err
+	}
+
+	matrix, ok := value.(model.Matrix)
+	if !ok {
+		return checkResult{}, fmt.Errorf("expected matrix but got %s", value.Type())
+	}
+
+	// A step whose sample failed to be written gets the previous sample, so it's not checked.
+	expected := make(map[model.Time]struct{}, len(r.expected))
+	for _, ts := range r.expected {
+		expected[ts] = struct{}{}
+	}
+
+	return p.verifyMatrix(matrix, r.expected, func(ts model.Time) bool {
+		_, ok := expected[ts]
+		return !ok
+	}), nil
+}
+
+// checkInstantQuery checks the raw samples returned by an instant query with a range selector.
+func (p *Prober) checkInstantQuery(ctx context.Context, r probeRange) (checkResult, error) {
+	// The range selector includes the samples in [end - range, end], so all the samples from start to end.
+	query := fmt.Sprintf("%s[%ds]", p.selector, int64(p.cfg.QueryRange/time.Second))
+	value, _, err := p.client.Query(ctx, query, r.end)
+	if err != nil {
+		return checkResult{}, err
+	}
+
+	matrix, ok := value.(model.Matrix)
+	if !ok {
+		return checkResult{}, fmt.Errorf("expected matrix but got %s", value.Type())
+	}
+
+	return p.verifyMatrix(matrix, r.expected, nil), nil
+}
+
+// verifyMatrix verifies each series has the expected samples. Samples at timestamps for which
+// ignore returns true are not verified.
+func (p *Prober) verifyMatrix(matrix model.Matrix, expected []model.Time, ignore func(model.Time) bool) checkResult {
+	res := checkResult{}
+	found := make(map[int]bool, p.cfg.NumSeries)
+
+	for _, stream := range matrix {
+		series, err := strconv.Atoi(string(stream.Metric[proberSeriesLabel]))
+		if err != nil || series < 0 || series >= p.cfg.NumSeries || found[series] {
+			res.extraSeries++
+			continue
+		}
+		found[series] = true
+
+		actual := make(map[model.Time]model.SampleValue, len(stream.Values))
+		for _, pair := range stream.Values {
+			if ignore != nil && ignore(pair.Timestamp) {
+				continue
+			}
+			actual[pair.Timestamp] = pair.Value
+		}
+
+		for _, ts := range expected {
+			value, ok := actual[ts]
+			if !ok {
+				res.missingSamples++
+				continue
+			}
+			if value != expectedValue(series, ts) {
+				// The range query returns the previous sample for a step whose sample is missing,
+				// so a value written at an earlier timestamp is a missing sample.
+				if value < expectedValue(series, ts) {
+					res.missingSamples++
+				} else {
+					res.wrongSamples++
+				}
+			}
+			delete(actual, ts)
+		}
+
+		// Any sample left is unexpected.
+		res.wrongSamples += len(actual)
+	}
+
+	for series := 0; series < p.cfg.NumSeries; series++ {
+		if !found[series] {
+			res.missingSeries++
+			res.missingSamples += len(expected)
+		}
+	}
+
+	return res
+}
+
+// checkSeries checks all series are returned by the series API.
+func (p *Prober) checkSeries(ctx context.Context, r probeRange) (checkResult, error) {
+	series, _, err := p.client.Series(ctx, []string{p.selector}, r.start, r.end)
+	if err != nil {
+		return checkResult{}, err
+	}
+
+	expected := make(map[string]struct{}, p.cfg.NumSeries)
+	for i := 0; i < p.cfg.NumSeries; i++ {
+		expected[p.seriesLabels(i).String()] = struct{}{}
+	}
+
+	res := checkResult{}
+	for _, s := range series {
+		key := labelSetToLabels(s).String()
+		if _, ok := expected[key]; !ok {
+			res.extraSeries++
+			continue
+		}
+		delete(expected, key)
+	}
+	res.missingSeries = len(expected)
+
+	return res, nil
+}
+
+func labelSetToLabels(ls model.LabelSet) labels.Labels {
+	b := labels.NewBuilder(nil)
+	for k, v := range ls {
+		b.Set(string(k), string(v))
+	}
+	return b.Labels()
+}
+
+// durationList is a comma separated list of durations that can be used as a flag.
+type durationList []time.Duration
+
+// String implements flag.Value
+func (l durationList) String() string {
+	parts := make([]string, 0, len(l))
+	for _, d := range l {
+		parts = append(parts, model.Duration(d).String())
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set implements flag.Value
+func (l *durationList) Set(s string) error {
+	var list durationList
+	for _, part := range strings.Split(s, ",") {
+		d, err := model.ParseDuration(strings.TrimSpace(part))
+		if err != nil {
+			return err
+		}
+		list = append(list, time.Duration(d))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+
+	*l = list
+	return nil
+}
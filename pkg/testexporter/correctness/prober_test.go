@@ -0,0 +1,308 @@
+package correctness
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/cortexpb"
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+func TestProber(t *testing.T) {
+	storage := newFakeStorage()
+	server := httptest.NewServer(storage)
+	defer server.Close()
+
+	t0 := time.Unix(1626775200, 0)
+	cfg := ProberConfig{
+		RemoteWriteURL:       server.URL,
+		ProberID:             "test",
+		NumSeries:            3,
+		WriteInterval:        15 * time.Second,
+		WriteTimeout:         time.Second,
+		QueryInterval:        time.Minute,
+		QueryRange:           time.Minute,
+		Lookbacks:            durationList{0, 2 * time.Minute},
+		QueryStoreAfter:      150 * time.Second,
+		QueryIngestersWithin: 4 * time.Minute,
+		timeQueryStart:       NewTimeValue(t0.Add(-time.Hour)),
+		UserID:               "user-1",
+	}
+	require.NoError(t, cfg.Validate())
+
+	reg := prometheus.NewPedanticRegistry()
+	p := newProber(cfg, &fakeAPI{storage: storage}, log.NewNopLogger(), reg)
+
+	// Write for 5 minutes, with a failed write.
+	storage.failAt = t0.Add(4 * time.Minute)
+	for ts := t0; !ts.After(t0.Add(5 * time.Minute)); ts = ts.Add(cfg.WriteInterval) {
+		err := p.write(context.Background(), ts)
+		if ts.Equal(storage.failAt) {
+			require.Error(t, err)
+		} else {
+			require.NoError(t, err)
+		}
+	}
+
+	assert.Equal(t, float64(20), testutil.ToFloat64(p.writesTotal.WithLabelValues(success)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.writesTotal.WithLabelValues(fail)))
+	assert.Equal(t, float64(60), testutil.ToFloat64(p.writtenSamplesTotal))
+	assert.Equal(t, []string{"user-1"}, storage.tenants())
+
+	// The failed write is not reported as data loss.
+	now := t0.Add(5 * time.Minute)
+	p.check(context.Background(), now)
+
+	for _, check := range []string{checkRangeQuery, checkInstantQuery, checkSeries} {
+		assert.Equal(t, float64(1), testutil.ToFloat64(p.checksTotal.WithLabelValues(check, timeRangeIngester, success)), check)
+		assert.Equal(t, float64(1), testutil.ToFloat64(p.checksTotal.WithLabelValues(check, timeRangeMixed, success)), check)
+		assert.Equal(t, float64(0), testutil.ToFloat64(p.checksTotal.WithLabelValues(check, timeRangeIngester, fail)), check)
+		assert.Equal(t, float64(0), testutil.ToFloat64(p.checksTotal.WithLabelValues(check, timeRangeMixed, fail)), check)
+	}
+
+	// Lose a sample of a series, and a whole series.
+	storage.delete("0", model.TimeFromUnixNano(t0.Add(4*time.Minute+30*time.Second).UnixNano()))
+	storage.delete("2", 0)
+	p.check(context.Background(), now)
+
+	// Both the range query and the instant query detect the lost sample, and the 4 samples of the lost series.
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.checksTotal.WithLabelValues(checkRangeQuery, timeRangeIngester, fail)))
+	assert.Equal(t, float64(5), testutil.ToFloat64(p.missingSamplesTotal.WithLabelValues(checkRangeQuery, timeRangeIngester)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(p.wrongSamplesTotal.WithLabelValues(checkRangeQuery, timeRangeIngester)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.checksTotal.WithLabelValues(checkInstantQuery, timeRangeIngester, fail)))
+	assert.Equal(t, float64(5), testutil.ToFloat64(p.missingSamplesTotal.WithLabelValues(checkInstantQuery, timeRangeIngester)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.missingSeriesTotal.WithLabelValues(checkInstantQuery, timeRangeIngester)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.checksTotal.WithLabelValues(checkSeries, timeRangeIngester, fail)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.missingSeriesTotal.WithLabelValues(checkSeries, timeRangeIngester)))
+
+	// The mixed time range only lost a series, including the sample at the start of the range.
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.checksTotal.WithLabelValues(checkInstantQuery, timeRangeMixed, fail)))
+	assert.Equal(t, float64(5), testutil.ToFloat64(p.missingSamplesTotal.WithLabelValues(checkInstantQuery, timeRangeMixed)))
+}
+
+func TestProber_ranges(t *testing.T) {
+	t0 := time.Unix(1626775200, 0)
+	cfg := ProberConfig{
+		WriteInterval:        15 * time.Second,
+		QueryRange:           10 * time.Minute,
+		Lookbacks:            durationList{0, time.Hour, 12*time.Hour + 30*time.Minute, 24 * time.Hour},
+		QueryStoreAfter:      12 * time.Hour,
+		QueryIngestersWithin: 13 * time.Hour,
+	}
+	p := newProber(cfg, nil, log.NewNopLogger(), nil)
+
+	// Nothing is checked until the first write.
+	assert.Empty(t, p.ranges(t0))
+
+	p.firstWrite = t0.Add(-24 * time.Hour)
+	p.lastWrite = t0.Add(-15 * time.Second)
+	p.failedWrites[t0.Add(-time.Hour-time.Minute).UnixNano()] = struct{}{}
+
+	ranges := p.ranges(t0.Add(10 * time.Second))
+	require.Len(t, ranges, 3)
+
+	// The end is capped to the last write.
+	assert.Equal(t, t0.Add(-15*time.Second), ranges[0].end)
+	assert.Equal(t, t0.Add(-10*time.Minute-15*time.Second), ranges[0].start)
+	assert.Equal(t, timeRangeIngester, ranges[0].kind)
+	assert.Len(t, ranges[0].expected, 41)
+
+	// The failed write is not expected.
+	assert.Equal(t, t0.Add(-time.Hour), ranges[1].end)
+	assert.Equal(t, timeRangeIngester, ranges[1].kind)
+	assert.Len(t, ranges[1].expected, 40)
+
+	assert.Equal(t, timeRangeMixed, ranges[2].kind)
+
+	// The time range starting before the first write is skipped, otherwise it's served by the store-gateway.
+	p.firstWrite = t0.Add(-48 * time.Hour)
+	ranges = p.ranges(t0.Add(10 * time.Second))
+	require.Len(t, ranges, 4)
+	assert.Equal(t, timeRangeStoreGateway, ranges[3].kind)
+
+	// The time ranges starting before the first write are checked if the min query time is
+	// configured, eg. after a restart.
+	p.firstWrite = t0.Add(-15 * time.Second)
+	p.cfg.timeQueryStart = NewTimeValue(t0.Add(-48 * time.Hour))
+	require.Len(t, p.ranges(t0.Add(10*time.Second)), 4)
+
+	p.cfg.timeQueryStart = NewTimeValue(t0.Add(-12 * time.Hour))
+	require.Len(t, p.ranges(t0.Add(10*time.Second)), 2)
+}
+
+func TestDurationList(t *testing.T) {
+	var l durationList
+	require.NoError(t, l.Set("1h, 0s,30m"))
+	assert.Equal(t, durationList{0, 30 * time.Minute, time.Hour}, l)
+	assert.Equal(t, "0s,30m,1h", l.String())
+	assert.Error(t, l.Set("1h,abc"))
+}
+
+// fakeStorage is a remote write endpoint storing the samples in memory.
+type fakeStorage struct {
+	mtx     sync.Mutex
+	series  map[string][]model.SamplePair
+	users   map[string]struct{}
+	failAt  time.Time
+	metrics map[string]model.Metric
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{
+		series:  map[string][]model.SamplePair{},
+		users:   map[string]struct{}{},
+		metrics: map[string]model.Metric{},
+	}
+}
+
+func (s *fakeStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req cortexpb.WriteRequest
+	if err := util.ParseProtoReader(r.Context(), r.Body, int(r.ContentLength), 1<<20, &req, util.RawSnappy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.users[r.Header.Get("X-Scope-OrgID")] = struct{}{}
+	for _, ts := range req.Timeseries {
+		metric := model.Metric{}
+		for _, l := range ts.Labels {
+			metric[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+		}
+		for _, sample := range ts.Samples {
+			if model.Time(sample.TimestampMs).Time().Equal(s.failAt) {
+				http.Error(w, "failed", http.StatusInternalServerError)
+				return
+			}
+			id := string(metric[proberSeriesLabel])
+			s.metrics[id] = metric
+			s.series[id] = append(s.series[id], model.SamplePair{Timestamp: model.Time(sample.TimestampMs), Value: model.SampleValue(sample.Value)})
+		}
+	}
+}
+
+func (s *fakeStorage) tenants() []string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var tenants []string
+	for u := range s.users {
+		tenants = append(tenants, u)
+	}
+	return tenants
+}
+
+// delete deletes the sample of the series at the timestamp, or the whole series if the timestamp is 0.
+func (s *fakeStorage) delete(series string, ts model.Time) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if ts == 0 {
+		delete(s.series, series)
+		delete(s.metrics, series)
+		return
+	}
+
+	var kept []model.SamplePair
+	for _, pair := range s.series[series] {
+		if pair.Timestamp != ts {
+			kept = append(kept, pair)
+		}
+	}
+	s.series[series] = kept
+}
+
+// fakeAPI answers the queries run by the prober from the fake storage.
+type fakeAPI struct {
+	v1.API
+	storage *fakeStorage
+}
+
+var rangeSelectorRegexp = regexp.MustCompile(`\[(\d+)s\]$`)
+
+func (a *fakeAPI) Query(_ context.Context, query string, ts time.Time) (model.Value, v1.Warnings, error) {
+	match := rangeSelectorRegexp.FindStringSubmatch(query)
+	seconds, _ := strconv.Atoi(match[1])
+	end := model.TimeFromUnixNano(ts.UnixNano())
+	start := end.Add(-time.Duration(seconds) * time.Second)
+
+	a.storage.mtx.Lock()
+	defer a.storage.mtx.Unlock()
+
+	matrix := model.Matrix{}
+	for id, pairs := range a.storage.series {
+		stream := &model.SampleStream{Metric: a.storage.metrics[id]}
+		for _, pair := range pairs {
+			// The PromQL engine includes both the range selector bounds.
+			if pair.Timestamp >= start && pair.Timestamp <= end {
+				stream.Values = append(stream.Values, pair)
+			}
+		}
+		if len(stream.Values) > 0 {
+			matrix = append(matrix, stream)
+		}
+	}
+	return matrix, nil, nil
+}
+
+func (a *fakeAPI) QueryRange(_ context.Context, _ string, r v1.Range) (model.Value, v1.Warnings, error) {
+	a.storage.mtx.Lock()
+	defer a.storage.mtx.Unlock()
+
+	matrix := model.Matrix{}
+	for id, pairs := range a.storage.series {
+		stream := &model.SampleStream{Metric: a.storage.metrics[id]}
+		for step := r.Start; !step.After(r.End); step = step.Add(r.Step) {
+			ts := model.TimeFromUnixNano(step.UnixNano())
+
+			// Pick the latest sample within the 5m lookback.
+			var latest *model.SamplePair
+			for i := range pairs {
+				if pairs[i].Timestamp <= ts && pairs[i].Timestamp > ts.Add(-5*time.Minute) {
+					latest = &pairs[i]
+				}
+			}
+			if latest != nil {
+				stream.Values = append(stream.Values, model.SamplePair{Timestamp: ts, Value: latest.Value})
+			}
+		}
+		if len(stream.Values) > 0 {
+			matrix = append(matrix, stream)
+		}
+	}
+	return matrix, nil, nil
+}
+
+func (a *fakeAPI) Series(_ context.Context, _ []string, startTime, endTime time.Time) ([]model.LabelSet, v1.Warnings, error) {
+	start, end := model.TimeFromUnixNano(startTime.UnixNano()), model.TimeFromUnixNano(endTime.UnixNano())
+
+	a.storage.mtx.Lock()
+	defer a.storage.mtx.Unlock()
+
+	var series []model.LabelSet
+	for id, pairs := range a.storage.series {
+		for _, pair := range pairs {
+			if pair.Timestamp >= start && pair.Timestamp <= end {
+				series = append(series, model.LabelSet(a.storage.metrics[id]))
+				break
+			}
+		}
+	}
+	return series, nil, nil
+}
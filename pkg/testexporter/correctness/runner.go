@@ -80,13 +80,31 @@ type Runner struct {
 
 // NewRunner makes a new Runner.
 func NewRunner(cfg RunnerConfig) (*Runner, error) {
+	client, err := newPrometheusClient(cfg.PrometheusAddr, cfg.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	tc := &Runner{
+		cfg:    cfg,
+		quit:   make(chan struct{}),
+		client: client,
+	}
+
+	tc.wg.Add(1)
+	go tc.verifyLoop()
+	return tc, nil
+}
+
+// newPrometheusClient makes a traced client of the Prometheus API, sending the requests on behalf of the user, if any.
+func newPrometheusClient(address, userID string) (v1.API, error) {
 	apiCfg := api.Config{
-		Address: cfg.PrometheusAddr,
+		Address: address,
 	}
-	if cfg.UserID != "" {
+	if userID != "" {
 		apiCfg.RoundTripper = &nethttp.Transport{
 			RoundTripper: promhttp.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
-				_ = user.InjectOrgIDIntoHTTPRequest(user.InjectOrgID(context.Background(), cfg.UserID), req)
+				_ = user.InjectOrgIDIntoHTTPRequest(user.InjectOrgID(context.Background(), userID), req)
 				return api.DefaultRoundTripper.RoundTrip(req)
 			}),
 		}
@@ -99,15 +117,7 @@ func NewRunner(cfg RunnerConfig) (*Runner, error) {
 		return nil, err
 	}
 
-	tc := &Runner{
-		cfg:    cfg,
-		quit:   make(chan struct{}),
-		client: v1.NewAPI(tracingClient{client}),
-	}
-
-	tc.wg.Add(1)
-	go tc.verifyLoop()
-	return tc, nil
+	return v1.NewAPI(tracingClient{client}), nil
 }
 
 type tracingClient struct {
@@ -0,0 +1,178 @@
+// Package auth provides the built-in authentication of the requests received by Cortex, and
+// the authorization of the tenants and scopes the authenticated requests are allowed to access.
+package auth
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// TypeHeader trusts the tenant set in the X-Scope-OrgID header, without any authentication.
+	TypeHeader = "header"
+
+	// TypeToken authenticates requests with the static bearer tokens loaded from a file.
+	TypeToken = "token"
+
+	// TypeJWT authenticates requests with a JWT bearer token, signed by one of the keys loaded
+	// from a JWKS file.
+	TypeJWT = "jwt"
+
+	// AnyTenant grants access to all tenants.
+	AnyTenant = "*"
+)
+
+var (
+	supportedTypes = []string{TypeHeader, TypeToken, TypeJWT}
+
+	errMissingCredentials = errors.New("no bearer token provided")
+	errInvalidCredentials = errors.New("invalid bearer token")
+)
+
+// Scope is the permission required to access an API route.
+type Scope int
+
+const (
+	// ScopeNone means the route doesn't require any authentication nor tenant.
+	ScopeNone Scope = iota
+
+	// ScopeRead is required to run queries and read the tenant's configuration.
+	ScopeRead
+
+	// ScopeWrite is required to push series and update the tenant's configuration.
+	ScopeWrite
+
+	// ScopeAdmin is required to run administrative operations on the tenant, like deleting
+	// its series. It implies all other scopes.
+	ScopeAdmin
+
+	// ScopeCluster is required to access the routes not bound to a tenant, like the ones exposing
+	// the data of all tenants or operating the Cortex instances. It can't be granted: it requires
+	// the admin scope on all tenants, and the requests don't get any tenant.
+	ScopeCluster
+)
+
+func (s Scope) String() string {
+	switch s {
+	case ScopeNone:
+		return "none"
+	case ScopeRead:
+		return "read"
+	case ScopeWrite:
+		return "write"
+	case ScopeAdmin:
+		return "admin"
+	case ScopeCluster:
+		return "cluster"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// ParseScope parses a scope from its name.
+func ParseScope(name string) (Scope, error) {
+	switch strings.ToLower(name) {
+	case "read":
+		return ScopeRead, nil
+	case "write":
+		return ScopeWrite, nil
+	case "admin":
+		return ScopeAdmin, nil
+	default:
+		return ScopeNone, fmt.Errorf("unknown scope %q", name)
+	}
+}
+
+// Config holds the configuration of the built-in authentication.
+type Config struct {
+	Type       string    `yaml:"type"`
+	TokensFile string    `yaml:"tokens_file"`
+	JWT        JWTConfig `yaml:"jwt"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.Type, "auth.type", TypeHeader, fmt.Sprintf("How requests are authenticated when auth is enabled. Supported values are: %s. The %q type trusts the tenant set in the X-Scope-OrgID header, while the other types require a bearer token granting access to the requested tenants.", strings.Join(supportedTypes, ", "), TypeHeader))
+	f.StringVar(&cfg.TokensFile, "auth.tokens-file", "", "Path to the YAML file listing the static bearer tokens, with the tenants and scopes each one is granted. Required when the auth type is token.")
+	cfg.JWT.RegisterFlagsWithPrefix("auth.jwt.", f)
+}
+
+// Validate the config.
+func (cfg *Config) Validate() error {
+	switch cfg.Type {
+	case TypeHeader:
+	case TypeToken:
+		if cfg.TokensFile == "" {
+			return errors.New("the tokens file is required when the auth type is token")
+		}
+	case TypeJWT:
+		if err := cfg.JWT.Validate(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported auth type %q, supported values are: %s", cfg.Type, strings.Join(supportedTypes, ", "))
+	}
+	return nil
+}
+
+// Principal is an authenticated client, along with the tenants and scopes it has been granted.
+type Principal struct {
+	Subject string
+	Tenants []string
+	Scopes  []Scope
+}
+
+// AllowsTenant returns whether the principal has been granted access to the tenant.
+func (p *Principal) AllowsTenant(tenantID string) bool {
+	for _, t := range p.Tenants {
+		if t == AnyTenant || t == tenantID {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsScope returns whether the principal has been granted the scope.
+func (p *Principal) AllowsScope(scope Scope) bool {
+	if scope == ScopeNone {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultTenant returns the tenant to use when the client didn't specify any, which is
+// only possible if the principal has been granted access to a single tenant.
+func (p *Principal) defaultTenant() (string, bool) {
+	if len(p.Tenants) != 1 || p.Tenants[0] == AnyTenant {
+		return "", false
+	}
+	return p.Tenants[0], true
+}
+
+// Authenticator authenticates a bearer token.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*Principal, error)
+}
+
+// NewAuthenticator returns the Authenticator for the configured type, or nil if the requests
+// are not authenticated by Cortex.
+func NewAuthenticator(cfg Config, logger log.Logger) (Authenticator, error) {
+	switch cfg.Type {
+	case TypeToken:
+		return NewTokensAuthenticator(cfg.TokensFile)
+	case TypeJWT:
+		return NewJWTAuthenticator(cfg.JWT, logger)
+	default:
+		return nil, nil
+	}
+}
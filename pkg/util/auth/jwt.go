@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+// Only asymmetric signing methods are supported, since the keys are public.
+var jwtValidMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// JWTConfig holds the configuration of the JWT authentication.
+type JWTConfig struct {
+	JWKSFile     string `yaml:"jwks_file"`
+	Issuer       string `yaml:"issuer"`
+	Audience     string `yaml:"audience"`
+	TenantsClaim string `yaml:"tenants_claim"`
+	ScopesClaim  string `yaml:"scopes_claim"`
+}
+
+// RegisterFlagsWithPrefix registers flags with prefix.
+func (cfg *JWTConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.JWKSFile, prefix+"jwks-file", "", "Path to the JSON Web Key Set file holding the public keys used to verify the signature of the tokens. Required when the auth type is jwt.")
+	f.StringVar(&cfg.Issuer, prefix+"issuer", "", "If set, the tokens must have been issued by this issuer (iss claim).")
+	f.StringVar(&cfg.Audience, prefix+"audience", "", "If set, the tokens must have been issued for this audience (aud claim).")
+	f.StringVar(&cfg.TenantsClaim, prefix+"tenants-claim", "cortex_tenants", "The claim holding the tenants the token grants access to, either as a string or an array of strings. The tenant * grants access to all tenants.")
+	f.StringVar(&cfg.ScopesClaim, prefix+"scopes-claim", "scope", "The claim holding the scopes the token grants, either as a space-separated string or an array of strings. Supported scopes are read, write and admin, other scopes are ignored.")
+}
+
+// Validate the config.
+func (cfg *JWTConfig) Validate() error {
+	if cfg.JWKSFile == "" {
+		return errors.New("the JWKS file is required when the auth type is jwt")
+	}
+	if cfg.TenantsClaim == "" {
+		return errors.New("the JWT tenants claim is required")
+	}
+	if cfg.ScopesClaim == "" {
+		return errors.New("the JWT scopes claim is required")
+	}
+	return nil
+}
+
+// JWTAuthenticator authenticates the JWT bearer tokens signed by one of the keys of a JWKS file.
+type JWTAuthenticator struct {
+	cfg    JWTConfig
+	keys   map[string]interface{}
+	parser *jwt.Parser
+}
+
+// NewJWTAuthenticator loads the keys from the JWKS file.
+func NewJWTAuthenticator(cfg JWTConfig, logger log.Logger) (*JWTAuthenticator, error) {
+	content, err := ioutil.ReadFile(cfg.JWKSFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "read JWKS file")
+	}
+
+	keys, err := parseJWKS(content, logger)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse JWKS file")
+	}
+
+	return &JWTAuthenticator{
+		cfg:    cfg,
+		keys:   keys,
+		parser: &jwt.Parser{ValidMethods: jwtValidMethods},
+	}, nil
+}
+
+// Authenticate implements Authenticator.
+func (a *JWTAuthenticator) Authenticate(_ context.Context, token string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(token, claims, a.keyFunc); err != nil {
+		return nil, errors.Wrap(errInvalidCredentials, err.Error())
+	}
+
+	// The expiration is only checked by the parser if the claim is set, while we don't
+	// want to accept tokens which never expire.
+	if _, ok := claims["exp"]; !ok {
+		return nil, errors.Wrap(errInvalidCredentials, "token has no expiration")
+	}
+	if a.cfg.Issuer != "" && !claims.VerifyIssuer(a.cfg.Issuer, true) {
+		return nil, errors.Wrap(errInvalidCredentials, "unexpected issuer")
+	}
+	// The audience is checked manually, since jwt.MapClaims doesn't support
+	// the array of audiences once decoded from JSON.
+	if a.cfg.Audience != "" && !util.StringsContain(claimValues(claims["aud"], false), a.cfg.Audience) {
+		return nil, errors.Wrap(errInvalidCredentials, "unexpected audience")
+	}
+
+	p := &Principal{}
+	p.Subject, _ = claims["sub"].(string)
+	p.Tenants = claimValues(claims[a.cfg.TenantsClaim], false)
+	if len(p.Tenants) == 0 {
+		return nil, errors.Wrap(errInvalidCredentials, "token doesn't grant access to any tenant")
+	}
+
+	for _, name := range claimValues(claims[a.cfg.ScopesClaim], true) {
+		// Tokens are commonly shared with other applications, so we ignore their scopes.
+		if s, err := ParseScope(name); err == nil {
+			p.Scopes = append(p.Scopes, s)
+		}
+	}
+
+	return p, nil
+}
+
+func (a *JWTAuthenticator) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := a.keys[kid]; ok {
+		return key, nil
+	}
+
+	// Tokens may have no key ID if the set has a single key.
+	if kid == "" && len(a.keys) == 1 {
+		for _, key := range a.keys {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown key ID %q", kid)
+}
+
+// claimValues returns the values of a claim holding either a string or an array of strings.
+// If split is true, string claims are split on spaces.
+func claimValues(claim interface{}, split bool) []string {
+	switch v := claim.(type) {
+	case string:
+		if split {
+			return strings.Fields(v)
+		}
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+
+	// RSA keys.
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC keys.
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS parses the public keys of a JSON Web Key Set, indexed by key ID. Keys which are
+// not used for signatures or whose type is not supported are skipped.
+func parseJWKS(content []byte, logger log.Logger) (map[string]interface{}, error) {
+	var set jwks
+	if err := json.Unmarshal(content, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		var (
+			key interface{}
+			err error
+		)
+
+		switch k.Kty {
+		case "RSA":
+			key, err = k.rsaPublicKey()
+		case "EC":
+			key, err = k.ecdsaPublicKey()
+		default:
+			level.Warn(logger).Log("msg", "skipping JWKS key with unsupported type", "kid", k.Kid, "kty", k.Kty)
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "key %q", k.Kid)
+		}
+
+		if _, ok := keys[k.Kid]; ok {
+			return nil, fmt.Errorf("duplicate key ID %q", k.Kid)
+		}
+		keys[k.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no signature key found")
+	}
+	return keys, nil
+}
+
+func (k jwk) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(k.N)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid modulus")
+	}
+	e, err := decodeBigInt(k.E)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid exponent")
+	}
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, errors.New("exponent too large")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (k jwk) ecdsaPublicKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+
+	x, err := decodeBigInt(k.X)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid x coordinate")
+	}
+	y, err := decodeBigInt(k.Y)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid y coordinate")
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("point is not on the curve")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, errors.New("missing value")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,269 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTAuthenticator(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, jwksFile, []jwk{
+		rsaJWK("rsa", &rsaKey.PublicKey),
+		ecJWK("ec", &ecKey.PublicKey),
+		// Encryption keys must be ignored.
+		{Kty: "RSA", Kid: "enc", Use: "enc", N: "AQAB", E: "AQAB"},
+	})
+
+	cfg := JWTConfig{
+		JWKSFile:     jwksFile,
+		Issuer:       "https://issuer",
+		Audience:     "cortex",
+		TenantsClaim: "cortex_tenants",
+		ScopesClaim:  "scope",
+	}
+
+	a, err := NewJWTAuthenticator(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub":            "grafana",
+			"iss":            "https://issuer",
+			"aud":            []string{"cortex", "other"},
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"cortex_tenants": []string{"team-a", "team-b"},
+			"scope":          "openid read write",
+		}
+	}
+
+	tests := map[string]struct {
+		method            jwt.SigningMethod
+		kid               string
+		key               interface{}
+		claims            func() jwt.MapClaims
+		expectedErr       bool
+		expectedPrincipal *Principal
+	}{
+		"valid token signed with a RSA key": {
+			method: jwt.SigningMethodRS256,
+			kid:    "rsa",
+			key:    rsaKey,
+			claims: validClaims,
+			expectedPrincipal: &Principal{
+				Subject: "grafana",
+				Tenants: []string{"team-a", "team-b"},
+				Scopes:  []Scope{ScopeRead, ScopeWrite},
+			},
+		},
+		"valid token signed with an EC key": {
+			method: jwt.SigningMethodES256,
+			kid:    "ec",
+			key:    ecKey,
+			claims: func() jwt.MapClaims {
+				c := validClaims()
+				c["cortex_tenants"] = "team-a"
+				c["scope"] = []string{"admin"}
+				return c
+			},
+			expectedPrincipal: &Principal{
+				Subject: "grafana",
+				Tenants: []string{"team-a"},
+				Scopes:  []Scope{ScopeAdmin},
+			},
+		},
+		"token signed with an unknown key ID": {
+			method:      jwt.SigningMethodRS256,
+			kid:         "unknown",
+			key:         rsaKey,
+			claims:      validClaims,
+			expectedErr: true,
+		},
+		"token signed with another key": {
+			method:      jwt.SigningMethodRS256,
+			kid:         "rsa",
+			key:         otherKey,
+			claims:      validClaims,
+			expectedErr: true,
+		},
+		"token signed with a symmetric key": {
+			method:      jwt.SigningMethodHS256,
+			kid:         "rsa",
+			key:         []byte("secret"),
+			claims:      validClaims,
+			expectedErr: true,
+		},
+		"expired token": {
+			method: jwt.SigningMethodRS256,
+			kid:    "rsa",
+			key:    rsaKey,
+			claims: func() jwt.MapClaims {
+				c := validClaims()
+				c["exp"] = time.Now().Add(-time.Minute).Unix()
+				return c
+			},
+			expectedErr: true,
+		},
+		"token without expiration": {
+			method: jwt.SigningMethodRS256,
+			kid:    "rsa",
+			key:    rsaKey,
+			claims: func() jwt.MapClaims {
+				c := validClaims()
+				delete(c, "exp")
+				return c
+			},
+			expectedErr: true,
+		},
+		"token issued by another issuer": {
+			method: jwt.SigningMethodRS256,
+			kid:    "rsa",
+			key:    rsaKey,
+			claims: func() jwt.MapClaims {
+				c := validClaims()
+				c["iss"] = "https://other"
+				return c
+			},
+			expectedErr: true,
+		},
+		"token issued for another audience": {
+			method: jwt.SigningMethodRS256,
+			kid:    "rsa",
+			key:    rsaKey,
+			claims: func() jwt.MapClaims {
+				c := validClaims()
+				c["aud"] = "other"
+				return c
+			},
+			expectedErr: true,
+		},
+		"token without tenants": {
+			method: jwt.SigningMethodRS256,
+			kid:    "rsa",
+			key:    rsaKey,
+			claims: func() jwt.MapClaims {
+				c := validClaims()
+				delete(c, "cortex_tenants")
+				return c
+			},
+			expectedErr: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			token := jwt.NewWithClaims(testData.method, testData.claims())
+			token.Header["kid"] = testData.kid
+			signed, err := token.SignedString(testData.key)
+			require.NoError(t, err)
+
+			actual, err := a.Authenticate(context.Background(), signed)
+			if testData.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedPrincipal, actual)
+		})
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		keys         []jwk
+		expectedKids []string
+		expectedErr  bool
+	}{
+		"supported keys": {
+			keys:         []jwk{rsaJWK("a", &rsaKey.PublicKey), rsaJWK("b", &rsaKey.PublicKey)},
+			expectedKids: []string{"a", "b"},
+		},
+		"unsupported key types are skipped": {
+			keys:         []jwk{rsaJWK("a", &rsaKey.PublicKey), {Kty: "oct", Kid: "b"}},
+			expectedKids: []string{"a"},
+		},
+		"duplicate key IDs": {
+			keys:        []jwk{rsaJWK("a", &rsaKey.PublicKey), rsaJWK("a", &rsaKey.PublicKey)},
+			expectedErr: true,
+		},
+		"EC key not on the curve": {
+			keys:        []jwk{{Kty: "EC", Kid: "a", Crv: "P-256", X: "AQ", Y: "AQ"}},
+			expectedErr: true,
+		},
+		"no signature key": {
+			keys:        []jwk{{Kty: "oct", Kid: "a"}},
+			expectedErr: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			content, err := json.Marshal(jwks{Keys: testData.keys})
+			require.NoError(t, err)
+
+			keys, err := parseJWKS(content, log.NewNopLogger())
+			if testData.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			actualKids := make([]string, 0, len(keys))
+			for kid := range keys {
+				actualKids = append(actualKids, kid)
+			}
+			assert.ElementsMatch(t, testData.expectedKids, actualKids)
+		})
+	}
+}
+
+func writeJWKS(t *testing.T, path string, keys []jwk) {
+	content, err := json.Marshal(jwks{Keys: keys})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, content, 0600))
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) jwk {
+	return jwk{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) jwk {
+	return jwk{
+		Kty: "EC",
+		Kid: kid,
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.Bytes()),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.Bytes()),
+	}
+}
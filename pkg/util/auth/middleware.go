@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/weaveworks/common/middleware"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+)
+
+const (
+	authorizationHeader      = "Authorization"
+	lowerAuthorizationHeader = "authorization"
+	lowerOrgIDHeader         = "x-scope-orgid"
+	bearerPrefix             = "bearer "
+)
+
+// errForbidden is returned when the principal is authenticated but not allowed to
+// access the requested tenants or scope.
+type errForbidden struct {
+	msg string
+}
+
+func (e errForbidden) Error() string {
+	return e.msg
+}
+
+// HTTPMiddleware returns a middleware authenticating the requests with the authenticator and
+// authorizing the requested tenants for the scope. The request must either set the tenants in
+// the X-Scope-OrgID header, or be granted access to a single tenant which is then used. The
+// requests to the routes requiring ScopeCluster are passed through without any tenant.
+func HTTPMiddleware(a Authenticator, scope Scope, logger log.Logger) middleware.Interface {
+	return middleware.Func(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var (
+				orgID string
+				err   error
+			)
+			if scope == ScopeCluster {
+				err = authorizeCluster(r.Context(), a, httpToken(r))
+			} else {
+				orgID, err = authorize(r.Context(), a, httpToken(r), r.Header.Get(user.OrgIDHeaderName), scope)
+			}
+			if err != nil {
+				level.Debug(logger).Log("msg", "request not authorized", "path", r.URL.Path, "scope", scope, "err", err)
+
+				if _, ok := err.(errForbidden); ok {
+					http.Error(w, err.Error(), http.StatusForbidden)
+					return
+				}
+				w.Header().Set("WWW-Authenticate", `Bearer realm="cortex"`)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			if scope == ScopeCluster {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Set the header too, since some handlers forward the request as is.
+			r.Header.Set(user.OrgIDHeaderName, orgID)
+			next.ServeHTTP(w, r.WithContext(user.InjectOrgID(r.Context(), orgID)))
+		})
+	})
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor authenticating the calls to the given methods
+// and authorizing the requested tenants for the method's scope. Calls to other methods are
+// passed through.
+func UnaryServerInterceptor(a Authenticator, methods map[string]Scope, logger log.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		scope, ok := methods[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		ctx, err := authorizeGRPC(ctx, a, scope)
+		if err != nil {
+			level.Debug(logger).Log("msg", "gRPC call not authorized", "method", info.FullMethod, "scope", scope, "err", err)
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+func StreamServerInterceptor(a Authenticator, methods map[string]Scope, logger log.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		scope, ok := methods[info.FullMethod]
+		if !ok {
+			return handler(srv, ss)
+		}
+
+		ctx, err := authorizeGRPC(ss.Context(), a, scope)
+		if err != nil {
+			level.Debug(logger).Log("msg", "gRPC stream not authorized", "method", info.FullMethod, "scope", scope, "err", err)
+			return err
+		}
+		return handler(srv, serverStream{ctx: ctx, ServerStream: ss})
+	}
+}
+
+func authorizeGRPC(ctx context.Context, a Authenticator, scope Scope) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	orgID, err := authorize(ctx, a, bearerToken(firstValue(md, lowerAuthorizationHeader)), firstValue(md, lowerOrgIDHeader), scope)
+	if err != nil {
+		if _, ok := err.(errForbidden); ok {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return user.InjectOrgID(ctx, orgID), nil
+}
+
+// authorize authenticates the token and returns the org ID of the request, once checked
+// the principal is allowed to access all its tenants with the scope.
+func authorize(ctx context.Context, a Authenticator, token, orgID string, scope Scope) (string, error) {
+	if token == "" {
+		return "", errMissingCredentials
+	}
+
+	p, err := a.Authenticate(ctx, token)
+	if err != nil {
+		return "", err
+	}
+
+	if !p.AllowsScope(scope) {
+		return "", errForbidden{msg: "the token doesn't grant the " + scope.String() + " scope"}
+	}
+
+	if orgID == "" {
+		var ok bool
+		if orgID, ok = p.defaultTenant(); !ok {
+			return "", errForbidden{msg: "no org id, which is required when the token grants access to more than one tenant"}
+		}
+	}
+
+	tenantIDs, err := tenant.TenantIDsFromOrgID(orgID)
+	if err != nil {
+		return "", errForbidden{msg: err.Error()}
+	}
+	for _, tenantID := range tenantIDs {
+		if !p.AllowsTenant(tenantID) {
+			return "", errForbidden{msg: "the token doesn't grant access to the tenant " + tenantID}
+		}
+	}
+
+	return orgID, nil
+}
+
+// authorizeCluster authenticates the token, and checks the principal is granted the admin scope
+// on all tenants, as required by ScopeCluster.
+func authorizeCluster(ctx context.Context, a Authenticator, token string) error {
+	if token == "" {
+		return errMissingCredentials
+	}
+
+	p, err := a.Authenticate(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if !p.AllowsScope(ScopeAdmin) || !p.AllowsTenant(AnyTenant) {
+		return errForbidden{msg: "the token doesn't grant the admin scope on all tenants"}
+	}
+	return nil
+}
+
+// httpToken returns the bearer token of the request. Since some clients only support
+// basic authentication, the token can be passed as the password too.
+func httpToken(r *http.Request) string {
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+	return bearerToken(r.Header.Get(authorizationHeader))
+}
+
+func bearerToken(header string) string {
+	if len(header) < len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(bearerPrefix):])
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+type serverStream struct {
+	ctx context.Context
+	grpc.ServerStream
+}
+
+func (ss serverStream) Context() context.Context {
+	return ss.ctx
+}
@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+)
+
+func newTestTokensAuthenticator(t *testing.T) Authenticator {
+	a, err := newTokensAuthenticator([]TokenConfig{
+		{Name: "reader", Token: "reader-token", Tenants: []string{"team-a"}, Scopes: []string{"read"}},
+		{Name: "writer", Token: "writer-token", Tenants: []string{"team-a", "team-b"}, Scopes: []string{"write"}},
+		{Name: "admin", Token: "admin-token", Tenants: []string{AnyTenant}, Scopes: []string{"admin"}},
+		{Name: "tenant-admin", Token: "tenant-admin-token", Tenants: []string{"team-a"}, Scopes: []string{"admin"}},
+	})
+	require.NoError(t, err)
+	return a
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	tests := map[string]struct {
+		scope          Scope
+		authorization  string
+		basicAuth      string
+		orgID          string
+		federation     bool
+		expectedStatus int
+		expectedOrgID  string
+	}{
+		"no token": {
+			scope:          ScopeRead,
+			orgID:          "team-a",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"invalid token": {
+			scope:          ScopeRead,
+			authorization:  "Bearer unknown",
+			orgID:          "team-a",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"unsupported authorization scheme": {
+			scope:          ScopeRead,
+			authorization:  "Digest reader-token",
+			orgID:          "team-a",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"valid token granting the tenant and scope": {
+			scope:          ScopeRead,
+			authorization:  "Bearer reader-token",
+			orgID:          "team-a",
+			expectedStatus: http.StatusOK,
+			expectedOrgID:  "team-a",
+		},
+		"valid token passed as basic auth password": {
+			scope:          ScopeRead,
+			basicAuth:      "reader-token",
+			orgID:          "team-a",
+			expectedStatus: http.StatusOK,
+			expectedOrgID:  "team-a",
+		},
+		"valid token without org ID granting a single tenant": {
+			scope:          ScopeRead,
+			authorization:  "Bearer reader-token",
+			expectedStatus: http.StatusOK,
+			expectedOrgID:  "team-a",
+		},
+		"valid token without org ID granting multiple tenants": {
+			scope:          ScopeWrite,
+			authorization:  "Bearer writer-token",
+			expectedStatus: http.StatusForbidden,
+		},
+		"valid token not granting the tenant": {
+			scope:          ScopeRead,
+			authorization:  "Bearer reader-token",
+			orgID:          "team-b",
+			expectedStatus: http.StatusForbidden,
+		},
+		"valid token not granting the scope": {
+			scope:          ScopeWrite,
+			authorization:  "Bearer reader-token",
+			orgID:          "team-a",
+			expectedStatus: http.StatusForbidden,
+		},
+		"admin scope implies the other scopes": {
+			scope:          ScopeWrite,
+			authorization:  "Bearer admin-token",
+			orgID:          "team-c",
+			expectedStatus: http.StatusOK,
+			expectedOrgID:  "team-c",
+		},
+		"federated query of tenants all granted": {
+			scope:          ScopeWrite,
+			authorization:  "Bearer writer-token",
+			orgID:          "team-a|team-b",
+			federation:     true,
+			expectedStatus: http.StatusOK,
+			expectedOrgID:  "team-a|team-b",
+		},
+		"federated query of a tenant not granted": {
+			scope:          ScopeRead,
+			authorization:  "Bearer reader-token",
+			orgID:          "team-a|team-b",
+			federation:     true,
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			if testData.federation {
+				tenant.WithDefaultResolver(tenant.NewMultiResolver())
+				defer tenant.WithDefaultResolver(tenant.NewSingleResolver())
+			}
+
+			var actualOrgID string
+			handler := HTTPMiddleware(newTestTokensAuthenticator(t), testData.scope, log.NewNopLogger()).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tenantIDs, err := tenant.TenantIDs(r.Context())
+				require.NoError(t, err)
+				actualOrgID = tenant.JoinTenantIDs(tenantIDs)
+				assert.Equal(t, actualOrgID, r.Header.Get(user.OrgIDHeaderName))
+			}))
+
+			req := httptest.NewRequest("GET", "/api/v1/query", nil)
+			if testData.authorization != "" {
+				req.Header.Set("Authorization", testData.authorization)
+			}
+			if testData.basicAuth != "" {
+				req.SetBasicAuth("user", testData.basicAuth)
+			}
+			if testData.orgID != "" {
+				req.Header.Set(user.OrgIDHeaderName, testData.orgID)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, testData.expectedStatus, rec.Code)
+			assert.Equal(t, testData.expectedOrgID, actualOrgID)
+			if testData.expectedStatus == http.StatusUnauthorized {
+				assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestHTTPMiddleware_ClusterScope(t *testing.T) {
+	tests := map[string]struct {
+		authorization  string
+		expectedStatus int
+	}{
+		"no token": {
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"valid token not granting the admin scope": {
+			authorization:  "Bearer writer-token",
+			expectedStatus: http.StatusForbidden,
+		},
+		"valid token granting the admin scope on some tenants": {
+			authorization:  "Bearer tenant-admin-token",
+			expectedStatus: http.StatusForbidden,
+		},
+		"valid token granting the admin scope on all tenants": {
+			authorization:  "Bearer admin-token",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			handler := HTTPMiddleware(newTestTokensAuthenticator(t), ScopeCluster, log.NewNopLogger()).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// No tenant is injected.
+				_, err := tenant.TenantID(r.Context())
+				assert.Error(t, err)
+			}))
+
+			req := httptest.NewRequest("GET", "/runtime_config", nil)
+			if testData.authorization != "" {
+				req.Header.Set("Authorization", testData.authorization)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, testData.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	const (
+		authenticatedMethod = "/distributor.Distributor/Push"
+		otherMethod         = "/cortex.Ingester/Push"
+	)
+
+	interceptor := UnaryServerInterceptor(newTestTokensAuthenticator(t), map[string]Scope{authenticatedMethod: ScopeWrite}, log.NewNopLogger())
+
+	tests := map[string]struct {
+		method        string
+		md            metadata.MD
+		expectedCode  codes.Code
+		expectedOrgID string
+	}{
+		"other methods are passed through": {
+			method:       otherMethod,
+			md:           metadata.Pairs("x-scope-orgid", "team-a"),
+			expectedCode: codes.OK,
+		},
+		"no token": {
+			method:       authenticatedMethod,
+			md:           metadata.Pairs("x-scope-orgid", "team-a"),
+			expectedCode: codes.Unauthenticated,
+		},
+		"valid token not granting the scope": {
+			method:       authenticatedMethod,
+			md:           metadata.Pairs("authorization", "Bearer reader-token", "x-scope-orgid", "team-a"),
+			expectedCode: codes.PermissionDenied,
+		},
+		"valid token not granting the tenant": {
+			method:       authenticatedMethod,
+			md:           metadata.Pairs("authorization", "Bearer writer-token", "x-scope-orgid", "team-c"),
+			expectedCode: codes.PermissionDenied,
+		},
+		"valid token granting the tenant and scope": {
+			method:        authenticatedMethod,
+			md:            metadata.Pairs("authorization", "Bearer writer-token", "x-scope-orgid", "team-b"),
+			expectedCode:  codes.OK,
+			expectedOrgID: "team-b",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var actualOrgID string
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				actualOrgID, _ = tenant.TenantID(ctx)
+				return nil, nil
+			}
+
+			ctx := metadata.NewIncomingContext(context.Background(), testData.md)
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: testData.method}, handler)
+
+			assert.Equal(t, testData.expectedCode, status.Code(err))
+			assert.Equal(t, testData.expectedOrgID, actualOrgID)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// TokensFile is the content of the static bearer tokens file.
+type TokensFile struct {
+	Tokens []TokenConfig `yaml:"tokens"`
+}
+
+// TokenConfig is a static bearer token, along with the tenants and scopes it grants.
+type TokenConfig struct {
+	// Name identifies the client using the token in logs.
+	Name    string   `yaml:"name"`
+	Token   string   `yaml:"token"`
+	Tenants []string `yaml:"tenants"`
+	Scopes  []string `yaml:"scopes"`
+}
+
+// TokensAuthenticator authenticates the static bearer tokens loaded from a file.
+type TokensAuthenticator struct {
+	// Principals indexed by the SHA-256 of their token, so that looking up a
+	// token doesn't leak its content through timing.
+	principals map[[sha256.Size]byte]*Principal
+}
+
+// NewTokensAuthenticator loads the tokens from the file at path.
+func NewTokensAuthenticator(path string) (*TokensAuthenticator, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read tokens file")
+	}
+
+	var file TokensFile
+	if err := yaml.UnmarshalStrict(content, &file); err != nil {
+		return nil, errors.Wrap(err, "parse tokens file")
+	}
+
+	return newTokensAuthenticator(file.Tokens)
+}
+
+func newTokensAuthenticator(tokens []TokenConfig) (*TokensAuthenticator, error) {
+	a := &TokensAuthenticator{principals: make(map[[sha256.Size]byte]*Principal, len(tokens))}
+
+	for i, t := range tokens {
+		if t.Token == "" {
+			return nil, fmt.Errorf("token #%d has an empty token", i)
+		}
+		if len(t.Tenants) == 0 {
+			return nil, fmt.Errorf("token #%d doesn't grant access to any tenant", i)
+		}
+
+		p := &Principal{Subject: t.Name, Tenants: t.Tenants}
+		for _, name := range t.Scopes {
+			s, err := ParseScope(name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "token #%d", i)
+			}
+			p.Scopes = append(p.Scopes, s)
+		}
+
+		key := sha256.Sum256([]byte(t.Token))
+		if _, ok := a.principals[key]; ok {
+			return nil, fmt.Errorf("token #%d is a duplicate", i)
+		}
+		a.principals[key] = p
+	}
+
+	return a, nil
+}
+
+// Authenticate implements Authenticator.
+func (a *TokensAuthenticator) Authenticate(_ context.Context, token string) (*Principal, error) {
+	p, ok := a.principals[sha256.Sum256([]byte(token))]
+	if !ok {
+		return nil, errInvalidCredentials
+	}
+	return p, nil
+}
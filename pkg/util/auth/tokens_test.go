@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTokensAuthenticator(t *testing.T) {
+	tests := map[string]struct {
+		content     string
+		expectedErr string
+	}{
+		"valid tokens": {
+			content: `
+tokens:
+  - name: grafana
+    token: secret-1
+    tenants: [team-a, team-b]
+    scopes: [read]
+  - name: prometheus
+    token: secret-2
+    tenants: [team-a]
+    scopes: [write]
+`,
+		},
+		"unknown scope": {
+			content: `
+tokens:
+  - token: secret-1
+    tenants: [team-a]
+    scopes: [delete]
+`,
+			expectedErr: `token #0: unknown scope "delete"`,
+		},
+		"duplicate token": {
+			content: `
+tokens:
+  - token: secret-1
+    tenants: [team-a]
+  - token: secret-1
+    tenants: [team-b]
+`,
+			expectedErr: "token #1 is a duplicate",
+		},
+		"token without tenants": {
+			content: `
+tokens:
+  - token: secret-1
+`,
+			expectedErr: "token #0 doesn't grant access to any tenant",
+		},
+		"unknown field": {
+			content: `
+tokens:
+  - token: secret-1
+    tenant: team-a
+`,
+			expectedErr: "parse tokens file",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tokens.yaml")
+			require.NoError(t, ioutil.WriteFile(path, []byte(testData.content), 0600))
+
+			a, err := NewTokensAuthenticator(path)
+			if testData.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testData.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			p, err := a.Authenticate(context.Background(), "secret-1")
+			require.NoError(t, err)
+			assert.Equal(t, &Principal{Subject: "grafana", Tenants: []string{"team-a", "team-b"}, Scopes: []Scope{ScopeRead}}, p)
+
+			_, err = a.Authenticate(context.Background(), "unknown")
+			assert.Error(t, err)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package flagext
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 )
@@ -58,3 +59,17 @@ func (t *Time) UnmarshalYAML(unmarshal func(interface{}) error) error {
 func (t Time) MarshalYAML() (interface{}, error) {
 	return t.String(), nil
 }
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Time) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return t.Set(s)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Time) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
@@ -1,6 +1,7 @@
 package flagext
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -52,6 +53,25 @@ func TestTimeYAML(t *testing.T) {
 	}
 }
 
+func TestTimeJSON(t *testing.T) {
+	type TestStruct struct {
+		T Time `json:"time"`
+	}
+
+	var testStruct TestStruct
+	require.NoError(t, testStruct.T.Set("2020-10-20"))
+
+	marshaled, err := json.Marshal(testStruct)
+	require.NoError(t, err)
+
+	expected := `{"time":"2020-10-20T00:00:00Z"}`
+	assert.Equal(t, expected, string(marshaled))
+
+	var actualStruct TestStruct
+	require.NoError(t, json.Unmarshal([]byte(expected), &actualStruct))
+	assert.Equal(t, testStruct, actualStruct)
+}
+
 func TestTimeFormats(t *testing.T) {
 	ts := &Time{}
 	require.NoError(t, ts.Set("0"))
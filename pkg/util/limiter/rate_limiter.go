@@ -47,6 +47,12 @@ func (l *RateLimiter) AllowN(now time.Time, tenantID string, n int) bool {
 	return l.getTenantLimiter(now, tenantID).AllowN(now, n)
 }
 
+// ReserveN returns a reservation of n tokens at time now. The reservation can be canceled,
+// restoring the tokens, when the caller doesn't act on it.
+func (l *RateLimiter) ReserveN(now time.Time, tenantID string, n int) *rate.Reservation {
+	return l.getTenantLimiter(now, tenantID).ReserveN(now, n)
+}
+
 // Limit returns the currently configured maximum overall tokens rate.
 func (l *RateLimiter) Limit(now time.Time, tenantID string) float64 {
 	return float64(l.getTenantLimiter(now, tenantID).Limit())
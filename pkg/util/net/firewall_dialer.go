@@ -34,12 +34,31 @@ func (d *FirewallDialer) DialContext(ctx context.Context, network, address strin
 	return d.parent.DialContext(ctx, network, address)
 }
 
-func (d *FirewallDialer) control(_, address string, _ syscall.RawConn) error {
-	blockPrivateAddresses := d.cfgProvider.BlockPrivateAddresses()
-	blockCIDRNetworks := d.cfgProvider.BlockCIDRNetworks()
+// CheckHost resolves the input host and returns an error if the firewall would block
+// the connection to any of its addresses, without actually opening a connection.
+func (d *FirewallDialer) CheckHost(ctx context.Context, host string) error {
+	// Skip any control if no firewall has been configured.
+	if !d.isEnabled() {
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return errors.Wrapf(err, "unable to resolve host %s", host)
+	}
+
+	for _, addr := range addrs {
+		if err := d.checkIP(addr.IP); err != nil {
+			return err
+		}
+	}
 
+	return nil
+}
+
+func (d *FirewallDialer) control(_, address string, _ syscall.RawConn) error {
 	// Skip any control if no firewall has been configured.
-	if !blockPrivateAddresses && len(blockCIDRNetworks) == 0 {
+	if !d.isEnabled() {
 		return nil
 	}
 
@@ -54,11 +73,19 @@ func (d *FirewallDialer) control(_, address string, _ syscall.RawConn) error {
 		return errBlockedAddress
 	}
 
-	if blockPrivateAddresses && (isPrivate(ip) || isLocal(ip)) {
+	return d.checkIP(ip)
+}
+
+func (d *FirewallDialer) isEnabled() bool {
+	return d.cfgProvider.BlockPrivateAddresses() || len(d.cfgProvider.BlockCIDRNetworks()) > 0
+}
+
+func (d *FirewallDialer) checkIP(ip net.IP) error {
+	if d.cfgProvider.BlockPrivateAddresses() && (isPrivate(ip) || isLocal(ip)) {
 		return errBlockedAddress
 	}
 
-	for _, cidr := range blockCIDRNetworks {
+	for _, cidr := range d.cfgProvider.BlockCIDRNetworks() {
 		if cidr.Value.Contains(ip) {
 			return errBlockedAddress
 		}
@@ -100,6 +100,54 @@ func TestFirewallDialer(t *testing.T) {
 	}
 }
 
+func TestFirewallDialer_CheckHost(t *testing.T) {
+	blockedCIDR := flagext.CIDR{}
+	require.NoError(t, blockedCIDR.Set("172.217.168.64/28"))
+
+	tests := map[string]struct {
+		cfg           FirewallDialerConfigProvider
+		host          string
+		expectBlocked bool
+	}{
+		"should not block with no block config": {
+			cfg:  firewallCfgProvider{},
+			host: "127.0.0.1",
+		},
+		"should block a private address": {
+			cfg:           firewallCfgProvider{blockPrivateAddresses: true},
+			host:          "192.168.0.1",
+			expectBlocked: true,
+		},
+		"should block a local address": {
+			cfg:           firewallCfgProvider{blockPrivateAddresses: true},
+			host:          "::1",
+			expectBlocked: true,
+		},
+		"should block an address in a custom CIDR": {
+			cfg:           firewallCfgProvider{blockCIDRNetworks: []flagext.CIDR{blockedCIDR}},
+			host:          "172.217.168.78",
+			expectBlocked: true,
+		},
+		"should not block an address outside the custom CIDR": {
+			cfg:  firewallCfgProvider{blockCIDRNetworks: []flagext.CIDR{blockedCIDR}},
+			host: "10.0.0.1",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			d := NewFirewallDialer(testData.cfg)
+
+			err := d.CheckHost(context.Background(), testData.host)
+			if testData.expectBlocked {
+				assert.Equal(t, errBlockedAddress, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestIsPrivate(t *testing.T) {
 	tests := []struct {
 		ip       net.IP
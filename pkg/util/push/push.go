@@ -3,6 +3,7 @@ package push
 import (
 	"context"
 	"net/http"
+	"strconv"
 
 	"github.com/go-kit/kit/log/level"
 	"github.com/weaveworks/common/httpgrpc"
@@ -13,6 +14,26 @@ import (
 	"github.com/cortexproject/cortex/pkg/util/log"
 )
 
+// HADrainingHeader is the header a Prometheus HA replica can set on its remote write requests
+// while shutting down, so that the distributors failover to another replica of the same cluster
+// right away, instead of waiting for the HA tracker failover timeout.
+const HADrainingHeader = "X-Cortex-HA-Draining"
+
+type haDrainingContextKey struct{}
+
+var haDrainingCtxKey = &haDrainingContextKey{}
+
+// ContextWithHADraining returns a context marking the request as sent by a draining HA replica.
+func ContextWithHADraining(ctx context.Context) context.Context {
+	return context.WithValue(ctx, haDrainingCtxKey, true)
+}
+
+// IsHADraining returns whether the request has been sent by a draining HA replica.
+func IsHADraining(ctx context.Context) bool {
+	draining, _ := ctx.Value(haDrainingCtxKey).(bool)
+	return draining
+}
+
 // Func defines the type of the push. It is similar to http.HandlerFunc.
 type Func func(context.Context, *cortexpb.WriteRequest) (*cortexpb.WriteResponse, error)
 
@@ -28,6 +49,10 @@ func Handler(maxRecvMsgSize int, sourceIPs *middleware.SourceIPExtractor, push F
 				logger = log.WithSourceIPs(source, logger)
 			}
 		}
+		if draining, _ := strconv.ParseBool(r.Header.Get(HADrainingHeader)); draining {
+			ctx = ContextWithHADraining(ctx)
+		}
+
 		var req cortexpb.PreallocWriteRequest
 		err := util.ParseProtoReader(ctx, r.Body, int(r.ContentLength), maxRecvMsgSize, &req, util.RawSnappy)
 		if err != nil {
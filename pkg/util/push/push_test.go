@@ -46,6 +46,23 @@ func TestHandler_ignoresSkipLabelNameValidationIfSet(t *testing.T) {
 	}
 }
 
+func TestHandler_haDrainingHeader(t *testing.T) {
+	for header, expected := range map[string]bool{"": false, "false": false, "true": true} {
+		req := createRequest(t, createPrometheusRemoteWriteProtobuf(t))
+		if header != "" {
+			req.Header.Set(HADrainingHeader, header)
+		}
+
+		resp := httptest.NewRecorder()
+		handler := Handler(100000, nil, func(ctx context.Context, _ *cortexpb.WriteRequest) (*cortexpb.WriteResponse, error) {
+			assert.Equal(t, expected, IsHADraining(ctx), "header: %q", header)
+			return &cortexpb.WriteResponse{}, nil
+		})
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, 200, resp.Code)
+	}
+}
+
 func verifyWriteRequestHandler(t *testing.T, expectSource cortexpb.WriteRequest_SourceEnum) func(ctx context.Context, request *cortexpb.WriteRequest) (response *cortexpb.WriteResponse, err error) {
 	t.Helper()
 	return func(ctx context.Context, request *cortexpb.WriteRequest) (response *cortexpb.WriteResponse, err error) {
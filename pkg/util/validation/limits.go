@@ -55,6 +55,9 @@ type Limits struct {
 	IngestionTenantShardSize  int                 `yaml:"ingestion_tenant_shard_size" json:"ingestion_tenant_shard_size"`
 	MetricRelabelConfigs      []*relabel.Config   `yaml:"metric_relabel_configs,omitempty" json:"metric_relabel_configs,omitempty" doc:"nocli|description=List of metric relabel configurations. Note that in most situations, it is more effective to use metrics relabeling directly in the Prometheus server, e.g. remote_write.write_relabel_configs."`
 
+	// Streaming aggregation, computed by the distributors.
+	StreamingAggregationRules []*StreamingAggregationRule `yaml:"streaming_aggregation_rules,omitempty" json:"streaming_aggregation_rules,omitempty" doc:"nocli|description=List of streaming aggregation rules, summing the samples of an input metric grouped by some of its labels while ingesting it. Requires -distributor.streaming-aggregation.enabled. The runtime configuration containing an invalid rule is rejected."`
+
 	// Ingester enforced limits.
 	// Series
 	MaxSeriesPerQuery        int `yaml:"max_series_per_query" json:"max_series_per_query"`
@@ -64,6 +67,12 @@ type Limits struct {
 	MaxGlobalSeriesPerUser   int `yaml:"max_global_series_per_user" json:"max_global_series_per_user"`
 	MaxGlobalSeriesPerMetric int `yaml:"max_global_series_per_metric" json:"max_global_series_per_metric"`
 	MinChunkLength           int `yaml:"min_chunk_length" json:"min_chunk_length"`
+	// TSDB head
+	IngesterTSDBBlockRangePeriod          model.Duration `yaml:"ingester_tsdb_block_range_period" json:"ingester_tsdb_block_range_period"`
+	IngesterTSDBHeadChunksWriteBufferSize int            `yaml:"ingester_tsdb_head_chunks_write_buffer_size_bytes" json:"ingester_tsdb_head_chunks_write_buffer_size_bytes"`
+	IngesterTSDBStripeSize                int            `yaml:"ingester_tsdb_stripe_size" json:"ingester_tsdb_stripe_size"`
+	IngesterTSDBMaxExemplars              int            `yaml:"ingester_tsdb_max_exemplars" json:"ingester_tsdb_max_exemplars"`
+	IngesterTSDBWALCompression            string         `yaml:"ingester_tsdb_wal_compression" json:"ingester_tsdb_wal_compression"`
 	// Metadata
 	MaxLocalMetricsWithMetadataPerUser  int `yaml:"max_metadata_per_user" json:"max_metadata_per_user"`
 	MaxLocalMetadataPerMetric           int `yaml:"max_metadata_per_metric" json:"max_metadata_per_metric"`
@@ -81,6 +90,14 @@ type Limits struct {
 	CardinalityLimit             int            `yaml:"cardinality_limit" json:"cardinality_limit"`
 	MaxCacheFreshness            model.Duration `yaml:"max_cache_freshness" json:"max_cache_freshness"`
 	MaxQueriersPerTenant         int            `yaml:"max_queriers_per_tenant" json:"max_queriers_per_tenant"`
+	ResultsCacheMaxBytes         int            `yaml:"results_cache_max_bytes" json:"results_cache_max_bytes"`
+	MaxRangeQueriesPerSecond     float64        `yaml:"max_range_queries_per_second" json:"max_range_queries_per_second"`
+	MaxInstantQueriesPerSecond   float64        `yaml:"max_instant_queries_per_second" json:"max_instant_queries_per_second"`
+	MaxMetadataQueriesPerSecond  float64        `yaml:"max_metadata_queries_per_second" json:"max_metadata_queries_per_second"`
+	MaxConcurrentRangeQueries    int            `yaml:"max_concurrent_range_queries" json:"max_concurrent_range_queries"`
+	MaxConcurrentInstantQueries  int            `yaml:"max_concurrent_instant_queries" json:"max_concurrent_instant_queries"`
+	MaxConcurrentMetadataQueries int            `yaml:"max_concurrent_metadata_queries" json:"max_concurrent_metadata_queries"`
+	UseSecondStoreBeforeTime     flagext.Time   `yaml:"use_second_store_before_time" json:"use_second_store_before_time"`
 
 	// Ruler defaults and limits.
 	RulerEvaluationDelay        model.Duration `yaml:"ruler_evaluation_delay_duration" json:"ruler_evaluation_delay_duration"`
@@ -145,6 +162,11 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.MaxGlobalSeriesPerUser, "ingester.max-global-series-per-user", 0, "The maximum number of active series per user, across the cluster before replication. 0 to disable. Supported only if -distributor.shard-by-all-labels is true.")
 	f.IntVar(&l.MaxGlobalSeriesPerMetric, "ingester.max-global-series-per-metric", 0, "The maximum number of active series per metric name, across the cluster before replication. 0 to disable.")
 	f.IntVar(&l.MinChunkLength, "ingester.min-chunk-length", 0, "Minimum number of samples in an idle chunk to flush it to the store. Use with care, if chunks are less than this size they will be discarded. This option is ignored when running the Cortex blocks storage. 0 to disable.")
+	f.Var(&l.IngesterTSDBBlockRangePeriod, "ingester.tsdb-block-range-period", "Per-tenant period of the blocks the TSDB head is compacted to in the ingesters. This option only works with the blocks storage, and is applied when the tenant's TSDB is opened. The period is ignored if larger than -blocks-storage.tsdb.retention-period, not lower than -querier.query-ingesters-within (when set), or not dividing the larger -compactor.block-ranges. 0 to use -blocks-storage.tsdb.block-ranges-period.")
+	f.IntVar(&l.IngesterTSDBHeadChunksWriteBufferSize, "ingester.tsdb-head-chunks-write-buffer-size-bytes", 0, "Per-tenant write buffer size used by the TSDB head chunks mapper in the ingesters. This option only works with the blocks storage, and is applied when the tenant's TSDB is opened. 0 to use -blocks-storage.tsdb.head-chunks-write-buffer-size-bytes.")
+	f.IntVar(&l.IngesterTSDBStripeSize, "ingester.tsdb-stripe-size", 0, "Per-tenant number of shards of series in the TSDB head in the ingesters (must be a power of 2). This option only works with the blocks storage, and is applied when the tenant's TSDB is opened. 0 to use -blocks-storage.tsdb.stripe-size.")
+	f.IntVar(&l.IngesterTSDBMaxExemplars, "ingester.tsdb-max-exemplars", 0, "Per-tenant maximum number of exemplars stored in the TSDB head in the ingesters. This option only works with the blocks storage, and is applied when the tenant's TSDB is opened. 0 to use -blocks-storage.tsdb.max-exemplars, negative to disable exemplars for the tenant.")
+	f.StringVar(&l.IngesterTSDBWALCompression, "ingester.tsdb-wal-compression", "", "Per-tenant TSDB WAL compression in the ingesters. Supported values are: none, snappy. This option only works with the blocks storage, and is applied when the tenant's TSDB is opened. Empty to use -blocks-storage.tsdb.wal-compression-enabled.")
 
 	f.IntVar(&l.MaxLocalMetricsWithMetadataPerUser, "ingester.max-metadata-per-user", 8000, "The maximum number of active metrics with metadata per user, per ingester. 0 to disable.")
 	f.IntVar(&l.MaxLocalMetadataPerMetric, "ingester.max-metadata-per-metric", 10, "The maximum number of metadata per metric, per ingester. 0 to disable.")
@@ -161,6 +183,14 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	_ = l.MaxCacheFreshness.Set("1m")
 	f.Var(&l.MaxCacheFreshness, "frontend.max-cache-freshness", "Most recent allowed cacheable result per-tenant, to prevent caching very recent results that might still be in flux.")
 	f.IntVar(&l.MaxQueriersPerTenant, "frontend.max-queriers-per-tenant", 0, "Maximum number of queriers that can handle requests for a single tenant. If set to 0 or value higher than number of available queriers, *all* queriers will handle requests for the tenant. Each frontend (or query-scheduler, if used) will select the same set of queriers for the same tenant (given that all queriers are connected to all frontends / query-schedulers). This option only works with queriers connecting to the query-frontend / query-scheduler, not when using downstream URL.")
+	f.IntVar(&l.ResultsCacheMaxBytes, "frontend.results-cache.max-bytes", 0, "Maximum number of bytes of query results a tenant can store in the results cache through each query-frontend. Only enforced when -frontend.results-cache.tenant-isolation-enabled is true. 0 to disable.")
+	f.Float64Var(&l.MaxRangeQueriesPerSecond, "frontend.max-range-queries-per-second", 0, "Maximum number of range queries per second a tenant can run through each query-frontend, including remote read and exemplar queries. Bursts of up to one second of queries are allowed. Requests exceeding the limit are rejected with 429. 0 to disable.")
+	f.Float64Var(&l.MaxInstantQueriesPerSecond, "frontend.max-instant-queries-per-second", 0, "Maximum number of instant queries per second a tenant can run through each query-frontend. Bursts of up to one second of queries are allowed. Requests exceeding the limit are rejected with 429. 0 to disable.")
+	f.Float64Var(&l.MaxMetadataQueriesPerSecond, "frontend.max-metadata-queries-per-second", 0, "Maximum number of series, labels, label values and metadata queries per second a tenant can run through each query-frontend. Bursts of up to one second of queries are allowed. Requests exceeding the limit are rejected with 429. 0 to disable.")
+	f.IntVar(&l.MaxConcurrentRangeQueries, "frontend.max-concurrent-range-queries", 0, "Maximum number of range queries a tenant can run concurrently through each query-frontend, including remote read and exemplar queries. Requests exceeding the limit are rejected with 429. 0 to disable.")
+	f.IntVar(&l.MaxConcurrentInstantQueries, "frontend.max-concurrent-instant-queries", 0, "Maximum number of instant queries a tenant can run concurrently through each query-frontend. Requests exceeding the limit are rejected with 429. 0 to disable.")
+	f.IntVar(&l.MaxConcurrentMetadataQueries, "frontend.max-concurrent-metadata-queries", 0, "Maximum number of series, labels, label values and metadata queries a tenant can run concurrently through each query-frontend. Requests exceeding the limit are rejected with 429. 0 to disable.")
+	f.Var(&l.UseSecondStoreBeforeTime, "querier.tenant-use-second-store-before-time", "If specified, the second store is only used for the tenant's queries before this timestamp, overriding -querier.use-second-store-before-time and the blocks conversion progress. Used to migrate a tenant's data to the primary store day by day. Default value 0 means the querier's setting is used.")
 
 	f.Var(&l.RulerEvaluationDelay, "ruler.evaluation-delay-duration", "Duration to delay the evaluation of rules to ensure the underlying metrics have been pushed to Cortex.")
 	f.IntVar(&l.RulerTenantShardSize, "ruler.tenant-shard-size", 0, "The default tenant's shard size when the shuffle-sharding strategy is used by ruler. When this setting is specified in the per-tenant overrides, a value of 0 disables shuffle sharding for the tenant.")
@@ -435,6 +465,54 @@ func (o *Overrides) MaxQueriersPerUser(userID string) int {
 	return o.getOverridesForUser(userID).MaxQueriersPerTenant
 }
 
+// ResultsCacheMaxBytes returns the maximum number of bytes of query results the user can
+// store in the results cache through each query-frontend.
+func (o *Overrides) ResultsCacheMaxBytes(userID string) int {
+	return o.getOverridesForUser(userID).ResultsCacheMaxBytes
+}
+
+// MaxRangeQueriesPerSecond returns the maximum number of range queries per second the user
+// can run through each query-frontend.
+func (o *Overrides) MaxRangeQueriesPerSecond(userID string) float64 {
+	return o.getOverridesForUser(userID).MaxRangeQueriesPerSecond
+}
+
+// MaxInstantQueriesPerSecond returns the maximum number of instant queries per second the user
+// can run through each query-frontend.
+func (o *Overrides) MaxInstantQueriesPerSecond(userID string) float64 {
+	return o.getOverridesForUser(userID).MaxInstantQueriesPerSecond
+}
+
+// MaxMetadataQueriesPerSecond returns the maximum number of metadata queries per second the user
+// can run through each query-frontend.
+func (o *Overrides) MaxMetadataQueriesPerSecond(userID string) float64 {
+	return o.getOverridesForUser(userID).MaxMetadataQueriesPerSecond
+}
+
+// MaxConcurrentRangeQueries returns the maximum number of range queries the user can run
+// concurrently through each query-frontend.
+func (o *Overrides) MaxConcurrentRangeQueries(userID string) int {
+	return o.getOverridesForUser(userID).MaxConcurrentRangeQueries
+}
+
+// MaxConcurrentInstantQueries returns the maximum number of instant queries the user can run
+// concurrently through each query-frontend.
+func (o *Overrides) MaxConcurrentInstantQueries(userID string) int {
+	return o.getOverridesForUser(userID).MaxConcurrentInstantQueries
+}
+
+// MaxConcurrentMetadataQueries returns the maximum number of metadata queries the user can run
+// concurrently through each query-frontend.
+func (o *Overrides) MaxConcurrentMetadataQueries(userID string) int {
+	return o.getOverridesForUser(userID).MaxConcurrentMetadataQueries
+}
+
+// UseSecondStoreBeforeTime returns the timestamp before which the second store is queried for the tenant,
+// or zero if not overridden.
+func (o *Overrides) UseSecondStoreBeforeTime(userID string) time.Time {
+	return time.Time(o.getOverridesForUser(userID).UseSecondStoreBeforeTime)
+}
+
 // MaxQueryParallelism returns the limit to the number of split queries the
 // frontend will process in parallel.
 func (o *Overrides) MaxQueryParallelism(userID string) int {
@@ -461,6 +539,31 @@ func (o *Overrides) MinChunkLength(userID string) int {
 	return o.getOverridesForUser(userID).MinChunkLength
 }
 
+// IngesterTSDBBlockRangePeriod returns the period of the blocks the tenant's TSDB head is compacted to.
+func (o *Overrides) IngesterTSDBBlockRangePeriod(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).IngesterTSDBBlockRangePeriod)
+}
+
+// IngesterTSDBHeadChunksWriteBufferSize returns the write buffer size of the tenant's TSDB head chunks mapper.
+func (o *Overrides) IngesterTSDBHeadChunksWriteBufferSize(userID string) int {
+	return o.getOverridesForUser(userID).IngesterTSDBHeadChunksWriteBufferSize
+}
+
+// IngesterTSDBStripeSize returns the number of shards of series in the tenant's TSDB head.
+func (o *Overrides) IngesterTSDBStripeSize(userID string) int {
+	return o.getOverridesForUser(userID).IngesterTSDBStripeSize
+}
+
+// IngesterTSDBMaxExemplars returns the maximum number of exemplars stored in the tenant's TSDB head.
+func (o *Overrides) IngesterTSDBMaxExemplars(userID string) int {
+	return o.getOverridesForUser(userID).IngesterTSDBMaxExemplars
+}
+
+// IngesterTSDBWALCompression returns the compression of the tenant's TSDB WAL.
+func (o *Overrides) IngesterTSDBWALCompression(userID string) string {
+	return o.getOverridesForUser(userID).IngesterTSDBWALCompression
+}
+
 // MaxLocalMetricsWithMetadataPerUser returns the maximum number of metrics with metadata a user is allowed to store in a single ingester.
 func (o *Overrides) MaxLocalMetricsWithMetadataPerUser(userID string) int {
 	return o.getOverridesForUser(userID).MaxLocalMetricsWithMetadataPerUser
@@ -501,6 +604,11 @@ func (o *Overrides) MetricRelabelConfigs(userID string) []*relabel.Config {
 	return o.getOverridesForUser(userID).MetricRelabelConfigs
 }
 
+// StreamingAggregationRules returns the streaming aggregation rules for a given user.
+func (o *Overrides) StreamingAggregationRules(userID string) []*StreamingAggregationRule {
+	return o.getOverridesForUser(userID).StreamingAggregationRules
+}
+
 // RulerTenantShardSize returns shard size (number of rulers) used by this tenant when using shuffle-sharding strategy.
 func (o *Overrides) RulerTenantShardSize(userID string) int {
 	return o.getOverridesForUser(userID).RulerTenantShardSize
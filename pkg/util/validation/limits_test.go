@@ -255,6 +255,76 @@ metric_relabel_configs:
 	assert.Equal(t, []*relabel.Config{&exp}, l.MetricRelabelConfigs)
 }
 
+func TestStreamingAggregationRulesLoadingFromYamlAndJson(t *testing.T) {
+	SetDefaultLimitsForYAMLUnmarshalling(Limits{})
+
+	tests := map[string]struct {
+		yaml        string
+		json        string
+		expectedErr string
+	}{
+		"valid rule": {
+			yaml: `
+streaming_aggregation_rules:
+- record: http_requests:sum
+  metric: http_requests_total
+  type: counter
+  by: [status]
+  interval: 1m
+`,
+			json: `{"streaming_aggregation_rules": [{"record": "http_requests:sum", "metric": "http_requests_total", "type": "counter", "by": ["status"], "interval": "1m"}]}`,
+		},
+		"invalid rule": {
+			yaml: `
+streaming_aggregation_rules:
+- record: http_requests:sum
+  metric: http_requests_total
+  type: counter
+  by: [status]
+`,
+			json:        `{"streaming_aggregation_rules": [{"record": "http_requests:sum", "metric": "http_requests_total", "type": "counter", "by": ["status"]}]}`,
+			expectedErr: "the streaming aggregation interval must be greater than 0",
+		},
+		"rule without type": {
+			yaml: `
+streaming_aggregation_rules:
+- record: http_requests:sum
+  metric: http_requests_total
+  by: [status]
+  interval: 1m
+`,
+			json:        `{"streaming_aggregation_rules": [{"record": "http_requests:sum", "metric": "http_requests_total", "by": ["status"], "interval": "1m"}]}`,
+			expectedErr: `invalid streaming aggregation type ""`,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			limitsYAML := Limits{}
+			errYAML := yaml.UnmarshalStrict([]byte(testData.yaml), &limitsYAML)
+
+			limitsJSON := Limits{}
+			errJSON := json.Unmarshal([]byte(testData.json), &limitsJSON)
+
+			if testData.expectedErr != "" {
+				require.Error(t, errYAML)
+				assert.Contains(t, errYAML.Error(), testData.expectedErr)
+				require.Error(t, errJSON)
+				assert.Contains(t, errJSON.Error(), testData.expectedErr)
+				return
+			}
+
+			require.NoError(t, errYAML)
+			require.NoError(t, errJSON)
+			assert.Equal(t, limitsYAML, limitsJSON)
+
+			require.Len(t, limitsYAML.StreamingAggregationRules, 1)
+			rule := limitsYAML.StreamingAggregationRules[0]
+			assert.Equal(t, "http_requests:sum=sum by ([status]) (http_requests_total) every 1m, type: counter, drop input: false", rule.String())
+		})
+	}
+}
+
 func TestSmallestPositiveIntPerTenant(t *testing.T) {
 	tenantLimits := map[string]*Limits{
 		"tenant-a": {
@@ -0,0 +1,97 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+const (
+	// StreamingAggregationGauge is the type of the rules summing the last sample of the input series.
+	StreamingAggregationGauge = "gauge"
+
+	// StreamingAggregationCounter is the type of the rules summing the increases of the input series,
+	// taking their counter resets into account, so that the aggregated series is a counter too.
+	StreamingAggregationCounter = "counter"
+)
+
+// StreamingAggregationRule configures the sum of the samples of an input metric, grouped by some
+// of its labels, computed by the distributors while ingesting the input metric.
+type StreamingAggregationRule struct {
+	Record    string         `yaml:"record" json:"record"`
+	Metric    string         `yaml:"metric" json:"metric"`
+	Type      string         `yaml:"type" json:"type"`
+	By        []string       `yaml:"by" json:"by"`
+	Interval  model.Duration `yaml:"interval" json:"interval"`
+	DropInput bool           `yaml:"drop_input" json:"drop_input"`
+
+	// The key uniquely identifying the rule, computed once the rule is loaded.
+	key string
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface. The rules are validated once
+// loaded, so that the (runtime) configuration containing an invalid rule is rejected.
+func (r *StreamingAggregationRule) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain StreamingAggregationRule
+	if err := unmarshal((*plain)(r)); err != nil {
+		return err
+	}
+	return r.init()
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (r *StreamingAggregationRule) UnmarshalJSON(data []byte) error {
+	type plain StreamingAggregationRule
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+	return r.init()
+}
+
+func (r *StreamingAggregationRule) init() error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	r.key = ""
+	r.key = r.String()
+	return nil
+}
+
+// Validate returns an error if the rule is invalid.
+func (r *StreamingAggregationRule) Validate() error {
+	if !model.IsValidMetricName(model.LabelValue(r.Record)) {
+		return fmt.Errorf("invalid streaming aggregation record metric name %q", r.Record)
+	}
+	if !model.IsValidMetricName(model.LabelValue(r.Metric)) {
+		return fmt.Errorf("invalid streaming aggregation input metric name %q", r.Metric)
+	}
+	if r.Record == r.Metric {
+		return errors.New("the streaming aggregation record metric name must be different than the input metric name")
+	}
+	if r.Type != StreamingAggregationGauge && r.Type != StreamingAggregationCounter {
+		return fmt.Errorf("invalid streaming aggregation type %q, supported values are %q and %q", r.Type, StreamingAggregationGauge, StreamingAggregationCounter)
+	}
+	if r.Interval <= 0 {
+		return errors.New("the streaming aggregation interval must be greater than 0")
+	}
+
+	for _, name := range r.By {
+		if !model.LabelName(name).IsValid() || name == labels.MetricName {
+			return fmt.Errorf("invalid streaming aggregation grouping label %q", name)
+		}
+	}
+
+	return nil
+}
+
+// String returns a representation of the rule uniquely identifying it.
+func (r *StreamingAggregationRule) String() string {
+	if r.key != "" {
+		return r.key
+	}
+	return fmt.Sprintf("%s=sum by (%v) (%s) every %s, type: %s, drop input: %t", r.Record, r.By, r.Metric, r.Interval, r.Type, r.DropInput)
+}
@@ -89,10 +89,20 @@ var DiscardedMetadata = prometheus.NewCounterVec(
 	[]string{discardReasonLabel, "user"},
 )
 
+// DiscardedRequests is a metric of the number of discarded query requests, by reason.
+var DiscardedRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cortex_discarded_requests_total",
+		Help: "The total number of query requests that were discarded.",
+	},
+	[]string{discardReasonLabel, "user"},
+)
+
 func init() {
 	prometheus.MustRegister(DiscardedSamples)
 	prometheus.MustRegister(DiscardedExemplars)
 	prometheus.MustRegister(DiscardedMetadata)
+	prometheus.MustRegister(DiscardedRequests)
 }
 
 // SampleValidationConfig helps with getting required config to validate sample.
@@ -2,6 +2,8 @@ package scheduler
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"time"
 )
 
@@ -58,3 +60,47 @@ func (ps plan) Status() planStatus {
 
 	return New
 }
+
+// convertedSince returns the start of the oldest day from which all the user's days, up to the most
+// recent planned one, have been converted and verified, or zero if the most recent day hasn't been
+// converted yet. Queries after this time don't need the chunks storage anymore, so
+// queriers stop querying it for the user, and the chunks index from this day on can be dropped.
+func convertedSince(plans map[string]plan, verified func(dayIndex int, blockID string) (bool, error)) (time.Time, error) {
+	type dayPlan struct {
+		dayIndex int
+		plan     plan
+	}
+
+	var days []dayPlan
+	for base, p := range plans {
+		// Only scanner-produced plans have a day index as base name.
+		dayIndex, err := strconv.Atoi(base)
+		if err != nil {
+			continue
+		}
+		days = append(days, dayPlan{dayIndex: dayIndex, plan: p})
+	}
+
+	// Most recent days are converted first.
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].dayIndex > days[j].dayIndex
+	})
+
+	since := time.Time{}
+	for _, d := range days {
+		if d.plan.Status() != Finished {
+			break
+		}
+
+		ok, err := verified(d.dayIndex, d.plan.Finished[0])
+		if err != nil {
+			return time.Time{}, err
+		}
+		if !ok {
+			break
+		}
+
+		since = time.Unix(int64(d.dayIndex)*int64((24*time.Hour).Seconds()), 0).UTC()
+	}
+	return since, nil
+}
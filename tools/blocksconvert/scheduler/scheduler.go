@@ -19,9 +19,11 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
 	"github.com/thanos-io/thanos/pkg/objstore"
 	"google.golang.org/grpc"
 
+	"github.com/cortexproject/cortex/pkg/storage/tsdb"
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/services"
 	"github.com/cortexproject/cortex/tools/blocksconvert"
@@ -78,6 +80,9 @@ func newSchedulerWithBucket(l log.Logger, b objstore.Bucket, bucketPrefix string
 		allowedUsers: users,
 		ignoredUsers: ignoredUsers,
 
+		convertedSince: map[string]time.Time{},
+		verifiedDays:   map[string]map[int]bool{},
+
 		planStatus: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
 			Name: "cortex_blocksconvert_scheduler_scanned_plans",
 			Help: "Number of plans in different status",
@@ -123,6 +128,12 @@ type Scheduler struct {
 	scanning     bool
 	allUserPlans map[string]map[string]plan
 	plansQueue   []queuedPlan // Queued plans are sorted by day index - more recent (higher day index) days go first.
+
+	// Per-user conversion progress, as last written to the bucket.
+	convertedSince map[string]time.Time
+
+	// Per-user day indexes whose block has been verified to exist. Only accessed by scans.
+	verifiedDays map[string]map[int]bool
 }
 
 type queuedPlan struct {
@@ -167,6 +178,11 @@ func (s *Scheduler) scanBucketForPlans(ctx context.Context) error {
 	}
 	var queue []queuedPlan
 
+	s.scanMu.Lock()
+	prevConvertedSince := s.convertedSince
+	s.scanMu.Unlock()
+	allConvertedSince := map[string]time.Time{}
+
 	runConcurrently(ctx, s.cfg.PlanScanConcurrency, users, func(user string) {
 		userPrefix := path.Join(s.bucketPrefix, user) + "/"
 
@@ -211,6 +227,27 @@ func (s *Scheduler) scanBucketForPlans(ctx context.Context) error {
 			})
 			mu.Unlock()
 		}
+
+		mu.Lock()
+		verifiedDays := s.verifiedDays[user]
+		if verifiedDays == nil {
+			verifiedDays = map[int]bool{}
+			s.verifiedDays[user] = verifiedDays
+		}
+		mu.Unlock()
+
+		since, ok, err := s.updateConversionProgress(ctx, user, userPlans, prevConvertedSince, verifiedDays)
+		if err != nil {
+			level.Error(s.log).Log("msg", "failed to update conversion progress for user", "user", user, "err", err)
+		}
+
+		mu.Lock()
+		if ok {
+			allConvertedSince[user] = since
+		} else if prev, ok := prevConvertedSince[user]; ok {
+			allConvertedSince[user] = prev
+		}
+		mu.Unlock()
 	})
 
 	// Plans with higher day-index (more recent) are put at the beginning.
@@ -225,6 +262,7 @@ func (s *Scheduler) scanBucketForPlans(ctx context.Context) error {
 	s.scanMu.Lock()
 	s.allUserPlans = allPlans
 	s.plansQueue = queue
+	s.convertedSince = allConvertedSince
 	s.updateQueuedPlansMetrics()
 	s.scanMu.Unlock()
 
@@ -238,6 +276,56 @@ func (s *Scheduler) scanBucketForPlans(ctx context.Context) error {
 	return nil
 }
 
+// updateConversionProgress computes the user's conversion progress and, if changed, writes it to the
+// bucket for queriers to use. Returns the progress and whether it's stored in the bucket.
+func (s *Scheduler) updateConversionProgress(ctx context.Context, user string, userPlans map[string]plan, prevConvertedSince map[string]time.Time, verifiedDays map[int]bool) (time.Time, bool, error) {
+	prev, hasPrev := prevConvertedSince[user]
+	if !hasPrev {
+		// Days the progress already written to the bucket includes have been verified before.
+		// Their blocks may have been compacted and deleted since, so they're not checked again.
+		progress, err := tsdb.ReadBlocksConversionProgress(ctx, s.bucket, user)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		if progress != nil {
+			prev, hasPrev = progress.ConvertedSinceTime(), true
+		}
+	}
+
+	since, err := convertedSince(userPlans, func(dayIndex int, blockID string) (bool, error) {
+		if verifiedDays[dayIndex] {
+			return true, nil
+		}
+
+		dayStart := time.Unix(int64(dayIndex)*int64((24*time.Hour).Seconds()), 0).UTC()
+		if hasPrev && !prev.IsZero() && !dayStart.Before(prev) {
+			verifiedDays[dayIndex] = true
+			return true, nil
+		}
+
+		exists, err := s.bucket.Exists(ctx, path.Join(user, blockID, metadata.MetaFilename))
+		if err != nil {
+			return false, errors.Wrapf(err, "check block %s", blockID)
+		}
+		verifiedDays[dayIndex] = exists
+		return exists, nil
+	})
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	if hasPrev && prev.Equal(since) {
+		return since, true, nil
+	}
+
+	if err := tsdb.WriteBlocksConversionProgress(ctx, s.bucket, user, nil, tsdb.NewBlocksConversionProgress(since)); err != nil {
+		return time.Time{}, false, err
+	}
+
+	level.Info(s.log).Log("msg", "updated conversion progress", "user", user, "converted_since", since)
+	return since, true, nil
+}
+
 func (s *Scheduler) deleteObsoleteProgressFiles(ctx context.Context, plan *plan, planBaseName string) {
 	for pg, t := range plan.ProgressFiles {
 		if time.Since(t) < s.cfg.MaxProgressFileAge {
@@ -429,8 +517,10 @@ var plansTemplate = template.Must(template.New("plans").Parse(`
 		</ul>
 
 		<h1>Users</h1>
+		{{ $since := .ConvertedSince }}
 		{{ range $u, $up := .Plans }}
 			<h2>{{ $u }}</h2>
+			<p>Converted since: {{ with index $since $u }}{{ . }}{{ else }}none{{ end }}</p>
 
 			<table width="100%" border="1">
 				<thead>
@@ -464,16 +554,29 @@ func (s *Scheduler) httpPlans(writer http.ResponseWriter, req *http.Request) {
 	s.scanMu.Lock()
 	plans := s.allUserPlans
 	queue := s.plansQueue
+	convertedSince := s.convertedSince
 	s.scanMu.Unlock()
 
+	// The time is formatted as expected by the use_second_store_before_time override, and empty
+	// if the user has no converted day yet.
+	since := map[string]string{}
+	for user := range plans {
+		since[user] = ""
+		if t := convertedSince[user]; !t.IsZero() {
+			since[user] = t.Format(time.RFC3339)
+		}
+	}
+
 	data := struct {
-		Now   time.Time
-		Plans map[string]map[string]plan
-		Queue []queuedPlan
+		Now            time.Time                  `json:"now"`
+		Plans          map[string]map[string]plan `json:"plans"`
+		ConvertedSince map[string]string          `json:"converted_since"`
+		Queue          []queuedPlan               `json:"queue"`
 	}{
-		Now:   time.Now(),
-		Plans: plans,
-		Queue: queue,
+		Now:            time.Now(),
+		Plans:          plans,
+		ConvertedSince: since,
+		Queue:          queue,
 	}
 
 	util.RenderHTTPResponse(writer, data, plansTemplate, req)
@@ -2,7 +2,9 @@ package scheduler
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http/httptest"
 	"os"
 	"regexp"
 	"strings"
@@ -13,6 +15,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/thanos-io/thanos/pkg/objstore"
 
+	"github.com/cortexproject/cortex/pkg/storage/tsdb"
 	"github.com/cortexproject/cortex/tools/blocksconvert"
 )
 
@@ -120,3 +123,138 @@ func TestSchedulerScan(t *testing.T) {
 		require.Equal(t, "", pg)
 	}
 }
+
+func TestConvertedSince(t *testing.T) {
+	finished := plan{PlanFiles: []string{"plan"}, Finished: []string{"01E8GCW9J0HV0992HSZ0N6RAMN"}}
+	inProgress := plan{PlanFiles: []string{"plan"}, ProgressFiles: map[string]time.Time{"progress": time.Now()}}
+	day := func(dayIndex int64) time.Time {
+		return time.Unix(dayIndex*int64((24*time.Hour).Seconds()), 0).UTC()
+	}
+
+	tests := map[string]struct {
+		plans      map[string]plan
+		unverified int
+		expected   time.Time
+	}{
+		"no plans": {
+			plans:    map[string]plan{},
+			expected: time.Time{},
+		},
+		"most recent day not converted yet": {
+			plans:    map[string]plan{"10": inProgress, "9": finished},
+			expected: time.Time{},
+		},
+		"all days converted": {
+			plans:    map[string]plan{"10": finished, "9": finished, "8": finished},
+			expected: day(8),
+		},
+		"stops at the first day not converted": {
+			plans:    map[string]plan{"10": finished, "9": finished, "8": {PlanFiles: []string{"plan"}}, "7": finished},
+			expected: day(9),
+		},
+		"ignores plans without day index": {
+			plans:    map[string]plan{"10": finished, "custom": inProgress},
+			expected: day(10),
+		},
+		"stops at the first day not verified": {
+			plans:      map[string]plan{"10": finished, "9": finished, "8": finished},
+			unverified: 8,
+			expected:   day(9),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			since, err := convertedSince(tc.plans, func(dayIndex int, blockID string) (bool, error) {
+				return dayIndex != tc.unverified, nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, since)
+		})
+	}
+}
+
+func TestSchedulerHTTPPlans(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	require.NoError(t, bucket.Upload(context.Background(), "migration/user1/18800.plan", strings.NewReader("")))
+	require.NoError(t, bucket.Upload(context.Background(), "migration/user1/18800.finished.01E8GCW9J0HV0992HSZ0N6RAMN", strings.NewReader("")))
+	require.NoError(t, bucket.Upload(context.Background(), "user1/01E8GCW9J0HV0992HSZ0N6RAMN/meta.json", strings.NewReader("")))
+	require.NoError(t, bucket.Upload(context.Background(), "migration/user2/18800.plan", strings.NewReader("")))
+
+	s := newSchedulerWithBucket(log.NewNopLogger(), bucket, "migration", blocksconvert.AllowAllUsers, nil, Config{
+		ScanInterval:        10 * time.Second,
+		PlanScanConcurrency: 5,
+		MaxProgressFileAge:  5 * time.Minute,
+	}, nil)
+	require.NoError(t, s.scanBucketForPlans(context.Background()))
+
+	t.Run("JSON", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/plans", nil)
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+		s.httpPlans(rec, req)
+
+		res := struct {
+			ConvertedSince map[string]string `json:"converted_since"`
+		}{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		require.Equal(t, map[string]string{"user1": "2021-06-22T00:00:00Z", "user2": ""}, res.ConvertedSince)
+	})
+
+	t.Run("HTML", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		s.httpPlans(rec, httptest.NewRequest("GET", "/plans", nil))
+
+		require.Contains(t, rec.Body.String(), "Converted since: 2021-06-22T00:00:00Z")
+		require.Contains(t, rec.Body.String(), "Converted since: none")
+	})
+}
+
+func TestSchedulerConversionProgress(t *testing.T) {
+	ctx := context.Background()
+	bucket := objstore.NewInMemBucket()
+	require.NoError(t, bucket.Upload(ctx, "migration/user1/18800.plan", strings.NewReader("")))
+	require.NoError(t, bucket.Upload(ctx, "migration/user1/18800.finished.01E8GCW9J0HV0992HSZ0N6RAMN", strings.NewReader("")))
+	require.NoError(t, bucket.Upload(ctx, "user1/01E8GCW9J0HV0992HSZ0N6RAMN/meta.json", strings.NewReader("")))
+	require.NoError(t, bucket.Upload(ctx, "migration/user1/18799.plan", strings.NewReader("")))
+	require.NoError(t, bucket.Upload(ctx, "migration/user1/18799.finished.01E8GCW9J0HV0992HSZ0N6RAMP", strings.NewReader("")))
+
+	cfg := Config{
+		ScanInterval:        10 * time.Second,
+		PlanScanConcurrency: 5,
+		MaxProgressFileAge:  5 * time.Minute,
+	}
+	newScheduler := func() *Scheduler {
+		return newSchedulerWithBucket(log.NewNopLogger(), bucket, "migration", blocksconvert.AllowAllUsers, nil, cfg, nil)
+	}
+	assertConvertedSince := func(s *Scheduler, expected string) {
+		require.NoError(t, s.scanBucketForPlans(ctx))
+
+		progress, err := tsdb.ReadBlocksConversionProgress(ctx, bucket, "user1")
+		require.NoError(t, err)
+		require.NotNil(t, progress)
+
+		actual := ""
+		if ts := progress.ConvertedSinceTime(); !ts.IsZero() {
+			actual = ts.Format(time.RFC3339)
+		}
+		require.Equal(t, expected, actual)
+	}
+
+	// The block of the oldest day doesn't exist yet.
+	s := newScheduler()
+	assertConvertedSince(s, "2021-06-22T00:00:00Z")
+
+	require.NoError(t, bucket.Upload(ctx, "user1/01E8GCW9J0HV0992HSZ0N6RAMP/meta.json", strings.NewReader("")))
+	assertConvertedSince(s, "2021-06-21T00:00:00Z")
+
+	// Verified blocks may be compacted and deleted, without affecting the progress, even after restarting.
+	require.NoError(t, bucket.Delete(ctx, "user1/01E8GCW9J0HV0992HSZ0N6RAMN/meta.json"))
+	require.NoError(t, bucket.Delete(ctx, "user1/01E8GCW9J0HV0992HSZ0N6RAMP/meta.json"))
+	assertConvertedSince(s, "2021-06-21T00:00:00Z")
+	assertConvertedSince(newScheduler(), "2021-06-21T00:00:00Z")
+
+	// A more recent day not converted yet must be queried from the chunks storage again.
+	require.NoError(t, bucket.Upload(ctx, "migration/user1/18801.plan", strings.NewReader("")))
+	assertConvertedSince(s, "")
+}
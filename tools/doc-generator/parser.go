@@ -257,6 +257,8 @@ func getFieldType(t reflect.Type) (string, error) {
 		return "string", nil
 	case "[]*relabel.Config":
 		return "relabel_config...", nil
+	case "[]*validation.StreamingAggregationRule":
+		return "streaming_aggregation_rule...", nil
 	}
 
 	// Fallback to auto-detection of built-in data types
@@ -0,0 +1,204 @@
+package queryreplay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logfmt/logfmt"
+)
+
+const (
+	slowQueryMessage  = "slow query detected"
+	queryStatsMessage = "query stats"
+
+	paramPrefix = "param_"
+)
+
+// Query is a query executed by the query-frontend, as parsed from its logs.
+type Query struct {
+	// Time at which the query has been logged.
+	Time time.Time
+
+	// Tenant which executed the query. Empty if not logged.
+	OrgID string
+
+	Method string
+	Path   string
+	Params url.Values
+
+	// The time taken by the query-frontend to run the query. Zero if not logged.
+	Duration time.Duration
+}
+
+// ParseQueryLogs parses the queries from the query-frontend slow queries and query stats log lines,
+// formatted either in logfmt or JSON. The other log lines are ignored. The returned queries are
+// sorted by time.
+func ParseQueryLogs(r io.Reader) ([]Query, error) {
+	var queries []Query
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields, err := parseLogLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+
+		q, ok, err := queryFromFields(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		if ok {
+			queries = append(queries, q)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return SortQueries(queries), nil
+}
+
+// SortQueries sorts the queries by time, ie. after merging the queries parsed from multiple logs.
+func SortQueries(queries []Query) []Query {
+	sort.SliceStable(queries, func(i, j int) bool {
+		return queries[i].Time.Before(queries[j].Time)
+	})
+	return queries
+}
+
+func parseLogLine(line string) (map[string]string, error) {
+	fields := map[string]string{}
+
+	if strings.HasPrefix(line, "{") {
+		var raw map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &raw); err != nil {
+			return nil, err
+		}
+		for k, v := range raw {
+			fields[k] = fmt.Sprint(v)
+		}
+		return fields, nil
+	}
+
+	dec := logfmt.NewDecoder(strings.NewReader(line))
+	for dec.ScanRecord() {
+		for dec.ScanKeyval() {
+			fields[string(dec.Key())] = string(dec.Value())
+		}
+	}
+	return fields, dec.Err()
+}
+
+// queryFromFields builds the query from the fields of a log line. Returns false if the
+// log line is not about a query.
+func queryFromFields(fields map[string]string) (Query, bool, error) {
+	if msg := fields["msg"]; msg != slowQueryMessage && msg != queryStatsMessage {
+		return Query{}, false, nil
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, fields["ts"])
+	if err != nil {
+		return Query{}, false, fmt.Errorf("invalid timestamp %q: %v", fields["ts"], err)
+	}
+
+	q := Query{
+		Time:   ts,
+		OrgID:  fields["org_id"],
+		Method: fields["method"],
+		Path:   fields["path"],
+		Params: url.Values{},
+	}
+
+	if q.Method == "" {
+		q.Method = "GET"
+	}
+
+	// The slow queries log the duration as "time_taken", while the query stats as "response_time".
+	for _, key := range []string{"time_taken", "response_time"} {
+		if v, ok := fields[key]; ok {
+			if q.Duration, err = time.ParseDuration(v); err != nil {
+				return Query{}, false, fmt.Errorf("invalid %s %q: %v", key, v, err)
+			}
+		}
+	}
+
+	for k, v := range fields {
+		if !strings.HasPrefix(k, paramPrefix) {
+			continue
+		}
+
+		name := strings.TrimPrefix(k, paramPrefix)
+		if strings.HasSuffix(name, "[]") {
+			q.Params[name] = splitMultiValuedParam(v)
+		} else {
+			q.Params.Set(name, v)
+		}
+	}
+
+	return q, true, nil
+}
+
+// splitMultiValuedParam splits the values of a parameter provided multiple times (ie. match[]),
+// which the query-frontend logs joined by a comma. The values are series selectors, so only the
+// commas outside of quotes, braces, brackets and parentheses separate the values. If the value is
+// malformed, it's returned as is.
+func splitMultiValuedParam(value string) []string {
+	var (
+		values []string
+		start  int
+		depth  int
+		quote  rune
+	)
+
+	for i := 0; i < len(value); i++ {
+		c := rune(value[i])
+
+		if quote != 0 {
+			switch {
+			case c == '\\' && quote != '`':
+				i++ // Skip the escaped character.
+			case c == quote:
+				quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		case '{', '(', '[':
+			depth++
+		case '}', ')', ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				values = append(values, value[start:i])
+				start = i + 1
+			}
+		}
+
+		if depth < 0 {
+			return []string{value}
+		}
+	}
+
+	if quote != 0 || depth != 0 {
+		return []string{value}
+	}
+
+	return append(values, value[start:])
+}
@@ -0,0 +1,70 @@
+package queryreplay
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQueryLogs(t *testing.T) {
+	logs := `
+level=info ts=2021-07-20T10:00:02.5Z caller=handler.go:183 org_id=team-a msg="query stats" component=query-frontend method=GET path=/api/v1/query_range response_time=1.5s query_wall_time_seconds=1.2 param_query="sum(rate(up[5m]))" param_start=1626773400 param_end=1626775200 param_step=30
+level=info ts=2021-07-20T10:00:01Z caller=handler.go:159 org_id=team-b msg="slow query detected" method=POST host=cortex path=/prometheus/api/v1/query time_taken=12s param_query=up param_time=1626775201
+level=debug ts=2021-07-20T10:00:01.5Z caller=logging.go:66 traceID=abc msg="GET /api/v1/query (200) 10ms"
+{"caller":"handler.go:183","component":"query-frontend","level":"info","method":"GET","msg":"query stats","org_id":"team-c","param_match[]":"up{job=\"a,b\",instance=\"c\"},process_start_time_seconds","param_start":"1626773400","path":"/api/v1/series","response_time":"250ms","ts":"2021-07-20T10:00:03Z"}
+`
+
+	queries, err := ParseQueryLogs(strings.NewReader(logs))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Query{
+		{
+			Time:     time.Date(2021, 7, 20, 10, 0, 1, 0, time.UTC),
+			OrgID:    "team-b",
+			Method:   "POST",
+			Path:     "/prometheus/api/v1/query",
+			Params:   url.Values{"query": {"up"}, "time": {"1626775201"}},
+			Duration: 12 * time.Second,
+		}, {
+			Time:     time.Date(2021, 7, 20, 10, 0, 2, int(500*time.Millisecond), time.UTC),
+			OrgID:    "team-a",
+			Method:   "GET",
+			Path:     "/api/v1/query_range",
+			Params:   url.Values{"query": {"sum(rate(up[5m]))"}, "start": {"1626773400"}, "end": {"1626775200"}, "step": {"30"}},
+			Duration: 1500 * time.Millisecond,
+		}, {
+			Time:     time.Date(2021, 7, 20, 10, 0, 3, 0, time.UTC),
+			OrgID:    "team-c",
+			Method:   "GET",
+			Path:     "/api/v1/series",
+			Params:   url.Values{"match[]": {`up{job="a,b",instance="c"}`, "process_start_time_seconds"}, "start": {"1626773400"}},
+			Duration: 250 * time.Millisecond,
+		},
+	}, queries)
+}
+
+func TestParseQueryLogs_InvalidTimestamp(t *testing.T) {
+	_, err := ParseQueryLogs(strings.NewReader(`level=info ts=yesterday msg="query stats" path=/api/v1/query`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 1")
+}
+
+func TestSplitMultiValuedParam(t *testing.T) {
+	for value, expected := range map[string][]string{
+		`up`:                                 {`up`},
+		`up,process_start_time_seconds`:      {`up`, `process_start_time_seconds`},
+		`up{job="a",instance="b"},{job="c"}`: {`up{job="a",instance="b"}`, `{job="c"}`},
+		`{job=~"a,b"},{job="c\",d"}`:         {`{job=~"a,b"}`, `{job="c\",d"}`},
+		`{job='a,b'},{job=` + "`c,d`" + `}`:  {`{job='a,b'}`, `{job=` + "`c,d`" + `}`},
+		`{job="a",}`:                         {`{job="a",}`},
+		`{job="a"`:                           {`{job="a"`},
+		`{job="a},up`:                        {`{job="a},up`},
+		`up},{job="a"`:                       {`up},{job="a"`},
+	} {
+		assert.Equal(t, expected, splitMultiValuedParam(value), value)
+	}
+}
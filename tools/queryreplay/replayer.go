@@ -0,0 +1,228 @@
+package queryreplay
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/weaveworks/common/user"
+)
+
+// Query parameters holding a timestamp, which are shifted when replaying the query.
+var timeParams = []string{"start", "end", "time"}
+
+type Config struct {
+	TargetURL      string
+	Speed          float64
+	TimeShift      bool
+	MaxConcurrency int
+	Timeout        time.Duration
+	DefaultOrgID   string
+}
+
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.TargetURL, "target.url", "", "The base URL of the Cortex cluster where queries are replayed, ie. http://query-frontend:8080. The paths of the queries are appended as logged.")
+	f.Float64Var(&cfg.Speed, "replay.speed", 1, "The speed at which queries are replayed, compared to the original traffic. For example, 2 replays the queries twice as fast.")
+	f.BoolVar(&cfg.TimeShift, "replay.time-shift", true, "Shift the start, end and time parameters of each query by the time elapsed since the query was originally executed, so that the same relative time range is queried.")
+	f.IntVar(&cfg.MaxConcurrency, "replay.max-concurrency", 100, "The maximum number of queries in flight. Queries are delayed once the limit is reached.")
+	f.DurationVar(&cfg.Timeout, "replay.timeout", 2*time.Minute, "The timeout of each query.")
+	f.StringVar(&cfg.DefaultOrgID, "replay.default-org-id", "", "The tenant used to replay the queries whose tenant has not been logged.")
+}
+
+func (cfg *Config) Validate() error {
+	if cfg.TargetURL == "" {
+		return errors.New("the target URL is required")
+	}
+	if _, err := url.Parse(cfg.TargetURL); err != nil {
+		return errors.Wrap(err, "invalid target URL")
+	}
+	if cfg.Speed <= 0 {
+		return errors.New("the replay speed must be greater than 0")
+	}
+	if cfg.MaxConcurrency <= 0 {
+		return errors.New("the max concurrency must be greater than 0")
+	}
+	return nil
+}
+
+// Replayer replays queries against a target Cortex cluster, honoring their relative timing.
+type Replayer struct {
+	cfg    Config
+	target *url.URL
+	client *http.Client
+	logger log.Logger
+}
+
+func NewReplayer(cfg Config, logger log.Logger) (*Replayer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	target, err := url.Parse(cfg.TargetURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Replayer{
+		cfg:    cfg,
+		target: target,
+		client: &http.Client{Timeout: cfg.Timeout},
+		logger: logger,
+	}, nil
+}
+
+// Run replays the queries, which must be sorted by time, and returns the report once all
+// queries have completed or the context is canceled.
+func (r *Replayer) Run(ctx context.Context, queries []Query) *Report {
+	report := NewReport()
+	if len(queries) == 0 {
+		return report
+	}
+
+	var (
+		wg        sync.WaitGroup
+		inflight  = make(chan struct{}, r.cfg.MaxConcurrency)
+		replayAt  = time.Now()
+		firstTime = queries[0].Time
+	)
+
+	for _, q := range queries {
+		// Wait until the query is due, according to its time relative to the first query.
+		delay := time.Until(replayAt.Add(time.Duration(float64(q.Time.Sub(firstTime)) / r.cfg.Speed)))
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				wg.Wait()
+				return report
+			}
+		}
+
+		select {
+		case inflight <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return report
+		}
+
+		wg.Add(1)
+		go func(q Query) {
+			defer wg.Done()
+			defer func() { <-inflight }()
+
+			start := time.Now()
+			status, err := r.execute(ctx, q, start)
+			report.Add(q, time.Since(start), status, err)
+
+			if err != nil {
+				level.Warn(r.logger).Log("msg", "query failed", "path", q.Path, "org_id", q.OrgID, "query", q.Params.Get("query"), "err", err)
+			}
+		}(q)
+	}
+
+	wg.Wait()
+	return report
+}
+
+// execute runs the query and returns the response status code.
+func (r *Replayer) execute(ctx context.Context, q Query, now time.Time) (int, error) {
+	params := q.Params
+	if r.cfg.TimeShift {
+		params = shiftTimeParams(params, now.Sub(q.Time))
+	}
+
+	u := *r.target
+	u.Path = strings.TrimSuffix(u.Path, "/") + q.Path
+
+	var body io.Reader
+	if q.Method == http.MethodPost {
+		body = strings.NewReader(params.Encode())
+	} else {
+		u.RawQuery = params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, q.Method, u.String(), body)
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	orgID := q.OrgID
+	if orgID == "" {
+		orgID = r.cfg.DefaultOrgID
+	}
+	if orgID != "" {
+		req.Header.Set(user.OrgIDHeaderName, orgID)
+	}
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	// Read the whole response, so that the latency includes the transfer of the results.
+	if _, err := io.Copy(ioutil.Discard, res.Body); err != nil {
+		return res.StatusCode, err
+	}
+
+	if res.StatusCode/100 != 2 {
+		return res.StatusCode, fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// shiftTimeParams returns a copy of the params with the timestamps shifted by the given offset.
+// Timestamps which can't be parsed are left untouched.
+func shiftTimeParams(params url.Values, offset time.Duration) url.Values {
+	shifted := make(url.Values, len(params))
+	for k, v := range params {
+		shifted[k] = append([]string(nil), v...)
+	}
+
+	for _, name := range timeParams {
+		value := shifted.Get(name)
+		if value == "" {
+			continue
+		}
+
+		ts, err := parseTime(value)
+		if err != nil {
+			continue
+		}
+
+		shifted.Set(name, formatTime(ts.Add(offset)))
+	}
+
+	return shifted
+}
+
+// parseTime parses a timestamp in the formats supported by the Prometheus API.
+func parseTime(s string) (time.Time, error) {
+	if t, err := strconv.ParseFloat(s, 64); err == nil {
+		s, ns := math.Modf(t)
+		return time.Unix(int64(s), int64(math.Round(ns*1000))*int64(time.Millisecond)).UTC(), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q to a valid timestamp", s)
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano()/int64(time.Millisecond))/1000, 'f', -1, 64)
+}
@@ -0,0 +1,117 @@
+package queryreplay
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayer_Run(t *testing.T) {
+	type receivedRequest struct {
+		method string
+		path   string
+		orgID  string
+		params url.Values
+		at     time.Time
+	}
+
+	var (
+		mtx      sync.Mutex
+		received []receivedRequest
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+
+		mtx.Lock()
+		received = append(received, receivedRequest{method: r.Method, path: r.URL.Path, orgID: r.Header.Get("X-Scope-OrgID"), params: r.Form, at: time.Now()})
+		mtx.Unlock()
+
+		if r.Form.Get("query") == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	// Two queries logged 2 seconds apart one hour ago, replayed twice as fast.
+	origTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	queries := []Query{
+		{Time: origTime, OrgID: "team-a", Method: "GET", Path: "/api/v1/query", Params: url.Values{"query": {"up"}, "time": {formatTime(origTime)}}},
+		{Time: origTime.Add(2 * time.Second), Method: "POST", Path: "/api/v1/query_range", Params: url.Values{"query": {"fail"}, "start": {formatTime(origTime.Add(-time.Hour))}, "end": {formatTime(origTime.Add(2 * time.Second))}}},
+	}
+
+	replayer, err := NewReplayer(Config{
+		TargetURL:      server.URL + "/prometheus",
+		Speed:          2,
+		TimeShift:      true,
+		MaxConcurrency: 1,
+		Timeout:        time.Second,
+		DefaultOrgID:   "default",
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	report := replayer.Run(context.Background(), queries)
+
+	require.Len(t, received, 2)
+	assert.Equal(t, "GET", received[0].method)
+	assert.Equal(t, "/prometheus/api/v1/query", received[0].path)
+	assert.Equal(t, "team-a", received[0].orgID)
+	assert.Equal(t, "POST", received[1].method)
+	assert.Equal(t, "/prometheus/api/v1/query_range", received[1].path)
+	assert.Equal(t, "default", received[1].orgID)
+
+	// The relative timing is scaled by the speed.
+	assert.InDelta(t, float64(time.Second), float64(received[1].at.Sub(received[0].at)), float64(500*time.Millisecond))
+
+	// The timestamps are shifted by the time elapsed since the original query.
+	shiftedTime, err := parseTime(received[0].params.Get("time"))
+	require.NoError(t, err)
+	assert.WithinDuration(t, received[0].at, shiftedTime, time.Second)
+
+	shiftedStart, err := parseTime(received[1].params.Get("start"))
+	require.NoError(t, err)
+	shiftedEnd, err := parseTime(received[1].params.Get("end"))
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour+2*time.Second, shiftedEnd.Sub(shiftedStart))
+	assert.WithinDuration(t, received[1].at, shiftedEnd, time.Second)
+
+	summary := report.Summary()
+	require.Len(t, summary, 3)
+	assert.Equal(t, "/api/v1/query", summary[0].Path)
+	assert.Equal(t, 1, summary[0].Total)
+	assert.Equal(t, 0, summary[0].Errors)
+	assert.Equal(t, "/api/v1/query_range", summary[1].Path)
+	assert.Equal(t, 1, summary[1].Errors)
+	assert.Equal(t, map[int]int{500: 1}, summary[1].Statuses)
+	assert.Equal(t, "total", summary[2].Path)
+	assert.Equal(t, 2, summary[2].Total)
+	assert.Equal(t, 0.5, summary[2].ErrorRate)
+}
+
+func TestShiftTimeParams(t *testing.T) {
+	params := url.Values{
+		"query": {"up"},
+		"start": {"1626773400.5"},
+		"end":   {"2021-07-20T10:00:00Z"},
+		"time":  {"invalid"},
+	}
+
+	shifted := shiftTimeParams(params, time.Hour)
+	assert.Equal(t, url.Values{
+		"query": {"up"},
+		"start": {"1626777000.5"},
+		"end":   {"1626778800"},
+		"time":  {"invalid"},
+	}, shifted)
+
+	// The original params are not modified.
+	assert.Equal(t, "1626773400.5", params.Get("start"))
+}
@@ -0,0 +1,146 @@
+package queryreplay
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Report summarizes the outcome of the replayed queries.
+type Report struct {
+	mtx    sync.Mutex
+	routes map[string]*routeStats
+}
+
+type routeStats struct {
+	durations []time.Duration
+	errors    int
+	statuses  map[int]int
+}
+
+// RouteSummary is the summary of the queries replayed for a path.
+type RouteSummary struct {
+	Path      string
+	Total     int
+	Errors    int
+	ErrorRate float64
+	P50       time.Duration
+	P90       time.Duration
+	P99       time.Duration
+	Statuses  map[int]int
+}
+
+func NewReport() *Report {
+	return &Report{routes: map[string]*routeStats{}}
+}
+
+// Add records the outcome of a replayed query.
+func (r *Report) Add(q Query, duration time.Duration, status int, err error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	stats, ok := r.routes[q.Path]
+	if !ok {
+		stats = &routeStats{statuses: map[int]int{}}
+		r.routes[q.Path] = stats
+	}
+
+	stats.durations = append(stats.durations, duration)
+	if status > 0 {
+		stats.statuses[status]++
+	}
+	if err != nil {
+		stats.errors++
+	}
+}
+
+// Summary returns the summary of each path, sorted by path, followed by the summary of all queries.
+func (r *Report) Summary() []RouteSummary {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	paths := make([]string, 0, len(r.routes))
+	for path := range r.routes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	total := &routeStats{statuses: map[int]int{}}
+	summaries := make([]RouteSummary, 0, len(paths)+1)
+
+	for _, path := range paths {
+		stats := r.routes[path]
+		summaries = append(summaries, stats.summary(path))
+
+		total.durations = append(total.durations, stats.durations...)
+		total.errors += stats.errors
+		for status, count := range stats.statuses {
+			total.statuses[status] += count
+		}
+	}
+
+	return append(summaries, total.summary("total"))
+}
+
+// Print writes the summary as a table.
+func (r *Report) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "PATH\tQUERIES\tERRORS\tERROR RATE\tP50\tP90\tP99\tSTATUS CODES")
+	for _, s := range r.Summary() {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f%%\t%s\t%s\t%s\t%s\n", s.Path, s.Total, s.Errors, s.ErrorRate*100, s.P50, s.P90, s.P99, formatStatuses(s.Statuses))
+	}
+
+	return tw.Flush()
+}
+
+func (s *routeStats) summary(path string) RouteSummary {
+	durations := append([]time.Duration(nil), s.durations...)
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	summary := RouteSummary{
+		Path:     path,
+		Total:    len(durations),
+		Errors:   s.errors,
+		P50:      percentile(durations, 0.5),
+		P90:      percentile(durations, 0.9),
+		P99:      percentile(durations, 0.99),
+		Statuses: s.statuses,
+	}
+	if summary.Total > 0 {
+		summary.ErrorRate = float64(s.errors) / float64(summary.Total)
+	}
+	return summary
+}
+
+// percentile returns the nearest-rank percentile of the sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	idx := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+func formatStatuses(statuses map[int]int) string {
+	codes := make([]int, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	parts := make([]string, 0, len(codes))
+	for _, code := range codes {
+		parts = append(parts, fmt.Sprintf("%d=%d", code, statuses[code]))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,53 @@
+package queryreplay
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	report := NewReport()
+
+	query := Query{Path: "/api/v1/query"}
+	for i := 1; i <= 100; i++ {
+		report.Add(query, time.Duration(i)*time.Millisecond, 200, nil)
+	}
+	report.Add(Query{Path: "/api/v1/series"}, time.Second, 0, errors.New("connection refused"))
+
+	summary := report.Summary()
+	require.Len(t, summary, 3)
+
+	assert.Equal(t, RouteSummary{
+		Path:     "/api/v1/query",
+		Total:    100,
+		P50:      50 * time.Millisecond,
+		P90:      90 * time.Millisecond,
+		P99:      99 * time.Millisecond,
+		Statuses: map[int]int{200: 100},
+	}, summary[0])
+
+	assert.Equal(t, RouteSummary{
+		Path:      "/api/v1/series",
+		Total:     1,
+		Errors:    1,
+		ErrorRate: 1,
+		P50:       time.Second,
+		P90:       time.Second,
+		P99:       time.Second,
+		Statuses:  map[int]int{},
+	}, summary[1])
+
+	assert.Equal(t, "total", summary[2].Path)
+	assert.Equal(t, 101, summary[2].Total)
+	assert.Equal(t, 1, summary[2].Errors)
+	assert.Equal(t, 100*time.Millisecond, summary[2].P99)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.Print(&buf))
+	assert.Contains(t, buf.String(), "/api/v1/query   100      0       0.00%       50ms")
+}
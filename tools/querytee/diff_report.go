@@ -0,0 +1,146 @@
+package querytee
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+)
+
+// ResponsesDiffer is implemented by the comparators which are able to describe in detail
+// the differences between two responses.
+type ResponsesDiffer interface {
+	Diff(expected, actual []byte) (*ResponseDiff, error)
+}
+
+// ResponseDiff describes the differences between the expected and actual responses.
+type ResponseDiff struct {
+	// Series in the expected response missing from the actual one, and vice versa.
+	MissingSeries []string `json:"missing_series,omitempty"`
+	ExtraSeries   []string `json:"extra_series,omitempty"`
+
+	// The earliest sample differing between series found in both responses.
+	FirstDifferentSample *SampleDiff `json:"first_different_sample,omitempty"`
+
+	// Label names, label values or metadata in the expected response missing from
+	// the actual one, and vice versa.
+	MissingValues []string `json:"missing_values,omitempty"`
+	ExtraValues   []string `json:"extra_values,omitempty"`
+
+	// Set when the responses can't be compared in detail, eg. because of a different status.
+	Error string `json:"error,omitempty"`
+}
+
+// SampleDiff describes a sample differing between the expected and actual responses.
+// Expected or Actual is empty if the sample is missing from the response.
+type SampleDiff struct {
+	Series    string     `json:"series"`
+	Timestamp model.Time `json:"timestamp"`
+	Expected  string     `json:"expected,omitempty"`
+	Actual    string     `json:"actual,omitempty"`
+}
+
+// IsEmpty returns whether the responses match.
+func (d *ResponseDiff) IsEmpty() bool {
+	return d == nil || (len(d.MissingSeries) == 0 && len(d.ExtraSeries) == 0 && d.FirstDifferentSample == nil &&
+		len(d.MissingValues) == 0 && len(d.ExtraValues) == 0 && d.Error == "")
+}
+
+// String summarizes the differences.
+func (d *ResponseDiff) String() string {
+	if d.Error != "" {
+		return d.Error
+	}
+
+	var parts []string
+	if len(d.MissingSeries) > 0 {
+		parts = append(parts, fmt.Sprintf("%d series missing from actual response (first: %s)", len(d.MissingSeries), d.MissingSeries[0]))
+	}
+	if len(d.ExtraSeries) > 0 {
+		parts = append(parts, fmt.Sprintf("%d extra series in actual response (first: %s)", len(d.ExtraSeries), d.ExtraSeries[0]))
+	}
+	if d.FirstDifferentSample != nil {
+		parts = append(parts, fmt.Sprintf("first different sample for series %s at timestamp %v", d.FirstDifferentSample.Series, d.FirstDifferentSample.Timestamp))
+	}
+	if len(d.MissingValues) > 0 {
+		parts = append(parts, fmt.Sprintf("%d values missing from actual response (first: %s)", len(d.MissingValues), d.MissingValues[0]))
+	}
+	if len(d.ExtraValues) > 0 {
+		parts = append(parts, fmt.Sprintf("%d extra values in actual response (first: %s)", len(d.ExtraValues), d.ExtraValues[0]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// DiffReport is a single line of the diff reports file.
+type DiffReport struct {
+	Time            time.Time     `json:"time"`
+	Route           string        `json:"route"`
+	Method          string        `json:"method"`
+	Path            string        `json:"path"`
+	Query           string        `json:"query"`
+	ExpectedBackend string        `json:"expected_backend"`
+	ActualBackend   string        `json:"actual_backend"`
+	ExpectedStatus  int           `json:"expected_status"`
+	ActualStatus    int           `json:"actual_status"`
+	Error           string        `json:"error"`
+	Diff            *ResponseDiff `json:"diff,omitempty"`
+}
+
+// DiffReporter writes the diff reports to a file, as JSON lines.
+type DiffReporter struct {
+	sampleRate float64
+
+	mtx  sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+	rand *rand.Rand
+}
+
+// NewDiffReporter returns a DiffReporter appending reports to the file at the given path. Only
+// the given fraction of the reports is written.
+func NewDiffReporter(path string, sampleRate float64) (*DiffReporter, error) {
+	if sampleRate <= 0 || sampleRate > 1 {
+		return nil, fmt.Errorf("the diff reports sample rate must be greater than 0 and lower or equal to 1, got %v", sampleRate)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open the diff reports file")
+	}
+
+	return &DiffReporter{
+		sampleRate: sampleRate,
+		file:       file,
+		enc:        json.NewEncoder(file),
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// Report writes the report, unless it's not sampled. Returns whether the report has been written.
+func (r *DiffReporter) Report(report DiffReport) (bool, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.sampleRate < 1 && r.rand.Float64() >= r.sampleRate {
+		return false, nil
+	}
+
+	if err := r.enc.Encode(report); err != nil {
+		return false, errors.Wrap(err, "unable to write diff report")
+	}
+	return true, nil
+}
+
+// Close closes the diff reports file.
+func (r *DiffReporter) Close() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.file.Close()
+}
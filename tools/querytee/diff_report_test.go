@@ -0,0 +1,153 @@
+package querytee
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffReporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reports.jsonl")
+
+	reporter, err := NewDiffReporter(path, 1)
+	require.NoError(t, err)
+
+	for _, route := range []string{"route-1", "route-2"} {
+		written, err := reporter.Report(DiffReport{Route: route, Diff: &ResponseDiff{MissingSeries: []string{`{foo="bar"}`}}})
+		require.NoError(t, err)
+		assert.True(t, written)
+	}
+	require.NoError(t, reporter.Close())
+
+	// Reports are appended to the existing file.
+	reporter, err = NewDiffReporter(path, 1)
+	require.NoError(t, err)
+	_, err = reporter.Report(DiffReport{Route: "route-3"})
+	require.NoError(t, err)
+	require.NoError(t, reporter.Close())
+
+	reports := readDiffReports(t, path)
+	require.Len(t, reports, 3)
+	assert.Equal(t, "route-1", reports[0].Route)
+	assert.Equal(t, []string{`{foo="bar"}`}, reports[0].Diff.MissingSeries)
+	assert.Equal(t, "route-2", reports[1].Route)
+	assert.Equal(t, "route-3", reports[2].Route)
+	assert.Nil(t, reports[2].Diff)
+}
+
+func TestDiffReporter_ShouldSampleReports(t *testing.T) {
+	reporter, err := NewDiffReporter(filepath.Join(t.TempDir(), "reports.jsonl"), 0.5)
+	require.NoError(t, err)
+	defer reporter.Close() //nolint:errcheck
+
+	written := 0
+	for i := 0; i < 1000; i++ {
+		ok, err := reporter.Report(DiffReport{})
+		require.NoError(t, err)
+		if ok {
+			written++
+		}
+	}
+
+	assert.Greater(t, written, 0)
+	assert.Less(t, written, 1000)
+}
+
+func TestNewDiffReporter_ShouldValidateSampleRate(t *testing.T) {
+	for _, rate := range []float64{0, -1, 1.5} {
+		_, err := NewDiffReporter(filepath.Join(t.TempDir(), "reports.jsonl"), rate)
+		assert.Error(t, err)
+	}
+}
+
+func TestProxyEndpoint_reportDiff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reports.jsonl")
+	reporter, err := NewDiffReporter(path, 1)
+	require.NoError(t, err)
+
+	backendURL1, err := url.Parse("http://backend-1/")
+	require.NoError(t, err)
+	backendURL2, err := url.Parse("http://backend-2/")
+	require.NoError(t, err)
+	backendPref := NewProxyBackend("backend-1", backendURL1, time.Second, true)
+	backendOther := NewProxyBackend("backend-2", backendURL2, time.Second, false)
+
+	reg := prometheus.NewPedanticRegistry()
+	metrics := NewProxyMetrics(reg)
+	endpoint := NewProxyEndpoint([]*ProxyBackend{backendPref, backendOther}, "api_v1_series", metrics, log.NewNopLogger(), NewSeriesComparator(nil), reporter)
+
+	expected := &backendResponse{backend: backendPref, status: 200, body: []byte(`{"status":"success","data":[{"__name__":"up","job":"a"}]}`)}
+	actual := &backendResponse{backend: backendOther, status: 200, body: []byte(`{"status":"success","data":[{"__name__":"up","job":"b"}]}`)}
+	req := httptest.NewRequest("GET", "/api/v1/series?match[]=up", nil)
+
+	// Responses which can be compared include the detailed differences.
+	endpoint.reportDiff(req, expected, actual, errors.New("responses differ"))
+
+	// Responses which can't be compared only include the error.
+	actual = &backendResponse{backend: backendOther, status: 500}
+	endpoint.reportDiff(req, expected, actual, errors.New("status code differs"))
+
+	require.NoError(t, reporter.Close())
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.diffReportsTotal.WithLabelValues("api_v1_series")))
+
+	reports := readDiffReports(t, path)
+	require.Len(t, reports, 2)
+
+	assert.Equal(t, "api_v1_series", reports[0].Route)
+	assert.Equal(t, "GET", reports[0].Method)
+	assert.Equal(t, "/api/v1/series", reports[0].Path)
+	assert.Equal(t, "match[]=up", reports[0].Query)
+	assert.Equal(t, "backend-1", reports[0].ExpectedBackend)
+	assert.Equal(t, "backend-2", reports[0].ActualBackend)
+	assert.Equal(t, "responses differ", reports[0].Error)
+	assert.Equal(t, &ResponseDiff{
+		MissingSeries: []string{`up{job="a"}`},
+		ExtraSeries:   []string{`up{job="b"}`},
+	}, reports[0].Diff)
+
+	assert.Equal(t, 200, reports[1].ExpectedStatus)
+	assert.Equal(t, 500, reports[1].ActualStatus)
+	assert.Equal(t, "status code differs", reports[1].Error)
+	assert.Nil(t, reports[1].Diff)
+}
+
+func TestResponseDiff_String(t *testing.T) {
+	diff := &ResponseDiff{
+		MissingSeries:        []string{`{foo="a"}`, `{foo="b"}`},
+		FirstDifferentSample: &SampleDiff{Series: `{foo="c"}`, Timestamp: model.Time(1000), Expected: "1", Actual: "2"},
+	}
+	assert.False(t, diff.IsEmpty())
+	assert.Equal(t, `2 series missing from actual response (first: {foo="a"}), first different sample for series {foo="c"} at timestamp 1`, diff.String())
+
+	assert.True(t, (&ResponseDiff{}).IsEmpty())
+	assert.True(t, (*ResponseDiff)(nil).IsEmpty())
+}
+
+func readDiffReports(t *testing.T, path string) []DiffReport {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close() //nolint:errcheck
+
+	var reports []DiffReport
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var report DiffReport
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &report))
+		reports = append(reports, report)
+	}
+	require.NoError(t, scanner.Err())
+	return reports
+}
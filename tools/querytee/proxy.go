@@ -18,6 +18,8 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
 )
 
 var (
@@ -30,7 +32,12 @@ type ProxyConfig struct {
 	PreferredBackend               string
 	BackendReadTimeout             time.Duration
 	CompareResponses               bool
+	CompareMetadataResponses       bool
 	ValueComparisonTolerance       float64
+	CompareIgnoredLabels           flagext.StringSliceCSV
+	CompareSkipRecentSamples       time.Duration
+	DiffReportsFile                string
+	DiffReportsSampleRate          float64
 	PassThroughNonRegisteredRoutes bool
 }
 
@@ -40,7 +47,12 @@ func (cfg *ProxyConfig) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.PreferredBackend, "backend.preferred", "", "The hostname of the preferred backend when selecting the response to send back to the client. If no preferred backend is configured then the query-tee will send back to the client the first successful response received without waiting for other backends.")
 	f.DurationVar(&cfg.BackendReadTimeout, "backend.read-timeout", 90*time.Second, "The timeout when reading the response from a backend.")
 	f.BoolVar(&cfg.CompareResponses, "proxy.compare-responses", false, "Compare responses between preferred and secondary endpoints for supported routes.")
+	f.BoolVar(&cfg.CompareMetadataResponses, "proxy.compare-metadata-responses", false, "Also compare the responses of the series, labels, label values and metadata endpoints. Requires -proxy.compare-responses.")
 	f.Float64Var(&cfg.ValueComparisonTolerance, "proxy.value-comparison-tolerance", 0.000001, "The tolerance to apply when comparing floating point values in the responses. 0 to disable tolerance and require exact match (not recommended).")
+	f.Var(&cfg.CompareIgnoredLabels, "proxy.compare-ignored-labels", "Comma separated list of label names to ignore when comparing series in the responses.")
+	f.DurationVar(&cfg.CompareSkipRecentSamples, "proxy.compare-skip-recent-samples", 0, "Skip the samples more recent than this period when comparing the responses, since they may not have been ingested yet by all backends. 0 to disable.")
+	f.StringVar(&cfg.DiffReportsFile, "proxy.diff-reports-file", "", "Path of the file where a JSON report is appended for each comparison failure. Requires -proxy.compare-responses. Empty to disable.")
+	f.Float64Var(&cfg.DiffReportsSampleRate, "proxy.diff-reports-sample-rate", 1, "The fraction of comparison failures, between 0 (excluded) and 1, for which a diff report is written.")
 	f.BoolVar(&cfg.PassThroughNonRegisteredRoutes, "proxy.passthrough-non-registered-routes", false, "Passthrough requests for non-registered routes to preferred backend.")
 }
 
@@ -58,6 +70,9 @@ type Proxy struct {
 	metrics  *ProxyMetrics
 	routes   []Route
 
+	// Optional, used to write the diff reports.
+	reporter *DiffReporter
+
 	// The HTTP server used to run the proxy service.
 	srv         *http.Server
 	srvListener net.Listener
@@ -71,6 +86,10 @@ func NewProxy(cfg ProxyConfig, logger log.Logger, routes []Route, registerer pro
 		return nil, fmt.Errorf("when enabling comparison of results -backend.preferred flag must be set to hostname of preferred backend")
 	}
 
+	if cfg.DiffReportsFile != "" && !cfg.CompareResponses {
+		return nil, fmt.Errorf("when enabling diff reports -proxy.compare-responses flag must be set")
+	}
+
 	if cfg.PassThroughNonRegisteredRoutes && cfg.PreferredBackend == "" {
 		return nil, fmt.Errorf("when enabling passthrough for non-registered routes -backend.preferred flag must be set to hostname of backend where those requests needs to be passed")
 	}
@@ -140,6 +159,14 @@ func NewProxy(cfg ProxyConfig, logger log.Logger, routes []Route, registerer pro
 		level.Warn(p.logger).Log("msg", "The proxy is running with only 1 backend. At least 2 backends are required to fulfil the purpose of the proxy and compare results.")
 	}
 
+	if cfg.DiffReportsFile != "" {
+		reporter, err := NewDiffReporter(cfg.DiffReportsFile, cfg.DiffReportsSampleRate)
+		if err != nil {
+			return nil, err
+		}
+		p.reporter = reporter
+	}
+
 	return p, nil
 }
 
@@ -163,7 +190,7 @@ func (p *Proxy) Start() error {
 		if p.cfg.CompareResponses {
 			comparator = route.ResponseComparator
 		}
-		router.Path(route.Path).Methods(route.Methods...).Handler(NewProxyEndpoint(p.backends, route.RouteName, p.metrics, p.logger, comparator))
+		router.Path(route.Path).Methods(route.Methods...).Handler(NewProxyEndpoint(p.backends, route.RouteName, p.metrics, p.logger, comparator, p.reporter))
 	}
 
 	if p.cfg.PassThroughNonRegisteredRoutes {
@@ -201,7 +228,16 @@ func (p *Proxy) Stop() error {
 		return nil
 	}
 
-	return p.srv.Shutdown(context.Background())
+	err := p.srv.Shutdown(context.Background())
+
+	// Close the diff reports file once in-flight requests have completed.
+	if p.reporter != nil {
+		if closeErr := p.reporter.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
 }
 
 func (p *Proxy) Await() {
@@ -22,6 +22,7 @@ type ProxyEndpoint struct {
 	metrics    *ProxyMetrics
 	logger     log.Logger
 	comparator ResponsesComparator
+	reporter   *DiffReporter
 
 	// Whether for this endpoint there's a preferred backend configured.
 	hasPreferredBackend bool
@@ -30,7 +31,7 @@ type ProxyEndpoint struct {
 	routeName string
 }
 
-func NewProxyEndpoint(backends []*ProxyBackend, routeName string, metrics *ProxyMetrics, logger log.Logger, comparator ResponsesComparator) *ProxyEndpoint {
+func NewProxyEndpoint(backends []*ProxyBackend, routeName string, metrics *ProxyMetrics, logger log.Logger, comparator ResponsesComparator, reporter *DiffReporter) *ProxyEndpoint {
 	hasPreferredBackend := false
 	for _, backend := range backends {
 		if backend.preferred {
@@ -45,6 +46,7 @@ func NewProxyEndpoint(backends []*ProxyBackend, routeName string, metrics *Proxy
 		metrics:             metrics,
 		logger:              logger,
 		comparator:          comparator,
+		reporter:            reporter,
 		hasPreferredBackend: hasPreferredBackend,
 	}
 }
@@ -130,6 +132,10 @@ func (p *ProxyEndpoint) executeBackendRequests(r *http.Request, resCh chan *back
 			level.Error(util_log.Logger).Log("msg", "response comparison failed", "route-name", p.routeName,
 				"query", r.URL.RawQuery, "err", err)
 			result = comparisonFailed
+
+			if p.reporter != nil {
+				p.reportDiff(r, expectedResponse, actualResponse, err)
+			}
 		}
 
 		p.metrics.responsesComparedTotal.WithLabelValues(p.routeName, result).Inc()
@@ -188,6 +194,41 @@ func (p *ProxyEndpoint) compareResponses(expectedResponse, actualResponse *backe
 	return p.comparator.Compare(expectedResponse.body, actualResponse.body)
 }
 
+// reportDiff writes a diff report about the responses which failed the comparison. The detailed
+// differences are included only if the comparator supports them.
+func (p *ProxyEndpoint) reportDiff(r *http.Request, expectedResponse, actualResponse *backendResponse, compareErr error) {
+	report := DiffReport{
+		Time:            time.Now(),
+		Route:           p.routeName,
+		Method:          r.Method,
+		Path:            r.URL.Path,
+		Query:           r.URL.RawQuery,
+		ExpectedBackend: expectedResponse.backend.name,
+		ActualBackend:   actualResponse.backend.name,
+		ExpectedStatus:  expectedResponse.statusCode(),
+		ActualStatus:    actualResponse.statusCode(),
+		Error:           compareErr.Error(),
+	}
+
+	if differ, ok := p.comparator.(ResponsesDiffer); ok && expectedResponse.status == 200 && actualResponse.status == 200 {
+		diff, err := differ.Diff(expectedResponse.body, actualResponse.body)
+		if err != nil {
+			level.Warn(p.logger).Log("msg", "Unable to diff responses", "route-name", p.routeName, "query", r.URL.RawQuery, "err", err)
+		}
+		report.Diff = diff
+	}
+
+	written, err := p.reporter.Report(report)
+	if err != nil {
+		level.Error(p.logger).Log("msg", "Unable to write diff report", "route-name", p.routeName, "err", err)
+		return
+	}
+
+	if written {
+		p.metrics.diffReportsTotal.WithLabelValues(p.routeName).Inc()
+	}
+}
+
 type backendResponse struct {
 	backend *ProxyBackend
 	status  int
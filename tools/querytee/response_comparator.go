@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"sort"
+	"time"
 
 	"github.com/go-kit/kit/log/level"
 	"github.com/pkg/errors"
@@ -23,9 +25,22 @@ type SamplesResponse struct {
 	}
 }
 
-func NewSamplesComparator(tolerance float64) *SamplesComparator {
+// SampleComparisonOptions configures how samples are compared.
+type SampleComparisonOptions struct {
+	// The tolerance to apply when comparing floating point values.
+	Tolerance float64
+
+	// Labels removed from the series before comparing them.
+	IgnoredLabels []string
+
+	// Samples more recent than this are not compared, because they may not have been
+	// ingested by both backends yet. 0 to compare all samples.
+	SkipRecentSamples time.Duration
+}
+
+func NewSamplesComparator(opts SampleComparisonOptions) *SamplesComparator {
 	return &SamplesComparator{
-		tolerance: tolerance,
+		opts: opts,
 		sampleTypesComparator: map[string]SamplesComparatorFunc{
 			"matrix": compareMatrix,
 			"vector": compareVector,
@@ -35,7 +50,7 @@ func NewSamplesComparator(tolerance float64) *SamplesComparator {
 }
 
 type SamplesComparator struct {
-	tolerance             float64
+	opts                  SampleComparisonOptions
 	sampleTypesComparator map[string]SamplesComparatorFunc
 }
 
@@ -70,7 +85,205 @@ func (s *SamplesComparator) Compare(expectedResponse, actualResponse []byte) err
 		return fmt.Errorf("resultType %s not registered for comparison", expected.Data.ResultType)
 	}
 
-	return comparator(expected.Data.Result, actual.Data.Result, s.tolerance)
+	expectedResult, err := s.filterResult(expected.Data.ResultType, expected.Data.Result)
+	if err != nil {
+		return errors.Wrap(err, "unable to filter expected response")
+	}
+
+	actualResult, err := s.filterResult(actual.Data.ResultType, actual.Data.Result)
+	if err != nil {
+		return errors.Wrap(err, "unable to filter actual response")
+	}
+
+	return comparator(expectedResult, actualResult, s.opts.Tolerance)
+}
+
+// Diff implements ResponsesDiffer.
+func (s *SamplesComparator) Diff(expectedResponse, actualResponse []byte) (*ResponseDiff, error) {
+	var expected, actual SamplesResponse
+
+	if err := json.Unmarshal(expectedResponse, &expected); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal expected response")
+	}
+
+	if err := json.Unmarshal(actualResponse, &actual); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal actual response")
+	}
+
+	if expected.Status != actual.Status {
+		return &ResponseDiff{Error: fmt.Sprintf("expected status %s but got %s", expected.Status, actual.Status)}, nil
+	}
+
+	if expected.Data.ResultType != actual.Data.ResultType {
+		return &ResponseDiff{Error: fmt.Sprintf("expected resultType %s but got %s", expected.Data.ResultType, actual.Data.ResultType)}, nil
+	}
+
+	expectedSeries, err := s.parseSeries(expected.Data.ResultType, expected.Data.Result)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse expected response")
+	}
+
+	actualSeries, err := s.parseSeries(actual.Data.ResultType, actual.Data.Result)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse actual response")
+	}
+
+	diff := &ResponseDiff{}
+	for series, expectedSamples := range expectedSeries {
+		actualSamples, ok := actualSeries[series]
+		if !ok {
+			diff.MissingSeries = append(diff.MissingSeries, series)
+			continue
+		}
+
+		sampleDiff := diffSamples(series, expectedSamples, actualSamples, s.opts.Tolerance)
+		if sampleDiff != nil && (diff.FirstDifferentSample == nil || sampleDiff.Timestamp < diff.FirstDifferentSample.Timestamp) {
+			diff.FirstDifferentSample = sampleDiff
+		}
+	}
+
+	for series := range actualSeries {
+		if _, ok := expectedSeries[series]; !ok {
+			diff.ExtraSeries = append(diff.ExtraSeries, series)
+		}
+	}
+
+	sort.Strings(diff.MissingSeries)
+	sort.Strings(diff.ExtraSeries)
+	return diff, nil
+}
+
+// filterResult removes the ignored labels and the recent samples from the result, if configured.
+func (s *SamplesComparator) filterResult(resultType string, result json.RawMessage) (json.RawMessage, error) {
+	if len(s.opts.IgnoredLabels) == 0 && s.opts.SkipRecentSamples <= 0 {
+		return result, nil
+	}
+
+	switch resultType {
+	case "matrix":
+		var matrix model.Matrix
+		if err := json.Unmarshal(result, &matrix); err != nil {
+			return nil, err
+		}
+		return json.Marshal(s.filterMatrix(matrix))
+	case "vector":
+		var vector model.Vector
+		if err := json.Unmarshal(result, &vector); err != nil {
+			return nil, err
+		}
+		return json.Marshal(s.filterVector(vector))
+	default:
+		return result, nil
+	}
+}
+
+func (s *SamplesComparator) filterMatrix(matrix model.Matrix) model.Matrix {
+	minRecentTime := s.minRecentTime()
+	filtered := make(model.Matrix, 0, len(matrix))
+
+	for _, stream := range matrix {
+		values := stream.Values
+		for len(values) > 0 && minRecentTime != 0 && !values[len(values)-1].Timestamp.Before(minRecentTime) {
+			values = values[:len(values)-1]
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		filtered = append(filtered, &model.SampleStream{
+			Metric: s.removeIgnoredLabels(stream.Metric),
+			Values: values,
+		})
+	}
+
+	return filtered
+}
+
+func (s *SamplesComparator) filterVector(vector model.Vector) model.Vector {
+	minRecentTime := s.minRecentTime()
+	filtered := make(model.Vector, 0, len(vector))
+
+	for _, sample := range vector {
+		if minRecentTime != 0 && !sample.Timestamp.Before(minRecentTime) {
+			continue
+		}
+
+		filtered = append(filtered, &model.Sample{
+			Metric:    s.removeIgnoredLabels(sample.Metric),
+			Value:     sample.Value,
+			Timestamp: sample.Timestamp,
+		})
+	}
+
+	return filtered
+}
+
+// minRecentTime returns the timestamp since which samples are not compared, or zero if
+// all samples are compared.
+func (s *SamplesComparator) minRecentTime() model.Time {
+	if s.opts.SkipRecentSamples <= 0 {
+		return 0
+	}
+	return model.TimeFromUnixNano(time.Now().Add(-s.opts.SkipRecentSamples).UnixNano())
+}
+
+func (s *SamplesComparator) removeIgnoredLabels(metric model.Metric) model.Metric {
+	return removeLabels(metric, s.opts.IgnoredLabels)
+}
+
+// parseSeries returns the samples of each series in the result, by series labels.
+func (s *SamplesComparator) parseSeries(resultType string, result json.RawMessage) (map[string][]model.SamplePair, error) {
+	series := map[string][]model.SamplePair{}
+
+	switch resultType {
+	case "matrix":
+		var matrix model.Matrix
+		if err := json.Unmarshal(result, &matrix); err != nil {
+			return nil, err
+		}
+		for _, stream := range s.filterMatrix(matrix) {
+			series[stream.Metric.String()] = stream.Values
+		}
+	case "vector":
+		var vector model.Vector
+		if err := json.Unmarshal(result, &vector); err != nil {
+			return nil, err
+		}
+		for _, sample := range s.filterVector(vector) {
+			series[sample.Metric.String()] = []model.SamplePair{{Timestamp: sample.Timestamp, Value: sample.Value}}
+		}
+	case "scalar":
+		var scalar model.Scalar
+		if err := json.Unmarshal(result, &scalar); err != nil {
+			return nil, err
+		}
+		series["scalar"] = []model.SamplePair{{Timestamp: scalar.Timestamp, Value: scalar.Value}}
+	default:
+		return nil, fmt.Errorf("resultType %s not supported for diff", resultType)
+	}
+
+	return series, nil
+}
+
+// diffSamples returns the first sample differing between the expected and actual samples of the
+// series, or nil if they match.
+func diffSamples(series string, expected, actual []model.SamplePair, tolerance float64) *SampleDiff {
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		switch {
+		case i >= len(actual):
+			return &SampleDiff{Series: series, Timestamp: expected[i].Timestamp, Expected: expected[i].Value.String()}
+		case i >= len(expected):
+			return &SampleDiff{Series: series, Timestamp: actual[i].Timestamp, Actual: actual[i].Value.String()}
+		case expected[i].Timestamp < actual[i].Timestamp:
+			return &SampleDiff{Series: series, Timestamp: expected[i].Timestamp, Expected: expected[i].Value.String()}
+		case expected[i].Timestamp > actual[i].Timestamp:
+			return &SampleDiff{Series: series, Timestamp: actual[i].Timestamp, Actual: actual[i].Value.String()}
+		case !compareSampleValue(expected[i].Value, actual[i].Value, tolerance):
+			return &SampleDiff{Series: series, Timestamp: expected[i].Timestamp, Expected: expected[i].Value.String(), Actual: actual[i].Value.String()}
+		}
+	}
+
+	return nil
 }
 
 func compareMatrix(expectedRaw, actualRaw json.RawMessage, tolerance float64) error {
@@ -217,3 +430,195 @@ func compareSampleValue(first, second model.SampleValue, tolerance float64) bool
 
 	return math.Abs(f-s) <= tolerance
 }
+
+// removeLabels returns a copy of the metric without the given labels.
+func removeLabels(metric model.Metric, labels []string) model.Metric {
+	if len(labels) == 0 {
+		return metric
+	}
+
+	result := metric.Clone()
+	for _, name := range labels {
+		delete(result, model.LabelName(name))
+	}
+	return result
+}
+
+// SeriesResponse is the response of the /api/v1/series endpoint.
+type SeriesResponse struct {
+	Status string
+	Data   []model.Metric
+}
+
+// SeriesComparator compares the label sets returned by the /api/v1/series endpoint.
+type SeriesComparator struct {
+	ignoredLabels []string
+}
+
+func NewSeriesComparator(ignoredLabels []string) *SeriesComparator {
+	return &SeriesComparator{ignoredLabels: ignoredLabels}
+}
+
+// Compare implements ResponsesComparator.
+func (s *SeriesComparator) Compare(expected, actual []byte) error {
+	return compareWithDiff(s, expected, actual)
+}
+
+// Diff implements ResponsesDiffer.
+func (s *SeriesComparator) Diff(expectedResponse, actualResponse []byte) (*ResponseDiff, error) {
+	var expected, actual SeriesResponse
+
+	if err := json.Unmarshal(expectedResponse, &expected); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal expected response")
+	}
+
+	if err := json.Unmarshal(actualResponse, &actual); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal actual response")
+	}
+
+	if expected.Status != actual.Status {
+		return &ResponseDiff{Error: fmt.Sprintf("expected status %s but got %s", expected.Status, actual.Status)}, nil
+	}
+
+	toSet := func(series []model.Metric) map[string]struct{} {
+		set := make(map[string]struct{}, len(series))
+		for _, metric := range series {
+			set[removeLabels(metric, s.ignoredLabels).String()] = struct{}{}
+		}
+		return set
+	}
+
+	missing, extra := diffSets(toSet(expected.Data), toSet(actual.Data))
+	return &ResponseDiff{MissingSeries: missing, ExtraSeries: extra}, nil
+}
+
+// LabelsResponse is the response of the /api/v1/labels and /api/v1/label/{name}/values endpoints.
+type LabelsResponse struct {
+	Status string
+	Data   []string
+}
+
+// LabelsComparator compares the label names or values returned by the /api/v1/labels
+// and /api/v1/label/{name}/values endpoints.
+type LabelsComparator struct{}
+
+func NewLabelsComparator() *LabelsComparator {
+	return &LabelsComparator{}
+}
+
+// Compare implements ResponsesComparator.
+func (c *LabelsComparator) Compare(expected, actual []byte) error {
+	return compareWithDiff(c, expected, actual)
+}
+
+// Diff implements ResponsesDiffer.
+func (c *LabelsComparator) Diff(expectedResponse, actualResponse []byte) (*ResponseDiff, error) {
+	var expected, actual LabelsResponse
+
+	if err := json.Unmarshal(expectedResponse, &expected); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal expected response")
+	}
+
+	if err := json.Unmarshal(actualResponse, &actual); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal actual response")
+	}
+
+	if expected.Status != actual.Status {
+		return &ResponseDiff{Error: fmt.Sprintf("expected status %s but got %s", expected.Status, actual.Status)}, nil
+	}
+
+	toSet := func(values []string) map[string]struct{} {
+		set := make(map[string]struct{}, len(values))
+		for _, v := range values {
+			set[v] = struct{}{}
+		}
+		return set
+	}
+
+	missing, extra := diffSets(toSet(expected.Data), toSet(actual.Data))
+	return &ResponseDiff{MissingValues: missing, ExtraValues: extra}, nil
+}
+
+// MetadataResponse is the response of the /api/v1/metadata endpoint.
+type MetadataResponse struct {
+	Status string
+	Data   map[string][]struct {
+		Type string
+		Help string
+		Unit string
+	}
+}
+
+// MetadataComparator compares the metrics metadata returned by the /api/v1/metadata endpoint.
+type MetadataComparator struct{}
+
+func NewMetadataComparator() *MetadataComparator {
+	return &MetadataComparator{}
+}
+
+// Compare implements ResponsesComparator.
+func (c *MetadataComparator) Compare(expected, actual []byte) error {
+	return compareWithDiff(c, expected, actual)
+}
+
+// Diff implements ResponsesDiffer.
+func (c *MetadataComparator) Diff(expectedResponse, actualResponse []byte) (*ResponseDiff, error) {
+	var expected, actual MetadataResponse
+
+	if err := json.Unmarshal(expectedResponse, &expected); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal expected response")
+	}
+
+	if err := json.Unmarshal(actualResponse, &actual); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal actual response")
+	}
+
+	if expected.Status != actual.Status {
+		return &ResponseDiff{Error: fmt.Sprintf("expected status %s but got %s", expected.Status, actual.Status)}, nil
+	}
+
+	toSet := func(resp MetadataResponse) map[string]struct{} {
+		set := map[string]struct{}{}
+		for metric, entries := range resp.Data {
+			for _, m := range entries {
+				set[fmt.Sprintf("%s type=%s unit=%q help=%q", metric, m.Type, m.Unit, m.Help)] = struct{}{}
+			}
+		}
+		return set
+	}
+
+	missing, extra := diffSets(toSet(expected), toSet(actual))
+	return &ResponseDiff{MissingValues: missing, ExtraValues: extra}, nil
+}
+
+// compareWithDiff compares the responses using the differ, returning an error summarizing
+// the differences, if any.
+func compareWithDiff(differ ResponsesDiffer, expected, actual []byte) error {
+	diff, err := differ.Diff(expected, actual)
+	if err != nil {
+		return err
+	}
+	if !diff.IsEmpty() {
+		return errors.New(diff.String())
+	}
+	return nil
+}
+
+// diffSets returns the sorted entries of expected missing from actual, and the sorted entries
+// of actual not in expected.
+func diffSets(expected, actual map[string]struct{}) (missing, extra []string) {
+	for v := range expected {
+		if _, ok := actual[v]; !ok {
+			missing = append(missing, v)
+		}
+	}
+	for v := range actual {
+		if _, ok := expected[v]; !ok {
+			extra = append(extra, v)
+		}
+	}
+
+	sort.Strings(missing)
+	sort.Strings(extra)
+	return missing, extra
+}
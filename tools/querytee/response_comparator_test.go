@@ -3,8 +3,12 @@ package querytee
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -327,7 +331,7 @@ func TestCompareSamplesResponse(t *testing.T) {
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			samplesComparator := NewSamplesComparator(tc.tolerance)
+			samplesComparator := NewSamplesComparator(SampleComparisonOptions{Tolerance: tc.tolerance})
 			err := samplesComparator.Compare(tc.expected, tc.actual)
 			if tc.err == nil {
 				require.NoError(t, err)
@@ -338,3 +342,164 @@ func TestCompareSamplesResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestSamplesComparator_Diff(t *testing.T) {
+	now := model.Now()
+	recent := now.Add(-time.Minute)
+	old := now.Add(-time.Hour)
+
+	for _, tc := range []struct {
+		name     string
+		opts     SampleComparisonOptions
+		expected json.RawMessage
+		actual   json.RawMessage
+		diff     *ResponseDiff
+	}{
+		{
+			name: "no differences",
+			expected: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"1"],[2,"2"]]}]}
+						}`),
+			actual: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"1"],[2,"2"]]}]}
+						}`),
+			diff: &ResponseDiff{},
+		},
+		{
+			name: "missing and extra series, and the earliest differing sample",
+			expected: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"matrix","result":[
+								{"metric":{"foo":"a"},"values":[[1,"1"]]},
+								{"metric":{"foo":"b"},"values":[[1,"1"],[2,"2"],[3,"3"]]},
+								{"metric":{"foo":"c"},"values":[[1,"1"],[2,"2"]]}
+							]}
+						}`),
+			actual: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"matrix","result":[
+								{"metric":{"foo":"b"},"values":[[1,"1"],[2,"2"],[3,"4"]]},
+								{"metric":{"foo":"c"},"values":[[1,"1"]]},
+								{"metric":{"foo":"d"},"values":[[1,"1"]]}
+							]}
+						}`),
+			diff: &ResponseDiff{
+				MissingSeries:        []string{`{foo="a"}`},
+				ExtraSeries:          []string{`{foo="d"}`},
+				FirstDifferentSample: &SampleDiff{Series: `{foo="c"}`, Timestamp: 2000, Expected: "2"},
+			},
+		},
+		{
+			name: "different vector sample value",
+			expected: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"vector","result":[{"metric":{"foo":"bar"},"value":[1,"1"]}]}
+						}`),
+			actual: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"vector","result":[{"metric":{"foo":"bar"},"value":[1,"2"]}]}
+						}`),
+			diff: &ResponseDiff{
+				FirstDifferentSample: &SampleDiff{Series: `{foo="bar"}`, Timestamp: 1000, Expected: "1", Actual: "2"},
+			},
+		},
+		{
+			name: "different result type",
+			expected: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"vector","result":[]}
+						}`),
+			actual: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"matrix","result":[]}
+						}`),
+			diff: &ResponseDiff{Error: "expected resultType vector but got matrix"},
+		},
+		{
+			name: "ignored labels",
+			opts: SampleComparisonOptions{IgnoredLabels: []string{"replica"}},
+			expected: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"vector","result":[{"metric":{"foo":"bar","replica":"1"},"value":[1,"1"]}]}
+						}`),
+			actual: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"vector","result":[{"metric":{"foo":"bar","replica":"2"},"value":[1,"1"]}]}
+						}`),
+			diff: &ResponseDiff{},
+		},
+		{
+			name: "skipped recent samples",
+			opts: SampleComparisonOptions{SkipRecentSamples: 5 * time.Minute},
+			expected: json.RawMessage(fmt.Sprintf(`{
+							"status": "success",
+							"data": {"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[%s,"1"],[%s,"2"]]}]}
+						}`, old, recent)),
+			actual: json.RawMessage(fmt.Sprintf(`{
+							"status": "success",
+							"data": {"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[%s,"1"]]}]}
+						}`, old)),
+			diff: &ResponseDiff{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			samplesComparator := NewSamplesComparator(tc.opts)
+
+			diff, err := samplesComparator.Diff(tc.expected, tc.actual)
+			require.NoError(t, err)
+			assert.Equal(t, tc.diff, diff)
+
+			// The comparison must be consistent with the diff.
+			err = samplesComparator.Compare(tc.expected, tc.actual)
+			assert.Equal(t, tc.diff.IsEmpty(), err == nil)
+		})
+	}
+}
+
+func TestSeriesComparator(t *testing.T) {
+	expected := []byte(`{"status":"success","data":[{"__name__":"up","job":"a","replica":"1"},{"__name__":"up","job":"b"}]}`)
+	actual := []byte(`{"status":"success","data":[{"__name__":"up","job":"a","replica":"2"},{"__name__":"up","job":"c"}]}`)
+
+	diff, err := NewSeriesComparator([]string{"replica"}).Diff(expected, actual)
+	require.NoError(t, err)
+	assert.Equal(t, &ResponseDiff{MissingSeries: []string{`up{job="b"}`}, ExtraSeries: []string{`up{job="c"}`}}, diff)
+
+	err = NewSeriesComparator([]string{"replica"}).Compare(expected, expected)
+	assert.NoError(t, err)
+
+	err = NewSeriesComparator(nil).Compare(expected, actual)
+	require.Error(t, err)
+	assert.Equal(t, `2 series missing from actual response (first: up{job="a", replica="1"}), 2 extra series in actual response (first: up{job="a", replica="2"})`, err.Error())
+}
+
+func TestLabelsComparator(t *testing.T) {
+	expected := []byte(`{"status":"success","data":["a","b","c"]}`)
+	actual := []byte(`{"status":"success","data":["c","b","d"]}`)
+
+	diff, err := NewLabelsComparator().Diff(expected, actual)
+	require.NoError(t, err)
+	assert.Equal(t, &ResponseDiff{MissingValues: []string{"a"}, ExtraValues: []string{"d"}}, diff)
+
+	// The order of the values doesn't matter.
+	assert.NoError(t, NewLabelsComparator().Compare(expected, []byte(`{"status":"success","data":["c","a","b"]}`)))
+
+	diff, err = NewLabelsComparator().Diff(expected, []byte(`{"status":"error"}`))
+	require.NoError(t, err)
+	assert.Equal(t, &ResponseDiff{Error: "expected status success but got error"}, diff)
+}
+
+func TestMetadataComparator(t *testing.T) {
+	expected := []byte(`{"status":"success","data":{"up":[{"type":"gauge","help":"Up.","unit":""}],"requests_total":[{"type":"counter","help":"Requests.","unit":""}]}}`)
+	actual := []byte(`{"status":"success","data":{"up":[{"type":"gauge","help":"Up.","unit":""}],"requests_total":[{"type":"counter","help":"Total requests.","unit":""}]}}`)
+
+	diff, err := NewMetadataComparator().Diff(expected, actual)
+	require.NoError(t, err)
+	assert.Equal(t, &ResponseDiff{
+		MissingValues: []string{`requests_total type=counter unit="" help="Requests."`},
+		ExtraValues:   []string{`requests_total type=counter unit="" help="Total requests."`},
+	}, diff)
+
+	assert.NoError(t, NewMetadataComparator().Compare(expected, expected))
+}